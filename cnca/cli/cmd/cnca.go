@@ -4,16 +4,13 @@
 package cnca
 
 import (
-	"crypto/tls"
-	"crypto/x509"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"time"
 
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
 	"github.com/spf13/cobra"
-	"golang.org/x/net/http2"
 )
 
 type HTTPProtocol int
@@ -100,22 +97,17 @@ func Execute() error {
 }
 
 func InitHTTP2Client(clientCertData string) error {
-	CACert, err := ioutil.ReadFile(clientCertData)
+	// The CA certificate is reloaded if it changes while the client is used
+	rootCAs := tlsmgr.New(tlsmgr.Config{CAPaths: []string{clientCertData}})
+	err := rootCAs.Reload()
 	if err != nil {
 		return err
 	}
 
-	CACertPool := x509.NewCertPool()
-	CACertPool.AppendCertsFromPEM(CACert)
-
 	if UseHTTPProtocol == HTTP2 {
 		client = http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http2.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs: CACertPool,
-				},
-			},
+			Timeout:   10 * time.Second,
+			Transport: rootCAs.HTTP2Transport(),
 		}
 	} else {
 		err = errors.New("Incorrect HTTP Protocol Configured")
@@ -10,6 +10,9 @@ require (
 	github.com/onsi/ginkgo v1.10.3
 	github.com/onsi/gomega v1.7.1
 	github.com/open-ness/common/log v0.0.0-20191220144925-273a86a3f0d0
+	github.com/prometheus/client_golang v1.0.0
+	github.com/prometheus/client_model v0.0.0-20190129233127-fd36f4220a90
+	github.com/prometheus/common v0.4.1
 	github.com/spf13/cobra v1.0.0
 	golang.org/x/net v0.0.0-20190522155817-f3200d17e092
 	gopkg.in/yaml.v2 v2.2.4
@@ -5,21 +5,34 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
 	"syscall"
 
 	logger "github.com/open-ness/common/log"
 	"github.com/open-ness/epcforedge/ngc/pkg/af"
+	config "github.com/open-ness/epcforedge/ngc/pkg/config"
+	"github.com/open-ness/epcforedge/ngc/pkg/oauth2"
 )
 
 var log = logger.DefaultLogger.WithField("main", nil)
 
-const cfgPath = "configs/af.json"
+const (
+	cfgPath       = "configs/af.json"
+	oauth2CfgPath = "configs/oauth2.json"
+)
 
 func main() {
 
-	lvl, err := logger.ParseLevel("info")
+	flags := config.RegisterFlags(flag.CommandLine, "AF", cfgPath)
+	oauth2Cfg := flag.String("oauth2-config",
+		config.EnvDefault("OAUTH2_CONFIG", oauth2CfgPath),
+		"Path of the OAuth2 configuration file, JSON or YAML")
+	flag.Parse()
+	oauth2.SetConfigPath(*oauth2Cfg)
+
+	lvl, err := logger.ParseLevel(flags.LogLevel)
 	if err != nil {
 		log.Errf("Failed to parse log level: %s", err.Error())
 		os.Exit(1)
@@ -35,7 +48,8 @@ func main() {
 	}()
 
 	log.Infof("Starting NGC AF servers..")
-	if err = af.Run(parenCtx, cfgPath); err != nil {
+	if err = af.Run(parenCtx, flags.ConfigPath); err != nil {
 		log.Errf("AF finished with error: %v", err)
+		os.Exit(1)
 	}
 }
@@ -5,28 +5,42 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
 	"syscall"
 
 	logtool "github.com/open-ness/common/log"
+	config "github.com/open-ness/epcforedge/ngc/pkg/config"
 	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+	"github.com/open-ness/epcforedge/ngc/pkg/oauth2"
 )
 
 // Log handler initialized. This is to be used for NEF Main
 var log = logtool.DefaultLogger.WithField("NEF-MAIN", nil)
 
-// Path for NEF Configuration file
+// Default path for NEF Configuration file
 const cfgPath string = "configs/nef.json"
 
+// Default path for OAuth2 Configuration file
+const oauth2CfgPath string = "configs/oauth2.json"
+
 // main: Entry point for NEF Module Execution
 // Input Args: None
 // Output Args: None
 func main() {
 
-	/* Reading Log Level and and set it to logger, As of now it is hardcoded to
-	 * info */
-	lvl, err := logtool.ParseLevel("info")
+	/* Reading the configuration paths and the log level from the command
+	 * line or the environment */
+	flags := config.RegisterFlags(flag.CommandLine, "NEF", cfgPath)
+	oauth2Cfg := flag.String("oauth2-config",
+		config.EnvDefault("OAUTH2_CONFIG", oauth2CfgPath),
+		"Path of the OAuth2 configuration file, JSON or YAML")
+	flag.Parse()
+	oauth2.SetConfigPath(*oauth2Cfg)
+
+	/* Reading Log Level and and set it to logger */
+	lvl, err := logtool.ParseLevel(flags.LogLevel)
 	if err != nil {
 		log.Errf("Failed to parse log level: %s", err.Error())
 		os.Exit(1)
@@ -52,6 +66,9 @@ func main() {
 	}()
 
 	log.Infof("Starting NEF server ...")
-	_ = ngcnef.Run(ctx, cfgPath)
+	if err = ngcnef.Run(ctx, flags.ConfigPath); err != nil {
+		log.Errf("NEF finished with error: %v", err)
+		os.Exit(1)
+	}
 
 }
@@ -4,6 +4,8 @@
 package main
 
 import (
+	"crypto/tls"
+	"flag"
 	"net/http"
 	"os"
 	"time"
@@ -12,20 +14,23 @@ import (
 	logger "github.com/open-ness/common/log"
 	config "github.com/open-ness/epcforedge/ngc/pkg/config"
 	oam "github.com/open-ness/epcforedge/ngc/pkg/oam"
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
 	"golang.org/x/net/http2"
 )
 
 const (
 	// HTTP2Enabled is a flag for Enablling/DIsabling HTTP2
 	HTTP2Enabled = true
+	// Default path for OAM Configuration file
+	cfgPath = "./configs/oam.json"
 )
 
 type oamCfg struct {
 	TLSEndpoint    string `json:"TlsEndpoint"`
-	OpenEndpoint   string `json:"OpenEndpoint"`
+	OpenEndpoint   string `json:"OpenEndpoint" config:"required"`
 	UIEndpoint     string `json:"UIEndpoint"`
-	NgcEndpoint    string `json:"NgcEndpoint"`
-	NgcType        string `json:"NgcType"`
+	NgcEndpoint    string `json:"NgcEndpoint" config:"required"`
+	NgcType        string `json:"NgcType" config:"required"`
 	NgcTestData    string `json:"NgcTestData"`
 	ServerCertPath string `json:"ServerCertPath"`
 	ServerKeyPath  string `json:"ServerKeyPath"`
@@ -35,7 +40,10 @@ var log = logger.DefaultLogger.WithField("oam-main", nil)
 
 func main() {
 
-	lvl, err := logger.ParseLevel("info")
+	flags := config.RegisterFlags(flag.CommandLine, "OAM", cfgPath)
+	flag.Parse()
+
+	lvl, err := logger.ParseLevel(flags.LogLevel)
 	if err != nil {
 		log.Errf("Failed to parse log level: %s", err.Error())
 		os.Exit(1)
@@ -43,7 +51,7 @@ func main() {
 	logger.SetLevel(lvl)
 
 	var cfg oamCfg
-	err = config.LoadJSONConfig("./configs/oam.json", &cfg)
+	err = config.Load(flags.ConfigPath, "OAM", &cfg)
 	if err != nil {
 		log.Errf("Failed to load config: %s", err.Error())
 		os.Exit(1)
@@ -80,14 +88,25 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 	if HTTP2Enabled == true {
+		// The server certificate is reloaded when it changes
+		tlsMgr := tlsmgr.New(tlsmgr.Config{CertPath: cfg.ServerCertPath,
+			KeyPath: cfg.ServerKeyPath})
+		if err = tlsMgr.Reload(); err != nil {
+			log.Errf("OAM failed at loading the server certificate: %s",
+				err.Error())
+			os.Exit(1)
+		}
+		oam.SetServerTLS(tlsMgr)
+		serverOAM.TLSConfig = tlsMgr.ServerConfig(tls.NoClientCert)
+
 		if err = http2.ConfigureServer(serverOAM, &http2.Server{}); err != nil {
 			log.Errf("OAM failed at configuring HTTP2 server ")
 			os.Exit(1)
 		}
 
 		log.Infof("OAM HTTP2 Server Listening on:  %s\n", cfg.OpenEndpoint)
-		if err = serverOAM.ListenAndServeTLS(cfg.ServerCertPath,
-			cfg.ServerKeyPath); err != http.ErrServerClosed {
+		if err = serverOAM.ListenAndServeTLS("", ""); err !=
+			http.ErrServerClosed {
 			log.Errf("HTTP2: OAM CNCA server error: " + err.Error())
 			os.Exit(1)
 		}
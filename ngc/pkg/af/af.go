@@ -6,6 +6,7 @@ package af
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/handlers"
@@ -31,11 +32,18 @@ type NotifSubscryptions map[string]map[string]TrafficInfluSub
 // Store the  Access token
 var nefAccessToken string
 
+// nefAccessTokenAfID is the AF ID the NEF access token is requested for
+var nefAccessTokenAfID string
+
+// nefTokenMu protects nefAccessToken and nefAccessTokenAfID, which are
+// updated by the requests to the NEF and the reload of the configuration
+var nefTokenMu sync.Mutex
+
 // ServerConfig struct
 type ServerConfig struct {
-	CNCAEndpoint   string `json:"CNCAEndpoint"`
+	CNCAEndpoint   string `json:"CNCAEndpoint" config:"required"`
 	Hostname       string `json:"Hostname"`
-	NotifPort      string `json:"NotifPort"`
+	NotifPort      string `json:"NotifPort" config:"required"`
 	UIEndpoint     string `json:"UIEndpoint"`
 	ServerCertPath string `json:"ServerCertPath"`
 	ServerKeyPath  string `json:"ServerKeyPath"`
@@ -43,11 +51,16 @@ type ServerConfig struct {
 
 //Config struct
 type Config struct {
-	AfID              string       `json:"AfId"`
+	AfID              string       `json:"AfId" config:"required"`
 	AfAPIRoot         string       `json:"AfAPIRoot"`
 	LocationPrefixPfd string       `json:"LocationPrefixPfd"`
 	SrvCfg            ServerConfig `json:"ServerConfig"`
 	CliCfg            CliConfig    `json:"CliConfig"`
+	// Log level, overriding the --log-level of the AF if set
+	LogLevel string `json:"LogLevel"`
+	// Interval in seconds between two checks of the configuration file for
+	// a change, 10 if not set. The file is not watched if negative
+	ConfigWatchInterval int `json:"ConfigWatchInterval"`
 }
 
 //Context struct
@@ -55,6 +68,10 @@ type Context struct {
 	subscriptions NotifSubscryptions
 	transactions  TransactionIDs
 	cfg           Config
+	// cfgMu protects the reloadable fields of cfg and nefClient
+	cfgMu sync.RWMutex
+	// Client to the NEF, replaced when NEFCliCertPath is reloaded
+	nefClient *http.Client
 }
 
 var (
@@ -82,12 +99,32 @@ func runServer(ctx context.Context, AfCtx *Context) error {
 	AfCtx.subscriptions = make(NotifSubscryptions)
 	AfRouter = NewAFRouter(AfCtx)
 	NotifRouter = NewNotifRouter(AfCtx)
+	AfCtx.setNEFClient(AfCtx.cliConfig())
+
+	if AfCtx.cliConfig().OAuth2Support {
+		log.Infoln("Fetching NEF access token")
+		setNEFAccessTokenAfID(AfCtx.cfg.AfID)
+		if err = fetchNEFAuthorizationToken(); err != nil {
+			log.Infoln("Failed to get access token")
+			return err
+		}
+	} else {
+		log.Infoln("OAuth2 DISABLED")
+	}
+
+	// The server certificate is reloaded when it changes
+	tlsCfg, err := serverTLSConfig(AfCtx)
+	if err != nil {
+		log.Errf("AF failed at loading the server certificate: %v", err)
+		return err
+	}
 
 	serverCNCA := &http.Server{
 		Addr:         AfCtx.cfg.SrvCfg.CNCAEndpoint,
 		Handler:      handlers.CORS(headersOK, originsOK, methodsOK)(AfRouter),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		TLSConfig:    tlsCfg,
 	}
 
 	if HTTP2Enabled == true {
@@ -102,6 +139,7 @@ func runServer(ctx context.Context, AfCtx *Context) error {
 		Handler:      NotifRouter,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		TLSConfig:    tlsCfg.Clone(),
 	}
 
 	if err = http2.ConfigureServer(serverNotif, &http2.Server{}); err != nil {
@@ -109,16 +147,6 @@ func runServer(ctx context.Context, AfCtx *Context) error {
 		return err
 	}
 
-	if AfCtx.cfg.CliCfg.OAuth2Support {
-		log.Infoln("Fetching NEF access token")
-		if fetchNEFAuthorizationToken() != nil {
-			log.Infoln("Failed to get access token")
-			return err
-		}
-	} else {
-		log.Infoln("OAuth2 DISABLED")
-	}
-
 	stopServerCh := make(chan bool, 2)
 	go func(stopServerCh chan bool) {
 		<-ctx.Done()
@@ -137,9 +165,8 @@ func runServer(ctx context.Context, AfCtx *Context) error {
 	go func(stopServerCh chan bool) {
 		log.Infof("Serving AF Notifications on: %s",
 			AfCtx.cfg.SrvCfg.NotifPort)
-		if err = serverNotif.ListenAndServeTLS(
-			AfCtx.cfg.SrvCfg.ServerCertPath,
-			AfCtx.cfg.SrvCfg.ServerKeyPath); err != http.ErrServerClosed {
+		if err = serverNotif.ListenAndServeTLS("", ""); err !=
+			http.ErrServerClosed {
 
 			log.Errf("AF Notifications server error: " + err.Error())
 		}
@@ -149,8 +176,7 @@ func runServer(ctx context.Context, AfCtx *Context) error {
 	if HTTP2Enabled == true {
 		log.Infof("Serving AF (CNCA HTTP2 Requests) on: %s",
 			AfCtx.cfg.SrvCfg.CNCAEndpoint)
-		err = serverCNCA.ListenAndServeTLS(AfCtx.cfg.SrvCfg.ServerCertPath,
-			AfCtx.cfg.SrvCfg.ServerKeyPath)
+		err = serverCNCA.ListenAndServeTLS("", "")
 	} else {
 		log.Infof("Serving AF (CNCA HTTP Requests) on: %s",
 			AfCtx.cfg.SrvCfg.CNCAEndpoint)
@@ -187,6 +213,8 @@ func printConfig(cfg Config) {
 	log.Infoln("UserAgent: ", cfg.CliCfg.UserAgent)
 	log.Infoln("NEFCliCertPath: ", cfg.CliCfg.NEFCliCertPath)
 	log.Infoln("OAuth2Support: ", cfg.CliCfg.OAuth2Support)
+	log.Infoln("LogLevel: ", cfg.LogLevel)
+	log.Infoln("ConfigWatchInterval: ", cfg.ConfigWatchInterval)
 	log.Infoln("*************************************************************")
 
 }
@@ -196,32 +224,54 @@ func Run(parentCtx context.Context, cfgPath string) error {
 
 	var AfCtx Context
 
-	// load AF configuration from file
-	err := config.LoadJSONConfig(cfgPath, &AfCtx.cfg)
+	// load AF configuration from file, overridden by the AF_ environment
+	// variables
+	err := config.Load(cfgPath, afEnvPrefix, &AfCtx.cfg)
 
 	if err != nil {
 		log.Errf("Failed to load AF configuration: %v", err)
 		return err
 	}
 	printConfig(AfCtx.cfg)
+	setLogLevel(AfCtx.cfg.LogLevel)
+
+	// reload the configuration on SIGHUP or when the file changes
+	go watchConfig(parentCtx, &AfCtx, cfgPath)
 
 	return runServer(parentCtx, &AfCtx)
 }
 
+// setNEFAccessTokenAfID sets the AF ID the NEF access token is requested
+// for
+func setNEFAccessTokenAfID(afID string) {
+
+	nefTokenMu.Lock()
+	defer nefTokenMu.Unlock()
+	nefAccessTokenAfID = afID
+}
+
 func fetchNEFAuthorizationToken() error {
 
-	var err error
+	nefTokenMu.Lock()
+	afID := nefAccessTokenAfID
+	nefTokenMu.Unlock()
 
-	nefAccessToken, err = oauth2.GetAccessToken()
+	token, err := oauth2.GetAFAccessToken(afID)
 	if err != nil {
 		log.Errf("Failed to Fetch Access Token ")
 		return err
 	}
-	log.Errf("Got Access Token ", nefAccessToken)
+	log.Infoln("Got NEF access token")
+
+	nefTokenMu.Lock()
+	nefAccessToken = token
+	nefTokenMu.Unlock()
 	return nil
 }
 
 func getNEFAuthorizationToken() (token string, err error) {
 
+	nefTokenMu.Lock()
+	defer nefTokenMu.Unlock()
 	return nefAccessToken, nil
 }
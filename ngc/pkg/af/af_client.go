@@ -6,19 +6,13 @@ package af
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
-	"time"
-
-	"golang.org/x/net/http2"
 )
 
 const contentType string = "application/json"
@@ -77,24 +71,9 @@ func NewClient(cfg *CliConfig) *Client {
 		cfg.HTTPClient = HTTPClient
 
 	}
-	if cfg.HTTPClient == nil || !TestAf {
-
-		CACert, err := ioutil.ReadFile(cfg.NEFCliCertPath)
-		if err != nil {
-			log.Errf("Error: %v", err)
-		}
-
-		CACertPool := x509.NewCertPool()
-		CACertPool.AppendCertsFromPEM(CACert)
-
-		cfg.HTTPClient = &http.Client{
-			Timeout: 15 * time.Second,
-			Transport: &http2.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs: CACertPool,
-				},
-			},
-		}
+	// The client of the AF context is reused, a new one is created if none
+	if cfg.HTTPClient == nil {
+		_, cfg.HTTPClient = newNEFHTTPClient(cfg.NEFCliCertPath, 0)
 	}
 
 	c := &Client{}
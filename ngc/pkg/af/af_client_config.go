@@ -9,8 +9,8 @@ import (
 
 // CliConfig struct
 type CliConfig struct {
-	Protocol       string `json:"Protocol"`
-	NEFHostname    string `json:"NEFHostname"`
+	Protocol       string `json:"Protocol" config:"required"`
+	NEFHostname    string `json:"NEFHostname" config:"required"`
 	NEFPort        string `json:"NEFPort"`
 	NEFBasePath    string `json:"NEFBasePath"`
 	NEFPFDBasePath string `json:"NEFPFDBasePath"`
@@ -23,15 +23,17 @@ type CliConfig struct {
 // NewConfiguration function initializes client configuration
 func NewConfiguration(afCtx *Context) *CliConfig {
 
+	cliCfg := afCtx.cliConfig()
 	cfg := &CliConfig{
-		Protocol:       afCtx.cfg.CliCfg.Protocol,
-		NEFPort:        afCtx.cfg.CliCfg.NEFPort,
-		NEFHostname:    afCtx.cfg.CliCfg.NEFHostname,
-		NEFBasePath:    afCtx.cfg.CliCfg.NEFBasePath,
-		NEFPFDBasePath: afCtx.cfg.CliCfg.NEFPFDBasePath,
-		UserAgent:      afCtx.cfg.CliCfg.UserAgent,
-		NEFCliCertPath: afCtx.cfg.CliCfg.NEFCliCertPath,
-		OAuth2Support:  afCtx.cfg.CliCfg.OAuth2Support,
+		Protocol:       cliCfg.Protocol,
+		NEFPort:        cliCfg.NEFPort,
+		NEFHostname:    cliCfg.NEFHostname,
+		NEFBasePath:    cliCfg.NEFBasePath,
+		NEFPFDBasePath: cliCfg.NEFPFDBasePath,
+		UserAgent:      cliCfg.UserAgent,
+		NEFCliCertPath: cliCfg.NEFCliCertPath,
+		OAuth2Support:  cliCfg.OAuth2Support,
+		HTTPClient:     afCtx.nefHTTPClient(),
 	}
 
 	return cfg
@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package af
+
+import (
+	"net/http"
+
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
+)
+
+var (
+	// afMetrics contains the metrics of the AF exposed on /metrics
+	afMetrics = metrics.NewRegistry()
+	// afHTTPMetrics records the requests of the CNCA and notification
+	// routes
+	afHTTPMetrics = metrics.NewHTTPMetrics(afMetrics, "af")
+	// afTLSMetrics reports the expiry of the server certificate and of the
+	// NEF CA certificate
+	afTLSMetrics = tlsmgr.NewMetrics(afMetrics, "af")
+)
+
+// GetMetrics function returns the metrics of the AF in the Prometheus text
+// format
+func GetMetrics(w http.ResponseWriter, r *http.Request) {
+	afMetrics.Handler().ServeHTTP(w, r)
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package af
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	config "github.com/open-ness/epcforedge/ngc/pkg/config"
+	oauth2 "github.com/open-ness/epcforedge/ngc/pkg/oauth2"
+)
+
+// afEnvPrefix is the prefix of the environment variables overriding the AF
+// configuration, e.g. AF_CLICONFIG_NEFHOSTNAME
+const afEnvPrefix = "AF"
+
+// afDefaultWatchInterval is the default interval in seconds between two
+// checks of the configuration file for a change
+const afDefaultWatchInterval = 10
+
+// afReloadable are the fields of the configuration applied by a reload: the
+// client to the NEF and the log level
+var afReloadable = []string{"CliConfig", "LogLevel"}
+
+// Validate checks the AF configuration which is loaded
+func (cfg Config) Validate() error {
+
+	if cfg.LogLevel != "" {
+		if _, err := logger.ParseLevel(cfg.LogLevel); err != nil {
+			return errors.New("LogLevel: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// setLogLevel sets the log level of the configuration, if any. The level is
+// validated with the configuration
+func setLogLevel(level string) {
+
+	if lvl, err := logger.ParseLevel(level); level != "" && err == nil {
+		logger.SetLevel(lvl)
+	}
+}
+
+// cliConfig returns the configuration of the client to the NEF
+func (afCtx *Context) cliConfig() CliConfig {
+
+	afCtx.cfgMu.RLock()
+	defer afCtx.cfgMu.RUnlock()
+	return afCtx.cfg.CliCfg
+}
+
+// reloadConfig reads the configuration file again and applies the client
+// to the NEF and the log level. The OAuth2 configuration is read again
+func reloadConfig(afCtx *Context, cfgPath string) (config.ReloadResult,
+	error) {
+
+	afCtx.cfgMu.RLock()
+	cur := afCtx.cfg
+	afCtx.cfgMu.RUnlock()
+
+	var next Config
+	res, err := config.Reload(cfgPath, afEnvPrefix, &cur, &next,
+		afReloadable...)
+	if err != nil {
+		return res, err
+	}
+
+	afCtx.cfgMu.Lock()
+	afCtx.cfg.CliCfg = next.CliCfg
+	afCtx.cfg.LogLevel = next.LogLevel
+	afCtx.cfgMu.Unlock()
+
+	setLogLevel(next.LogLevel)
+	if err = oauth2.Reload(); err != nil {
+		log.Errf("OAuth2 configuration not reloaded: %v", err)
+	}
+	if next.CliCfg.NEFCliCertPath != cur.CliCfg.NEFCliCertPath {
+		afCtx.setNEFClient(next.CliCfg)
+	}
+	if next.CliCfg.OAuth2Support && !cur.CliCfg.OAuth2Support {
+		log.Infoln("Fetching NEF access token")
+		setNEFAccessTokenAfID(cur.AfID)
+		if err = fetchNEFAuthorizationToken(); err != nil {
+			log.Errf("Failed to get access token: %v", err)
+		}
+	}
+	return res, nil
+}
+
+// watchInterval returns the interval between two checks of the
+// configuration file, 0 if the file is not watched
+func watchInterval(cfg *Config) time.Duration {
+
+	if cfg.ConfigWatchInterval < 0 {
+		return 0
+	}
+	if cfg.ConfigWatchInterval == 0 {
+		return afDefaultWatchInterval * time.Second
+	}
+	return time.Duration(cfg.ConfigWatchInterval) * time.Second
+}
+
+// watchConfig reloads the configuration on SIGHUP or when the configuration
+// file changes, until ctx is done
+func watchConfig(ctx context.Context, afCtx *Context, cfgPath string) {
+
+	config.Watch(ctx, cfgPath, watchInterval(&afCtx.cfg), func(reason string) {
+		log.Infof("%s, reloading the AF configuration", reason)
+		res, err := reloadConfig(afCtx, cfgPath)
+		if err != nil {
+			log.Errf("AF configuration not reloaded: %v", err)
+			return
+		}
+		for _, c := range res.Applied {
+			log.Infof("Configuration reloaded, %v", c)
+		}
+		for _, c := range res.Ignored {
+			log.Infof("Configuration not reloaded, restart required: %v", c)
+		}
+		log.Infof("AF configuration reloaded: %d changes applied, %d "+
+			"requiring a restart", len(res.Applied), len(res.Ignored))
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
 )
 
 // DefaultNotifURL const
@@ -40,6 +41,7 @@ func NewAFRouter(afCtx *Context) *mux.Router {
 			Name(route.Name).
 			Handler(handler)
 	}
+	router.Use(afHTTPMetrics.Middleware)
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := context.WithValue(
@@ -66,6 +68,7 @@ func NewNotifRouter(afCtx *Context) *mux.Router {
 			Name(route.Name).
 			Handler(handler)
 	}
+	router.Use(afHTTPMetrics.Middleware)
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := context.WithValue(
@@ -196,4 +199,11 @@ var afRoutes = Routes{
 		"/af/v1/pfd/transactions/{transactionId}/applications/{appId}",
 		PatchPfdAppTransaction,
 	},
+
+	Route{
+		"GetMetrics",
+		strings.ToUpper("Get"),
+		metrics.Path,
+		GetMetrics,
+	},
 }
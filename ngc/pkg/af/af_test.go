@@ -15,9 +15,12 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/open-ness/epcforedge/ngc/pkg/af"
+	"github.com/open-ness/epcforedge/ngc/pkg/oauth2"
 )
 
 func TestAf(t *testing.T) {
+	// The NEF access token is signed with the shipped OAuth2 configuration
+	oauth2.SetConfigPath("../../configs/oauth2.json")
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "AF Suite")
 }
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package af
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
+)
+
+// afTLSInterval returns the interval between two checks of the TLS files,
+// the one of the configuration file. They are not reloaded if negative
+func afTLSInterval(cfg *Config) time.Duration {
+
+	if interval := watchInterval(cfg); interval != 0 {
+		return interval
+	}
+	return -1
+}
+
+// serverTLSConfig returns the TLS configuration of the CNCA and notification
+// servers with the server certificate, reloaded when it changes
+func serverTLSConfig(afCtx *Context) (*tls.Config, error) {
+
+	m := tlsmgr.New(tlsmgr.Config{CertPath: afCtx.cfg.SrvCfg.ServerCertPath,
+		KeyPath:  afCtx.cfg.SrvCfg.ServerKeyPath,
+		Interval: afTLSInterval(&afCtx.cfg)})
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	afTLSMetrics.Set("server", m)
+	return m.ServerConfig(tls.NoClientCert), nil
+}
+
+// newNEFHTTPClient returns a client to the NEF verifying its certificate with
+// the CA of certPath, reloaded when it changes
+func newNEFHTTPClient(certPath string,
+	interval time.Duration) (*tlsmgr.Manager, *http.Client) {
+
+	m := tlsmgr.New(tlsmgr.Config{CAPaths: []string{certPath},
+		Interval: interval})
+	if err := m.Reload(); err != nil {
+		log.Errf("Error: %v", err)
+	}
+	return m, &http.Client{Timeout: 15 * time.Second,
+		Transport: m.HTTP2Transport()}
+}
+
+// setNEFClient sets the client to the NEF of the configuration
+func (afCtx *Context) setNEFClient(cliCfg CliConfig) {
+
+	m, client := newNEFHTTPClient(cliCfg.NEFCliCertPath,
+		afTLSInterval(&afCtx.cfg))
+	afTLSMetrics.Set("nef_ca", m)
+
+	afCtx.cfgMu.Lock()
+	afCtx.nefClient = client
+	afCtx.cfgMu.Unlock()
+}
+
+// nefHTTPClient returns the client to the NEF, nil if not set
+func (afCtx *Context) nefHTTPClient() *http.Client {
+
+	afCtx.cfgMu.RLock()
+	defer afCtx.cfgMu.RUnlock()
+	return afCtx.nefClient
+}
@@ -296,4 +296,37 @@ type EventNotification struct {
 	TgtUEIPv6Prefix TgtUEIPv6Prefix `json:"tgtUeIpv6Prefix,omitempty"`
 	// UeMac
 	UEMac UEMac `json:"ueMac,omitempty"`
+	// AfAckInd
+	AfAckInd bool `json:"afAckInd,omitempty"`
+}
+
+// AfResultStatus type
+type AfResultStatus string
+
+// List of AfResultStatus
+const (
+	AfResultSuccess        AfResultStatus = "SUCCESS"
+	AfResultTempCongest    AfResultStatus = "TEMP_CONGEST"
+	AfResultRelocNoAllowed AfResultStatus = "RELOC_NO_ALLOWED"
+	AfResultOther          AfResultStatus = "OTHER"
+)
+
+// AfResultInfo structure
+type AfResultInfo struct {
+	// AfStatus
+	AfStatus AfResultStatus `json:"afStatus"`
+	// TrafficRoute
+	TrafficRoute *RouteToLocation `json:"trafficRoute,omitempty"`
+	// UpBuffInd
+	UpBuffInd bool `json:"upBuffInd,omitempty"`
+}
+
+// AfAckInfo structure
+type AfAckInfo struct {
+	// AfTransID
+	AFTransID string `json:"afTransId,omitempty"`
+	// AckResult
+	AckResult AfResultInfo `json:"ackResult"`
+	// Gpsi
+	GPSI string `json:"gpsi,omitempty"`
 }
@@ -114,5 +114,22 @@ func NotificationPost(w http.ResponseWriter, r *http.Request) {
 		log.Errf("Traffic Influance Subscription notify: %s", err.Error())
 		return
 	}
+
+	if en.AfAckInd {
+		// The application is not relocated by the AF, the UP path change is
+		// acknowledged right away
+		ack := AfAckInfo{AFTransID: en.AFTransID,
+			AckResult: AfResultInfo{AfStatus: AfResultSuccess},
+			GPSI:      en.GPSI}
+		if prJSON, err = json.Marshal(ack); err == nil {
+			w.WriteHeader(statusCode)
+			if _, err = w.Write(prJSON); err != nil {
+				log.Errf("Traffic Influance Subscription notify: %s",
+					err.Error())
+			}
+			return
+		}
+		log.Errf("Traffic Influance Subscription notify: %s", err.Error())
+	}
 	w.WriteHeader(statusCode)
 }
@@ -1,14 +1,29 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright (c) 2019 Intel Corporation
+// Copyright (c) 2019-2020 Intel Corporation
 
 package config
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/ghodss/yaml"
 )
 
+// requiredTag is the value of the config struct tag of the fields which must
+// be set, e.g. `json:"endpoint" config:"required"`
+const requiredTag = "required"
+
+// Validator is implemented by the configurations with checks which can not
+// be expressed with the required fields, e.g. one of two fields must be set
+type Validator interface {
+	Validate() error
+}
+
 // LoadJSONConfig reads a file located at configPath and unmarshals it to
 // config structure
 func LoadJSONConfig(configPath string, config interface{}) error {
@@ -18,3 +33,117 @@ func LoadJSONConfig(configPath string, config interface{}) error {
 	}
 	return json.Unmarshal(cfgData, config)
 }
+
+// LoadFile reads a JSON or YAML file located at configPath and unmarshals it
+// to config structure. The file is YAML if its extension is .yaml or .yml,
+// the json tags of the structure apply to both formats
+func LoadFile(configPath string, config interface{}) error {
+	cfgData, err := ioutil.ReadFile(filepath.Clean(configPath))
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yaml", ".yml":
+		if cfgData, err = yaml.YAMLToJSON(cfgData); err != nil {
+			return errors.New(configPath + ": " + err.Error())
+		}
+	}
+	if err = json.Unmarshal(cfgData, config); err != nil {
+		return errors.New(configPath + ": " + err.Error())
+	}
+	return nil
+}
+
+// Load reads the configuration file located at configPath into config
+// structure, overrides its fields with the environment variables starting
+// with prefix and validates it. See ApplyEnv and Validate
+func Load(configPath string, prefix string, config interface{}) error {
+	if err := LoadFile(configPath, config); err != nil {
+		return err
+	}
+	if err := ApplyEnv(prefix, config); err != nil {
+		return err
+	}
+	return Validate(prefix, config)
+}
+
+// Validate checks that the fields of config structure tagged as required are
+// set, then runs its own checks if it is a Validator. All the missing fields
+// are reported with the environment variable which can set them
+func Validate(prefix string, config interface{}) error {
+	var missing []string
+	walkFields(reflect.ValueOf(config), prefix, "",
+		func(f reflect.Value, sf reflect.StructField, env string,
+			path string) {
+			if sf.Tag.Get("config") == requiredTag && isZero(f) {
+				missing = append(missing, path+" (or "+env+")")
+			}
+		})
+	if len(missing) != 0 {
+		return errors.New("Missing required configuration: " +
+			strings.Join(missing, ", "))
+	}
+	if v, ok := config.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// isZero returns whether the value of the field is not set
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// fieldName returns the name of the struct field in the configuration file,
+// empty if it is not part of it
+func fieldName(sf reflect.StructField) string {
+	if sf.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name
+}
+
+// walkFields calls fn for each field of the structure pointed by v, except
+// the nested structures which are walked. The environment variable and the
+// path of each field are built from its name in the configuration file
+func walkFields(v reflect.Value, env string, path string,
+	fn func(f reflect.Value, sf reflect.StructField, env string,
+		path string)) {
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := fieldName(sf)
+		if name == "" {
+			continue
+		}
+		f := v.Field(i)
+		fEnv, fPath := env, path
+		// The fields of the embedded structures are promoted
+		if !sf.Anonymous || sf.Tag.Get("json") != "" {
+			fEnv = envName(env, name)
+			fPath = strings.TrimPrefix(path+"."+name, ".")
+		}
+		if f.Kind() == reflect.Struct {
+			walkFields(f.Addr(), fEnv, fPath, fn)
+			continue
+		}
+		fn(f, sf, fEnv, fPath)
+	}
+}
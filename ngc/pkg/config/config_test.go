@@ -1,10 +1,18 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright (c) 2019 Intel Corporation
+// Copyright (c) 2019-2020 Intel Corporation
 
 package config
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -30,3 +38,154 @@ var _ = Describe("LoadJSONonfig", func() {
 			})
 	})
 })
+
+type testServer struct {
+	Endpoint string `json:"endpoint" config:"required"`
+	Timeout  int    `json:"timeout"`
+}
+
+type testConf struct {
+	Val    int               `json:"Val"`
+	Server testServer        `json:"Server"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"labels"`
+	Debug  bool
+	Key    string `json:"key" config:"required"`
+}
+
+type testValidatedConf struct {
+	Val int `json:"Val"`
+}
+
+func (c testValidatedConf) Validate() error {
+	if c.Val < 2 {
+		return errors.New("Val must be at least 2")
+	}
+	return nil
+}
+
+var _ = Describe("Load", func() {
+
+	AfterEach(func() {
+		for _, env := range []string{"TEST_VAL", "TEST_SERVER_ENDPOINT",
+			"TEST_NAMES", "TEST_LABELS", "TEST_DEBUG", "TEST_KEY",
+			"TEST_CONFIG", "TEST_LOG_LEVEL"} {
+			Expect(os.Unsetenv(env)).To(Succeed())
+		}
+	})
+
+	It("Will load a YAML config file with the json names", func() {
+		conf := testConf{}
+		Expect(LoadFile("testdata/conf.yaml", &conf)).To(Succeed())
+		Expect(conf.Val).To(Equal(1))
+		Expect(conf.Server.Endpoint).To(Equal(":8080"))
+		Expect(conf.Names).To(Equal([]string{"a", "b"}))
+	})
+
+	It("Will override the fields with the environment variables", func() {
+		Expect(os.Setenv("TEST_VAL", "3")).To(Succeed())
+		Expect(os.Setenv("TEST_NAMES", `["c"]`)).To(Succeed())
+		Expect(os.Setenv("TEST_LABELS", `{"k":"v"}`)).To(Succeed())
+		Expect(os.Setenv("TEST_DEBUG", "true")).To(Succeed())
+		Expect(os.Setenv("TEST_KEY", "secret")).To(Succeed())
+
+		conf := testConf{}
+		Expect(Load("testdata/conf.yaml", "TEST", &conf)).To(Succeed())
+		Expect(conf).To(Equal(testConf{Val: 3,
+			Server: testServer{Endpoint: ":8080"}, Names: []string{"c"},
+			Labels: map[string]string{"k": "v"}, Debug: true,
+			Key: "secret"}))
+
+		Expect(os.Setenv("TEST_VAL", "three")).To(Succeed())
+		err := ApplyEnv("TEST", &conf)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix(`TEST_VAL: invalid value "three"`))
+	})
+
+	It("Will report the missing required fields", func() {
+		conf := testConf{}
+		err := Load("testdata/conf.json", "TEST", &conf)
+		Expect(err).To(MatchError("Missing required configuration: " +
+			"Server.endpoint (or TEST_SERVER_ENDPOINT), key (or TEST_KEY)"))
+
+		Expect(Validate("TEST", &testValidatedConf{Val: 1})).To(
+			MatchError("Val must be at least 2"))
+		Expect(Validate("TEST", &testValidatedConf{Val: 2})).To(Succeed())
+	})
+
+	It("Will take the flags from the command line or environment", func() {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := RegisterFlags(fs, "TEST", "conf.json")
+		Expect(fs.Parse(nil)).To(Succeed())
+		Expect(*f).To(Equal(Flags{ConfigPath: "conf.json", LogLevel: "info"}))
+
+		Expect(os.Setenv("TEST_CONFIG", "env.yaml")).To(Succeed())
+		fs = flag.NewFlagSet("test", flag.ContinueOnError)
+		f = RegisterFlags(fs, "TEST", "conf.json")
+		Expect(fs.Parse([]string{"--log-level", "debug"})).To(Succeed())
+		Expect(*f).To(Equal(Flags{ConfigPath: "env.yaml", LogLevel: "debug"}))
+	})
+})
+
+var _ = Describe("Reload", func() {
+
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "config")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will split the changes between reloadable and other fields", func() {
+		cur := testConf{Val: 1, Server: testServer{Endpoint: ":8080"},
+			Names: []string{"a", "b"}, Key: "k"}
+		path := filepath.Join(tmpDir, "conf.yaml")
+		Expect(ioutil.WriteFile(path, []byte("Val: 2\nkey: k\n"+
+			"Server:\n  endpoint: \":9090\"\n  timeout: 5\nNames: [a]\n"),
+			0600)).To(Succeed())
+
+		next := testConf{}
+		res, err := Reload(path, "TEST", &cur, &next, "Val", "Server.timeout",
+			"Names")
+		Expect(err).To(BeNil())
+		Expect(res.Applied).To(Equal([]Change{
+			{Path: "Val", Old: 1, New: 2},
+			{Path: "Server.timeout", Old: 0, New: 5},
+			{Path: "Names", Old: []string{"a", "b"}, New: []string{"a"}}}))
+		Expect(res.Ignored).To(Equal([]Change{{Path: "Server.endpoint",
+			Old: ":8080", New: ":9090"}}))
+		Expect(res.Applied[0].String()).To(Equal("Val: 1 -> 2"))
+		Expect(res.Applied[2].String()).To(Equal("Names changed"))
+
+		Expect(ioutil.WriteFile(path, []byte("Val: 2\n"), 0600)).To(
+			Succeed())
+		_, err = Reload(path, "TEST", &cur, &testConf{}, "Val")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Will call reload on SIGHUP or when the file changes", func() {
+		path := filepath.Join(tmpDir, "conf.json")
+		Expect(ioutil.WriteFile(path, []byte("{}"), 0600)).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reasons := make(chan string, 2)
+		go Watch(ctx, path, 100*time.Millisecond, func(reason string) {
+			reasons <- reason
+		})
+		time.Sleep(200 * time.Millisecond)
+
+		Expect(ioutil.WriteFile(path, []byte(`{"Val":1}`), 0600)).To(
+			Succeed())
+		Eventually(reasons).Should(Receive(Equal(path + " changed")))
+
+		Expect(syscall.Kill(os.Getpid(), syscall.SIGHUP)).To(Succeed())
+		Eventually(reasons).Should(Receive(Equal("SIGHUP received")))
+		Consistently(reasons, 300*time.Millisecond).ShouldNot(Receive())
+	})
+})
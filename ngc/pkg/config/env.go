@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envName returns the environment variable of the field name under prefix,
+// e.g. NEF_HTTPCONFIG_ENDPOINT for the endpoint of the NEF HTTPConfig
+func envName(prefix string, name string) string {
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// EnvDefault returns the value of the environment variable name, def if it
+// is not set
+func EnvDefault(name string, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+// ApplyEnv overrides the fields of config structure with the environment
+// variables set. The variable of a field is its path in the configuration
+// file, in upper case with "_" separators, under prefix, e.g.
+// NEF_PCFCONFIG_APIROOT. The lists, maps and pointers are given in JSON
+func ApplyEnv(prefix string, config interface{}) error {
+	var errs []string
+	walkFields(reflect.ValueOf(config), prefix, "",
+		func(f reflect.Value, sf reflect.StructField, env string,
+			path string) {
+			s, ok := os.LookupEnv(env)
+			if !ok {
+				return
+			}
+			if err := setField(f, s); err != nil {
+				errs = append(errs, env+": invalid value \""+s+"\": "+
+					err.Error())
+			}
+		})
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// setField sets the field to the value of an environment variable
+func setField(f reflect.Value, s string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(v)
+	default:
+		v := reflect.New(f.Type())
+		if err := json.Unmarshal([]byte(s), v.Interface()); err != nil {
+			return err
+		}
+		f.Set(v.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package config
+
+import (
+	"flag"
+)
+
+// Flags are the command line options common to the NGC daemons
+type Flags struct {
+	// Path of the configuration file, JSON or YAML
+	ConfigPath string
+	// Log level, e.g. "info" or "debug"
+	LogLevel string
+}
+
+// RegisterFlags registers the --config and --log-level flags of a daemon in
+// fs. Their default values are taken from the <prefix>_CONFIG and
+// <prefix>_LOG_LEVEL environment variables if set, from defaultConfig and
+// "info" otherwise
+func RegisterFlags(fs *flag.FlagSet, prefix string,
+	defaultConfig string) *Flags {
+
+	f := &Flags{}
+	fs.StringVar(&f.ConfigPath, "config",
+		EnvDefault(envName(prefix, "CONFIG"), defaultConfig),
+		"Path of the configuration file, JSON or YAML (.yaml, .yml). "+
+			"The fields can be overridden by the "+prefix+
+			"_<FIELD PATH> environment variables")
+	fs.StringVar(&f.LogLevel, "log-level",
+		EnvDefault(envName(prefix, "LOG_LEVEL"), "info"),
+		"Log level: debug, info, notice, warning, err, crit, alert or emerg")
+	return f
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Change is a field whose value differs between two configurations
+type Change struct {
+	// Path of the field in the configuration file, e.g. HTTPConfig.endpoint
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// String returns the path with the old and new values of the field, the
+// lists, maps and pointers are only reported as changed
+func (c Change) String() string {
+	switch reflect.ValueOf(c.New).Kind() {
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface:
+		return c.Path + " changed"
+	}
+	return fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// ReloadResult is the result of the reload of a configuration
+type ReloadResult struct {
+	// Changes of the reloadable fields, to be applied
+	Applied []Change
+	// Changes of the other fields, applied after a restart only
+	Ignored []Change
+}
+
+// field is a leaf field of a configuration
+type field struct {
+	path  string
+	value reflect.Value
+}
+
+// leafFields returns the fields of the structure pointed by v, the nested
+// structures being walked
+func leafFields(v interface{}) []field {
+	var fields []field
+	walkFields(reflect.ValueOf(v), "", "",
+		func(f reflect.Value, sf reflect.StructField, env string,
+			path string) {
+			fields = append(fields, field{path: path, value: f})
+		})
+	return fields
+}
+
+// Diff returns the fields which differ between the structures of the same
+// type pointed by cur and next
+func Diff(cur interface{}, next interface{}) []Change {
+	var changes []Change
+	curFields, nextFields := leafFields(cur), leafFields(next)
+	for i := range curFields {
+		if i >= len(nextFields) {
+			break
+		}
+		o, n := curFields[i].value.Interface(), nextFields[i].value.Interface()
+		if !reflect.DeepEqual(o, n) {
+			changes = append(changes, Change{Path: curFields[i].path,
+				Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// isReloadable returns whether the field path is one of the reloadable
+// paths or under one of them
+func isReloadable(path string, reloadable []string) bool {
+	for _, r := range reloadable {
+		if path == r || strings.HasPrefix(path, r+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload loads the configuration file located at configPath into next, as
+// Load does, and compares it with cur, both pointing to structures of the
+// same type. The changes are split between the fields under the reloadable
+// paths and the others. Nothing is applied, the caller copies the
+// reloadable fields of next it supports
+func Reload(configPath string, prefix string, cur interface{},
+	next interface{}, reloadable ...string) (ReloadResult, error) {
+
+	var res ReloadResult
+	if err := Load(configPath, prefix, next); err != nil {
+		return res, err
+	}
+	for _, c := range Diff(cur, next) {
+		if isReloadable(c.Path, reloadable) {
+			res.Applied = append(res.Applied, c)
+		} else {
+			res.Ignored = append(res.Ignored, c)
+		}
+	}
+	return res, nil
+}
+
+// fileStamp returns the modification time and size of the file
+func fileStamp(path string) (time.Time, int64) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return fi.ModTime(), fi.Size()
+}
+
+// Watch calls reload on SIGHUP or when the file located at configPath
+// changes, until ctx is done, with the reason of the reload. The file is
+// checked every interval, it is not watched if interval is 0
+func Watch(ctx context.Context, configPath string, interval time.Duration,
+	reload func(reason string)) {
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	modTime, size := fileStamp(configPath)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hupCh:
+			modTime, size = fileStamp(configPath)
+			reload("SIGHUP received")
+		case <-tick:
+			t, s := fileStamp(configPath)
+			if t.Equal(modTime) && s == size {
+				continue
+			}
+			modTime, size = t, s
+			reload(configPath + " changed")
+		}
+	}
+}
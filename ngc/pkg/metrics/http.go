@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Path is the path of the metrics endpoint
+const Path = "/metrics"
+
+// HTTPMetrics counts the HTTP requests and their latency per route name and
+// status code
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+// NewHTTPMetrics registers the HTTP request metrics of the daemon, their
+// names are prefixed with namespace
+func NewHTTPMetrics(r *Registry, namespace string) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: r.NewCounterVec(namespace+"_http_requests_total",
+			"Number of HTTP requests by route and status code",
+			"route", "code"),
+		duration: r.NewHistogramVec(
+			namespace+"_http_request_duration_seconds",
+			"Latency of the HTTP requests by route and status code",
+			DefaultBuckets, "route", "code"),
+	}
+}
+
+// Requests returns the number of requests of the route with the status code
+func (m *HTTPMetrics) Requests(route string, code int) float64 {
+	return m.requests.Value(route, strconv.Itoa(code))
+}
+
+// Middleware is the mux middleware recording the requests with the name of
+// the matched route. Added first, it also records the requests rejected by
+// the other middlewares
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if cr := mux.CurrentRoute(r); cr != nil {
+			route = cr.GetName()
+		}
+		m.Instrument(next, route).ServeHTTP(w, r)
+	})
+}
+
+// Instrument returns the handler recording the requests of the route
+func (m *HTTPMetrics) Instrument(inner http.Handler,
+	route string) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		inner.ServeHTTP(rec, r)
+
+		code := strconv.Itoa(rec.status())
+		m.requests.Inc(route, code)
+		m.duration.Observe(time.Since(start).Seconds(), route, code)
+	})
+}
+
+// statusRecorder records the status code of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+// status returns the status code sent, 200 if only the body was written
+func (s *statusRecorder) status() int {
+	if s.code == 0 {
+		return http.StatusOK
+	}
+	return s.code
+}
+
+// WriteHeader records the status code and sends it
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Flush sends the buffered data if the writer supports it
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection, e.g. for the WebSockets, which is
+// recorded as 101
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection cannot be hijacked")
+	}
+	if s.code == 0 {
+		s.code = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
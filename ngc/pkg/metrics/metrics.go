@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package metrics exposes the metrics of the NGC daemons in the Prometheus
+// text format with the Prometheus client library. It wraps the counters,
+// histograms and gauges used by the daemons, the gauges being collected
+// when the metrics are scraped.
+package metrics
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// ContentType is the content type of the metrics exposition
+const ContentType = string(expfmt.FmtText)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms
+var DefaultBuckets = prometheus.DefBuckets
+
+// Registry contains the metric families exposed by a daemon
+type Registry struct {
+	reg *prometheus.Registry
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{reg: prometheus.NewRegistry()}
+}
+
+// Write writes the metric families in the text format, sorted by name
+func (r *Registry) Write(w io.Writer) error {
+	mfs, err := r.reg.Gather()
+	if err != nil {
+		return err
+	}
+	for _, mf := range mfs {
+		if _, err = expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler exposing the metrics. The metrics
+// collected without error are exposed if a gauge fails
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.reg, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError})
+}
+
+// findMetric returns the series of the collector with the label values, nil
+// if it was not created yet
+func findMetric(c prometheus.Collector, labels []string,
+	values []string) *dto.Metric {
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var found *dto.Metric
+	for m := range ch {
+		pb := &dto.Metric{}
+		if found != nil || m.Write(pb) != nil {
+			continue
+		}
+		match := len(pb.Label) == len(labels)
+		for _, lp := range pb.Label {
+			for i, name := range labels {
+				if lp.GetName() == name && lp.GetValue() != values[i] {
+					match = false
+				}
+			}
+		}
+		if match {
+			found = pb
+		}
+	}
+	return found
+}
+
+// CounterVec is a counter per label values
+type CounterVec struct {
+	vec    *prometheus.CounterVec
+	labels []string
+}
+
+// NewCounterVec registers a counter with the labels, it panics if the name
+// is already registered
+func (r *Registry) NewCounterVec(name string, help string,
+	labels ...string) *CounterVec {
+
+	c := &CounterVec{vec: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: name, Help: help}, labels), labels: labels}
+	r.reg.MustRegister(c.vec)
+	return c
+}
+
+// Inc increments the counter of the label values
+func (c *CounterVec) Inc(values ...string) {
+	c.vec.WithLabelValues(values...).Inc()
+}
+
+// Add adds v, which must not be negative, to the counter of the label values
+func (c *CounterVec) Add(v float64, values ...string) {
+	c.vec.WithLabelValues(values...).Add(v)
+}
+
+// Value returns the counter of the label values
+func (c *CounterVec) Value(values ...string) float64 {
+	if m := findMetric(c.vec, c.labels, values); m != nil {
+		return m.GetCounter().GetValue()
+	}
+	return 0
+}
+
+// HistogramVec is a histogram per label values
+type HistogramVec struct {
+	vec    *prometheus.HistogramVec
+	labels []string
+}
+
+// NewHistogramVec registers a histogram with the bucket upper bounds, in
+// increasing order, and the labels
+func (r *Registry) NewHistogramVec(name string, help string,
+	buckets []float64, labels ...string) *HistogramVec {
+
+	h := &HistogramVec{vec: prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets},
+		labels), labels: labels}
+	r.reg.MustRegister(h.vec)
+	return h
+}
+
+// Observe adds the observation v to the histogram of the label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.vec.WithLabelValues(values...).Observe(v)
+}
+
+// Count returns the number of observations of the label values
+func (h *HistogramVec) Count(values ...string) uint64 {
+	if m := findMetric(h.vec, h.labels, values); m != nil {
+		return m.GetHistogram().GetSampleCount()
+	}
+	return 0
+}
+
+// GaugeCollector reports the gauges of a family when the metrics are
+// scraped, set is called for each series with its value and label values
+type GaugeCollector func(set func(v float64, values ...string))
+
+// gaugeFunc is a gauge family whose series are collected on scrape
+type gaugeFunc struct {
+	desc    *prometheus.Desc
+	collect GaugeCollector
+}
+
+// NewGaugeFunc registers a gauge with the labels whose series are reported
+// by collect when the metrics are scraped
+func (r *Registry) NewGaugeFunc(name string, help string, labels []string,
+	collect GaugeCollector) {
+
+	r.reg.MustRegister(&gaugeFunc{
+		desc: prometheus.NewDesc(name, help, labels, nil), collect: collect})
+}
+
+// Describe sends the description of the gauge family
+func (g *gaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect sends the series reported by the collector
+func (g *gaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	g.collect(func(v float64, values ...string) {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v,
+			values...)
+	})
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMetrics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Metrics suite")
+}
+
+// exposition returns the metrics of the registry in the text format
+func exposition(r *Registry) string {
+	var b bytes.Buffer
+	Expect(r.Write(&b)).To(Succeed())
+	return b.String()
+}
+
+var _ = Describe("Registry", func() {
+
+	It("Will expose the counters, histograms and gauges", func() {
+		r := NewRegistry()
+		c := r.NewCounterVec("test_total", "Test counter", "kind")
+		c.Inc("a")
+		c.Add(2, "b\"")
+		h := r.NewHistogramVec("test_seconds", "Test histogram",
+			[]float64{0.1, 1}, "kind")
+		h.Observe(0.05, "a")
+		h.Observe(0.5, "a")
+		r.NewGaugeFunc("test_gauge", "Test\ngauge", nil,
+			func(set func(v float64, values ...string)) { set(3) })
+
+		Expect(exposition(r)).To(Equal(`# HELP test_gauge Test\ngauge
+# TYPE test_gauge gauge
+test_gauge 3
+# HELP test_seconds Test histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{kind="a",le="0.1"} 1
+test_seconds_bucket{kind="a",le="1"} 2
+test_seconds_bucket{kind="a",le="+Inf"} 2
+test_seconds_sum{kind="a"} 0.55
+test_seconds_count{kind="a"} 2
+# HELP test_total Test counter
+# TYPE test_total counter
+test_total{kind="a"} 1
+test_total{kind="b\""} 2
+`))
+		Expect(c.Value("b\"")).To(Equal(2.0))
+		Expect(h.Count("a")).To(Equal(uint64(2)))
+	})
+
+	It("Will reject a duplicate metric or wrong label values", func() {
+		r := NewRegistry()
+		c := r.NewCounterVec("test_total", "Test counter", "kind")
+		Expect(func() { r.NewCounterVec("test_total", "Again") }).To(Panic())
+		Expect(func() { c.Inc() }).To(Panic())
+	})
+})
+
+var _ = Describe("HTTPMetrics", func() {
+
+	It("Will record the requests by route name and status code", func() {
+		r := NewRegistry()
+		m := NewHTTPMetrics(r, "test")
+		router := mux.NewRouter()
+		router.Methods("GET").Path("/ok").Name("Ok").HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ok"))
+			})
+		router.Methods("GET").Path("/missing").Name("Missing").HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			})
+		router.Methods("GET").Path(Path).Name("Metrics").Handler(
+			r.Handler())
+		router.Use(m.Middleware)
+
+		for _, path := range []string{"/ok", "/ok", "/missing"} {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		}
+		Expect(m.Requests("Ok", http.StatusOK)).To(Equal(2.0))
+		Expect(m.Requests("Missing", http.StatusNotFound)).To(Equal(1.0))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", Path, nil))
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Header().Get("Content-Type")).To(Equal(ContentType))
+		Expect(rr.Body.String()).To(ContainSubstring(
+			`test_http_requests_total{code="200",route="Ok"} 2`))
+		Expect(rr.Body.String()).To(ContainSubstring(
+			`test_http_request_duration_seconds_count{code="404",` +
+				`route="Missing"} 1`))
+	})
+})
@@ -0,0 +1,50 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef
+
+import (
+	"context"
+
+	config "github.com/open-ness/epcforedge/ngc/pkg/config"
+)
+
+// NefStarted returns true once Run created the NEF routers in NefAppG. The
+// tests read NefAppG without lock once it returned true
+func NefStarted() bool {
+
+	nefAppMu.Lock()
+	defer nefAppMu.Unlock()
+	return NefAppG.NefRouter != nil && NefAppG.NefAdminRouter != nil
+}
+
+// NewNefRouters creates the NEF data of the configuration and its routers in
+// NefAppG without starting the servers, for the tests served with httptest.
+// The returned function destroys the NEF data once ctx is cancelled
+func NewNefRouters(ctx context.Context, cfgPath string) (func(), error) {
+
+	nefCtx := &nefContext{cfgPath: cfgPath}
+	if err := config.Load(cfgPath, nefEnvPrefix, &nefCtx.cfg); err != nil {
+		return nil, err
+	}
+	live := nefCtx.cfg
+	nefCtx.live.Store(&live)
+	if err := nefCtx.nef.nefCreate(ctx, nefCtx.cfg); err != nil {
+		return nil, err
+	}
+
+	nefAppMu.Lock()
+	NefAppG = NefApp{NefRouter: NewNEFRouter(nefCtx),
+		NefAdminRouter: NewNEFAdminRouter(nefCtx), NefCtx: nefCtx}
+	nefAppMu.Unlock()
+	return func() { nefCtx.nef.nefDestroy(nefCtx) }, nil
+}
+
+// ResetNefApp clears NefAppG before Run is started again
+func ResetNefApp() {
+
+	nefAppMu.Lock()
+	NefAppG = NefApp{}
+	nefAppMu.Unlock()
+}
@@ -16,6 +16,16 @@ const (
 )
 */
 
+// Events notified to the AF. UP_PATH_CHANGE is defined by 3GPP 29.522, the
+// other events relay the SMF events of the PDU session of the UE
+const (
+	SubscribedEventUpPathChange      SubscribedEvent = "UP_PATH_CHANGE"
+	SubscribedEventUeIPChange        SubscribedEvent = "UE_IP_CHANGE"
+	SubscribedEventPlmnChange        SubscribedEvent = "PLMN_CHANGE"
+	SubscribedEventAccessTypeChange  SubscribedEvent = "ACCESS_TYPE_CHANGE"
+	SubscribedEventPduSessionRelease SubscribedEvent = "PDU_SESSION_RELEASE"
+)
+
 // TrafficInfluSub is Traffic Influence Subscription structure
 type TrafficInfluSub struct {
 	// Identifies a service on behalf of which the AF is issuing the request.
@@ -132,6 +142,61 @@ type EventNotification struct {
 	TgtUeIpv6Prefix Ipv6Prefix `json:"tgtUeIpv6Prefix,omitempty"`
 	// UE MAC address of the served UE
 	UeMac MacAddr48 `json:"ueMac,omitempty"`
+	// New PLMN ID of the UE. Present if the "subscribedEvent" sets to
+	// "PLMN_CHANGE".
+	PlmnID *PlmnID `json:"plmnId,omitempty"`
+	// New access type of the UE. Present if the "subscribedEvent" sets to
+	// "ACCESS_TYPE_CHANGE".
+	AccType AccessType `json:"accType,omitempty"`
+	// Released PDU session ID. Present if the "subscribedEvent" sets to
+	// "PDU_SESSION_RELEASE".
+	PduSeID PduSessionID `json:"pduSeId,omitempty"`
+	// Set to true if the AF shall acknowledge the notification with
+	// AfAckInfo, in the response or through the ack resource of the
+	// subscription
+	AfAckInd bool `json:"afAckInd,omitempty"`
+}
+
+// AfResultStatus : string identifying the result of the application
+// relocation by the AF
+// Possible values are
+// - SUCCESS: The application relocation is completed (ACK).
+// - TEMP_CONGEST: The application relocation fails due to temporary
+// congestion (NACK).
+// - RELOC_NO_ALLOWED: The application relocation fails because it is not
+// allowed (NACK).
+// - OTHER: The application relocation fails due to other reason (NACK).
+type AfResultStatus string
+
+// Results of the application relocation by the AF
+const (
+	AfResultStatusSuccess        AfResultStatus = "SUCCESS"
+	AfResultStatusTempCongest    AfResultStatus = "TEMP_CONGEST"
+	AfResultStatusRelocNoAllowed AfResultStatus = "RELOC_NO_ALLOWED"
+	AfResultStatusOther          AfResultStatus = "OTHER"
+)
+
+// AfResultInfo contains the result of the application relocation by the AF
+type AfResultInfo struct {
+	// Result of the application relocation
+	AfStatus AfResultStatus `json:"afStatus"`
+	// Identifies the N6 traffic routing requirement of the relocated
+	// application.
+	TrafficRoute *RouteToLocation `json:"trafficRoute,omitempty"`
+	// Set to true if the buffering of the uplink traffic is needed during
+	// the relocation
+	UpBuffInd bool `json:"upBuffInd,omitempty"`
+}
+
+// AfAckInfo is the acknowledgement of a UP path change notification sent by
+// the AF to the NEF
+type AfAckInfo struct {
+	// Identifies an NEF Northbound interface transaction, generated by the AF
+	AfTransID string `json:"afTransId,omitempty"`
+	// Result of the application relocation
+	AckResult AfResultInfo `json:"ackResult"`
+	// Identifies a user
+	Gpsi Gpsi `json:"gpsi,omitempty"`
 }
 
 // TemporalValidity Indicates the time interval(s) during which the AF request
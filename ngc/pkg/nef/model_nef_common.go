@@ -18,6 +18,13 @@ type Dnai string
 // - LATE: Late notification of UP path reconfiguration.
 type DnaiChangeType string
 
+// DNAI change types
+const (
+	DnaiChangeTypeEarly     DnaiChangeType = "EARLY"
+	DnaiChangeTypeEarlyLate DnaiChangeType = "EARLY_LATE"
+	DnaiChangeTypeLate      DnaiChangeType = "LATE"
+)
+
 // Dnn : string identify the Data network name
 type Dnn string
 
@@ -287,6 +294,8 @@ type PresenceInfo struct {
 	PraID string `json:"praId,omitempty"`
 	// presence state
 	PresenceState PresenceState `json:"presenceState,omitempty"`
+	// tracking area list
+	TrackingAreaList []Tai `json:"trackingAreaList,omitempty"`
 	// ecgi list
 	// Min Items: 1
 	EcgiList []Ecgi `json:"ecgiList"`
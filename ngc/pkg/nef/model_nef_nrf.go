@@ -0,0 +1,97 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef
+
+// NfType : string identifying the type of a network function (29.510)
+// Possible values used by the NEF are
+// - NEF
+// - PCF
+// - UDR
+// - UDM
+type NfType string
+
+// NF types used by the NEF
+const (
+	NfTypeNEF NfType = "NEF"
+	NfTypePCF NfType = "PCF"
+	NfTypeUDR NfType = "UDR"
+	NfTypeUDM NfType = "UDM"
+)
+
+// NfStatus : string identifying the status of a NF instance or service
+// Possible values are
+// - REGISTERED
+// - SUSPENDED
+// - UNDISCOVERABLE
+type NfStatus string
+
+// NF status values
+const (
+	NfStatusRegistered     NfStatus = "REGISTERED"
+	NfStatusSuspended      NfStatus = "SUSPENDED"
+	NfStatusUndiscoverable NfStatus = "UNDISCOVERABLE"
+)
+
+// NfServiceVersion contains the version details of a NF service
+type NfServiceVersion struct {
+	// API version in the URI e.g. v1
+	APIVersionInURI string `json:"apiVersionInUri"`
+	// Full version of the API e.g. 1.0.0
+	APIFullVersion string `json:"apiFullVersion"`
+}
+
+// IPEndPoint is an IP address and port on which a NF service is reachable
+type IPEndPoint struct {
+	Ipv4Address Ipv4Addr `json:"ipv4Address,omitempty"`
+	Ipv6Address Ipv6Addr `json:"ipv6Address,omitempty"`
+	Transport   string   `json:"transport,omitempty"`
+	Port        int      `json:"port,omitempty"`
+}
+
+// NfService contains the profile of a service of a NF instance
+type NfService struct {
+	// Unique ID of the service instance within the NF instance
+	ServiceInstanceID string `json:"serviceInstanceId"`
+	// Name of the service e.g. npcf-policyauthorization
+	ServiceName string `json:"serviceName"`
+	// API versions supported by the service
+	// minItems : 1
+	Versions []NfServiceVersion `json:"versions"`
+	// URI scheme: http or https
+	Scheme          string       `json:"scheme"`
+	NfServiceStatus NfStatus     `json:"nfServiceStatus"`
+	Fqdn            string       `json:"fqdn,omitempty"`
+	IPEndPoints     []IPEndPoint `json:"ipEndPoints,omitempty"`
+	// Optional path segments between the authority and the service name
+	APIPrefix string `json:"apiPrefix,omitempty"`
+}
+
+// NfProfile contains the profile of a NF instance registered in the NRF
+type NfProfile struct {
+	// UUID of the NF instance
+	NfInstanceID   string      `json:"nfInstanceId"`
+	NfType         NfType      `json:"nfType"`
+	NfStatus       NfStatus    `json:"nfStatus"`
+	HeartBeatTimer int         `json:"heartBeatTimer,omitempty"`
+	Fqdn           string      `json:"fqdn,omitempty"`
+	Ipv4Addresses  []Ipv4Addr  `json:"ipv4Addresses,omitempty"`
+	Ipv6Addresses  []Ipv6Addr  `json:"ipv6Addresses,omitempty"`
+	NfServices     []NfService `json:"nfServices,omitempty"`
+}
+
+// SearchResult contains the NF instances returned by the NRF discovery
+type SearchResult struct {
+	// Time in seconds during which the result can be cached
+	ValidityPeriod int         `json:"validityPeriod,omitempty"`
+	NfInstances    []NfProfile `json:"nfInstances"`
+}
+
+// PatchItem is an operation of a JSON patch (RFC 6902)
+type PatchItem struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
@@ -88,7 +88,7 @@ type AfRoutingRequirement struct {
 	// Min Items: 1
 	RouteToLocs []RouteToLocation `json:"routeToLocs"`
 	// sp val
-	SpVal SpatialValidity `json:"spVal,omitempty"`
+	SpVal *SpatialValidity `json:"spVal,omitempty"`
 	// temp vals
 	// Min Items: 1
 	TempVals []TemporalValidity `json:"tempVals"`
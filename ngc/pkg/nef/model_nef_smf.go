@@ -16,6 +16,19 @@ type NsmfEventExposureNotification struct {
 	// Note 3GPP 29508 defines this as EventNotification but this conflicts with
 	//  3GPP 29522 EventNotification so rename it
 	EventNotifs []NsmEventNotification `json:"eventNotifs"`
+	// URI to which the acknowledgement of the AF is sent for the early
+	// notifications of UP path change
+	AckURI URI `json:"ackUri,omitempty"`
+}
+
+// AckOfNotify is the acknowledgement of the AF relayed to the SMF
+type AckOfNotify struct {
+	// Notification correlation ID of the acknowledged notification
+	NotifID string `json:"notifId"`
+	// Result of the application relocation by the AF
+	AckResult AfResultInfo `json:"ackResult"`
+	// Identifies a user
+	Gpsi Gpsi `json:"gpsi,omitempty"`
 }
 
 // NsmEventNotification describes about the Notifications about Individual
@@ -85,3 +98,12 @@ type NsmEventNotification struct {
 // - PLMN_CH
 // - UE_IP_CH
 type SmfEvent string
+
+// SMF events notified to the NEF
+const (
+	SmfEventAcTyCh    SmfEvent = "AC_TY_CH"
+	SmfEventUpPathCh  SmfEvent = "UP_PATH_CH"
+	SmfEventPduSesRel SmfEvent = "PDU_SES_REL"
+	SmfEventPlmnCh    SmfEvent = "PLMN_CH"
+	SmfEventUeIPCh    SmfEvent = "UE_IP_CH"
+)
@@ -0,0 +1,37 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef
+
+// IDTranslationResult contains the identifiers of the UE returned by the
+// Nudm_SDM identifier translation (29.503)
+type IDTranslationResult struct {
+	// Supported features
+	SupportedFeatures string `json:"supportedFeatures,omitempty"`
+	// Subscription Permanent Identifier of the UE
+	// Required: true
+	Supi Supi `json:"supi"`
+	// Identifies a GPSI. It shall contain an MSISDN.
+	Gpsi Gpsi `json:"gpsi,omitempty"`
+}
+
+// UeID contains the identifiers of a member UE of a group
+type UeID struct {
+	// Subscription Permanent Identifier of the UE
+	// Required: true
+	Supi Supi `json:"supi"`
+	// GPSIs of the UE
+	GpsiList []Gpsi `json:"gpsiList,omitempty"`
+}
+
+// GroupIdentifiers contains the identifiers of a group returned by the
+// Nudm_SDM group identifiers retrieval (29.503)
+type GroupIdentifiers struct {
+	// External group identifier
+	ExtGroupID ExternalGroupID `json:"extGroupId,omitempty"`
+	// Internal group identifier
+	IntGroupID string `json:"intGroupId,omitempty"`
+	// Member UEs of the group
+	UeIDList []UeID `json:"ueIdList,omitempty"`
+}
@@ -44,7 +44,7 @@ type TrafficInfluData struct {
 	ValidStartTime DateTime `json:"validStartTime,omitempty"`
 	// Identifies a network area information that the request applies only to
 	// the traffic of UE(s) located in this specific zone
-	NwAreaInfo NetworkAreaInfo `json:"nwAreaInfo,omitempty"`
+	NwAreaInfo *NetworkAreaInfo `json:"nwAreaInfo,omitempty"`
 	// Contains the Notification Correlation Id allocated by the NEF for the
 	// UP path change notification.
 	UpPathChgNotifCorreID string `json:"upPathChgNotifCorreId,omitempty"`
@@ -84,7 +84,7 @@ type TrafficInfluDataPatch struct {
 	// Format: date-time
 	ValidStartTime DateTime `json:"validStartTime,omitempty"`
 	// nw area info
-	NwAreaInfo NetworkAreaInfo `json:"nwAreaInfo,omitempty"`
+	NwAreaInfo *NetworkAreaInfo `json:"nwAreaInfo,omitempty"`
 	// up path chg notif Uri
 	UpPathChgNotifURI URI `json:"upPathChgNotifUri,omitempty"`
 }
@@ -0,0 +1,244 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Handlers of the NEF administration API */
+
+package ngcnef
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// sendAdminJSONRsp sends a successful response with the JSON encoded body
+func sendAdminJSONRsp(w http.ResponseWriter, body interface{}) {
+
+	mdata, err := json.Marshal(body)
+	if err != nil {
+		sendCustomeErrorRspToAF(w, 500, "Failed to MARSHAL response data")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(mdata); err != nil {
+		log.Errf("Write Failed: %v", err)
+		return
+	}
+	log.Infof("HTTP Response sent: %d", http.StatusOK)
+}
+
+// NefAdminSub is the state of a traffic influence subscription
+type NefAdminSub struct {
+	AfID  string `json:"afId"`
+	SubID string `json:"subId"`
+	// Set if the policy is installed in the PCF/UDR, i.e. the subscription
+	// is within its tempValidities
+	Active bool `json:"active"`
+	// Time of the next activation or deactivation of the subscription
+	NextActivationChange *time.Time      `json:"nextActivationChange,omitempty"`
+	AfServiceID          string          `json:"afServiceId,omitempty"`
+	AfTransID            string          `json:"afTransId,omitempty"`
+	Gpsi                 Gpsi            `json:"gpsi,omitempty"`
+	ExternalGroupID      ExternalGroupID `json:"externalGroupId,omitempty"`
+	// Application session of a single UE subscription in the PCF
+	AppSessionID AppSessionID `json:"appSessionId,omitempty"`
+	// Influence data of a multiple UE subscription in the UDR
+	InfluenceID InfluenceID `json:"iid,omitempty"`
+	// Correlation ID of the notifications of the SMF
+	NotifCorreID            string `json:"notifCorreId,omitempty"`
+	NotificationDestination Link   `json:"notificationDestination,omitempty"`
+	// Result of the last test notification
+	TestNotification *NefTestNotifResult `json:"testNotification,omitempty"`
+}
+
+// NefAdminAf is the state of an AF
+type NefAdminAf struct {
+	AfID string `json:"afId"`
+	// Number of traffic influence subscriptions
+	Subscriptions int `json:"subscriptions"`
+	// Number of PFD transactions
+	PfdTransactions int `json:"pfdTransactions"`
+}
+
+// NefAdminPfdTrans is the state of a PFD transaction
+type NefAdminPfdTrans struct {
+	AfID          string        `json:"afId"`
+	TransID       string        `json:"transactionId"`
+	PfdManagement PfdManagement `json:"pfdManagement"`
+}
+
+// ListAfs : Returns the AFs with their number of subscriptions and PFD
+// transactions
+func ListAfs(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	nef := &nefCtx.nef
+
+	afs := []NefAdminAf{}
+	nef.mu.RLock()
+	for _, af := range nef.afs {
+		af.mu.Lock()
+		afs = append(afs, NefAdminAf{AfID: af.afID,
+			Subscriptions: len(af.subs), PfdTransactions: len(af.pfdtrans)})
+		af.mu.Unlock()
+	}
+	nef.mu.RUnlock()
+
+	sort.Slice(afs, func(i, j int) bool {
+		return afs[i].AfID < afs[j].AfID
+	})
+	sendAdminJSONRsp(w, afs)
+}
+
+// ListSubscriptions : Returns the state of the traffic influence
+// subscriptions. The afId query parameter selects the subscriptions of an AF
+func ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	nef := &nefCtx.nef
+	afID := r.URL.Query().Get("afId")
+
+	subs := []NefAdminSub{}
+	nef.mu.RLock()
+	for _, af := range nef.afs {
+		if afID != "" && af.afID != afID {
+			continue
+		}
+		af.mu.Lock()
+		for _, sub := range af.subs {
+			if sub.pending {
+				continue
+			}
+			s := NefAdminSub{
+				AfID:                    af.afID,
+				SubID:                   sub.subid,
+				Active:                  !sub.inactive,
+				AfServiceID:             sub.ti.AfServiceID,
+				AfTransID:               sub.ti.AfTransID,
+				Gpsi:                    sub.ti.Gpsi,
+				ExternalGroupID:         sub.ti.ExternalGroupID,
+				AppSessionID:            sub.appSessionID,
+				InfluenceID:             sub.iid,
+				NotifCorreID:            sub.NotifCorreID,
+				NotificationDestination: sub.afNotificationDestination,
+				TestNotification:        sub.testNotif}
+			if !sub.nextActivationChange.IsZero() {
+				next := sub.nextActivationChange
+				s.NextActivationChange = &next
+			}
+			subs = append(subs, s)
+		}
+		af.mu.Unlock()
+	}
+	nef.mu.RUnlock()
+
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].AfID != subs[j].AfID {
+			return subs[i].AfID < subs[j].AfID
+		}
+		return subs[i].SubID < subs[j].SubID
+	})
+	sendAdminJSONRsp(w, subs)
+}
+
+// ListPfdTransactions : Returns the PFD transactions. The afId query
+// parameter selects the transactions of an AF
+func ListPfdTransactions(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	nef := &nefCtx.nef
+	afID := r.URL.Query().Get("afId")
+
+	trans := []NefAdminPfdTrans{}
+	nef.mu.RLock()
+	for _, af := range nef.afs {
+		if afID != "" && af.afID != afID {
+			continue
+		}
+		af.mu.Lock()
+		for _, t := range af.pfdtrans {
+			if t.pending {
+				continue
+			}
+			trans = append(trans, NefAdminPfdTrans{AfID: af.afID,
+				TransID: t.transID, PfdManagement: t.pfdManagement})
+		}
+		af.mu.Unlock()
+	}
+	nef.mu.RUnlock()
+
+	sort.Slice(trans, func(i, j int) bool {
+		if trans[i].AfID != trans[j].AfID {
+			return trans[i].AfID < trans[j].AfID
+		}
+		return trans[i].TransID < trans[j].TransID
+	})
+	sendAdminJSONRsp(w, trans)
+}
+
+// ListDeadLetterNotifications : Returns the AF notifications that could not
+// be delivered. The afId query parameter selects the notifications of an AF
+func ListDeadLetterNotifications(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+
+	dls := nefCtx.nef.notifier.deadLetters(r.URL.Query().Get("afId"))
+	sendAdminJSONRsp(w, dls)
+}
+
+// ReplayDeadLetterNotification : Queues an undelivered AF notification again
+// for delivery
+func ReplayDeadLetterNotification(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	if err := nefCtx.nef.notifier.replay(vars["deadLetterId"]); err != nil {
+		log.Err(err)
+		sendCustomeErrorRspToAF(w, 404, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	log.Infof("HTTP Response sent: %d", http.StatusNoContent)
+}
+
+// ReplayAllDeadLetterNotifications : Queues all the undelivered AF
+// notifications, or those of the AF in the afId query parameter, again for
+// delivery. The body contains the replayed notifications
+func ReplayAllDeadLetterNotifications(w http.ResponseWriter,
+	r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	notifier := nefCtx.nef.notifier
+
+	replayed := []NefStoreDeadLetter{}
+	for _, dl := range notifier.deadLetters(r.URL.Query().Get("afId")) {
+		// A dead letter may be replayed by a concurrent request
+		if notifier.replay(dl.ID) == nil {
+			replayed = append(replayed, dl)
+		}
+	}
+	sendAdminJSONRsp(w, replayed)
+}
+
+// DeleteDeadLetterNotification : Discards an undelivered AF notification
+func DeleteDeadLetterNotification(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	_, err := nefCtx.nef.notifier.removeDeadLetter(vars["deadLetterId"])
+	if err != nil {
+		log.Err(err)
+		sendCustomeErrorRspToAF(w, 404, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	log.Infof("HTTP Response sent: %d", http.StatusNoContent)
+}
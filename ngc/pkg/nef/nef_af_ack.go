@@ -0,0 +1,210 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Acknowledgement by the AF of the early UP path change notifications. When
+ * the AF subscribed with the EARLY or EARLY_LATE DNAI change type, the early
+ * notifications are sent with afAckInd so that the AF relocates its
+ * application before the UP path is switched. The AF acknowledges with
+ * AfAckInfo in the response to the notification, or later through the ack
+ * resource of the subscription. The acknowledgement is relayed to the ackUri
+ * of the SMF with the NotifCorreID of the subscription. */
+
+package ngcnef
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// afAckDefaultTimeout is the default time in seconds the AF is given to
+// acknowledge a notification
+const afAckDefaultTimeout = 30
+
+// errAfAckNotPending is returned if no acknowledgement is expected for the
+// subscription
+var errAfAckNotPending = errors.New("No acknowledgement pending")
+
+// AckConfig contains the settings of the AF acknowledgements
+type AckConfig struct {
+	// Time in seconds the AF is given to acknowledge a notification, 30 if not
+	// set
+	Timeout int `json:"timeout"`
+}
+
+// afPendingAck is a notification waiting for the acknowledgement of the AF
+type afPendingAck struct {
+	// NotifCorreID of the subscription
+	notifID   string
+	ackURI    URI
+	afTransID string
+	gpsi      Gpsi
+	timer     *time.Timer
+}
+
+// afAcks contains the pending acknowledgements per subscription and relays
+// them to the SMF
+type afAcks struct {
+	ctx     context.Context
+	client  SmfNotifAck
+	timeout time.Duration
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[string]*afPendingAck
+}
+
+// newAfAcks creates the pending acknowledgements relayed until ctx is done
+func newAfAcks(ctx context.Context, cfg *Config, client SmfNotifAck) *afAcks {
+
+	return &afAcks{ctx: ctx, client: client,
+		timeout: time.Duration(notifConfigValue(cfg.AckConfig.Timeout,
+			afAckDefaultTimeout)) * time.Second,
+		pending: make(map[string]*afPendingAck)}
+}
+
+// afAckRequired returns true if the AF has to acknowledge the notification,
+// i.e. it is an early UP path change notification and the AF subscribed to
+// the early notifications
+func afAckRequired(ti TrafficInfluSub, ev EventNotification) bool {
+
+	return ev.SubscribedEvent == SubscribedEventUpPathChange &&
+		ev.DnaiChgType == DnaiChangeTypeEarly &&
+		(ti.DnaiChgType == DnaiChangeTypeEarly ||
+			ti.DnaiChgType == DnaiChangeTypeEarlyLate)
+}
+
+// expect records that an acknowledgement is expected for the notification
+// of the subscription. It replaces the acknowledgement previously expected
+func (a *afAcks) expect(afID string, subID string, notifID string,
+	ackURI URI, ev EventNotification) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := afSubKey(afID, subID)
+	if old, ok := a.pending[key]; ok {
+		old.timer.Stop()
+		log.Infof("Acknowledgement of %s replaced", key)
+	}
+	p := &afPendingAck{notifID: notifID, ackURI: ackURI,
+		afTransID: ev.AfTransID, gpsi: ev.Gpsi}
+	p.timer = time.AfterFunc(a.timeout, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.pending[key] == p {
+			delete(a.pending, key)
+			log.Errf("Notification of %s not acknowledged by the AF within "+
+				"%v", key, a.timeout)
+		}
+	})
+	a.pending[key] = p
+}
+
+// ack relays the acknowledgement of the AF to the SMF in a new routine. An
+// error is returned if no acknowledgement is expected for the subscription
+func (a *afAcks) ack(afID string, subID string, info AfAckInfo) error {
+
+	key := afSubKey(afID, subID)
+
+	a.mu.Lock()
+	p, ok := a.pending[key]
+	if ok {
+		p.timer.Stop()
+		delete(a.pending, key)
+	}
+	a.mu.Unlock()
+	if !ok {
+		return errAfAckNotPending
+	}
+
+	log.Infof("Notification of %s acknowledged by the AF: %s", key,
+		info.AckResult.AfStatus)
+	if p.ackURI == "" {
+		log.Infof("No ackUri given by the SMF for %s, acknowledgement not "+
+			"relayed", p.notifID)
+		return nil
+	}
+
+	gpsi := info.Gpsi
+	if gpsi == "" {
+		gpsi = p.gpsi
+	}
+	body := AckOfNotify{NotifID: p.notifID, AckResult: info.AckResult,
+		Gpsi: gpsi}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		if err := a.client.SmfNotifAck(a.ctx, p.ackURI, body); err != nil {
+			log.Errf("Failed to relay the acknowledgement of %s to %s: %v",
+				p.notifID, p.ackURI, err)
+			return
+		}
+		log.Infof("Acknowledgement of %s relayed to %s", p.notifID, p.ackURI)
+	}()
+	return nil
+}
+
+// stop drops the pending acknowledgements and waits for the relays to end
+func (a *afAcks) stop() {
+
+	a.mu.Lock()
+	for key, p := range a.pending {
+		p.timer.Stop()
+		delete(a.pending, key)
+	}
+	a.mu.Unlock()
+	a.wg.Wait()
+}
+
+// validateAfAckInfo checks the acknowledgement sent by the AF
+func validateAfAckInfo(info AfAckInfo) error {
+
+	switch info.AckResult.AfStatus {
+	case AfResultStatusSuccess, AfResultStatusTempCongest,
+		AfResultStatusRelocNoAllowed, AfResultStatusOther:
+		return nil
+	case "":
+		return errors.New("Missing ackResult afStatus")
+	}
+	return errors.New("Invalid ackResult afStatus " +
+		string(info.AckResult.AfStatus))
+}
+
+// AckTrafficInfluenceNotification : Receives the acknowledgement of the last
+// notification of the subscription sent by the AF after responding to the
+// notification
+func AckTrafficInfluenceNotification(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	if r.Body == nil {
+		sendCustomeErrorRspToAF(w, 400, "Failed to decode AfAckInfo")
+		return
+	}
+	info := AfAckInfo{}
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		log.Errf("Failed to decode AfAckInfo: %v", err)
+		sendCustomeErrorRspToAF(w, 400, "Failed to decode AfAckInfo")
+		return
+	}
+	if err := validateAfAckInfo(info); err != nil {
+		sendCustomeErrorRspToAF(w, 400, err.Error())
+		return
+	}
+
+	err := nefCtx.nef.acks.ack(vars["afId"], vars["subscriptionId"], info)
+	if err != nil {
+		sendCustomeErrorRspToAF(w, 404, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
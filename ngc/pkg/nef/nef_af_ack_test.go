@@ -0,0 +1,188 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// fakeSMFAck is an SMF server receiving the acknowledgements on its ackUri
+type fakeSMFAck struct {
+	mu   sync.Mutex
+	acks []ngcnef.AckOfNotify
+}
+
+func (s *fakeSMFAck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ack := ngcnef.AckOfNotify{}
+	if json.NewDecoder(r.Body).Decode(&ack) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.acks = append(s.acks, ack)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *fakeSMFAck) received() []ngcnef.AckOfNotify {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ngcnef.AckOfNotify{}, s.acks...)
+}
+
+var _ = Describe("Test NEF AF acknowledgement", func() {
+
+	var (
+		af     *fakeAFNotif
+		afSrv  *httptest.Server
+		smf    *fakeSMFAck
+		smfSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+	)
+
+	// sendUpPathChange sends the UP path change of the DNAI change type for
+	// the subscription 11111
+	sendUpPathChange := func(chgType ngcnef.DnaiChangeType) {
+
+		smfEv := ngcnef.NsmfEventExposureNotification{NotifID: "11131",
+			AckURI: ngcnef.URI(smfSrv.URL + "/ack"),
+			EventNotifs: []ngcnef.NsmEventNotification{{
+				Event: "UP_PATH_CH", Gpsi: "msisdn-1",
+				DnaiChgType: chgType}}}
+		data, err := json.Marshal(smfEv)
+		Expect(err).Should(BeNil())
+
+		req := httptest.NewRequest("POST", NefTIFApiPrefix+
+			"notification/upf", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+	}
+
+	// sendAck sends the acknowledgement of the subscription 11111
+	sendAck := func(info ngcnef.AfAckInfo) int {
+
+		data, err := json.Marshal(info)
+		Expect(err).Should(BeNil())
+		req := httptest.NewRequest("POST", NefTIFApiPrefix+
+			"AF_01/subscriptions/11111/ack", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr.Code
+	}
+
+	BeforeEach(func() {
+		af = &fakeAFNotif{}
+		afSrv = httptest.NewServer(af)
+		smf = &fakeSMFAck{}
+		smfSrv = httptest.NewServer(smf)
+
+		nef = newTestNef(NefTestCfgBasepath + "valid.json")
+		ctx = nef.ctx
+
+		// The subscription requests the EARLY notifications
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.NotificationDestination = ngcnef.Link(afSrv.URL)
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		afSrv.Close()
+		smfSrv.Close()
+	})
+
+	It("Will relay the acknowledgement sent in the response", func() {
+		af.ack = &ngcnef.AfAckInfo{AckResult: ngcnef.AfResultInfo{
+			AfStatus: ngcnef.AfResultStatusSuccess}}
+		sendUpPathChange(ngcnef.DnaiChangeTypeEarly)
+
+		Eventually(smf.received, 2*time.Second).Should(HaveLen(1))
+		ack := smf.received()[0]
+		Expect(ack.NotifID).Should(Equal("11131"))
+		Expect(ack.AckResult.AfStatus).Should(Equal(
+			ngcnef.AfResultStatusSuccess))
+		Expect(ack.Gpsi).Should(Equal(ngcnef.Gpsi("msisdn-1")))
+		Expect(af.receivedAckInds()).Should(Equal([]bool{true}))
+
+		// The notification is acknowledged only once
+		Expect(sendAck(*af.ack)).Should(Equal(http.StatusNotFound))
+	})
+
+	It("Will relay the acknowledgement sent after the response", func() {
+		sendUpPathChange(ngcnef.DnaiChangeTypeEarly)
+		Eventually(af.receivedAckInds, 2*time.Second).Should(Equal(
+			[]bool{true}))
+		Expect(smf.received()).Should(BeEmpty())
+
+		Expect(sendAck(ngcnef.AfAckInfo{AckResult: ngcnef.AfResultInfo{
+			AfStatus: "UNKNOWN"}})).Should(Equal(http.StatusBadRequest))
+		Expect(sendAck(ngcnef.AfAckInfo{AckResult: ngcnef.AfResultInfo{
+			AfStatus: ngcnef.AfResultStatusRelocNoAllowed}})).Should(Equal(
+			http.StatusNoContent))
+
+		Eventually(smf.received, 2*time.Second).Should(HaveLen(1))
+		ack := smf.received()[0]
+		Expect(ack.NotifID).Should(Equal("11131"))
+		Expect(ack.AckResult.AfStatus).Should(Equal(
+			ngcnef.AfResultStatusRelocNoAllowed))
+	})
+
+	It("Will not request the acknowledgement of a late notification",
+		func() {
+			sendUpPathChange(ngcnef.DnaiChangeTypeLate)
+			Eventually(af.receivedAckInds, 2*time.Second).Should(Equal(
+				[]bool{false}))
+
+			Expect(sendAck(ngcnef.AfAckInfo{AckResult: ngcnef.AfResultInfo{
+				AfStatus: ngcnef.AfResultStatusSuccess}})).Should(Equal(
+				http.StatusNotFound))
+			Expect(smf.received()).Should(BeEmpty())
+		})
+})
+
+var _ = Describe("Test NEF SMF acknowledgement client", func() {
+
+	It("Will only send the acknowledgements to absolute URIs", func() {
+		smf := &fakeSMFAck{}
+		srv := httptest.NewServer(smf)
+		defer srv.Close()
+
+		client, err := ngcnef.NewSmfAckClient(&ngcnef.Config{})
+		Expect(err).Should(BeNil())
+		ack := ngcnef.AckOfNotify{NotifID: "1"}
+		Expect(client.SmfNotifAck(context.Background(),
+			ngcnef.URI(srv.URL+"/ack"), ack)).Should(Succeed())
+		Expect(smf.received()).Should(HaveLen(1))
+
+		err = client.SmfNotifAck(context.Background(), "/ack", ack)
+		Expect(err).Should(MatchError(ContainSubstring("not an absolute")))
+		Expect(smf.received()).Should(HaveLen(1))
+	})
+})
@@ -11,14 +11,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
 )
 
-//const validCfgPath = "../../configs/nef.json"
+// const validCfgPath = "../../configs/nef.json"
 const testJSONPath = "../../test/nef/nef-cli-scripts/json/"
 const baseAPIURL = "http://localhost:8091/3gpp-traffic-influence/" +
 	"v1/AF_01/subscriptions"
@@ -56,22 +55,14 @@ func CreateReqForNEF(ctx context.Context, method string, subID string,
 
 var _ = Describe("Test NEF Server NB API's ", func() {
 	var ctx context.Context
-	var cancel func()
-
-	ctx, cancel = context.WithCancel(context.Background())
-	defer cancel()
+	var nef *testNef
 
 	Describe("Start the NEF Server: To be done to start NEF API testing",
 		func() {
 			It("Will init NefServer",
 				func() {
-					ctx, cancel = context.WithCancel(context.Background())
-					defer cancel()
-					go func() {
-						err := ngcnef.Run(ctx, NefTestCfgBasepath+"valid.json")
-						Expect(err).To(BeNil())
-					}()
-					time.Sleep(2 * time.Second)
+					nef = newTestNef(NefTestCfgBasepath + "valid.json")
+					ctx = nef.ctx
 				})
 		})
 
@@ -221,8 +212,7 @@ var _ = Describe("Test NEF Server NB API's ", func() {
 	Describe("End the NEF Server: To be done to end NEF API testing",
 		func() {
 			It("Will stop NefServer", func() {
-				cancel()
-				time.Sleep(2 * time.Second)
+				nef.stop()
 			})
 		})
 
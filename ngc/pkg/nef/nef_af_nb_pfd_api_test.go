@@ -11,7 +11,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -130,22 +129,14 @@ func CreateInvalidPFDReqForNEF(ctx context.Context, method string,
 
 var _ = Describe("Test NEF Server PFD NB API's ", func() {
 	var ctx context.Context
-	var cancel func()
-
-	ctx, cancel = context.WithCancel(context.Background())
-	defer cancel()
+	var nef *testNef
 
 	Describe("Start the NEF Server: To be done to start NEF PFD API testing",
 		func() {
 			It("Will init NefServer",
 				func() {
-					ctx, cancel = context.WithCancel(context.Background())
-					defer cancel()
-					go func() {
-						err := ngcnef.Run(ctx, NefTestCfgBasepath+"valid.json")
-						Expect(err).To(BeNil())
-					}()
-					time.Sleep(2 * time.Second)
+					nef = newTestNef(NefTestCfgBasepath + "valid.json")
+					ctx = nef.ctx
 				})
 		})
 
@@ -777,8 +768,7 @@ var _ = Describe("Test NEF Server PFD NB API's ", func() {
 	Describe("End the NEF Server: To be done to end NEF PFD API testing",
 		func() {
 			It("Will stop NefServer", func() {
-				cancel()
-				time.Sleep(2 * time.Second)
+				nef.stop()
 			})
 		})
 
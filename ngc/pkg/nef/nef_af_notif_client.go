@@ -9,16 +9,15 @@ package ngcnef
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"time"
 
-	"golang.org/x/net/http2"
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
 )
 
 /*  The AF Client is an implemenation of the AF Notification.
@@ -46,6 +45,19 @@ OAuth 2.0 would be supported
 // AfClient is an implementation of the Af Notification
 type AfClient struct {
 	af string
+	// CA certificate used to verify the https AF servers, reloaded when it
+	// changes
+	rootCAs *tlsmgr.Manager
+}
+
+// afNotifStatusError is returned when the AF answers a notification with an
+// error status code
+type afNotifStatusError struct {
+	code int
+}
+
+func (e *afNotifStatusError) Error() string {
+	return fmt.Sprintf("AF notification failed with status %d", e.code)
 }
 
 // NewAfClient creates a new Udr Client
@@ -53,57 +65,55 @@ func NewAfClient(cfg *Config) *AfClient {
 
 	c := &AfClient{}
 	c.af = "Af Notification Client"
+	if cfg != nil && cfg.HTTP2Config.AfClientCert != "" {
+		c.rootCAs = tlsmgr.New(tlsmgr.Config{
+			CAPaths:  []string{cfg.HTTP2Config.AfClientCert},
+			Interval: nefTLSInterval(cfg)})
+		// The certificate is loaded again when the file changes
+		if err := c.rootCAs.Reload(); err != nil {
+			log.Errf("Af Certification loading Error: %v", err)
+		}
+	}
 	return c
 }
 
 // AfNotificationUpfEvent is an implementation for sending upf event
 func (af *AfClient) AfNotificationUpfEvent(ctx context.Context,
-	afURI URI, body EventNotification) error {
+	afURI URI, body EventNotification) (*AfAckInfo, error) {
 
 	var client http.Client
 
-	nefCtx := ctx.Value(nefCtxKey("nefCtx")).(*nefContext)
-
 	log.Infof("AfNotificationUpfEvent uri :%s", afURI)
 
 	/* Check the url type - if its https or http */
 	u, err := url.Parse(string(afURI))
 	if err != nil {
 		log.Errf("AfNotification URl error :%v", err)
-		return err
+		return nil, err
 	}
 
-	// If https then load the certificate
+	// If https then verify the AF with the current CA certificate
 	if u.Scheme == "https" {
-		CACert, err1 := ioutil.ReadFile(nefCtx.cfg.HTTP2Config.AfClientCert)
-		if err1 != nil {
-			log.Errf("Af Certification loading Error: %v", err)
-			return err1
+		if af.rootCAs == nil {
+			log.Errf("Af Certification not configured")
+			return nil, errors.New("AF client certificate not configured")
 		}
-
-		CACertPool := x509.NewCertPool()
-		CACertPool.AppendCertsFromPEM(CACert)
-
 		client = http.Client{
-			Timeout: 15 * time.Second,
-			Transport: &http2.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs: CACertPool,
-				},
-			},
+			Timeout:   15 * time.Second,
+			Transport: af.rootCAs.HTTP2Transport(),
 		}
 	} else if u.Scheme == "http" {
 		client = http.Client{Timeout: 15 * time.Second}
 	} else {
 		log.Errf("Unsupported url scheme: %s", u.Scheme)
-		return errors.New("Unsupported url scheme")
+		return nil, errors.New("Unsupported url scheme")
 
 	}
 
 	requestBody, err := json.Marshal(body)
 	if err != nil {
 		log.Err(err)
-		return err
+		return nil, err
 	}
 	//log.Infof("POST body ==> \n %s", string(requestBody))
 	// Set request type as POST
@@ -117,7 +127,7 @@ func (af *AfClient) AfNotificationUpfEvent(ctx context.Context,
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Err(err)
-		return err
+		return nil, err
 	}
 	defer func() {
 		err = resp.Body.Close()
@@ -133,5 +143,26 @@ func (af *AfClient) AfNotificationUpfEvent(ctx context.Context,
 	log.Info("Body in the response =>")
 	respbody, err := ioutil.ReadAll(resp.Body)
 	log.Infof(string(respbody))
-	return err
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, &afNotifStatusError{code: resp.StatusCode}
+	}
+	return afNotifAck(resp.StatusCode, respbody), nil
+}
+
+// afNotifAck returns the acknowledgement sent by the AF in the response to
+// the notification, nil if there is none
+func afNotifAck(code int, body []byte) *AfAckInfo {
+
+	if code != http.StatusOK || len(body) == 0 {
+		return nil
+	}
+	ack := AfAckInfo{}
+	if err := json.Unmarshal(body, &ack); err != nil ||
+		ack.AckResult.AfStatus == "" {
+		return nil
+	}
+	return &ack
 }
@@ -0,0 +1,417 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Reliable delivery of the notifications towards the AF. The notifications
+ * are queued per destination and sent in order by one routine per
+ * destination, so the notifications of a subscription are never reordered.
+ * A failed notification is retried with an exponential backoff until it is
+ * delivered or gets too old, it is then moved to the dead letters from where
+ * it can be replayed through the admin API. */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default values of the notification delivery configuration
+const (
+	notifDefaultQueueSize      = 100
+	notifDefaultInitialBackoff = 500
+	notifDefaultMaxBackoff     = 30000
+	notifDefaultMaxAge         = 300
+	notifDefaultDeadLetterSize = 1000
+)
+
+// errNotifDeadLetterNotFound is returned for an unknown dead letter ID
+var errNotifDeadLetterNotFound = errors.New("Dead letter not found")
+
+// NotifConfig contains the settings of the AF notification delivery
+type NotifConfig struct {
+	// Maximum number of notifications queued per destination, 100 if not set
+	QueueSize int `json:"queueSize"`
+	// Delay in milliseconds before the first retry, 500 if not set. The delay
+	// is doubled after each failed attempt
+	InitialBackoff int `json:"initialBackoff"`
+	// Maximum delay in milliseconds between two attempts, 30000 if not set
+	MaxBackoff int `json:"maxBackoff"`
+	// Age in seconds after which an undelivered notification is moved to the
+	// dead letters, 300 if not set
+	MaxAge int `json:"maxAge"`
+	// Maximum number of dead letters kept, the oldest ones are dropped first.
+	// 1000 if not set
+	DeadLetterSize int `json:"deadLetterSize"`
+}
+
+// afNotif is a notification waiting to be delivered to the AF
+type afNotif struct {
+	id       string
+	afID     string
+	subID    string
+	dest     URI
+	body     EventNotification
+	created  time.Time
+	attempts int
+}
+
+// afNotifQueue contains the pending notifications of a destination, the
+// first one is the notification being delivered
+type afNotifQueue struct {
+	notifs []*afNotif
+}
+
+// afNotifier queues the notifications and delivers them to the AF
+type afNotifier struct {
+	ctx            context.Context
+	client         AfNotification
+	websocks       *afWebsocks
+	acks           *afAcks
+	store          NefStore
+	metrics        *nefMetrics
+	queueSize      int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxAge         time.Duration
+	deadLetterSize int
+	wg             sync.WaitGroup
+
+	mu     sync.Mutex
+	nextID uint64
+	queues map[URI]*afNotifQueue
+	// stopped is set by stop, no delivery routine is started afterwards
+	stopped bool
+	// dead contains the dead letters ordered by failure time
+	dead []NefStoreDeadLetter
+}
+
+// notifConfigValue returns the value or the default if the value is not set
+func notifConfigValue(value int, def int) int {
+	if value <= 0 {
+		return def
+	}
+	return value
+}
+
+// newAfNotifier creates the notifier delivering the notifications until ctx
+// is done. The dead letters are restored from the store and the
+// acknowledgements sent by the AF in the responses are given to acks. The
+// delivery results are recorded in m
+func newAfNotifier(ctx context.Context, cfg *Config, client AfNotification,
+	store NefStore, acks *afAcks, m *nefMetrics) (*afNotifier, error) {
+
+	state, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &afNotifier{ctx: ctx, client: client, websocks: newAfWebsocks(),
+		acks: acks, store: store, metrics: m,
+		queueSize: notifConfigValue(cfg.NotifConfig.QueueSize,
+			notifDefaultQueueSize),
+		initialBackoff: time.Duration(notifConfigValue(
+			cfg.NotifConfig.InitialBackoff,
+			notifDefaultInitialBackoff)) * time.Millisecond,
+		maxBackoff: time.Duration(notifConfigValue(cfg.NotifConfig.MaxBackoff,
+			notifDefaultMaxBackoff)) * time.Millisecond,
+		maxAge: time.Duration(notifConfigValue(cfg.NotifConfig.MaxAge,
+			notifDefaultMaxAge)) * time.Second,
+		deadLetterSize: notifConfigValue(cfg.NotifConfig.DeadLetterSize,
+			notifDefaultDeadLetterSize),
+		queues: make(map[URI]*afNotifQueue)}
+
+	for _, dl := range state.DeadLetters {
+		n.dead = append(n.dead, dl)
+		// The IDs of the restored dead letters must not be reused
+		if id, err := strconv.ParseUint(dl.ID, 10, 64); err == nil &&
+			id > n.nextID {
+			n.nextID = id
+		}
+	}
+	sort.Slice(n.dead, func(i, j int) bool {
+		return n.dead[i].Failed.Before(n.dead[j].Failed)
+	})
+	return n, nil
+}
+
+// enqueue adds a notification at the end of the queue of its destination.
+// The notification is moved to the dead letters if the queue is full
+func (n *afNotifier) enqueue(afID string, subID string, dest URI,
+	body EventNotification) {
+
+	n.mu.Lock()
+	n.nextID++
+	notif := &afNotif{id: strconv.FormatUint(n.nextID, 10), afID: afID,
+		subID: subID, dest: dest, body: body, created: time.Now()}
+	n.mu.Unlock()
+
+	n.push(notif)
+}
+
+// push adds the notification to the queue of its destination and starts the
+// delivery routine of the destination if it is not running
+func (n *afNotifier) push(notif *afNotif) {
+
+	n.mu.Lock()
+	if n.stopped || n.ctx.Err() != nil {
+		n.mu.Unlock()
+		n.deadLetter(notif, "NEF stopped")
+		return
+	}
+	q, ok := n.queues[notif.dest]
+	if !ok {
+		q = &afNotifQueue{}
+		n.queues[notif.dest] = q
+	}
+	if len(q.notifs) >= n.queueSize {
+		n.mu.Unlock()
+		n.deadLetter(notif, "Queue full")
+		return
+	}
+	q.notifs = append(q.notifs, notif)
+	if !ok {
+		n.wg.Add(1)
+		go n.run(notif.dest, q)
+	}
+	n.mu.Unlock()
+	log.Infof("AF notification %s queued for %s", notif.id, notif.dest)
+}
+
+// run delivers the notifications of the queue in order. The routine ends
+// once the queue is empty
+func (n *afNotifier) run(dest URI, q *afNotifQueue) {
+
+	defer n.wg.Done()
+	for {
+		n.mu.Lock()
+		if len(q.notifs) == 0 {
+			delete(n.queues, dest)
+			n.mu.Unlock()
+			return
+		}
+		notif := q.notifs[0]
+		n.mu.Unlock()
+
+		n.deliver(notif)
+
+		n.mu.Lock()
+		q.notifs = q.notifs[1:]
+		n.mu.Unlock()
+	}
+}
+
+// isAfNotifRetryable returns false if the AF rejected the notification, a
+// retry would fail again
+func isAfNotifRetryable(err error) bool {
+
+	se, ok := err.(*afNotifStatusError)
+	if !ok {
+		return true
+	}
+	return se.code >= 500 || se.code == http.StatusRequestTimeout ||
+		se.code == http.StatusTooManyRequests
+}
+
+// deliver sends the notification until it succeeds, the AF rejects it, it
+// gets too old or the NEF is stopped
+func (n *afNotifier) deliver(notif *afNotif) {
+
+	backoff := n.initialBackoff
+	for {
+		notif.attempts++
+		ack, err := n.send(notif)
+		n.metrics.observeNotifAttempt(err)
+		if err == nil {
+			log.Infof("AF notification %s delivered to %s after %d attempts",
+				notif.id, notif.dest, notif.attempts)
+			n.metrics.notifs.Inc(metricsNotifDelivered)
+			n.ack(notif, ack)
+			return
+		}
+		log.Errf("AF notification %s to %s attempt %d failed: %v", notif.id,
+			notif.dest, notif.attempts, err)
+
+		switch {
+		case n.ctx.Err() != nil:
+			n.deadLetter(notif, "NEF stopped: "+err.Error())
+			return
+		case !isAfNotifRetryable(err):
+			n.deadLetter(notif, err.Error())
+			return
+		case time.Since(notif.created)+backoff > n.maxAge:
+			n.deadLetter(notif, "Max age reached: "+err.Error())
+			return
+		}
+
+		select {
+		case <-n.ctx.Done():
+			n.deadLetter(notif, "NEF stopped: "+err.Error())
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > n.maxBackoff {
+			backoff = n.maxBackoff
+		}
+	}
+}
+
+// ack gives the acknowledgement sent by the AF in the response to the
+// notification to the pending acknowledgements
+func (n *afNotifier) ack(notif *afNotif, ack *AfAckInfo) {
+
+	if ack == nil || !notif.body.AfAckInd {
+		return
+	}
+	if err := validateAfAckInfo(*ack); err != nil {
+		log.Errf("AF notification %s acknowledgement ignored: %v", notif.id,
+			err)
+		return
+	}
+	if err := n.acks.ack(notif.afID, notif.subID, *ack); err != nil {
+		log.Errf("AF notification %s acknowledgement ignored: %v", notif.id,
+			err)
+	}
+}
+
+// send pushes the notification over the WebSocket of the subscription if the
+// AF connected one, otherwise it is sent to the notification destination. The
+// acknowledgement is returned if the AF sent one in the response
+func (n *afNotifier) send(notif *afNotif) (*AfAckInfo, error) {
+
+	err := n.websocks.send(notif.afID, notif.subID, notif.body)
+	if err == nil {
+		log.Infof("AF notification %s pushed over WebSocket", notif.id)
+		return nil, nil
+	}
+	if err != errAfWebsockNotConnected {
+		log.Errf("AF notification %s WebSocket push failed: %v", notif.id,
+			err)
+	}
+	return n.client.AfNotificationUpfEvent(n.ctx, notif.dest, notif.body)
+}
+
+// sendOnce sends the notification in a new routine without retrying it, done
+// is called with the result. It is used for the test notifications which
+// only check that the AF is reachable
+func (n *afNotifier) sendOnce(afID string, subID string, dest URI,
+	body EventNotification, done func(err error)) {
+
+	// The routine is added under the lock so that stop waits for it
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		done(errors.New("NEF stopped"))
+		return
+	}
+	n.wg.Add(1)
+	n.nextID++
+	notif := &afNotif{id: strconv.FormatUint(n.nextID, 10), afID: afID,
+		subID: subID, dest: dest, body: body, created: time.Now()}
+	n.mu.Unlock()
+
+	go func() {
+		defer n.wg.Done()
+
+		_, err := n.send(notif)
+		done(err)
+	}()
+}
+
+// deadLetter moves the notification to the dead letters, the oldest dead
+// letter is dropped if there are too many
+func (n *afNotifier) deadLetter(notif *afNotif, reason string) {
+
+	dl := NefStoreDeadLetter{ID: notif.id, AfID: notif.afID,
+		SubID: notif.subID, Destination: notif.dest,
+		Notification: notif.body, Created: notif.created,
+		Failed: time.Now(), Attempts: notif.attempts, Reason: reason}
+	log.Errf("AF notification %s to %s moved to dead letters: %s", dl.ID,
+		dl.Destination, reason)
+	n.metrics.notifs.Inc(metricsNotifDeadLetter)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.dead = append(n.dead, dl)
+	if err := n.store.PutDeadLetter(dl); err != nil {
+		log.Errf("Failed to store dead letter %s: %v", dl.ID, err)
+	}
+	for len(n.dead) > n.deadLetterSize {
+		log.Errf("Dropping dead letter %s", n.dead[0].ID)
+		if err := n.store.DeleteDeadLetter(n.dead[0].ID); err != nil {
+			log.Errf("Failed to delete dead letter %s: %v", n.dead[0].ID,
+				err)
+		}
+		n.dead = n.dead[1:]
+	}
+}
+
+// deadLetters returns the dead letters of the AF, or of all the AFs if afID
+// is empty, ordered by failure time
+func (n *afNotifier) deadLetters(afID string) []NefStoreDeadLetter {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	dls := []NefStoreDeadLetter{}
+	for _, dl := range n.dead {
+		if afID == "" || dl.AfID == afID {
+			dls = append(dls, dl)
+		}
+	}
+	return dls
+}
+
+// removeDeadLetter removes and returns the dead letter
+func (n *afNotifier) removeDeadLetter(id string) (NefStoreDeadLetter,
+	error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i, dl := range n.dead {
+		if dl.ID != id {
+			continue
+		}
+		n.dead = append(n.dead[:i:i], n.dead[i+1:]...)
+		if err := n.store.DeleteDeadLetter(id); err != nil {
+			log.Errf("Failed to delete dead letter %s: %v", id, err)
+		}
+		return dl, nil
+	}
+	return NefStoreDeadLetter{}, errNotifDeadLetterNotFound
+}
+
+// replay queues the dead letter again for delivery. The age of the
+// notification is reset
+func (n *afNotifier) replay(id string) error {
+
+	dl, err := n.removeDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	log.Infof("Replaying dead letter %s to %s", dl.ID, dl.Destination)
+	n.push(&afNotif{id: dl.ID, afID: dl.AfID, subID: dl.SubID,
+		dest: dl.Destination, body: dl.Notification, created: time.Now()})
+	return nil
+}
+
+// stop waits for the delivery routines to end and closes the WebSockets, the
+// context given at creation must be done. The routines move the pending
+// notifications to the dead letters
+func (n *afNotifier) stop() {
+
+	n.mu.Lock()
+	n.stopped = true
+	n.mu.Unlock()
+
+	n.wg.Wait()
+	n.websocks.closeAll()
+}
@@ -0,0 +1,388 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// fakeAFNotif is an AF server receiving the UP path change notifications
+type fakeAFNotif struct {
+	mu sync.Mutex
+	// code is returned for all the requests if set
+	code int
+	// failures is the number of requests to be answered with 503
+	failures int
+	attempts int
+	// gpsis of the notifications received in order
+	gpsis []ngcnef.Gpsi
+	// afTransIDs of the notifications received in order
+	afTransIDs []string
+	// events of the notifications received in order
+	events []ngcnef.SubscribedEvent
+	// ack is sent in the response to the notifications with afAckInd if set
+	ack *ngcnef.AfAckInfo
+	// afAckInds of the notifications received in order
+	afAckInds []bool
+}
+
+func (a *fakeAFNotif) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.attempts++
+	if a.code != 0 {
+		w.WriteHeader(a.code)
+		return
+	}
+	if a.failures > 0 {
+		a.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	ev := ngcnef.EventNotification{}
+	if json.NewDecoder(r.Body).Decode(&ev) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	a.gpsis = append(a.gpsis, ev.Gpsi)
+	a.afTransIDs = append(a.afTransIDs, ev.AfTransID)
+	a.events = append(a.events, ev.SubscribedEvent)
+	a.afAckInds = append(a.afAckInds, ev.AfAckInd)
+	if a.ack != nil && ev.AfAckInd {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a.ack)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *fakeAFNotif) received() []ngcnef.Gpsi {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ngcnef.Gpsi{}, a.gpsis...)
+}
+
+func (a *fakeAFNotif) receivedTransIDs() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string{}, a.afTransIDs...)
+}
+
+func (a *fakeAFNotif) receivedEvents() []ngcnef.SubscribedEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]ngcnef.SubscribedEvent{}, a.events...)
+}
+
+func (a *fakeAFNotif) receivedAckInds() []bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]bool{}, a.afAckInds...)
+}
+
+func (a *fakeAFNotif) setCode(code int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.code = code
+}
+
+// sendSmfNotif sends the UP path change notification of the subscription
+// 11111 for the UE
+func sendSmfNotif(ctx context.Context, gpsi string) {
+
+	data, err := ioutil.ReadFile(testJSONPath + "SMF_NEF_NOTIF_01.json")
+	Expect(err).Should(BeNil())
+	smfEv := ngcnef.NsmfEventExposureNotification{}
+	Expect(json.Unmarshal(data, &smfEv)).Should(Succeed())
+	smfEv.EventNotifs[0].Gpsi = ngcnef.Gpsi(gpsi)
+	data, err = json.Marshal(smfEv)
+	Expect(err).Should(BeNil())
+
+	req := httptest.NewRequest("POST", NefTIFApiPrefix+"notification/upf",
+		bytes.NewBuffer(data))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+	Expect(rr.Code).Should(Equal(http.StatusOK))
+}
+
+// getDeadLetters returns the dead letters listed by the admin API
+func getDeadLetters(ctx context.Context) []ngcnef.NefStoreDeadLetter {
+
+	req := httptest.NewRequest("GET",
+		"http://127.0.0.1:18071/nef-admin/v1/notifications/dead-letters", nil)
+	rr := httptest.NewRecorder()
+	ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+	Expect(rr.Code).Should(Equal(http.StatusOK))
+
+	var dls []ngcnef.NefStoreDeadLetter
+	Expect(json.Unmarshal(rr.Body.Bytes(), &dls)).Should(Succeed())
+	return dls
+}
+
+var _ = Describe("Test NEF AF notification delivery", func() {
+
+	var (
+		af      *fakeAFNotif
+		afSrv   *httptest.Server
+		ctx     context.Context
+		nef     *testNef
+		tmpDir  string
+		cfgPath string
+	)
+
+	startNef := func() {
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	}
+
+	BeforeEach(func() {
+		af = &fakeAFNotif{}
+		afSrv = httptest.NewServer(af)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-notif")
+		Expect(err).Should(BeNil())
+		cfgPath = writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["StoreConfig"] = map[string]interface{}{"type": "file",
+					"path": filepath.Join(tmpDir, "nef.journal")}
+				cfg["NotifConfig"] = map[string]interface{}{
+					"initialBackoff": 50, "maxBackoff": 200, "maxAge": 2}
+			})
+		startNef()
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.NotificationDestination = ngcnef.Link(afSrv.URL)
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		afSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will retry the notifications in order until delivered", func() {
+		af.mu.Lock()
+		af.failures = 3
+		af.mu.Unlock()
+
+		sendSmfNotif(ctx, "msisdn-1")
+		sendSmfNotif(ctx, "msisdn-2")
+		sendSmfNotif(ctx, "msisdn-3")
+
+		Eventually(af.received, 3*time.Second).Should(Equal(
+			[]ngcnef.Gpsi{"msisdn-1", "msisdn-2", "msisdn-3"}))
+		Expect(getDeadLetters(ctx)).Should(BeEmpty())
+	})
+
+	It("Will move an old notification to the dead letters", func() {
+		af.setCode(http.StatusServiceUnavailable)
+		sendSmfNotif(ctx, "msisdn-1")
+
+		var dls []ngcnef.NefStoreDeadLetter
+		Eventually(func() []ngcnef.NefStoreDeadLetter {
+			dls = getDeadLetters(ctx)
+			return dls
+		}, 4*time.Second).Should(HaveLen(1))
+		Expect(dls[0].AfID).Should(Equal("AF_01"))
+		Expect(dls[0].SubID).Should(Equal("11111"))
+		Expect(string(dls[0].Destination)).Should(Equal(afSrv.URL))
+		Expect(dls[0].Notification.Gpsi).Should(Equal(ngcnef.Gpsi("msisdn-1")))
+		Expect(dls[0].Attempts).Should(BeNumerically(">", 1))
+		Expect(af.received()).Should(BeEmpty())
+
+		// Replay the notification once the AF is back
+		af.setCode(0)
+		req := httptest.NewRequest("POST", "http://127.0.0.1:18071/nef-admin/"+
+			"v1/notifications/dead-letters/"+dls[0].ID+"/replay", nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNoContent))
+
+		Eventually(af.received, 2*time.Second).Should(Equal(
+			[]ngcnef.Gpsi{"msisdn-1"}))
+		Expect(getDeadLetters(ctx)).Should(BeEmpty())
+
+		// The dead letter is not present anymore
+		rr = httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNotFound))
+	})
+
+	It("Will keep a rejected notification across a restart", func() {
+		af.setCode(http.StatusBadRequest)
+		sendSmfNotif(ctx, "msisdn-1")
+
+		Eventually(func() []ngcnef.NefStoreDeadLetter {
+			return getDeadLetters(ctx)
+		}, 2*time.Second).Should(HaveLen(1))
+		// A rejected notification is not retried
+		Expect(getDeadLetters(ctx)[0].Attempts).Should(Equal(1))
+
+		nef.stop()
+		startNef()
+
+		dls := getDeadLetters(ctx)
+		Expect(dls).Should(HaveLen(1))
+		Expect(dls[0].Notification.Gpsi).Should(Equal(ngcnef.Gpsi("msisdn-1")))
+
+		req := httptest.NewRequest("DELETE", "http://127.0.0.1:18071/"+
+			"nef-admin/v1/notifications/dead-letters/"+dls[0].ID, nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNoContent))
+		Expect(getDeadLetters(ctx)).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Test NEF AF test notification", func() {
+
+	var (
+		af        *fakeAFNotif
+		afSrv     *httptest.Server
+		ctx       context.Context
+		nef       *testNef
+		tmpDir    string
+		storePath string
+	)
+
+	// tiWithTestNotif returns the traffic influence data of the file
+	// requesting a test notification
+	tiWithTestNotif := func(file string, afTransID string) []byte {
+
+		data, err := ioutil.ReadFile(testJSONPath + file)
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.AfTransID = afTransID
+		ti.NotificationDestination = ngcnef.Link(afSrv.URL)
+		ti.RequestTestNotification = true
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+		return data
+	}
+
+	// storedTestNotif stops the NEF and returns the test notification result
+	// persisted for the subscription 11111
+	storedTestNotif := func() *ngcnef.NefTestNotifResult {
+
+		nef.stop()
+		store, err := ngcnef.NewNefFileStore(storePath)
+		Expect(err).Should(BeNil())
+		defer store.Close()
+		state, err := store.Load()
+		Expect(err).Should(BeNil())
+		return state.Subs["AF_01"]["11111"].TestNotif
+	}
+
+	BeforeEach(func() {
+		af = &fakeAFNotif{}
+		afSrv = httptest.NewServer(af)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-test-notif")
+		Expect(err).Should(BeNil())
+		storePath = filepath.Join(tmpDir, "nef.journal")
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["StoreConfig"] = map[string]interface{}{"type": "file",
+					"path": storePath}
+				cfg["NotifConfig"] = map[string]interface{}{
+					"initialBackoff": 50, "maxBackoff": 200, "maxAge": 2}
+			})
+
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		afSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will send a test notification on create", func() {
+		rr, req := CreateReqForNEF(ctx, "POST", "",
+			tiWithTestNotif("AF_NEF_POST_01.json", "tr-test-1"))
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+		Eventually(af.receivedTransIDs, 2*time.Second).Should(Equal(
+			[]string{"tr-test-1"}))
+		Expect(getDeadLetters(ctx)).Should(BeEmpty())
+
+		res := storedTestNotif()
+		Expect(res).ShouldNot(BeNil())
+		Expect(res.Delivered).Should(BeTrue())
+		Expect(res.Reason).Should(BeEmpty())
+	})
+
+	It("Will record a failed test notification and retest on update",
+		func() {
+			af.setCode(http.StatusBadRequest)
+			rr, req := CreateReqForNEF(ctx, "POST", "",
+				tiWithTestNotif("AF_NEF_POST_UDR_01.json", "tr-test-1"))
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+			// The test notification is neither retried nor dead lettered
+			time.Sleep(500 * time.Millisecond)
+			af.mu.Lock()
+			Expect(af.attempts).Should(Equal(1))
+			af.mu.Unlock()
+			Expect(getDeadLetters(ctx)).Should(BeEmpty())
+
+			af.setCode(0)
+			rr, req = CreateReqForNEF(ctx, "PUT", "11111",
+				tiWithTestNotif("AF_NEF_PUT_UDR_01.json", "tr-test-2"))
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+			Eventually(af.receivedTransIDs, 2*time.Second).Should(Equal(
+				[]string{"tr-test-2"}))
+
+			res := storedTestNotif()
+			Expect(res).ShouldNot(BeNil())
+			Expect(res.Delivered).Should(BeTrue())
+		})
+
+	It("Will record the reason of a failed test notification", func() {
+		af.setCode(http.StatusNotFound)
+		rr, req := CreateReqForNEF(ctx, "POST", "",
+			tiWithTestNotif("AF_NEF_POST_01.json", "tr-test-1"))
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+		time.Sleep(500 * time.Millisecond)
+
+		res := storedTestNotif()
+		Expect(res).ShouldNot(BeNil())
+		Expect(res.Delivered).Should(BeFalse())
+		Expect(res.Reason).Should(ContainSubstring("404"))
+	})
+})
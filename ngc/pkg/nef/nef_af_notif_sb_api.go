@@ -14,8 +14,9 @@ import "context"
 type AfNotification interface {
 
 	// AAfNotificationUpfEvent sends the UPF event through POST method
-	// towards the AF
+	// towards the AF. The acknowledgement is returned if the AF sent one in
+	// the response
 	AfNotificationUpfEvent(ctx context.Context,
 		afURI URI,
-		body EventNotification) error
+		body EventNotification) (*AfAckInfo, error)
 }
@@ -0,0 +1,211 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* WebSocket delivery of the notifications towards the AF (3GPP 29.122
+ * 5.2.5.4). When the AF requests it in the websockNotifConfig of the
+ * subscription, the NEF allocates a WebSocket URI for the subscription. The
+ * AF connects to it and the notifications of the subscription are pushed over
+ * the socket. The notificationDestination is used while no socket is
+ * connected. */
+
+package ngcnef
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+)
+
+// afWebsockWriteTimeout is the maximum time to push a notification
+const afWebsockWriteTimeout = 5 * time.Second
+
+// afWebsockURISuffix is appended to the subscription URI to build the
+// WebSocket URI
+const afWebsockURISuffix = "/websocket"
+
+// errAfWebsockNotConnected is returned if the AF has no socket connected for
+// the subscription
+var errAfWebsockNotConnected = errors.New("WebSocket not connected")
+
+// afWebsocks contains the sockets connected by the AFs per subscription
+type afWebsocks struct {
+	mu    sync.Mutex
+	conns map[string]*websocket.Conn
+}
+
+func newAfWebsocks() *afWebsocks {
+	return &afWebsocks{conns: make(map[string]*websocket.Conn)}
+}
+
+// afSubKey returns the key of the subscription of the AF
+func afSubKey(afID string, subID string) string {
+	return afID + "/" + subID
+}
+
+// afWebsockNotifConfig returns the WebSocket configuration of the
+// subscription located at loc. The WebSocket URI is allocated by the NEF only
+// if the AF requested it
+func afWebsockNotifConfig(cfg WebsockNotifConfig,
+	loc string) WebsockNotifConfig {
+
+	cfg.WebsocketURI = ""
+	if !cfg.RequestWebsocketURI {
+		return cfg
+	}
+
+	uri := loc + afWebsockURISuffix
+	if strings.HasPrefix(uri, "https://") {
+		uri = "wss://" + strings.TrimPrefix(uri, "https://")
+	} else {
+		uri = "ws://" + strings.TrimPrefix(uri, "http://")
+	}
+	cfg.WebsocketURI = Link(uri)
+	return cfg
+}
+
+// attach makes conn the socket of the subscription, the previous socket of
+// the subscription is closed
+func (ws *afWebsocks) attach(afID string, subID string,
+	conn *websocket.Conn) {
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	key := afSubKey(afID, subID)
+	if old, ok := ws.conns[key]; ok {
+		log.Infof("Replacing WebSocket of %s", key)
+		_ = old.Close()
+	}
+	ws.conns[key] = conn
+	log.Infof("WebSocket of %s connected", key)
+}
+
+// detach removes conn if it is still the socket of the subscription
+func (ws *afWebsocks) detach(afID string, subID string,
+	conn *websocket.Conn) {
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	key := afSubKey(afID, subID)
+	if ws.conns[key] == conn {
+		delete(ws.conns, key)
+		log.Infof("WebSocket of %s disconnected", key)
+	}
+	_ = conn.Close()
+}
+
+// close closes the socket of the subscription if connected
+func (ws *afWebsocks) close(afID string, subID string) {
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	key := afSubKey(afID, subID)
+	if conn, ok := ws.conns[key]; ok {
+		delete(ws.conns, key)
+		_ = conn.Close()
+		log.Infof("WebSocket of %s closed", key)
+	}
+}
+
+// closeAll closes all the sockets
+func (ws *afWebsocks) closeAll() {
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for key, conn := range ws.conns {
+		_ = conn.Close()
+		delete(ws.conns, key)
+	}
+}
+
+// send pushes the notification over the socket of the subscription. The
+// socket is closed if the notification cannot be written
+func (ws *afWebsocks) send(afID string, subID string,
+	body EventNotification) error {
+
+	ws.mu.Lock()
+	conn, ok := ws.conns[afSubKey(afID, subID)]
+	ws.mu.Unlock()
+	if !ok {
+		return errAfWebsockNotConnected
+	}
+
+	err := conn.SetWriteDeadline(time.Now().Add(afWebsockWriteTimeout))
+	if err == nil {
+		err = websocket.JSON.Send(conn, body)
+	}
+	if err != nil {
+		ws.detach(afID, subID, conn)
+		return err
+	}
+	return nil
+}
+
+// afGetWebsockSub returns true if the subscription exists and requested the
+// WebSocket delivery, the caller must hold the AF lock
+func (af *afData) afGetWebsockSub(subID string) bool {
+
+	sub, ok := af.subs[subID]
+	return ok && !sub.pending &&
+		sub.ti.WebsockNotifConfig.WebsocketURI != ""
+}
+
+// ConnectTrafficInfluenceWebsocket : Accepts the WebSocket connection of the
+// AF on the WebSocket URI allocated for the subscription. The notifications
+// of the subscription are pushed over the socket until it is closed
+func ConnectTrafficInfluenceWebsocket(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	nef := &nefCtx.nef
+	vars := mux.Vars(r)
+	afID, subID := vars["afId"], vars["subscriptionId"]
+
+	af, err := nef.nefGetAf(afID)
+	if err != nil {
+		sendCustomeErrorRspToAF(w, 404, "Failed to find AF records")
+		return
+	}
+	af.mu.Lock()
+	found := af.afGetWebsockSub(subID)
+	af.mu.Unlock()
+	if !found {
+		sendCustomeErrorRspToAF(w, 404, "WebSocket URI not found")
+		return
+	}
+
+	// The origin is not checked, the AF is not a browser
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		// Clear the deadlines of the HTTP server on the hijacked connection
+		_ = conn.SetDeadline(time.Time{})
+
+		// The subscription may have been deleted during the handshake
+		af.mu.Lock()
+		if !af.afGetWebsockSub(subID) {
+			af.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		nef.notifier.websocks.attach(afID, subID, conn)
+		af.mu.Unlock()
+
+		// The AF is not expected to send anything, the messages are read
+		// only to detect the closure of the socket
+		var msg []byte
+		for {
+			if err := websocket.Message.Receive(conn, &msg); err != nil {
+				break
+			}
+		}
+		nef.notifier.websocks.detach(afID, subID, conn)
+	}}
+	server.ServeHTTP(w, r)
+}
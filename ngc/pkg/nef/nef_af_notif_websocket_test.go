@@ -0,0 +1,149 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+	"golang.org/x/net/websocket"
+)
+
+// dialNefWebsocket connects to the WebSocket URI allocated by the NEF
+func dialNefWebsocket(uri ngcnef.Link) *websocket.Conn {
+
+	conn, err := websocket.Dial(string(uri), "", "http://localhost/")
+	Expect(err).Should(BeNil())
+	// Let the NEF attach the socket to the subscription
+	time.Sleep(200 * time.Millisecond)
+	return conn
+}
+
+// receiveWebsockNotif returns the next notification pushed over the socket
+func receiveWebsockNotif(conn *websocket.Conn) ngcnef.EventNotification {
+
+	ev := ngcnef.EventNotification{}
+	Expect(conn.SetReadDeadline(time.Now().Add(2 * time.Second))).Should(
+		Succeed())
+	Expect(websocket.JSON.Receive(conn, &ev)).Should(Succeed())
+	return ev
+}
+
+var _ = Describe("Test NEF AF notification over WebSocket", func() {
+
+	var (
+		af     *fakeAFNotif
+		afSrv  *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// createSub creates the subscription 11111 and returns it
+	createSub := func(requestWebsocket bool) ngcnef.TrafficInfluSub {
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.NotificationDestination = ngcnef.Link(afSrv.URL)
+		ti.WebsockNotifConfig.RequestWebsocketURI = requestWebsocket
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+		Expect(json.Unmarshal(rr.Body.Bytes(), &ti)).Should(Succeed())
+		return ti
+	}
+
+	BeforeEach(func() {
+		af = &fakeAFNotif{}
+		afSrv = httptest.NewServer(af)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-websock")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				// The sockets are connected on the HTTP 1.1 endpoint
+				cfg["HTTP2Config"] = map[string]interface{}{"endpoint": ""}
+				cfg["NotifConfig"] = map[string]interface{}{
+					"initialBackoff": 50, "maxBackoff": 200, "maxAge": 2}
+			})
+
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		afSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will push the notifications over the connected WebSocket", func() {
+		ti := createSub(true)
+		Expect(string(ti.WebsockNotifConfig.WebsocketURI)).Should(Equal(
+			"ws://localhost:8091/3gpp-traffic-influence/v1/AF_01/" +
+				"subscriptions/11111/websocket"))
+
+		// The notification destination is used until the AF connects
+		sendSmfNotif(ctx, "msisdn-1")
+		Eventually(af.received, 2*time.Second).Should(Equal(
+			[]ngcnef.Gpsi{"msisdn-1"}))
+
+		conn := dialNefWebsocket(ti.WebsockNotifConfig.WebsocketURI)
+		sendSmfNotif(ctx, "msisdn-2")
+		ev := receiveWebsockNotif(conn)
+		Expect(ev.Gpsi).Should(Equal(ngcnef.Gpsi("msisdn-2")))
+		Expect(ev.SubscribedEvent).Should(Equal(
+			ngcnef.SubscribedEvent("UP_PATH_CHANGE")))
+
+		// Fall back to the notification destination once disconnected
+		Expect(conn.Close()).Should(Succeed())
+		time.Sleep(200 * time.Millisecond)
+		sendSmfNotif(ctx, "msisdn-3")
+		Eventually(af.received, 2*time.Second).Should(Equal(
+			[]ngcnef.Gpsi{"msisdn-1", "msisdn-3"}))
+	})
+
+	It("Will close the WebSocket when the subscription is deleted", func() {
+		ti := createSub(true)
+		conn := dialNefWebsocket(ti.WebsockNotifConfig.WebsocketURI)
+		defer conn.Close()
+
+		rr, req := CreateReqForNEF(ctx, "DELETE", "11111", nil)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNoContent))
+
+		var msg []byte
+		Expect(conn.SetReadDeadline(time.Now().Add(2 * time.Second))).Should(
+			Succeed())
+		// The socket is closed by the NEF before the read deadline
+		Expect(websocket.Message.Receive(conn, &msg)).Should(Equal(io.EOF))
+	})
+
+	It("Will not allocate a WebSocket URI if not requested", func() {
+		ti := createSub(false)
+		Expect(ti.WebsockNotifConfig.WebsocketURI).Should(BeEmpty())
+
+		req := httptest.NewRequest("GET", NefTIFApiPrefix+
+			"AF_01/subscriptions/11111/websocket", nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,272 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Catalogue of the AF services. The afServiceId of the traffic influence
+ * subscriptions is translated into the DNN and S-NSSAI sent to the PCF and
+ * UDR, and restricted to the DNAIs and owner AFs of the service. The
+ * catalogue is read from the afServiceIDs of the configuration, validated at
+ * start and reloaded on SIGHUP or when the configuration file changes. An
+ * invalid catalogue is rejected and the previous one is kept. */
+
+package ngcnef
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// afServiceSdPattern is the pattern of the slice differentiator
+var afServiceSdPattern = regexp.MustCompile("^[A-Fa-f0-9]{6}$")
+
+// AfService maps an afServiceId to the DNN and S-NSSAI of the service
+type AfService struct {
+	ID     string `json:"id"`
+	Dnn    Dnn    `json:"dnn"`
+	Snssai Snssai `json:"snssai"`
+	// DNAIs allowed in the traffic routes, any if empty
+	Dnais []Dnai `json:"dnais,omitempty"`
+	// AFs allowed to use the service, any if empty
+	AfIDs []string `json:"afIds,omitempty"`
+}
+
+// afServiceCatalog contains the AF services by ID
+type afServiceCatalog struct {
+	mu       sync.RWMutex
+	services map[string]AfService
+}
+
+// validateAfService checks that the service has an ID, a DNN and a valid
+// S-NSSAI
+func validateAfService(s AfService) error {
+
+	switch {
+	case s.ID == "":
+		return errors.New("Missing AF service id")
+	case s.Dnn == "":
+		return errors.New("AF service " + s.ID + " has no dnn")
+	case s.Snssai.Sd != "" && !afServiceSdPattern.MatchString(s.Snssai.Sd):
+		return errors.New("AF service " + s.ID + " has an invalid sd " +
+			s.Snssai.Sd)
+	}
+	for _, d := range s.Dnais {
+		if d == "" {
+			return errors.New("AF service " + s.ID + " has an empty dnai")
+		}
+	}
+	for _, id := range s.AfIDs {
+		if id == "" {
+			return errors.New("AF service " + s.ID + " has an empty afId")
+		}
+	}
+	return nil
+}
+
+// newAfServiceMap validates the services and returns them by ID
+func newAfServiceMap(services []AfService) (map[string]AfService, error) {
+
+	m := make(map[string]AfService)
+	for _, s := range services {
+		if err := validateAfService(s); err != nil {
+			return nil, err
+		}
+		if _, ok := m[s.ID]; ok {
+			return nil, errors.New("Duplicate AF service " + s.ID)
+		}
+		m[s.ID] = s
+	}
+	return m, nil
+}
+
+// newAfServiceCatalog creates the catalogue with the services of the
+// configuration
+func newAfServiceCatalog(services []AfService) (*afServiceCatalog, error) {
+
+	m, err := newAfServiceMap(services)
+	if err != nil {
+		return nil, err
+	}
+	return &afServiceCatalog{services: m}, nil
+}
+
+// list returns the services ordered by ID
+func (c *afServiceCatalog) list() []AfService {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	services := []AfService{}
+	for _, s := range c.services {
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+	return services
+}
+
+// get returns the service
+func (c *afServiceCatalog) get(id string) (AfService, bool) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s, ok := c.services[id]
+	return s, ok
+}
+
+// replace validates the services and replaces the catalogue with them. The
+// numbers of services added, removed and changed are returned
+func (c *afServiceCatalog) replace(services []AfService) (added int,
+	removed int, changed int, err error) {
+
+	m, err := newAfServiceMap(services)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, s := range m {
+		old, ok := c.services[id]
+		if !ok {
+			added++
+		} else if !afServiceEqual(old, s) {
+			changed++
+		}
+	}
+	for id := range c.services {
+		if _, ok := m[id]; !ok {
+			removed++
+		}
+	}
+	c.services = m
+	return added, removed, changed, nil
+}
+
+// afServiceEqual returns true if the services are the same
+func afServiceEqual(a AfService, b AfService) bool {
+
+	if a.ID != b.ID || a.Dnn != b.Dnn || a.Snssai != b.Snssai ||
+		len(a.Dnais) != len(b.Dnais) || len(a.AfIDs) != len(b.AfIDs) {
+		return false
+	}
+	for i := range a.Dnais {
+		if a.Dnais[i] != b.Dnais[i] {
+			return false
+		}
+	}
+	for i := range a.AfIDs {
+		if a.AfIDs[i] != b.AfIDs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateAfServiceID checks that the service of the subscription is known,
+// allowed for the AF and for the DNAIs of the traffic routes
+func validateAfServiceID(nefCtx *nefContext, afID string,
+	ti TrafficInfluSub) (rsp nefSBRspData, status bool) {
+
+	if ti.AfServiceID == "" {
+		return rsp, true
+	}
+
+	invalid := func(title string, param string, reason string) (
+		nefSBRspData, bool) {
+		rsp.errorCode = 400
+		rsp.pd.Title = title
+		rsp.pd.Status = 400
+		rsp.pd.InvalidParams = []InvalidParam{{Param: param, Reason: reason}}
+		return rsp, false
+	}
+
+	s, ok := nefCtx.nef.afServices.get(ti.AfServiceID)
+	if !ok {
+		return invalid("Unknown afServiceId", "/afServiceId",
+			"Unknown AF service "+ti.AfServiceID)
+	}
+	if len(s.AfIDs) > 0 && !afServiceHasAf(s, afID) {
+		return invalid("afServiceId not allowed", "/afServiceId",
+			"AF service "+s.ID+" not allowed for AF "+afID)
+	}
+	if len(s.Dnais) == 0 {
+		return rsp, true
+	}
+	for i, route := range ti.TrafficRoutes {
+		if !afServiceHasDnai(s, route.Dnai) {
+			return invalid("DNAI not allowed for afServiceId",
+				"/trafficRoutes/"+strconv.Itoa(i)+"/dnai",
+				"DNAI "+string(route.Dnai)+" not allowed for AF service "+s.ID)
+		}
+	}
+	return rsp, true
+}
+
+// afServiceHasAf returns true if the AF owns the service
+func afServiceHasAf(s AfService, afID string) bool {
+
+	for _, id := range s.AfIDs {
+		if id == afID {
+			return true
+		}
+	}
+	return false
+}
+
+// afServiceHasDnai returns true if the DNAI is allowed for the service
+func afServiceHasDnai(s AfService, dnai Dnai) bool {
+
+	for _, d := range s.Dnais {
+		if d == dnai {
+			return true
+		}
+	}
+	return false
+}
+
+// getAfServiceData returns the DNN and S-NSSAI of the subscription, those
+// of its AF service or those sent by the AF
+func getAfServiceData(nefCtx *nefContext, ti TrafficInfluSub) (Dnn, Snssai) {
+
+	if ti.AfServiceID == "" {
+		return ti.Dnn, ti.Snssai
+	}
+	s, ok := nefCtx.nef.afServices.get(ti.AfServiceID)
+	if !ok {
+		// The service is validated when received from the AF, it may have
+		// been removed by a reload since then
+		log.Errf("AF service %s not found", ti.AfServiceID)
+		return ti.Dnn, ti.Snssai
+	}
+	return s.Dnn, s.Snssai
+}
+
+// ListAfServices : Returns the AF services
+func ListAfServices(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	sendAdminJSONRsp(w, nefCtx.nef.afServices.list())
+}
+
+// ReadAfService : Returns an AF service
+func ReadAfService(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	s, ok := nefCtx.nef.afServices.get(vars["afServiceId"])
+	if !ok {
+		sendCustomeErrorRspToAF(w, 404, "AF service not found")
+		return
+	}
+	sendAdminJSONRsp(w, s)
+}
@@ -0,0 +1,219 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// pcfDnnSnssai returns the DNN and S-NSSAI of the only app session of the
+// PCF
+func pcfDnnSnssai(p *fakePCF) (ngcnef.Dnn, ngcnef.Snssai) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	Expect(p.sessions).Should(HaveLen(1))
+	for _, asc := range p.sessions {
+		return asc.AscReqData.Dnn, asc.AscReqData.SliceInfo
+	}
+	return "", ngcnef.Snssai{}
+}
+
+var _ = Describe("Test NEF AF services", func() {
+
+	var (
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// writeConfig writes the configuration with the AF services and watch
+	// interval
+	writeConfig := func(services []ngcnef.AfService, interval int) string {
+
+		return writeNefTestConfig(tmpDir, func(cfg map[string]interface{}) {
+			cfg["PCFConfig"] = map[string]interface{}{
+				"type": "http", "apiRoot": pcfSrv.URL}
+			cfg["afServiceIDs"] = services
+			cfg["configWatchInterval"] = interval
+		})
+	}
+
+	startNef := func(cfgPath string) {
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	}
+
+	// createSub creates a subscription for the service and traffic routes
+	createSub := func(serviceID string,
+		routes []ngcnef.RouteToLocation) *httptest.ResponseRecorder {
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.AfServiceID = serviceID
+		ti.TrafficRoutes = routes
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	// readService reads the service through the admin API, its DNN is
+	// returned if found
+	readService := func(serviceID string) ngcnef.Dnn {
+
+		req := httptest.NewRequest("GET",
+			"http://127.0.0.1:18071/nef-admin/v1/af-services/"+serviceID, nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+		if rr.Code == http.StatusNotFound {
+			return ""
+		}
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		s := ngcnef.AfService{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &s)).Should(Succeed())
+		return s.Dnn
+	}
+
+	// expectInvalid checks the response of a rejected subscription
+	expectInvalid := func(rr *httptest.ResponseRecorder, param string) {
+
+		Expect(rr.Code).Should(Equal(http.StatusBadRequest))
+		pd := ngcnef.ProblemDetails{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &pd)).Should(Succeed())
+		Expect(pd.InvalidParams).Should(HaveLen(1))
+		Expect(pd.InvalidParams[0].Param).Should(Equal(param))
+	}
+
+	services := []ngcnef.AfService{
+		{ID: "ServiceId01", Dnn: "edge",
+			Snssai: ngcnef.Snssai{Sst: 1, Sd: "0000A1"}},
+		{ID: "ServiceId02", Dnn: "edge", AfIDs: []string{"AF_02"}},
+		{ID: "ServiceId03", Dnn: "edge", Dnais: []ngcnef.Dnai{"dnai-1"}}}
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-af-service")
+		Expect(err).Should(BeNil())
+		nef = nil
+	})
+
+	AfterEach(func() {
+		if nef != nil {
+			nef.stop()
+		}
+		pcfSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will send the DNN and S-NSSAI of the service", func() {
+		startNef(writeConfig(services, -1))
+
+		Expect(createSub("ServiceId01", nil).Code).Should(Equal(
+			http.StatusCreated))
+		dnn, snssai := pcfDnnSnssai(pcf)
+		Expect(dnn).Should(Equal(ngcnef.Dnn("edge")))
+		Expect(snssai).Should(Equal(ngcnef.Snssai{Sst: 1, Sd: "0000A1"}))
+	})
+
+	It("Will reject the unknown and not allowed services", func() {
+		startNef(writeConfig(services, -1))
+
+		expectInvalid(createSub("ServiceId04", nil), "/afServiceId")
+		expectInvalid(createSub("ServiceId02", nil), "/afServiceId")
+		expectInvalid(createSub("ServiceId03", []ngcnef.RouteToLocation{
+			{Dnai: "dnai-1"}, {Dnai: "dnai-2"}}), "/trafficRoutes/1/dnai")
+		Expect(pcf.count()).Should(Equal(0))
+
+		Expect(createSub("ServiceId03", []ngcnef.RouteToLocation{
+			{Dnai: "dnai-1"}}).Code).Should(Equal(http.StatusCreated))
+	})
+
+	It("Will reject the patched routes not allowed by the service", func() {
+		startNef(writeConfig(services, -1))
+
+		rr := createSub("ServiceId03", []ngcnef.RouteToLocation{
+			{Dnai: "dnai-1"}})
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+		subID := rr.Header().Get("Location")
+		subID = subID[strings.LastIndex(subID, "/")+1:]
+
+		data, err := json.Marshal(ngcnef.TrafficInfluSubPatch{
+			TrafficRoutes: []ngcnef.RouteToLocation{{Dnai: "dnai-2"}}})
+		Expect(err).Should(BeNil())
+		rr, req := CreateReqForNEF(ctx, "PATCH", subID, data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		expectInvalid(rr, "/trafficRoutes/0/dnai")
+
+		// The subscription keeps its allowed route
+		rr, req = CreateReqForNEF(ctx, "GET", subID, nil)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &ti)).Should(Succeed())
+		Expect(ti.TrafficRoutes).Should(Equal([]ngcnef.RouteToLocation{
+			{Dnai: "dnai-1"}}))
+	})
+
+	It("Will not start with an invalid catalogue", func() {
+		cfgPath := writeConfig([]ngcnef.AfService{{ID: "ServiceId01"}}, -1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		Expect(ngcnef.Run(ctx, cfgPath)).ShouldNot(Succeed())
+	})
+
+	It("Will reload the catalogue when the file changes", func() {
+		startNef(writeConfig(services, 1))
+		Expect(readService("ServiceId01")).Should(Equal(ngcnef.Dnn("edge")))
+
+		_ = writeConfig([]ngcnef.AfService{
+			{ID: "ServiceId01", Dnn: "internet"},
+			{ID: "ServiceId04", Dnn: "edge"}}, 1)
+		Eventually(func() ngcnef.Dnn {
+			return readService("ServiceId01")
+		}, 3*time.Second).Should(Equal(ngcnef.Dnn("internet")))
+		Expect(readService("ServiceId02")).Should(BeEmpty())
+		Expect(readService("ServiceId04")).Should(Equal(ngcnef.Dnn("edge")))
+
+		// An invalid catalogue is not applied
+		_ = writeConfig([]ngcnef.AfService{{ID: "ServiceId01"}}, 1)
+		Consistently(func() ngcnef.Dnn {
+			return readService("ServiceId01")
+		}, 2*time.Second).Should(Equal(ngcnef.Dnn("internet")))
+	})
+
+	It("Will reload the catalogue on SIGHUP", func() {
+		startNef(writeConfig(services, 3600))
+
+		_ = writeConfig([]ngcnef.AfService{
+			{ID: "ServiceId01", Dnn: "internet"}}, 3600)
+		Expect(syscall.Kill(os.Getpid(), syscall.SIGHUP)).Should(Succeed())
+		Eventually(func() ngcnef.Dnn {
+			return readService("ServiceId01")
+		}, 2*time.Second).Should(Equal(ngcnef.Dnn("internet")))
+	})
+})
@@ -0,0 +1,103 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Authorization of the AF requests with OAuth2. The access token must grant
+ * the scope of the API of the route, nnef-trafficinfluence or
+ * nnef-pfdmanagement, and be issued for the AF of the path: the afId claim or
+ * the subject of the token is the AF ID, or the subject is a client allowed
+ * for the AF ID in OAuth2Clients. */
+
+package ngcnef
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/open-ness/epcforedge/ngc/pkg/oauth2"
+)
+
+// nefRouteScope returns the scope required by the route pattern and the
+// name of its AF path variable, empty if the route is not an AF API
+func nefRouteScope(pattern string) (scope string, afVar string) {
+
+	switch {
+	case strings.HasPrefix(pattern, "/3gpp-traffic-influence/") &&
+		strings.Contains(pattern, "{afId}"):
+		return oauth2.ScopeTrafficInfluence, "afId"
+	case strings.HasPrefix(pattern, "/3gpp-pfd-management/") &&
+		strings.Contains(pattern, "{scsAsId}"):
+		return oauth2.ScopePfdManagement, "scsAsId"
+	}
+	return "", ""
+}
+
+// nefTokenAllowedForAf returns true if the token is issued for the AF or for
+// a client allowed to act for the AF
+func nefTokenAllowedForAf(cfg *Config, claims *oauth2.AccessTokenClaims,
+	afID string) bool {
+
+	if claims.AfID == afID || claims.Subject == afID {
+		return true
+	}
+	for _, id := range cfg.OAuth2Clients[claims.Subject] {
+		if id == afID {
+			return true
+		}
+	}
+	return false
+}
+
+// nefSendForbidden sends 403 with the ProblemDetails
+func nefSendForbidden(w http.ResponseWriter, title string, detail string) {
+
+	log.Infof("Request forbidden: %s", detail)
+	sendErrorResponseToAF(w, nefSBRspData{errorCode: 403,
+		pd: ProblemDetails{Title: title, Status: 403, Detail: detail}})
+}
+
+// nefRouteAf returns the scope and the AF ID of the path of the request,
+// empty if the route is not an AF API
+func nefRouteAf(r *http.Request) (scope string, afID string) {
+
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", ""
+	}
+	pattern, err := route.GetPathTemplate()
+	if err != nil {
+		return "", ""
+	}
+	scope, afVar := nefRouteScope(pattern)
+	if scope == "" {
+		return "", ""
+	}
+	return scope, mux.Vars(r)[afVar]
+}
+
+// nefAuthorize checks that the token grants the scope of the route and is
+// issued for the AF of the path. 403 is sent if not
+func nefAuthorize(w http.ResponseWriter, r *http.Request, nefCtx *nefContext,
+	claims *oauth2.AccessTokenClaims) bool {
+
+	scope, afID := nefRouteAf(r)
+	if scope == "" {
+		return true
+	}
+
+	if !claims.HasScope(scope) {
+		w.Header().Set("WWW-Authenticate",
+			`Bearer error="insufficient_scope", scope="`+scope+`"`)
+		nefSendForbidden(w, "Insufficient scope",
+			"The access token does not grant the scope "+scope)
+		return false
+	}
+
+	if !nefTokenAllowedForAf(nefCtx.liveConfig(), claims, afID) {
+		nefSendForbidden(w, "AF not allowed",
+			"The access token is not issued for AF "+afID)
+		return false
+	}
+	return true
+}
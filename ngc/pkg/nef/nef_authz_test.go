@@ -0,0 +1,182 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+	"github.com/open-ness/epcforedge/ngc/pkg/oauth2"
+)
+
+// testSigningKey is the OAuth2 signing key of the tests
+const testSigningKey = "NEF-TEST"
+
+// testAccessToken returns a token signed for the subject, AF and scopes
+func testAccessToken(subject string, afID string, scope string) string {
+	return signTestAccessToken(testSigningKey, subject, afID, scope)
+}
+
+// signTestAccessToken returns a token signed with the key for the subject,
+// AF and scopes
+func signTestAccessToken(key string, subject string, afID string,
+	scope string) string {
+
+	claims := oauth2.AccessTokenClaims{Issuer: "OpenNESS", Subject: subject,
+		Audience: "AF-NEF", Scope: scope, AfID: afID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix()}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256,
+		claims).SignedString([]byte(key))
+	Expect(err).Should(BeNil())
+	return token
+}
+
+var _ = Describe("Test NEF OAuth2 authorization", func() {
+
+	const allScopes = oauth2.ScopeTrafficInfluence + " " +
+		oauth2.ScopePfdManagement
+
+	var (
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// writeOAuth2Config writes the OAuth2 configuration with the key
+	writeOAuth2Config := func(key string) string {
+		oauth2Cfg := filepath.Join(tmpDir, "oauth2.json")
+		Expect(ioutil.WriteFile(oauth2Cfg, []byte(`{"signingkey": "`+
+			key+`", "expiration": 3600}`), 0600)).Should(Succeed())
+		return oauth2Cfg
+	}
+
+	// writeConfig writes the NEF configuration with OAuth2 enabled
+	writeConfig := func(modify func(map[string]interface{})) string {
+		return writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["configWatchInterval"] = 1
+				cfg["OAuth2Support"] = true
+				cfg["OAuth2Clients"] = map[string][]string{
+					"scs-1": {"AF_01"}}
+				modify(cfg)
+			})
+	}
+
+	// sendReq sends a GET request of the URI with the token
+	sendReq := func(uri string, token string) *httptest.ResponseRecorder {
+
+		req := httptest.NewRequest("GET", "http://localhost:8091"+uri, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	// expectForbidden checks the response of a forbidden request
+	expectForbidden := func(rr *httptest.ResponseRecorder, title string) {
+
+		Expect(rr.Code).Should(Equal(http.StatusForbidden))
+		pd := ngcnef.ProblemDetails{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &pd)).Should(Succeed())
+		Expect(pd.Title).Should(Equal(title))
+		Expect(pd.Status).Should(Equal(int32(http.StatusForbidden)))
+	}
+
+	tiURI := func(afID string) string {
+		return "/3gpp-traffic-influence/v1/" + afID + "/subscriptions"
+	}
+	pfdURI := func(afID string) string {
+		return "/3gpp-pfd-management/v1/" + afID + "/transactions"
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-authz")
+		Expect(err).Should(BeNil())
+
+		oauth2.SetConfigPath(writeOAuth2Config(testSigningKey))
+		cfgPath := writeConfig(func(map[string]interface{}) {})
+
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		oauth2.SetConfigPath("configs/oauth2.json")
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will accept the token of the AF with the scope of the API", func() {
+		token := testAccessToken("", "AF_01", allScopes)
+		Expect(sendReq(tiURI("AF_01"), token).Code).ShouldNot(BeElementOf(
+			http.StatusUnauthorized, http.StatusForbidden))
+		Expect(sendReq(pfdURI("AF_01"), token).Code).ShouldNot(BeElementOf(
+			http.StatusUnauthorized, http.StatusForbidden))
+
+		Expect(sendReq(tiURI("AF_01"), "").Code).Should(Equal(
+			http.StatusUnauthorized))
+	})
+
+	It("Will reject the token without the scope of the API", func() {
+		token := testAccessToken("", "AF_01", oauth2.ScopeTrafficInfluence)
+		Expect(sendReq(tiURI("AF_01"), token).Code).ShouldNot(BeElementOf(
+			http.StatusUnauthorized, http.StatusForbidden))
+
+		rr := sendReq(pfdURI("AF_01"), token)
+		expectForbidden(rr, "Insufficient scope")
+		Expect(rr.Header().Get("WWW-Authenticate")).Should(ContainSubstring(
+			"insufficient_scope"))
+	})
+
+	It("Will reject the token of another AF", func() {
+		token := testAccessToken("", "AF_02", allScopes)
+		expectForbidden(sendReq(tiURI("AF_01"), token), "AF not allowed")
+		expectForbidden(sendReq(pfdURI("AF_01"), token), "AF not allowed")
+	})
+
+	It("Will accept the token of a client for its AFs", func() {
+		token := testAccessToken("scs-1", "", allScopes)
+		Expect(sendReq(tiURI("AF_01"), token).Code).ShouldNot(BeElementOf(
+			http.StatusUnauthorized, http.StatusForbidden))
+		expectForbidden(sendReq(tiURI("AF_02"), token), "AF not allowed")
+	})
+
+	It("Will verify the tokens with the reloaded signing key", func() {
+		const newKey = "NEF-TEST-2"
+		oldToken := testAccessToken("", "AF_01", allScopes)
+		newToken := signTestAccessToken(newKey, "", "AF_01", allScopes)
+
+		// The OAuth2 configuration is only read again on a reload
+		writeOAuth2Config(newKey)
+		Consistently(func() int {
+			return sendReq(tiURI("AF_01"), oldToken).Code
+		}, 1500*time.Millisecond, 500*time.Millisecond).ShouldNot(
+			BeElementOf(http.StatusUnauthorized, http.StatusForbidden))
+
+		writeConfig(func(cfg map[string]interface{}) {
+			cfg["logLevel"] = "info"
+		})
+		Eventually(func() int {
+			return sendReq(tiURI("AF_01"), oldToken).Code
+		}, 3*time.Second, 200*time.Millisecond).Should(BeElementOf(
+			http.StatusBadRequest, http.StatusUnauthorized))
+		Expect(sendReq(tiURI("AF_01"), newToken).Code).ShouldNot(
+			BeElementOf(http.StatusUnauthorized, http.StatusForbidden))
+	})
+})
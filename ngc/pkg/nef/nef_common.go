@@ -6,9 +6,7 @@ package ngcnef
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"net/http"
-	"path/filepath"
 )
 
 func closeReqBody(r *http.Request) {
@@ -31,6 +29,7 @@ func sendErrorResponseToAF(w http.ResponseWriter, rsp nefSBRspData) {
 
 	mdata, eCode := createErrorJSON(rsp)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	setRetryAfter(w, rsp.retryAfter)
 	w.WriteHeader(eCode)
 	_, err := w.Write(mdata)
 
@@ -40,20 +39,25 @@ func sendErrorResponseToAF(w http.ResponseWriter, rsp nefSBRspData) {
 	log.Infof("HTTP Response sent: %d", eCode)
 }
 
+// nefErrorCodes are the error status codes sent with the ProblemDetails of
+// the NEF, the other codes are sent as 404
+var nefErrorCodes = map[int]bool{
+	400: true,
+	403: true,
+	404: true,
+	411: true,
+	415: true,
+	429: true,
+	500: true,
+	503: true,
+}
+
 func createErrorJSON(rsp nefSBRspData) (mdata []byte, statusCode int) {
 
 	var err error
 	statusCode = 404
 
-	/*
-		TBD for future: Removed check for 401, 403, 413 and 429
-		due cyclometrix complexity lint warning. Once a better mechanism
-		is found to be added back. Anyhow currently these errors are not
-		supported
-	*/
-
-	if rsp.errorCode == 400 || rsp.errorCode == 404 || rsp.errorCode == 411 ||
-		rsp.errorCode == 415 || rsp.errorCode == 500 || rsp.errorCode == 503 {
+	if nefErrorCodes[rsp.errorCode] {
 		statusCode = rsp.errorCode
 		mdata, err = json.Marshal(rsp.pd)
 
@@ -77,27 +81,24 @@ func createErrorJSON(rsp nefSBRspData) (mdata []byte, statusCode int) {
 
 func logNef(nef *nefData) {
 
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
 	log.Infof("AF count %+v", len(nef.afs))
 	if len(nef.afs) > 0 {
 		for key, value := range nef.afs {
+			value.mu.Lock()
 			log.Infof(" AF ID : %+v, Sub Registered Count %+v",
 				key, len(value.subs))
 			for _, vs := range value.subs {
 				log.Infof("   SubId : %+v, ServiceId: %+v", vs.subid,
 					vs.ti.AfServiceID)
+				if vs.testNotif != nil {
+					log.Infof("   Test notification : %+v", *vs.testNotif)
+				}
 			}
-
+			value.mu.Unlock()
 		}
 	}
 
 }
-
-// LoadJSONConfig reads a file located at configPath and unmarshals it to
-// config structure
-func loadJSONConfig(configPath string, config interface{}) error {
-	cfgData, err := ioutil.ReadFile(filepath.Clean(configPath))
-	if err != nil {
-		return err
-	}
-	return json.Unmarshal(cfgData, config)
-}
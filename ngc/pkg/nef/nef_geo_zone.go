@@ -0,0 +1,337 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Registry of the geographic zones. The validGeoZoneIds of the traffic
+ * influence subscriptions are translated into the spatial validity sent to
+ * the PCF and the network area information sent to the UDR. The zones are
+ * loaded from the configuration and can be changed through the admin API,
+ * the changes apply to the policies installed afterwards. The changes are
+ * persisted in the NEF store and win over the configuration on restart,
+ * GeoZones is not applied by a configuration reload. */
+
+package ngcnef
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// GeoZone maps a geographic zone ID used by the AFs to the network areas
+type GeoZone struct {
+	ID string `json:"id"`
+	// Tracking areas of the zone
+	Tais []Tai `json:"tais,omitempty"`
+	// E-UTRA cells of the zone
+	Ecgis []Ecgi `json:"ecgis,omitempty"`
+	// NR cells of the zone
+	Ncgis []Ncgi `json:"ncgis,omitempty"`
+	// NG RAN nodes of the zone
+	GRanNodeIDs []GlobalRanNodeID `json:"gRanNodeIds,omitempty"`
+}
+
+// geoZoneRegistry contains the geographic zones by ID
+type geoZoneRegistry struct {
+	mu    sync.RWMutex
+	zones map[string]GeoZone
+}
+
+// validateGeoZone checks that the zone has an ID and a network area
+func validateGeoZone(z GeoZone) error {
+
+	if z.ID == "" {
+		return errors.New("Missing geo zone id")
+	}
+	if len(z.Tais) == 0 && len(z.Ecgis) == 0 && len(z.Ncgis) == 0 &&
+		len(z.GRanNodeIDs) == 0 {
+		return errors.New("Geo zone " + z.ID + " has no network area")
+	}
+	return nil
+}
+
+// newGeoZoneRegistry creates the registry with the zones of the
+// configuration
+func newGeoZoneRegistry(zones []GeoZone) (*geoZoneRegistry, error) {
+
+	r := &geoZoneRegistry{zones: make(map[string]GeoZone)}
+	for _, z := range zones {
+		if err := validateGeoZone(z); err != nil {
+			return nil, err
+		}
+		if _, ok := r.zones[z.ID]; ok {
+			return nil, errors.New("Duplicate geo zone " + z.ID)
+		}
+		r.zones[z.ID] = z
+	}
+	return r, nil
+}
+
+// list returns the zones ordered by ID
+func (r *geoZoneRegistry) list() []GeoZone {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	zones := []GeoZone{}
+	for _, z := range r.zones {
+		zones = append(zones, z)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].ID < zones[j].ID
+	})
+	return zones
+}
+
+// get returns the zone
+func (r *geoZoneRegistry) get(id string) (GeoZone, bool) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	z, ok := r.zones[id]
+	return z, ok
+}
+
+// restore applies the changes of the zones persisted in the store, a nil
+// zone is deleted
+func (r *geoZoneRegistry) restore(zones map[string]*GeoZone) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, z := range zones {
+		if z == nil {
+			delete(r.zones, id)
+			continue
+		}
+		r.zones[id] = *z
+	}
+}
+
+// put stores and adds or replaces the zone, created is true if it was added.
+// The zone is not changed if it cannot be stored
+func (r *geoZoneRegistry) put(z GeoZone, store NefStore) (created bool,
+	err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err = store.PutGeoZone(z); err != nil {
+		return false, err
+	}
+	_, ok := r.zones[z.ID]
+	r.zones[z.ID] = z
+	return !ok, nil
+}
+
+// delete stores the deletion and removes the zone, false is returned if it
+// does not exist
+func (r *geoZoneRegistry) delete(id string, store NefStore) (bool, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.zones[id]; !ok {
+		return false, nil
+	}
+	if err := store.DeleteGeoZone(id); err != nil {
+		return false, err
+	}
+	delete(r.zones, id)
+	return true, nil
+}
+
+// resolve returns the zones of the IDs. The unknown zones are returned as
+// invalid parameters of the AF request
+func (r *geoZoneRegistry) resolve(ids []string) (zones []GeoZone,
+	invalid []InvalidParam) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i, id := range ids {
+		z, ok := r.zones[id]
+		if !ok {
+			invalid = append(invalid, InvalidParam{
+				Param:  "/validGeoZoneIds/" + strconv.Itoa(i),
+				Reason: "Unknown geo zone " + id})
+			continue
+		}
+		zones = append(zones, z)
+	}
+	return zones, invalid
+}
+
+// validateGeoZoneIDs checks that the zones sent by the AF are known
+func validateGeoZoneIDs(nefCtx *nefContext, ids []string) (
+	rsp nefSBRspData, status bool) {
+
+	if _, invalid := nefCtx.nef.geoZones.resolve(ids); len(invalid) > 0 {
+		rsp.errorCode = 400
+		rsp.pd.Title = "Unknown validGeoZoneIds"
+		rsp.pd.Status = 400
+		rsp.pd.InvalidParams = invalid
+		return rsp, false
+	}
+	return rsp, true
+}
+
+// resolveGeoZones returns the zones of the IDs. The zones are validated when
+// received from the AF, those deleted since then are ignored
+func resolveGeoZones(nefCtx *nefContext, ids []string) []GeoZone {
+
+	zones, invalid := nefCtx.nef.geoZones.resolve(ids)
+	for _, p := range invalid {
+		log.Errf("Ignoring %s: %s", p.Param, p.Reason)
+	}
+	return zones
+}
+
+// getSpatialValidityData returns the spatial validity of the zones sent to
+// the PCF, nil if there is no zone
+func getSpatialValidityData(nefCtx *nefContext,
+	ids []string) *SpatialValidity {
+
+	zones := resolveGeoZones(nefCtx, ids)
+	if len(zones) == 0 {
+		return nil
+	}
+
+	spVal := &SpatialValidity{}
+	spVal.PresenceInfoList.PresenceState = "IN_AREA"
+	for _, z := range zones {
+		spVal.PresenceInfoList.TrackingAreaList = append(
+			spVal.PresenceInfoList.TrackingAreaList, z.Tais...)
+		spVal.PresenceInfoList.EcgiList = append(
+			spVal.PresenceInfoList.EcgiList, z.Ecgis...)
+		spVal.PresenceInfoList.NcgiList = append(
+			spVal.PresenceInfoList.NcgiList, z.Ncgis...)
+		spVal.PresenceInfoList.GlobalRanNodeIDList = append(
+			spVal.PresenceInfoList.GlobalRanNodeIDList, z.GRanNodeIDs...)
+	}
+	return spVal
+}
+
+// getNetworkAreaInfo returns the network area information of the zones sent
+// to the UDR, nil if there is no zone
+func getNetworkAreaInfo(nefCtx *nefContext, ids []string) *NetworkAreaInfo {
+
+	zones := resolveGeoZones(nefCtx, ids)
+	if len(zones) == 0 {
+		return nil
+	}
+
+	nwAreaInfo := &NetworkAreaInfo{}
+	for _, z := range zones {
+		nwAreaInfo.Tais = append(nwAreaInfo.Tais, z.Tais...)
+		nwAreaInfo.Ecgis = append(nwAreaInfo.Ecgis, z.Ecgis...)
+		nwAreaInfo.Ncgis = append(nwAreaInfo.Ncgis, z.Ncgis...)
+		nwAreaInfo.GRanNodeIds = append(nwAreaInfo.GRanNodeIds,
+			z.GRanNodeIDs...)
+	}
+	return nwAreaInfo
+}
+
+// ListGeoZones : Returns the geographic zones
+func ListGeoZones(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	sendAdminJSONRsp(w, nefCtx.nef.geoZones.list())
+}
+
+// ReadGeoZone : Returns a geographic zone
+func ReadGeoZone(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	z, ok := nefCtx.nef.geoZones.get(vars["zoneId"])
+	if !ok {
+		sendCustomeErrorRspToAF(w, 404, "Geo zone not found")
+		return
+	}
+	sendAdminJSONRsp(w, z)
+}
+
+// UpdateGeoZone : Creates or replaces a geographic zone
+func UpdateGeoZone(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	if r.Body == nil {
+		sendCustomeErrorRspToAF(w, 400, "Failed to decode geo zone")
+		return
+	}
+	z := GeoZone{}
+	if err := json.NewDecoder(r.Body).Decode(&z); err != nil {
+		log.Errf("Failed to decode geo zone: %v", err)
+		sendCustomeErrorRspToAF(w, 400, "Failed to decode geo zone")
+		return
+	}
+	if z.ID == "" {
+		z.ID = vars["zoneId"]
+	} else if z.ID != vars["zoneId"] {
+		sendCustomeErrorRspToAF(w, 400, "Geo zone id does not match the URI")
+		return
+	}
+	if err := validateGeoZone(z); err != nil {
+		sendCustomeErrorRspToAF(w, 400, err.Error())
+		return
+	}
+
+	created, err := nefCtx.nef.geoZones.put(z, nefCtx.nef.store)
+	if err != nil {
+		log.Errf("Failed to store geo zone %s: %v", z.ID, err)
+		sendCustomeErrorRspToAF(w, 500, "Failed to store geo zone")
+		return
+	}
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	log.Infof("Geo zone %s updated", z.ID)
+
+	mdata, err := json.Marshal(z)
+	if err != nil {
+		sendCustomeErrorRspToAF(w, 500, "Failed to MARSHAL response data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	if _, err = w.Write(mdata); err != nil {
+		log.Errf("Write Failed: %v", err)
+		return
+	}
+	log.Infof("HTTP Response sent: %d", code)
+}
+
+// DeleteGeoZone : Deletes a geographic zone. The policies already installed
+// for the zone are not changed
+func DeleteGeoZone(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	found, err := nefCtx.nef.geoZones.delete(vars["zoneId"],
+		nefCtx.nef.store)
+	if err != nil {
+		log.Errf("Failed to store the deletion of geo zone %s: %v",
+			vars["zoneId"], err)
+		sendCustomeErrorRspToAF(w, 500, "Failed to store geo zone")
+		return
+	}
+	if !found {
+		sendCustomeErrorRspToAF(w, 404, "Geo zone not found")
+		return
+	}
+	log.Infof("Geo zone %s deleted", vars["zoneId"])
+	w.WriteHeader(http.StatusNoContent)
+	log.Infof("HTTP Response sent: %d", http.StatusNoContent)
+}
@@ -0,0 +1,163 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// pcfSpVal returns the spatial validity of the only app session of the PCF
+func pcfSpVal(p *fakePCF) *ngcnef.SpatialValidity {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	Expect(p.sessions).Should(HaveLen(1))
+	for _, asc := range p.sessions {
+		return asc.AscReqData.AfRoutReq.SpVal
+	}
+	return nil
+}
+
+var _ = Describe("Test NEF geo zones", func() {
+
+	var (
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// createSub sends the subscription for the zones
+	createSub := func(zones []string) *httptest.ResponseRecorder {
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.ValidGeoZoneIDs = zones
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	// sendZoneReq sends a request of the admin API of the zone
+	sendZoneReq := func(method string, zoneID string,
+		body interface{}) *httptest.ResponseRecorder {
+
+		var data []byte
+		if body != nil {
+			var err error
+			data, err = json.Marshal(body)
+			Expect(err).Should(BeNil())
+		}
+		uri := "http://127.0.0.1:18071/nef-admin/v1/geo-zones"
+		if zoneID != "" {
+			uri += "/" + zoneID
+		}
+		req := httptest.NewRequest(method, uri, bytes.NewBuffer(data))
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-geo-zone")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+			})
+
+		nef = newTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		pcfSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will send the network areas of the configured zone", func() {
+		Expect(createSub([]string{"ZONE_01"}).Code).Should(Equal(
+			http.StatusCreated))
+
+		spVal := pcfSpVal(pcf)
+		Expect(spVal).ShouldNot(BeNil())
+		info := spVal.PresenceInfoList
+		Expect(info.PresenceState).Should(Equal(
+			ngcnef.PresenceState("IN_AREA")))
+		Expect(info.TrackingAreaList).Should(HaveLen(1))
+		Expect(info.TrackingAreaList[0].Tac).Should(Equal(
+			ngcnef.Tac("000001")))
+		Expect(info.EcgiList).Should(HaveLen(1))
+		Expect(info.EcgiList[0].EutraCellID).Should(Equal(
+			ngcnef.EutraCellID("0000001")))
+		Expect(info.NcgiList).Should(HaveLen(1))
+		Expect(info.GlobalRanNodeIDList).Should(HaveLen(1))
+	})
+
+	It("Will reject an unknown zone with invalidParams", func() {
+		rr := createSub([]string{"ZONE_01", "ZONE_XX"})
+		Expect(rr.Code).Should(Equal(http.StatusBadRequest))
+
+		pd := ngcnef.ProblemDetails{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &pd)).Should(Succeed())
+		Expect(pd.InvalidParams).Should(Equal([]ngcnef.InvalidParam{{
+			Param: "/validGeoZoneIds/1", Reason: "Unknown geo zone ZONE_XX"}}))
+		Expect(pcf.count()).Should(Equal(0))
+	})
+
+	It("Will manage the zones through the admin API", func() {
+		zone := ngcnef.GeoZone{Ncgis: []ngcnef.Ncgi{{NrCellID: "000000002",
+			PlmnID: ngcnef.PlmnID{Mcc: "001", Mnc: "01"}}}}
+		Expect(sendZoneReq("PUT", "ZONE_02", ngcnef.GeoZone{}).Code).Should(
+			Equal(http.StatusBadRequest))
+		Expect(sendZoneReq("PUT", "ZONE_02", zone).Code).Should(Equal(
+			http.StatusCreated))
+		Expect(sendZoneReq("PUT", "ZONE_02", zone).Code).Should(Equal(
+			http.StatusOK))
+
+		rr := sendZoneReq("GET", "", nil)
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		zones := []ngcnef.GeoZone{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &zones)).Should(Succeed())
+		Expect(zones).Should(HaveLen(2))
+		Expect(zones[1].ID).Should(Equal("ZONE_02"))
+
+		Expect(createSub([]string{"ZONE_02"}).Code).Should(Equal(
+			http.StatusCreated))
+		info := pcfSpVal(pcf).PresenceInfoList
+		Expect(info.NcgiList).Should(Equal(zone.Ncgis))
+		Expect(info.EcgiList).Should(BeEmpty())
+
+		Expect(sendZoneReq("DELETE", "ZONE_02", nil).Code).Should(Equal(
+			http.StatusNoContent))
+		Expect(sendZoneReq("GET", "ZONE_02", nil).Code).Should(Equal(
+			http.StatusNotFound))
+		Expect(sendZoneReq("DELETE", "ZONE_02", nil).Code).Should(Equal(
+			http.StatusNotFound))
+	})
+})
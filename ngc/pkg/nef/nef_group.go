@@ -0,0 +1,198 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Registry of the external groups. The externalGroupId of the traffic
+ * influence subscriptions towards the UDR is resolved into the internal group
+ * ID of the influence data. The groups are either listed in the
+ * configuration ("local" backend) or retrieved from the UDM ("udm" backend)
+ * with the group identifiers of Nudm_SDM. */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Backends of the group registry
+const (
+	groupBackendLocal = "local"
+	groupBackendUDM   = "udm"
+)
+
+// errGroupNotFound is returned if the external group is unknown
+var errGroupNotFound = errors.New("Group not found")
+
+// ExternalGroup maps an external group ID used by the AFs to the internal
+// group ID and the members of the group
+type ExternalGroup struct {
+	// External group ID, local identifier followed by "@" and a domain
+	ExternalGroupID ExternalGroupID `json:"externalGroupId"`
+	// Internal group ID sent to the UDR
+	InternalGroupID string `json:"internalGroupId"`
+	// GPSIs of the members of the group
+	Gpsis []Gpsi `json:"gpsis,omitempty"`
+}
+
+// GroupConfig contains the settings of the group registry
+type GroupConfig struct {
+	// Backend of the registry: "local" (default) or "udm"
+	Backend string `json:"backend"`
+	// Groups of the "local" backend
+	Groups []ExternalGroup `json:"groups"`
+}
+
+// groupRegistry resolves the external groups with the local groups or the
+// UDM
+type groupRegistry struct {
+	groups map[ExternalGroupID]ExternalGroup
+	// udm is nil with the local backend
+	udm UdmIDTranslation
+}
+
+// validateExternalGroupID checks that the ID is a local identifier followed
+// by "@" and a domain
+func validateExternalGroupID(id ExternalGroupID) error {
+
+	s := string(id)
+	i := strings.Index(s, "@")
+	if i <= 0 || i == len(s)-1 || strings.Count(s, "@") != 1 {
+		return errors.New("Invalid external group id " + s)
+	}
+	return nil
+}
+
+// newGroupRegistry creates the registry of the backend of the configuration
+func newGroupRegistry(cfg GroupConfig, udm UdmIDTranslation) (*groupRegistry,
+	error) {
+
+	switch cfg.Backend {
+	case "", groupBackendLocal:
+	case groupBackendUDM:
+		if len(cfg.Groups) > 0 {
+			log.Infof("%d local groups ignored with the udm backend",
+				len(cfg.Groups))
+		}
+		return &groupRegistry{udm: udm}, nil
+	default:
+		return nil, errors.New("Invalid group backend " + cfg.Backend)
+	}
+
+	r := &groupRegistry{groups: make(map[ExternalGroupID]ExternalGroup)}
+	for _, g := range cfg.Groups {
+		if err := validateExternalGroupID(g.ExternalGroupID); err != nil {
+			return nil, err
+		}
+		if g.InternalGroupID == "" {
+			return nil, errors.New("Missing internal group id of " +
+				string(g.ExternalGroupID))
+		}
+		if _, ok := r.groups[g.ExternalGroupID]; ok {
+			return nil, errors.New("Duplicate group " +
+				string(g.ExternalGroupID))
+		}
+		r.groups[g.ExternalGroupID] = g
+	}
+	return r, nil
+}
+
+// resolve returns the group of the external group ID. errGroupNotFound is
+// returned if the group is unknown
+func (r *groupRegistry) resolve(ctx context.Context,
+	id ExternalGroupID) (ExternalGroup, error) {
+
+	if r.udm == nil {
+		g, ok := r.groups[id]
+		if !ok {
+			return g, errGroupNotFound
+		}
+		return g, nil
+	}
+
+	g := ExternalGroup{ExternalGroupID: id}
+	gids, err := r.udm.UdmGetGroupIdentifiers(ctx, id)
+	if err == errUdmGroupNotFound {
+		return g, errGroupNotFound
+	} else if err != nil {
+		return g, err
+	}
+	g.InternalGroupID = gids.IntGroupID
+	for _, ue := range gids.UeIDList {
+		g.Gpsis = append(g.Gpsis, ue.GpsiList...)
+	}
+	return g, nil
+}
+
+// getInterGroupID returns the internal group ID of the external group sent
+// to the UDR, empty if there is no group
+func getInterGroupID(cliCtx context.Context, nefCtx *nefContext,
+	id ExternalGroupID) (intGroupID string, rsp nefSBRspData, err error) {
+
+	if id == "" {
+		return "", rsp, nil
+	}
+
+	g, err := nefCtx.nef.groups.resolve(cliCtx, id)
+	switch {
+	case err == errGroupNotFound:
+		log.Errf("Group %s not found", id)
+		rsp.errorCode = 404
+		rsp.pd.Title = "Group not found"
+		rsp.pd.Detail = "Unknown external group " + string(id)
+		rsp.pd.Status = 404
+	case err != nil:
+		log.Errf("Failed to resolve group %s: %v", id, err)
+		rsp.errorCode = 503
+		rsp.pd.Title = "Group resolution failed"
+	}
+	return g.InternalGroupID, rsp, err
+}
+
+// validateExternalGroup checks that the external group of a subscription
+// towards the UDR is known. The group of a single UE subscription is not used
+func validateExternalGroup(ctx context.Context, nefCtx *nefContext,
+	ti TrafficInfluSub) (rsp nefSBRspData, status bool) {
+
+	if ti.ExternalGroupID == "" || isSingleUeSub(ti) {
+		return rsp, true
+	}
+	if err := validateExternalGroupID(ti.ExternalGroupID); err != nil {
+		rsp.errorCode = 400
+		rsp.pd.Title = err.Error()
+		rsp.pd.Status = 400
+		rsp.pd.InvalidParams = []InvalidParam{{Param: "/externalGroupId",
+			Reason: "Not a local identifier followed by @ and a domain"}}
+		return rsp, false
+	}
+	if _, rsp, err := getInterGroupID(ctx, nefCtx,
+		ti.ExternalGroupID); err != nil {
+		return rsp, false
+	}
+	return rsp, true
+}
+
+// ReadGroup : Returns the internal group ID and the members of an external
+// group
+func ReadGroup(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	vars := mux.Vars(r)
+
+	g, err := nefCtx.nef.groups.resolve(r.Context(),
+		ExternalGroupID(vars["externalGroupId"]))
+	if err == errGroupNotFound {
+		sendCustomeErrorRspToAF(w, 404, "Group not found")
+		return
+	} else if err != nil {
+		log.Errf("Failed to resolve group %s: %v", vars["externalGroupId"],
+			err)
+		sendCustomeErrorRspToAF(w, 503, "Group resolution failed")
+		return
+	}
+	sendAdminJSONRsp(w, g)
+}
@@ -0,0 +1,169 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// udrInterGroupIDs returns the internal group IDs of the influence data of
+// the UDR
+func udrInterGroupIDs(u *fakeUDR) []string {
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ids := []string{}
+	for _, tid := range u.tid {
+		ids = append(ids, tid.InterGroupID)
+	}
+	return ids
+}
+
+var _ = Describe("Test NEF external groups", func() {
+
+	var (
+		udr    *fakeUDR
+		udrSrv *httptest.Server
+		udmSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// startNef starts the NEF with the group and UDM configurations, the
+	// ones of valid.json if nil
+	startNef := func(groupCfg map[string]interface{},
+		udmCfg map[string]interface{}) {
+
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["UDRConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": udrSrv.URL}
+				if groupCfg != nil {
+					cfg["GroupConfig"] = groupCfg
+				}
+				if udmCfg != nil {
+					cfg["UDMConfig"] = udmCfg
+				}
+			})
+
+		nef = newTestNef(cfgPath)
+		ctx = nef.ctx
+	}
+
+	// createSub creates a subscription towards the UDR for the group
+	createSub := func(group string) *httptest.ResponseRecorder {
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_UDR_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.ExternalGroupID = ngcnef.ExternalGroupID(group)
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	// readGroup reads the group through the admin API
+	readGroup := func(group string) *httptest.ResponseRecorder {
+
+		req := httptest.NewRequest("GET",
+			"http://127.0.0.1:18071/nef-admin/v1/groups/"+group, nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	BeforeEach(func() {
+		udr = newFakeUDR()
+		udrSrv = httptest.NewServer(udr)
+		udmSrv = httptest.NewServer(&fakeUDM{
+			groups: map[string]ngcnef.GroupIdentifiers{
+				"group-02@nef.example.com": {
+					ExtGroupID: "group-02@nef.example.com",
+					IntGroupID: "00000002-001-01-01",
+					UeIDList: []ngcnef.UeID{{Supi: "imsi-001010000000001",
+						GpsiList: []ngcnef.Gpsi{"msisdn-1"}}}}}})
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-group")
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		udrSrv.Close()
+		udmSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will send the internal group of the local backend", func() {
+		startNef(nil, nil)
+
+		Expect(createSub("group-01@nef.example.com").Code).Should(Equal(
+			http.StatusCreated))
+		Expect(udrInterGroupIDs(udr)).Should(Equal(
+			[]string{"00000001-001-01-01"}))
+
+		rr := readGroup("group-01@nef.example.com")
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		g := ngcnef.ExternalGroup{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &g)).Should(Succeed())
+		Expect(g.Gpsis).Should(Equal([]ngcnef.Gpsi{"msisdn-1234567890"}))
+	})
+
+	It("Will reject the unknown and invalid groups", func() {
+		startNef(nil, nil)
+
+		rr := createSub("group-02@nef.example.com")
+		Expect(rr.Code).Should(Equal(http.StatusNotFound))
+		pd := ngcnef.ProblemDetails{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &pd)).Should(Succeed())
+		Expect(pd.Title).Should(Equal("Group not found"))
+
+		rr = createSub("group-02")
+		Expect(rr.Code).Should(Equal(http.StatusBadRequest))
+		pd = ngcnef.ProblemDetails{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &pd)).Should(Succeed())
+		Expect(pd.InvalidParams).Should(HaveLen(1))
+		Expect(pd.InvalidParams[0].Param).Should(Equal("/externalGroupId"))
+
+		Expect(udr.ids()).Should(BeEmpty())
+		Expect(readGroup("group-02@nef.example.com").Code).Should(Equal(
+			http.StatusNotFound))
+	})
+
+	It("Will resolve the groups through the UDM", func() {
+		startNef(map[string]interface{}{"backend": "udm"},
+			map[string]interface{}{"type": "http", "apiRoot": udmSrv.URL})
+
+		Expect(createSub("group-01@nef.example.com").Code).Should(Equal(
+			http.StatusNotFound))
+		Expect(createSub("group-02@nef.example.com").Code).Should(Equal(
+			http.StatusCreated))
+		Expect(udrInterGroupIDs(udr)).Should(Equal(
+			[]string{"00000002-001-01-01"}))
+
+		rr := readGroup("group-02@nef.example.com")
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		g := ngcnef.ExternalGroup{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &g)).Should(Succeed())
+		Expect(g.InternalGroupID).Should(Equal("00000002-001-01-01"))
+		Expect(g.Gpsis).Should(Equal([]ngcnef.Gpsi{"msisdn-1"}))
+	})
+})
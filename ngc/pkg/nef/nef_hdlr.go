@@ -7,6 +7,10 @@ package ngcnef
 import (
 	"context"
 	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const correlationIDOffset = 20
@@ -15,18 +19,39 @@ const pfdNotFound string = "PFD transaction Not Found"
 const appNotFound string = "Application in PFD transaction Not Found"
 const pfdAppsFailed string = "ALL PFD Apps Failed"
 
+// errAfDeleted is returned when an AF entry got deleted by a concurrent
+// request after it was looked up
+var errAfDeleted = errors.New("AF entry deleted")
+
 //NEF context data
+//The AF map is protected by mu and the data of each AF by its own lock. When
+//both are needed mu is always acquired first.
 type nefData struct {
+	mu                   sync.RWMutex
 	ctx                  context.Context
+	sbCtx                context.Context
+	sbCancel             context.CancelFunc
 	afCount              int
 	locationURLPrefix    string
 	locationURLPrefixPfd string
+	nrfClient            *nrfClient
 	pcfClient            PcfPolicyAuthorization
 	udrClient            UdrInfluenceData
 	udrPfdClient         UdrPfdData
+	udmClient            UdmIDTranslation
+	sbClients            []nefSBClient
+	corrIDMu             sync.Mutex
 	corrID               uint
 	afs                  map[string]*afData
 	upfNotificationURL   URI
+	store                NefStore
+	notifier             *afNotifier
+	acks                 *afAcks
+	geoZones             *geoZoneRegistry
+	groups               *groupRegistry
+	afServices           *afServiceCatalog
+	quotas               *afQuotas
+	metrics              *nefMetrics
 }
 
 //NEFSBGetFn is the callback for SB API
@@ -79,10 +104,24 @@ type afSubscription struct {
 	iid                       InfluenceID
 	NotifCorreID              string
 	afNotificationDestination Link
+	testNotif                 *NefTestNotifResult
 	NEFSBGet                  NEFSBGetFn
 	NEFSBPut                  NEFSBPutFn
 	NEFSBPatch                NEFSBPatchFn
 	NEFSBDelete               NEFSBDeleteFn
+
+	//The policy is not installed in the PCF/UDR while inactive, the timer
+	//changes the state according to the tempValidities
+	inactive             bool
+	activationTimer      *time.Timer
+	nextActivationChange time.Time
+
+	//Held during the southbound requests of the subscription, which are sent
+	//without the AF lock, so that they are serialized
+	sbMu *sync.Mutex
+	//Set while the policy of a new subscription is installed, the
+	//subscription is not visible to the AF until it is created
+	pending bool
 }
 
 //PFD transaction data
@@ -94,10 +133,26 @@ type afPfdTransaction struct {
 	NEFSBPfdPut    NEFSBPutPfdFn
 	NEFSBAppPfdPut NEFSBAppPutPfdFn
 	NEFSBPfdDelete NEFSBDeletePfdFn
+
+	//Held during the southbound requests of the transaction, which are sent
+	//without the AF lock, so that they are serialized
+	sbMu *sync.Mutex
+	//Set while the PFDs of a new transaction are installed, the transaction
+	//is not visible to the AF until it is created
+	pending bool
 }
 
 //AF data
 type afData struct {
+	//Number of subscriptions by state and of PFD transactions, read by the
+	//metrics without the AF lock. First for the alignment of the atomics
+	activeSubs   int64
+	inactiveSubs int64
+	pfdTransNum  int64
+
+	mu sync.Mutex
+	//Set once the AF is removed from the NEF, no data can be added anymore
+	deleted    bool
 	afID       string
 	subIDnum   int
 	transIDnum int
@@ -109,6 +164,8 @@ type afData struct {
 type nefSBRspData struct {
 	errorCode int
 	pd        ProblemDetails
+	// Retry-After in seconds of the error response, not sent if 0
+	retryAfter int
 }
 
 type nefPFDSBRspData struct {
@@ -134,20 +191,9 @@ func (af *afData) afCreate(nefCtx *nefContext, afID string) error {
 //Initialize the NEF component
 func (nef *nefData) nefCreate(ctx context.Context, cfg Config) error {
 
-	nef.ctx = ctx
-	nef.afCount = 0
-	nef.pcfClient = NewPCFClient(&cfg)
-	if nef.pcfClient == nil {
-		return errors.New("PCF Client creation failed")
-	}
-	nef.udrClient = NewUDRClient(&cfg)
-	nef.udrPfdClient = NewUDRPfdClient(&cfg)
-	if nef.udrClient == nil {
-		return errors.New("PCF Client creation failed")
-	}
-	nef.afs = make(map[string]*afData)
-	nef.corrID = uint(cfg.SubStartID + correlationIDOffset)
-
+	/* The configuration is checked before the store is opened and the
+	 * notification routines are started, which are not released if the
+	 * NEF is not created */
 	if cfg.NefAPIRoot == "" {
 		return errors.New("NefAPIRoot is empty")
 	}
@@ -170,6 +216,109 @@ func (nef *nefData) nefCreate(ctx context.Context, cfg Config) error {
 	}
 	nef.upfNotificationURL = getNefNotificationURI(&cfg)
 	log.Infof("SMF UPF Notification URL :%s", nef.upfNotificationURL)
+
+	nef.ctx = ctx
+	// The southbound requests are not cancelled with ctx so that the
+	// requests in progress and the teardown complete at shutdown
+	nef.sbCtx, nef.sbCancel = context.WithCancel(context.Background())
+	nef.afCount = 0
+	nef.metrics = newNefMetrics(nef)
+	nrfClient, err := newNRFClient(&cfg)
+	if err != nil {
+		log.Errf("NRF Client creation failed: %v", err)
+		return errors.New("NRF Client creation failed")
+	}
+	nef.nrfClient = nrfClient
+	pcfClient, err := newPCFClient(&cfg, nrfClient)
+	if err != nil {
+		log.Errf("PCF Client creation failed: %v", err)
+		return errors.New("PCF Client creation failed")
+	}
+	nef.pcfClient = &pcfMetricsClient{inner: pcfClient, m: nef.metrics}
+	udrClient, err := newUDRClient(&cfg, nrfClient)
+	if err != nil {
+		log.Errf("UDR Client creation failed: %v", err)
+		return errors.New("UDR Client creation failed")
+	}
+	nef.udrClient = &udrMetricsClient{inner: udrClient, m: nef.metrics}
+	udrPfdClient, err := newUDRPfdClient(&cfg, nrfClient)
+	if err != nil {
+		log.Errf("UDR PFD Client creation failed: %v", err)
+		return errors.New("UDR PFD Client creation failed")
+	}
+	nef.udrPfdClient = &udrPfdMetricsClient{inner: udrPfdClient,
+		m: nef.metrics}
+	udmClient, err := newUDMClient(&cfg, nrfClient)
+	if err != nil {
+		log.Errf("UDM Client creation failed: %v", err)
+		return errors.New("UDM Client creation failed")
+	}
+	nef.udmClient = udmClient
+	nef.sbClients = []nefSBClient{{"PCF", pcfClient}, {"UDR", udrClient},
+		{"UDR PFD", udrPfdClient}, {"UDM", udmClient}}
+	nef.afs = make(map[string]*afData)
+	nef.corrID = uint(cfg.SubStartID + correlationIDOffset)
+
+	geoZones, err := newGeoZoneRegistry(cfg.GeoZones)
+	if err != nil {
+		log.Errf("Geo zones configuration error: %v", err)
+		return err
+	}
+	nef.geoZones = geoZones
+
+	groups, err := newGroupRegistry(cfg.GroupConfig, udmClient)
+	if err != nil {
+		log.Errf("Groups configuration error: %v", err)
+		return err
+	}
+	nef.groups = groups
+
+	afServices, err := newAfServiceCatalog(cfg.AfServiceIDs)
+	if err != nil {
+		log.Errf("AF services configuration error: %v", err)
+		return err
+	}
+	nef.afServices = afServices
+
+	quotas, err := newAfQuotas(cfg.QuotaConfig)
+	if err != nil {
+		log.Errf("Quotas configuration error: %v", err)
+		return err
+	}
+	nef.quotas = quotas
+
+	if _, err = shutdownPolicy(&cfg); err != nil {
+		log.Errf("Shutdown configuration error: %v", err)
+		return err
+	}
+
+	store, err := newNefStore(cfg.StoreConfig)
+	if err != nil {
+		return err
+	}
+	nef.store = store
+	if err = nef.nefRestore(cfg); err != nil {
+		_ = store.Close()
+		return err
+	}
+	smfAckClient, err := NewSmfAckClient(&cfg)
+	if err != nil {
+		_ = store.Close()
+		log.Errf("SMF Ack Client creation failed: %v", err)
+		return errors.New("SMF Ack Client creation failed")
+	}
+	nef.acks = newAfAcks(ctx, &cfg, smfAckClient)
+	afClient := NewAfClient(&cfg)
+	nef.metrics.tls.Set("af_client_ca", afClient.rootCAs)
+	nef.notifier, err = newAfNotifier(ctx, &cfg, afClient, store,
+		nef.acks, nef.metrics)
+	if err != nil {
+		_ = store.Close()
+		return err
+	}
+
+	// Register the NEF in the NRF and send the heartbeats until ctx is done
+	nef.nrfClient.start(ctx)
 	return nil
 }
 
@@ -179,12 +328,29 @@ func NEFInit() error {
 	return nef.nefCreate()
 }*/
 
+// nefGetOrAddAf returns the AF entry, it is created if not present
+func (nef *nefData) nefGetOrAddAf(nefCtx *nefContext, afID string) (
+	af *afData, err error) {
+
+	nef.mu.Lock()
+	defer nef.mu.Unlock()
+
+	af, ok := nef.afs[afID]
+	if ok {
+		log.Infoln("AF PRESENT")
+		return af, nil
+	}
+	log.Infoln("NO AF PRESENT CREATE AF")
+	return nef.nefAddAf(nefCtx, afID)
+}
+
+// nefAddAf creates a new AF entry, the caller must hold nef.mu
 func (nef *nefData) nefAddAf(nefCtx *nefContext, afID string) (af *afData,
 	err error) {
 
-	var afe afData
+	afe := &afData{}
 
-	if len(nef.afs) >= nefCtx.cfg.MaxAFSupport {
+	if len(nef.afs) >= nefCtx.liveConfig().MaxAFSupport {
 		log.Infoln("MAX AF exceeded ")
 		return af, errors.New("MAX AF exceeded")
 	}
@@ -192,14 +358,18 @@ func (nef *nefData) nefAddAf(nefCtx *nefContext, afID string) (af *afData,
 	//Create a new entry of AF
 
 	_ = afe.afCreate(nefCtx, afID)
-	nef.afs[afID] = &afe
+	nef.afs[afID] = afe
 	nef.afCount++
+	nef.nefStoreAf(afe)
 
-	return &afe, nil
+	return afe, nil
 }
 
 func (nef *nefData) nefGetAf(afID string) (af *afData, err error) {
 
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
 	//Check if AF is already present
 	afe, ok := nef.afs[afID]
 
@@ -212,13 +382,25 @@ func (nef *nefData) nefGetAf(afID string) (af *afData, err error) {
 
 func (nef *nefData) nefCheckDeleteAf(afID string) {
 
-	af, _ := nef.nefGetAf(afID)
+	nef.mu.Lock()
+	defer nef.mu.Unlock()
+
+	af, ok := nef.afs[afID]
+	if !ok {
+		return
+	}
+
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
 	// If the AF subcount and transaction count is 0 delete the AF
 	if af.afGetSubCount() == 0 && af.afGetPfdTransCount() == 0 {
+		af.deleted = true
 		_ = nef.nefDeleteAf(afID)
 	}
 }
+
+// nefDeleteAf deletes the AF entry, the caller must hold nef.mu
 func (nef *nefData) nefDeleteAf(afID string) (err error) {
 
 	//Check if AF is already present
@@ -227,6 +409,9 @@ func (nef *nefData) nefDeleteAf(afID string) (err error) {
 	if ok {
 		delete(nef.afs, afID)
 		//nef.afCount--
+		if err = nef.store.DeleteAf(afID); err != nil {
+			log.Errf("Failed to delete AF %s from store: %v", afID, err)
+		}
 		return nil
 	}
 
@@ -234,7 +419,147 @@ func (nef *nefData) nefDeleteAf(afID string) (err error) {
 	return err
 }
 
-func (nef *nefData) nefDestroy() {
+func (nef *nefData) nefDestroy(nefCtx *nefContext) {
+
+	// No policy is installed or removed once the NEF is stopped
+	nef.nefStopSubTimers()
+
+	// The southbound state is kept or released before the store is closed
+	nef.nefShutdown(nefCtx)
+
+	// Deregister from the NRF once the heartbeats are stopped
+	nef.nrfClient.stop()
+
+	// The undelivered notifications are stored before closing the store
+	if nef.notifier != nil {
+		nef.notifier.stop()
+	}
+	if nef.acks != nil {
+		nef.acks.stop()
+	}
+
+	if nef.store != nil {
+		if err := nef.store.Close(); err != nil {
+			log.Errf("Failed to close NEF store: %v", err)
+		}
+	}
+	nef.sbCancel()
+}
+
+// nefRestore rebuilds the AFs, subscriptions, PFD transactions and geo zones
+// from the state persisted in the NEF store
+func (nef *nefData) nefRestore(cfg Config) error {
+
+	state, err := nef.store.Load()
+	if err != nil {
+		return err
+	}
+	if state.CorrID > nef.corrID {
+		nef.corrID = state.CorrID
+	}
+	nef.geoZones.restore(state.GeoZones)
+
+	for afID, afs := range state.Afs {
+		af := &afData{afID: afID, subIDnum: afs.SubIDNum,
+			transIDnum: afs.TransIDNum, maxSubSupp: cfg.MaxSubSupport,
+			subs:     make(map[string]*afSubscription),
+			pfdtrans: make(map[string]*afPfdTransaction)}
+
+		for subID, s := range state.Subs[afID] {
+			sub := &afSubscription{subid: subID, ti: s.Ti,
+				appSessionID: s.AppSessionID, iid: s.Iid,
+				NotifCorreID:              s.NotifCorreID,
+				afNotificationDestination: s.AfNotificationDestination,
+				testNotif:                 s.TestNotif,
+				inactive:                  s.Inactive,
+				sbMu:                      &sync.Mutex{}}
+			afSubSetSBCallbacks(sub)
+			af.subs[subID] = sub
+			af.afCountSub(sub, 1)
+		}
+
+		for transID, t := range state.PfdTrans[afID] {
+			trans := &afPfdTransaction{transID: transID,
+				pfdManagement: t.PfdManagement, sbMu: &sync.Mutex{}}
+			afPfdTransSetSBCallbacks(trans)
+			af.pfdtrans[transID] = trans
+			atomic.AddInt64(&af.pfdTransNum, 1)
+		}
+		nef.afs[afID] = af
+		nef.afCount++
+		log.Infof("AF %s restored with %d subscriptions and %d PFD "+
+			"transactions", afID, len(af.subs), len(af.pfdtrans))
+	}
+	return nil
+}
 
-	// Todo
+// nefAllocCorrID allocates a new notification correlation ID
+func (nef *nefData) nefAllocCorrID() string {
+
+	nef.corrIDMu.Lock()
+	defer nef.corrIDMu.Unlock()
+
+	corrID := strconv.Itoa(int(nef.corrID))
+	nef.corrID++
+	if err := nef.store.PutCorrID(nef.corrID); err != nil {
+		log.Errf("Failed to store correlation ID: %v", err)
+	}
+	return corrID
+}
+
+// nefStoreAf persists the AF data in the NEF store
+func (nef *nefData) nefStoreAf(af *afData) {
+
+	err := nef.store.PutAf(NefStoreAf{AfID: af.afID, SubIDNum: af.subIDnum,
+		TransIDNum: af.transIDnum})
+	if err != nil {
+		log.Errf("Failed to store AF %s: %v", af.afID, err)
+	}
+}
+
+// nefStoreSub persists the subscription data in the NEF store
+func (nef *nefData) nefStoreSub(af *afData, sub *afSubscription) {
+
+	err := nef.store.PutSub(NefStoreSub{AfID: af.afID, SubID: sub.subid,
+		Ti: sub.ti, AppSessionID: sub.appSessionID, Iid: sub.iid,
+		NotifCorreID:              sub.NotifCorreID,
+		AfNotificationDestination: sub.afNotificationDestination,
+		TestNotif:                 sub.testNotif,
+		Inactive:                  sub.inactive})
+	if err != nil {
+		log.Errf("Failed to store subscription %s of AF %s: %v", sub.subid,
+			af.afID, err)
+	}
+}
+
+// nefStoreDelSub deletes the subscription data from the NEF store
+func (nef *nefData) nefStoreDelSub(af *afData, subID string) {
+
+	if err := nef.store.DeleteSub(af.afID, subID); err != nil {
+		log.Errf("Failed to delete subscription %s of AF %s from store: %v",
+			subID, af.afID, err)
+	}
+}
+
+// nefStorePfdTrans persists the PFD transaction in the NEF store. PFD reports
+// are sent only once to the AF so they are not persisted.
+func (nef *nefData) nefStorePfdTrans(af *afData, trans *afPfdTransaction) {
+
+	pfdMgmt := trans.pfdManagement
+	pfdMgmt.PfdReports = nil
+	err := nef.store.PutPfdTrans(NefStorePfdTrans{AfID: af.afID,
+		TransID: trans.transID, PfdManagement: pfdMgmt})
+	if err != nil {
+		log.Errf("Failed to store PFD transaction %s of AF %s: %v",
+			trans.transID, af.afID, err)
+	}
+}
+
+// nefStoreDelPfdTrans deletes the PFD transaction from the NEF store
+func (nef *nefData) nefStoreDelPfdTrans(af *afData, transID string) {
+
+	if err := nef.store.DeletePfdTrans(af.afID, transID); err != nil {
+		log.Errf("Failed to delete PFD transaction %s of AF %s from store: "+
+			"%v", transID, af.afID, err)
+	}
 }
@@ -0,0 +1,140 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Health and readiness of the NEF served by the admin listener. The NEF is
+ * healthy as long as it serves the requests. It is ready once its
+ * configuration is loaded, while it is not stopping and if the NFs of the
+ * southbound HTTP clients can be reached. The stub clients are always ready. */
+
+package ngcnef
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// healthProbeTimeout is the maximum time to probe the southbound NFs
+const healthProbeTimeout = 2 * time.Second
+
+// Health statuses
+const (
+	healthStatusUp   = "UP"
+	healthStatusDown = "DOWN"
+)
+
+// NefAdminCheck is the result of a readiness check
+type NefAdminCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// NefAdminHealth is the health or readiness of the NEF
+type NefAdminHealth struct {
+	Status string          `json:"status"`
+	Checks []NefAdminCheck `json:"checks,omitempty"`
+}
+
+// nefSBClient is a southbound client checked for the readiness
+type nefSBClient struct {
+	name   string
+	client interface{}
+}
+
+// sbProber is implemented by the southbound HTTP clients whose NF can be
+// probed
+type sbProber interface {
+	probe(ctx context.Context) error
+}
+
+func (c *PcfClient) probe(ctx context.Context) error {
+	return c.sb.probe(ctx)
+}
+
+func (c *UdrClient) probe(ctx context.Context) error {
+	return c.sb.probe(ctx)
+}
+
+func (c *UdrPfdClient) probe(ctx context.Context) error {
+	return c.sb.probe(ctx)
+}
+
+func (c *UdmClient) probe(ctx context.Context) error {
+	return c.sb.probe(ctx)
+}
+
+// nefReadinessChecks returns the readiness checks of the NEF
+func nefReadinessChecks(ctx context.Context,
+	nefCtx *nefContext) []NefAdminCheck {
+
+	checks := []NefAdminCheck{{Name: "config", Status: healthStatusUp,
+		Detail: nefCtx.cfgPath}}
+	if nefCtx.nef.ctx.Err() != nil {
+		checks = append(checks, NefAdminCheck{Name: "nef",
+			Status: healthStatusDown, Detail: "NEF stopping"})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	results := make([]chan NefAdminCheck, len(nefCtx.nef.sbClients))
+	for i, c := range nefCtx.nef.sbClients {
+		results[i] = make(chan NefAdminCheck, 1)
+		go func(c nefSBClient, result chan NefAdminCheck) {
+			check := NefAdminCheck{Name: c.name, Status: healthStatusUp,
+				Detail: "stub"}
+			if p, ok := c.client.(sbProber); ok {
+				check.Detail = "reachable"
+				if err := p.probe(ctx); err != nil {
+					check.Status = healthStatusDown
+					check.Detail = err.Error()
+				}
+			}
+			result <- check
+		}(c, results[i])
+	}
+	for _, result := range results {
+		checks = append(checks, <-result)
+	}
+	return checks
+}
+
+// sendHealthRsp sends the health, 503 if it is down
+func sendHealthRsp(w http.ResponseWriter, health NefAdminHealth) {
+
+	if health.Status == healthStatusUp {
+		sendAdminJSONRsp(w, health)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		log.Errf("Write Failed: %v", err)
+	}
+}
+
+// ReadHealth : Returns 200 while the NEF serves the requests
+func ReadHealth(w http.ResponseWriter, r *http.Request) {
+
+	sendAdminJSONRsp(w, NefAdminHealth{Status: healthStatusUp})
+}
+
+// ReadReadiness : Returns 200 if the NEF is ready to serve the AFs, 503
+// otherwise. The body contains the result of each check
+func ReadReadiness(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+
+	health := NefAdminHealth{Status: healthStatusUp,
+		Checks: nefReadinessChecks(r.Context(), nefCtx)}
+	for _, c := range health.Checks {
+		if c.Status != healthStatusUp {
+			health.Status = healthStatusDown
+			log.Infof("NEF not ready, %s: %s", c.Name, c.Detail)
+		}
+	}
+	sendHealthRsp(w, health)
+}
@@ -0,0 +1,151 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+const testAdminEndpoint = "127.0.0.1:18071"
+
+var _ = Describe("Test NEF admin listener", func() {
+
+	var (
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// getAdmin sends the GET request to the admin router and decodes the
+	// JSON response into body
+	getAdmin := func(uri string, body interface{}) int {
+		req := httptest.NewRequest("GET", "http://"+testAdminEndpoint+uri,
+			nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(json.Unmarshal(rr.Body.Bytes(), body)).Should(Succeed())
+		return rr.Code
+	}
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-admin")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["AdminConfig"] = map[string]interface{}{
+					"endpoint": testAdminEndpoint}
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+			})
+
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		pcfSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will serve the admin routes on the admin listener only", func() {
+		rsp, err := http.Get("http://" + testAdminEndpoint + "/healthz")
+		Expect(err).Should(BeNil())
+		Expect(rsp.StatusCode).Should(Equal(http.StatusOK))
+		Expect(rsp.Body.Close()).Should(Succeed())
+
+		// The operator routes are not reachable by the AFs
+		for _, r := range []struct{ method, uri string }{
+			{"GET", "/nef-admin/v1/subscriptions"},
+			{"POST", "/nef-admin/v1/notifications/dead-letters/replay"},
+			{"POST", "/nef-admin/v1/notifications/dead-letters/dl-1/replay"},
+			{"DELETE", "/nef-admin/v1/notifications/dead-letters/dl-1"},
+			{"DELETE", "/nef-admin/v1/geo-zones/zone-1"},
+			{"GET", "/metrics"},
+		} {
+			req := httptest.NewRequest(r.method, "http://localhost:8091"+r.uri,
+				nil)
+			rr := httptest.NewRecorder()
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusNotFound), r.uri)
+		}
+	})
+
+	It("Will be ready while the PCF is reachable", func() {
+		health := ngcnef.NefAdminHealth{}
+		Expect(getAdmin("/readyz", &health)).Should(Equal(http.StatusOK))
+		Expect(health.Status).Should(Equal("UP"))
+		Expect(health.Checks).Should(ContainElement(ngcnef.NefAdminCheck{
+			Name: "PCF", Status: "UP", Detail: "reachable"}))
+		Expect(health.Checks).Should(ContainElement(ngcnef.NefAdminCheck{
+			Name: "UDR", Status: "UP", Detail: "stub"}))
+
+		pcfSrv.Close()
+		health = ngcnef.NefAdminHealth{}
+		Expect(getAdmin("/readyz", &health)).Should(Equal(
+			http.StatusServiceUnavailable))
+		Expect(health.Status).Should(Equal("DOWN"))
+		for _, c := range health.Checks {
+			if c.Name == "PCF" {
+				Expect(c.Status).Should(Equal("DOWN"))
+			}
+		}
+	})
+
+	It("Will show the AFs, subscriptions and PFD transactions", func() {
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+		data, err = ioutil.ReadFile(testJSONPFDPath +
+			"AF_NEF_PFD_POST_001.json")
+		Expect(err).Should(BeNil())
+		req = httptest.NewRequest("POST", basePFDAPIURL,
+			bytes.NewBuffer(data))
+		rr = httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+		var afs []ngcnef.NefAdminAf
+		Expect(getAdmin("/nef-admin/v1/afs", &afs)).Should(Equal(
+			http.StatusOK))
+		Expect(afs).Should(Equal([]ngcnef.NefAdminAf{{AfID: "AF_01",
+			Subscriptions: 1, PfdTransactions: 1}}))
+
+		var subs []ngcnef.NefAdminSub
+		Expect(getAdmin("/nef-admin/v1/subscriptions?afId=AF_01",
+			&subs)).Should(Equal(http.StatusOK))
+		Expect(subs).Should(HaveLen(1))
+		Expect(subs[0].AppSessionID).Should(Equal(ngcnef.AppSessionID(
+			"100")))
+		Expect(subs[0].NotifCorreID).ShouldNot(BeEmpty())
+		Expect(subs[0].NotificationDestination).ShouldNot(BeEmpty())
+
+		var trans []ngcnef.NefAdminPfdTrans
+		Expect(getAdmin("/nef-admin/v1/pfd-transactions", &trans)).Should(
+			Equal(http.StatusOK))
+		Expect(trans).Should(HaveLen(1))
+		Expect(trans[0].AfID).Should(Equal("AF_01"))
+		Expect(trans[0].PfdManagement.PfdDatas).Should(HaveKey("app1"))
+	})
+})
@@ -0,0 +1,307 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Prometheus metrics of the NEF exposed on /metrics: the requests per route
+ * and status code, the southbound requests to the PCF and UDR, the
+ * subscriptions and PFD transactions per AF, the delivery of the AF
+ * notifications and the expiry of the TLS certificates. The southbound
+ * clients are wrapped so that the stub and HTTP clients are measured
+ * alike. */
+
+package ngcnef
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
+)
+
+// Southbound NF names used as label
+const (
+	metricsNfPCF = "PCF"
+	metricsNfUDR = "UDR"
+)
+
+// Results of the AF notifications used as label
+const (
+	metricsNotifDelivered  = "delivered"
+	metricsNotifDeadLetter = "dead_letter"
+	metricsAttemptSuccess  = "success"
+	metricsAttemptFailure  = "failure"
+)
+
+// nefMetrics contains the metrics of the NEF
+type nefMetrics struct {
+	registry      *metrics.Registry
+	http          *metrics.HTTPMetrics
+	sbRequests    *metrics.CounterVec
+	sbDuration    *metrics.HistogramVec
+	sbErrors      *metrics.CounterVec
+	notifs        *metrics.CounterVec
+	notifAttempts *metrics.CounterVec
+	tls           *tlsmgr.Metrics
+}
+
+// newNefMetrics registers the metrics of the NEF, the gauges are collected
+// from nef when scraped
+func newNefMetrics(nef *nefData) *nefMetrics {
+
+	r := metrics.NewRegistry()
+	m := &nefMetrics{registry: r,
+		http: metrics.NewHTTPMetrics(r, "nef"),
+		sbRequests: r.NewCounterVec("nef_sb_requests_total",
+			"Number of southbound requests by NF, operation and status "+
+				"code, error if no response was received",
+			"nf", "operation", "code"),
+		sbDuration: r.NewHistogramVec("nef_sb_request_duration_seconds",
+			"Latency of the southbound requests by NF and operation",
+			metrics.DefaultBuckets, "nf", "operation"),
+		sbErrors: r.NewCounterVec("nef_sb_request_errors_total",
+			"Number of failed southbound requests by NF and operation",
+			"nf", "operation"),
+		notifs: r.NewCounterVec("nef_notifications_total",
+			"Number of AF notifications by result, delivered or "+
+				"dead_letter", "result"),
+		notifAttempts: r.NewCounterVec("nef_notification_attempts_total",
+			"Number of AF notification attempts by result", "result"),
+		tls: tlsmgr.NewMetrics(r, "nef"),
+	}
+
+	r.NewGaugeFunc("nef_subscriptions",
+		"Number of traffic influence subscriptions by AF and state",
+		[]string{"af_id", "state"}, nef.collectSubs)
+	r.NewGaugeFunc("nef_pfd_transactions",
+		"Number of PFD transactions by AF", []string{"af_id"},
+		nef.collectPfdTrans)
+	r.NewGaugeFunc("nef_notification_dead_letters",
+		"Number of AF notification dead letters", nil,
+		func(set func(v float64, values ...string)) {
+			if nef.notifier != nil {
+				set(float64(len(nef.notifier.deadLetters(""))))
+			}
+		})
+	return m
+}
+
+// collectSubs reports the active and inactive subscriptions of each AF
+func (nef *nefData) collectSubs(set func(v float64, values ...string)) {
+
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
+	for _, af := range nef.afs {
+		set(float64(atomic.LoadInt64(&af.activeSubs)), af.afID, "active")
+		set(float64(atomic.LoadInt64(&af.inactiveSubs)), af.afID,
+			"inactive")
+	}
+}
+
+// collectPfdTrans reports the PFD transactions of each AF
+func (nef *nefData) collectPfdTrans(set func(v float64, values ...string)) {
+
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
+	for _, af := range nef.afs {
+		set(float64(atomic.LoadInt64(&af.pfdTransNum)), af.afID)
+	}
+}
+
+// afCountSub adds delta to the counter of the state of the subscription
+func (af *afData) afCountSub(sub *afSubscription, delta int64) {
+
+	if sub.inactive {
+		atomic.AddInt64(&af.inactiveSubs, delta)
+	} else {
+		atomic.AddInt64(&af.activeSubs, delta)
+	}
+}
+
+// afSetSubInactive sets the state of the subscription and moves it to the
+// counter of the new state, the caller must hold the AF lock. A pending
+// subscription is counted once created
+func (af *afData) afSetSubInactive(sub *afSubscription, inactive bool) {
+
+	if sub.pending {
+		sub.inactive = inactive
+		return
+	}
+	af.afCountSub(sub, -1)
+	sub.inactive = inactive
+	af.afCountSub(sub, 1)
+}
+
+// observeSB records a southbound request started at start. The request
+// failed if err is set or the NF responded with an error code
+func (m *nefMetrics) observeSB(nf string, op string, start time.Time,
+	code uint16, err error) {
+
+	m.sbDuration.Observe(time.Since(start).Seconds(), nf, op)
+	label := strconv.Itoa(int(code))
+	if err != nil {
+		label = "error"
+	}
+	m.sbRequests.Inc(nf, op, label)
+	if err != nil || code >= 400 {
+		m.sbErrors.Inc(nf, op)
+	}
+}
+
+// observeNotifAttempt records an attempt to deliver an AF notification
+func (m *nefMetrics) observeNotifAttempt(err error) {
+
+	if err != nil {
+		m.notifAttempts.Inc(metricsAttemptFailure)
+		return
+	}
+	m.notifAttempts.Inc(metricsAttemptSuccess)
+}
+
+// pcfMetricsClient measures the requests of the PCF client
+type pcfMetricsClient struct {
+	inner PcfPolicyAuthorization
+	m     *nefMetrics
+}
+
+// PolicyAuthorizationCreate is measured as create
+func (c *pcfMetricsClient) PolicyAuthorizationCreate(ctx context.Context,
+	body AppSessionContext) (AppSessionID, PcfPolicyResponse, error) {
+
+	start := time.Now()
+	id, rsp, err := c.inner.PolicyAuthorizationCreate(ctx, body)
+	c.m.observeSB(metricsNfPCF, "create", start, rsp.ResponseCode, err)
+	return id, rsp, err
+}
+
+// PolicyAuthorizationUpdate is measured as update
+func (c *pcfMetricsClient) PolicyAuthorizationUpdate(ctx context.Context,
+	body AppSessionContextUpdateData,
+	appSessionID AppSessionID) (PcfPolicyResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.PolicyAuthorizationUpdate(ctx, body, appSessionID)
+	c.m.observeSB(metricsNfPCF, "update", start, rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// PolicyAuthorizationDelete is measured as delete
+func (c *pcfMetricsClient) PolicyAuthorizationDelete(ctx context.Context,
+	appSessionID AppSessionID) (PcfPolicyResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.PolicyAuthorizationDelete(ctx, appSessionID)
+	c.m.observeSB(metricsNfPCF, "delete", start, rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// PolicyAuthorizationGet is measured as get
+func (c *pcfMetricsClient) PolicyAuthorizationGet(ctx context.Context,
+	appSessionID AppSessionID) (PcfPolicyResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.PolicyAuthorizationGet(ctx, appSessionID)
+	c.m.observeSB(metricsNfPCF, "get", start, rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// udrMetricsClient measures the requests of the UDR influence data client
+type udrMetricsClient struct {
+	inner UdrInfluenceData
+	m     *nefMetrics
+}
+
+// UdrInfluenceDataCreate is measured as influence_data_create
+func (c *udrMetricsClient) UdrInfluenceDataCreate(ctx context.Context,
+	body TrafficInfluData, iid InfluenceID) (UdrInfluenceResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.UdrInfluenceDataCreate(ctx, body, iid)
+	c.m.observeSB(metricsNfUDR, "influence_data_create", start,
+		rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// UdrInfluenceDataUpdate is measured as influence_data_update
+func (c *udrMetricsClient) UdrInfluenceDataUpdate(ctx context.Context,
+	body TrafficInfluDataPatch, iid InfluenceID) (UdrInfluenceResponse,
+	error) {
+
+	start := time.Now()
+	rsp, err := c.inner.UdrInfluenceDataUpdate(ctx, body, iid)
+	c.m.observeSB(metricsNfUDR, "influence_data_update", start,
+		rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// UdrInfluenceDataDelete is measured as influence_data_delete
+func (c *udrMetricsClient) UdrInfluenceDataDelete(ctx context.Context,
+	iid InfluenceID) (UdrInfluenceResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.UdrInfluenceDataDelete(ctx, iid)
+	c.m.observeSB(metricsNfUDR, "influence_data_delete", start,
+		rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// UdrInfluenceDataGet is measured as influence_data_get
+func (c *udrMetricsClient) UdrInfluenceDataGet(ctx context.Context,
+	filter UdrInfluenceDataFilter) (UdrInfluenceResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.UdrInfluenceDataGet(ctx, filter)
+	c.m.observeSB(metricsNfUDR, "influence_data_get", start,
+		rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// udrPfdMetricsClient measures the requests of the UDR PFD data client
+type udrPfdMetricsClient struct {
+	inner UdrPfdData
+	m     *nefMetrics
+}
+
+// UdrPfdDataCreate is measured as pfd_data_create
+func (c *udrPfdMetricsClient) UdrPfdDataCreate(ctx context.Context,
+	body PfdDataForApp) (UdrPfdResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.UdrPfdDataCreate(ctx, body)
+	c.m.observeSB(metricsNfUDR, "pfd_data_create", start, rsp.ResponseCode,
+		err)
+	return rsp, err
+}
+
+// UdrPfdDataGet is measured as pfd_data_get
+func (c *udrPfdMetricsClient) UdrPfdDataGet(ctx context.Context,
+	appID UdrAppID) (UdrPfdResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.UdrPfdDataGet(ctx, appID)
+	c.m.observeSB(metricsNfUDR, "pfd_data_get", start, rsp.ResponseCode, err)
+	return rsp, err
+}
+
+// UdrPfdDataDelete is measured as pfd_data_delete
+func (c *udrPfdMetricsClient) UdrPfdDataDelete(ctx context.Context,
+	appID UdrAppID) (UdrPfdResponse, error) {
+
+	start := time.Now()
+	rsp, err := c.inner.UdrPfdDataDelete(ctx, appID)
+	c.m.observeSB(metricsNfUDR, "pfd_data_delete", start, rsp.ResponseCode,
+		err)
+	return rsp, err
+}
+
+// ReadMetrics : Returns the metrics of the NEF in the Prometheus text format
+func ReadMetrics(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	nefCtx.nef.metrics.registry.Handler().ServeHTTP(w, r)
+}
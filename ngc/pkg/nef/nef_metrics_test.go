@@ -0,0 +1,161 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+var _ = Describe("Test NEF metrics", func() {
+
+	var (
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// getMetrics returns the exposition of the NEF metrics
+	getMetrics := func() string {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:18071/metrics",
+			nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		Expect(rr.Header().Get("Content-Type")).Should(
+			HavePrefix("text/plain; version=0.0.4"))
+		return rr.Body.String()
+	}
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-metrics")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+			})
+
+		nef = newTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		pcfSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will report the requests, PCF calls and subscriptions", func() {
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+		rr, req = CreateReqForNEF(ctx, "GET", "99999", nil)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNotFound))
+
+		m := getMetrics()
+		Expect(m).Should(ContainSubstring(`nef_http_requests_total{` +
+			`code="201",route="CreateTrafficInfluenceSubscription"} 1`))
+		Expect(m).Should(ContainSubstring(`nef_http_requests_total{` +
+			`code="404",route="ReadTrafficInfluenceSubscription"} 1`))
+		Expect(m).Should(ContainSubstring(`nef_http_request_duration_` +
+			`seconds_count{code="201",` +
+			`route="CreateTrafficInfluenceSubscription"} 1`))
+		Expect(m).Should(ContainSubstring(`nef_sb_requests_total{` +
+			`code="201",nf="PCF",operation="create"} 1`))
+		Expect(m).Should(ContainSubstring(`nef_sb_request_duration_` +
+			`seconds_count{nf="PCF",operation="create"} 1`))
+		Expect(m).Should(ContainSubstring(`nef_subscriptions{` +
+			`af_id="AF_01",state="active"} 1`))
+		Expect(m).Should(ContainSubstring(`nef_pfd_transactions{` +
+			`af_id="AF_01"} 0`))
+		Expect(m).ShouldNot(ContainSubstring("nef_sb_request_errors_total{"))
+
+		// The deleted subscriptions are no longer counted
+		rr, req = CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+		rr, req = CreateReqForNEF(ctx, "DELETE", "11111", nil)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNoContent))
+		Expect(getMetrics()).Should(ContainSubstring(`nef_subscriptions{` +
+			`af_id="AF_01",state="active"} 1`))
+	})
+
+	It("Will report the failed PCF calls", func() {
+		pcf.mu.Lock()
+		pcf.failCode = http.StatusInternalServerError
+		pcf.mu.Unlock()
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).ShouldNot(Equal(http.StatusCreated))
+
+		m := getMetrics()
+		Expect(m).Should(ContainSubstring(`nef_sb_requests_total{` +
+			`code="500",nf="PCF",operation="create"} 1`))
+		Expect(m).Should(ContainSubstring(`nef_sb_request_errors_total{` +
+			`nf="PCF",operation="create"} 1`))
+	})
+})
+
+var _ = Describe("Test NEF metrics without admin listener", func() {
+
+	var (
+		nef    *testNef
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-metrics")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				delete(cfg, "AdminConfig")
+			})
+
+		nef = startTestNef(cfgPath)
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will serve the metrics only on the HTTP listener", func() {
+		rsp, err := http.Get("http://127.0.0.1:8091/metrics")
+		Expect(err).Should(BeNil())
+		Expect(rsp.StatusCode).Should(Equal(http.StatusOK))
+		Expect(rsp.Header.Get("Content-Type")).Should(
+			HavePrefix("text/plain; version=0.0.4"))
+		Expect(rsp.Body.Close()).Should(Succeed())
+
+		// The other admin routes are still not served
+		rsp, err = http.Get("http://127.0.0.1:8091/nef-admin/v1/afs")
+		Expect(err).Should(BeNil())
+		Expect(rsp.StatusCode).Should(Equal(http.StatusNotFound))
+		Expect(rsp.Body.Close()).Should(Succeed())
+	})
+})
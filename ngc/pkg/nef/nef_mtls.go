@@ -0,0 +1,144 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Mutual TLS on the NEF HTTP/2 listener. When HTTP2Config.ClientCACert is
+ * set, the AF client certificates are verified with this CA bundle, and are
+ * required if HTTP2Config.ClientCertRequired is set. The identities of a
+ * client certificate are its subject, its subject common name and its DNS,
+ * URI and email SANs. On the AF routes, i.e. with {afId} or {scsAsId}, the
+ * AF of the path must be one of the identities or be allowed for one of them
+ * in HTTP2Config.ClientCertAfs. The check is done in addition to OAuth2 if
+ * enabled. The server certificate and the CA bundle are reloaded when their
+ * files change, checked every configWatchInterval. */
+
+package ngcnef
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
+)
+
+// nefTLSInterval returns the interval between two checks of the TLS files,
+// negative if they are not reloaded
+func nefTLSInterval(cfg *Config) time.Duration {
+
+	if interval := configWatchInterval(cfg); interval != 0 {
+		return interval
+	}
+	return -1
+}
+
+// nefTLSManager returns the manager of the certificate of the HTTP/2
+// listener and of the client CA bundle, if any
+func nefTLSManager(cfg *Config) (*tlsmgr.Manager, error) {
+
+	tlsCfg := tlsmgr.Config{CertPath: cfg.HTTP2Config.NefServerCert,
+		KeyPath: cfg.HTTP2Config.NefServerKey, Interval: nefTLSInterval(cfg)}
+	if cfg.HTTP2Config.ClientCACert != "" {
+		tlsCfg.CAPaths = []string{cfg.HTTP2Config.ClientCACert}
+	}
+	m := tlsmgr.New(tlsCfg)
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// nefServerTLSConfig returns the TLS configuration of the HTTP/2 listener,
+// verifying the AF client certificates if mutual TLS is enabled
+func nefServerTLSConfig(nefCtx *nefContext) (*tls.Config, error) {
+
+	m, err := nefTLSManager(&nefCtx.cfg)
+	if err != nil {
+		return nil, err
+	}
+	nefCtx.nef.metrics.tls.Set("server", m)
+
+	switch cfg := &nefCtx.cfg.HTTP2Config; {
+	case cfg.ClientCACert == "":
+		return m.ServerConfig(tls.NoClientCert), nil
+	case cfg.ClientCertRequired:
+		return m.ServerConfig(tls.RequireAndVerifyClientCert), nil
+	default:
+		return m.ServerConfig(tls.VerifyClientCertIfGiven), nil
+	}
+}
+
+// nefCertIdentities returns the identities of the client certificate
+func nefCertIdentities(cert *x509.Certificate) []string {
+
+	ids := []string{cert.Subject.String()}
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	ids = append(ids, cert.DNSNames...)
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+	return append(ids, cert.EmailAddresses...)
+}
+
+// nefCertAllowedForAf returns true if the client certificate is issued for
+// the AF or for a client allowed to act for the AF
+func nefCertAllowedForAf(cfg *Config, cert *x509.Certificate,
+	afID string) bool {
+
+	for _, id := range nefCertIdentities(cert) {
+		if id == afID {
+			return true
+		}
+		for _, allowed := range cfg.HTTP2Config.ClientCertAfs[id] {
+			if allowed == afID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nefClientCert returns the verified client certificate of the request, nil
+// if there is none
+func nefClientCert(r *http.Request) *x509.Certificate {
+
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 ||
+		len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// nefAuthorizeClientCert checks that the client certificate of the request,
+// if any, is issued for the AF of the path. The requests without client
+// certificate are rejected if it is required. 403 is sent if not authorized
+func nefAuthorizeClientCert(w http.ResponseWriter, r *http.Request,
+	nefCtx *nefContext) bool {
+
+	scope, afID := nefRouteAf(r)
+	if scope == "" {
+		return true
+	}
+
+	cfg := nefCtx.liveConfig()
+	cert := nefClientCert(r)
+	if cert == nil {
+		if cfg.HTTP2Config.ClientCertRequired {
+			nefSendForbidden(w, "Client certificate required",
+				"The request of AF "+afID+" has no client certificate")
+			return false
+		}
+		return true
+	}
+
+	if !nefCertAllowedForAf(cfg, cert, afID) {
+		nefSendForbidden(w, "AF not allowed",
+			"The client certificate "+cert.Subject.String()+
+				" is not issued for AF "+afID)
+		return false
+	}
+	return true
+}
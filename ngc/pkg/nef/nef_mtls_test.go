@@ -0,0 +1,229 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+	"golang.org/x/net/http2"
+)
+
+const testHTTP2Endpoint = "127.0.0.1:18090"
+
+// testCert is a certificate with its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert creates a certificate from the template, signed by the CA or
+// self-signed if ca is nil
+func newTestCert(tmpl *x509.Certificate, ca *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).Should(BeNil())
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	Expect(err).Should(BeNil())
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := tmpl, key
+	if ca != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent,
+		&key.PublicKey, signer)
+	Expect(err).Should(BeNil())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).Should(BeNil())
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and key in PEM files under dir
+func (c *testCert) write(dir string, name string) (string, string) {
+	certPath := filepath.Join(dir, name+"-cert.pem")
+	keyPath := filepath.Join(dir, name+"-key.pem")
+	Expect(ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: c.der}), 0600)).Should(Succeed())
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	Expect(err).Should(BeNil())
+	Expect(ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{
+		Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).Should(Succeed())
+	return certPath, keyPath
+}
+
+// tlsCertificate returns the certificate for a TLS configuration
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+var _ = Describe("Test NEF mutual TLS", func() {
+
+	var (
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+		ca     *testCert
+	)
+
+	// getSubs gets the subscriptions of the AF over HTTP/2 with the client
+	// certificate, if any
+	getSubs := func(afID string, client *testCert) (int, error) {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+		tlsCfg := &tls.Config{RootCAs: pool}
+		if client != nil {
+			tlsCfg.Certificates = []tls.Certificate{client.tlsCertificate()}
+		}
+		c := &http.Client{Timeout: 5 * time.Second,
+			Transport: &http2.Transport{TLSClientConfig: tlsCfg}}
+		rsp, err := c.Get("https://" + testHTTP2Endpoint +
+			"/3gpp-traffic-influence/v1/" + afID + "/subscriptions")
+		if err != nil {
+			return 0, err
+		}
+		Expect(rsp.Body.Close()).Should(Succeed())
+		return rsp.StatusCode, nil
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-mtls")
+		Expect(err).Should(BeNil())
+
+		ca = newTestCert(&x509.Certificate{
+			Subject: pkix.Name{CommonName: "NEF test CA"}, IsCA: true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign}, nil)
+		caPath, _ := ca.write(tmpDir, "ca")
+		server := newTestCert(&x509.Certificate{
+			Subject:     pkix.Name{CommonName: "nef"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, ca)
+		certPath, keyPath := server.write(tmpDir, "server")
+
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["configWatchInterval"] = 1
+				cfg["HTTP2Config"] = map[string]interface{}{
+					"endpoint":           testHTTP2Endpoint,
+					"NefServerCert":      certPath,
+					"NefServerKey":       keyPath,
+					"ClientCACert":       caPath,
+					"ClientCertRequired": true,
+					"ClientCertAfs": map[string][]string{
+						"gateway.af.example.com": {"AF_02"}}}
+			})
+
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will authorize the AFs of the client certificate", func() {
+		af01 := newTestCert(&x509.Certificate{
+			Subject:     pkix.Name{CommonName: "AF_01"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, ca)
+		Expect(getSubs("AF_01", af01)).Should(Equal(http.StatusOK))
+		Expect(getSubs("AF_02", af01)).Should(Equal(http.StatusForbidden))
+
+		gateway := newTestCert(&x509.Certificate{
+			Subject:     pkix.Name{CommonName: "gateway"},
+			DNSNames:    []string{"gateway.af.example.com"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, ca)
+		Expect(getSubs("AF_02", gateway)).Should(Equal(http.StatusOK))
+		Expect(getSubs("AF_01", gateway)).Should(Equal(http.StatusForbidden))
+	})
+
+	It("Will reject the AFs without trusted client certificate", func() {
+		_, err := getSubs("AF_01", nil)
+		Expect(err).Should(HaveOccurred())
+
+		other := newTestCert(&x509.Certificate{
+			Subject: pkix.Name{CommonName: "Other CA"}, IsCA: true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign}, nil)
+		untrusted := newTestCert(&x509.Certificate{
+			Subject:     pkix.Name{CommonName: "AF_01"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+			other)
+		_, err = getSubs("AF_01", untrusted)
+		Expect(err).Should(HaveOccurred())
+
+		// The client certificate is required on the HTTP 1.1 listener too
+		req := httptest.NewRequest("GET", "http://localhost:8091/"+
+			"3gpp-traffic-influence/v1/AF_01/subscriptions", nil)
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusForbidden))
+	})
+
+	It("Will reload the rotated server certificate and client CA", func() {
+		// serverName returns the common name of the server certificate
+		// verified with the CA, the client certificate being signed by it
+		serverName := func(issuer *testCert) func() (string, error) {
+			return func() (string, error) {
+				pool := x509.NewCertPool()
+				pool.AddCert(issuer.cert)
+				client := newTestCert(&x509.Certificate{
+					Subject: pkix.Name{CommonName: "AF_01"},
+					ExtKeyUsage: []x509.ExtKeyUsage{
+						x509.ExtKeyUsageClientAuth}}, issuer)
+				conn, err := tls.Dial("tcp", testHTTP2Endpoint,
+					&tls.Config{RootCAs: pool, NextProtos: []string{"h2"},
+						Certificates: []tls.Certificate{
+							client.tlsCertificate()}})
+				if err != nil {
+					return "", err
+				}
+				defer func() { _ = conn.Close() }()
+				return conn.ConnectionState().PeerCertificates[0].Subject.
+					CommonName, nil
+			}
+		}
+		Expect(serverName(ca)()).Should(Equal("nef"))
+
+		newCA := newTestCert(&x509.Certificate{
+			Subject: pkix.Name{CommonName: "NEF test CA 2"}, IsCA: true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign}, nil)
+		// The files are written in place of the current ones
+		_, _ = newCA.write(tmpDir, "ca")
+		server := newTestCert(&x509.Certificate{
+			Subject:     pkix.Name{CommonName: "nef rotated"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+			newCA)
+		_, _ = server.write(tmpDir, "server")
+
+		Eventually(serverName(newCA), 3*time.Second,
+			200*time.Millisecond).Should(Equal("nef rotated"))
+		_, err := serverName(ca)()
+		Expect(err).Should(HaveOccurred())
+	})
+})
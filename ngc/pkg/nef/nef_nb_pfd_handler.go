@@ -11,6 +11,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	//"strconv"
@@ -28,19 +30,19 @@ func createNewPFDTrans(nefCtx *nefContext, afID string,
 	var af *afData
 	nef := &nefCtx.nef
 
-	af, err = nef.nefGetAf(afID)
-
-	if err != nil {
-		log.Err("NO AF PRESENT CREATE AF")
-		af, err = nef.nefAddAf(nefCtx, afID)
+	for {
+		af, err = nef.nefGetOrAddAf(nefCtx, afID)
 		if err != nil {
 			return loc, rsp, err
 		}
-	} else {
-		log.Infoln("AF PRESENT")
-	}
 
-	loc, rsp, err = af.afAddPFDTransaction(nefCtx, trans)
+		loc, rsp, err = af.afAddPFDTransaction(nefCtx, trans)
+
+		// Retry if the AF was deleted by a concurrent request
+		if err != errAfDeleted {
+			break
+		}
+	}
 
 	if err != nil {
 		return loc, rsp, err
@@ -104,7 +106,6 @@ func CreatePFDManagementTransaction(w http.ResponseWriter,
 	r *http.Request) {
 
 	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
-	nef := &nefCtx.nef
 
 	vars := mux.Vars(r)
 	log.Infof(" AFID  : %s", vars["scsAsId"])
@@ -163,6 +164,10 @@ func CreatePFDManagementTransaction(w http.ResponseWriter,
 			sendPFDErrorResponseToAF(w, rsp1, "", pfdBody.PfdReports)
 			return
 		}
+		if rsp1, ok := quotaExceededRsp(nefCtx, err3); ok {
+			sendErrorResponseToAF(w, rsp1)
+			return
+		}
 		// fatal error return
 		rsp1 := nefSBRspData{errorCode: 400}
 		rsp1.pd.Title = "UDR Error in creating PFD transaction"
@@ -197,14 +202,7 @@ func CreatePFDManagementTransaction(w http.ResponseWriter,
 		log.Errf("Write Failed: %v", err)
 		return
 	}
-	// Deleting the PFD reports from the stored transactions once sent
-	afID := vars["scsAsId"]
-	transID := strconv.Itoa((nef.afs[afID].transIDnum) - 1)
-
-	for k := range nef.afs[afID].pfdtrans[transID].pfdManagement.PfdReports {
-		delete(nef.afs[afID].pfdtrans[transID].pfdManagement.PfdReports, k)
-	}
-	logNef(nef)
+	logNef(&nefCtx.nef)
 }
 
 // ReadPFDManagementTransaction : Read a particular PFD transaction details
@@ -373,6 +371,8 @@ func DeletePFDManagementTransaction(w http.ResponseWriter,
 	logNef(nef)
 }
 
+// afGetPfdTransCount returns the number of PFD transactions, the caller must
+// hold the AF lock
 func (af *afData) afGetPfdTransCount() (afPfdCount int) {
 
 	return len(af.pfdtrans)
@@ -668,14 +668,18 @@ func (af *afData) afUpdatePutPfdApplication(nefCtx *nefContext, transID string,
 	pfdReportList map[string]PfdReport) (rsp nefPFDSBRspData, updPfd PfdData,
 	err error) {
 
-	pfdTrans, ok := af.pfdtrans[transID]
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-	if !ok {
+	pfdTrans := af.afAcquirePfdTrans(transID)
+
+	if pfdTrans == nil {
 		rsp.result.errorCode = 404
 		rsp.result.pd.Title = pfdNotFound
 
 		return rsp, updPfd, errors.New(pfdNotFound)
 	}
+	defer pfdTrans.sbMu.Unlock()
 	trans, ok := pfdTrans.pfdManagement.PfdDatas[appID]
 
 	if !ok {
@@ -684,7 +688,10 @@ func (af *afData) afUpdatePutPfdApplication(nefCtx *nefContext, transID string,
 		return rsp, trans, errors.New(appNotFound)
 	}
 
-	rsp, err = pfdTrans.NEFSBAppPfdPut(pfdTrans, nefCtx, pfdData)
+	sbTrans := pfdTrans.afPfdTransSBCopy()
+	af.mu.Unlock()
+	rsp, err = sbTrans.NEFSBAppPfdPut(sbTrans, nefCtx, pfdData)
+	af.mu.Lock()
 
 	if err != nil {
 		log.Err("Failed to Update the PFD Application")
@@ -700,7 +707,8 @@ func (af *afData) afUpdatePutPfdApplication(nefCtx *nefContext, transID string,
 	}
 
 	pfdData.Self = trans.Self
-	pfdTrans.pfdManagement.PfdDatas[appID] = pfdData
+	pfdTrans.pfdManagement.PfdDatas[appID] = clonePfdData(pfdData)
+	nefCtx.nef.nefStorePfdTrans(af, pfdTrans)
 
 	updPfd = pfdData
 
@@ -713,14 +721,18 @@ func (af *afData) afUpdatePatchPfdApplication(nefCtx *nefContext,
 	pfdReportList map[string]PfdReport) (rsp nefPFDSBRspData,
 	updPfd PfdData, err error) {
 
-	pfdTrans, ok := af.pfdtrans[transID]
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-	if !ok {
+	pfdTrans := af.afAcquirePfdTrans(transID)
+
+	if pfdTrans == nil {
 		rsp.result.errorCode = 404
 		rsp.result.pd.Title = pfdNotFound
 
 		return rsp, updPfd, errors.New(pfdNotFound)
 	}
+	defer pfdTrans.sbMu.Unlock()
 
 	trans, ok := pfdTrans.pfdManagement.PfdDatas[appID]
 
@@ -731,7 +743,10 @@ func (af *afData) afUpdatePatchPfdApplication(nefCtx *nefContext,
 
 	}
 
-	rsp, err = pfdTrans.NEFSBAppPfdPut(pfdTrans, nefCtx, pfdData)
+	sbTrans := pfdTrans.afPfdTransSBCopy()
+	af.mu.Unlock()
+	rsp, err = sbTrans.NEFSBAppPfdPut(sbTrans, nefCtx, pfdData)
+	af.mu.Lock()
 
 	if err != nil {
 		log.Err("Failed to Update the PFD Application")
@@ -758,7 +773,8 @@ func (af *afData) afUpdatePatchPfdApplication(nefCtx *nefContext,
 
 	}
 	pfdData.Self = trans.Self
-	pfdTrans.pfdManagement.PfdDatas[appID] = pfdData
+	pfdTrans.pfdManagement.PfdDatas[appID] = clonePfdData(pfdData)
+	nefCtx.nef.nefStorePfdTrans(af, pfdTrans)
 
 	updPfd = pfdData
 
@@ -770,23 +786,30 @@ func (af *afData) afUpdatePutPfdTransaction(nefCtx *nefContext, transID string,
 	trans PfdManagement) (rsp map[string]nefPFDSBRspData, updPfd PfdManagement,
 	err error) {
 
-	pfdTrans, ok := af.pfdtrans[transID]
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-	if !ok {
+	pfdTrans := af.afAcquirePfdTrans(transID)
+
+	if pfdTrans == nil {
 
 		return rsp, updPfd, errors.New(pfdNotFound)
 	}
+	defer pfdTrans.sbMu.Unlock()
 
 	// PUT should not have any new application
 	for key := range trans.PfdDatas {
-		_, ok = pfdTrans.pfdManagement.PfdDatas[key]
+		_, ok := pfdTrans.pfdManagement.PfdDatas[key]
 		if !ok {
 			//Return error
 			return rsp, trans, errors.New("Application not present")
 		}
 	}
 
-	rsp, err = pfdTrans.NEFSBPfdPut(pfdTrans, nefCtx, trans)
+	sbTrans := pfdTrans.afPfdTransSBCopy()
+	af.mu.Unlock()
+	rsp, err = sbTrans.NEFSBPfdPut(sbTrans, nefCtx, trans)
+	af.mu.Lock()
 
 	if err != nil {
 
@@ -806,7 +829,9 @@ func (af *afData) afUpdatePutPfdTransaction(nefCtx *nefContext, transID string,
 		v.Self = pfdTrans.pfdManagement.PfdDatas[key].Self
 		updPfd.PfdDatas[key] = v
 	}
-	pfdTrans.pfdManagement = updPfd
+	pfdTrans.pfdManagement = clonePfdManagement(updPfd)
+	pfdTrans.pfdManagement.PfdReports = nil
+	nefCtx.nef.nefStorePfdTrans(af, pfdTrans)
 
 	log.Infoln("Update PFD transaction Successful")
 	return rsp, updPfd, err
@@ -815,16 +840,23 @@ func (af *afData) afUpdatePutPfdTransaction(nefCtx *nefContext, transID string,
 func (af *afData) afDeletePfdTransaction(nefCtx *nefContext,
 	pfdTrans string) (rsp nefPFDSBRspData, err error) {
 
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
 	//Check if PFD transaction is already present
-	trans, ok := af.pfdtrans[pfdTrans]
+	trans := af.afAcquirePfdTrans(pfdTrans)
 
-	if !ok {
+	if trans == nil {
 		rsp.result.errorCode = 404
 		rsp.result.pd.Title = pfdNotFound
 		return rsp, errors.New(pfdNotFound)
 	}
+	defer trans.sbMu.Unlock()
 
-	rsp, err = trans.NEFSBPfdDelete(trans, nefCtx)
+	sbTrans := trans.afPfdTransSBCopy()
+	af.mu.Unlock()
+	rsp, err = sbTrans.NEFSBPfdDelete(sbTrans, nefCtx)
+	af.mu.Lock()
 	if err != nil {
 		log.Err("Failed to Delete PFD transaction")
 		rsp.result.errorCode = 400
@@ -833,6 +865,8 @@ func (af *afData) afDeletePfdTransaction(nefCtx *nefContext,
 
 	//Delete local entry in map of pfd transactions
 	delete(af.pfdtrans, pfdTrans)
+	atomic.AddInt64(&af.pfdTransNum, -1)
+	nefCtx.nef.nefStoreDelPfdTrans(af, pfdTrans)
 
 	// TBD check if all trans and sub deleted for AF then delete AF
 
@@ -842,9 +876,12 @@ func (af *afData) afDeletePfdTransaction(nefCtx *nefContext,
 func (af *afData) afGetPfdApplication(nefCtx *nefContext,
 	transID string, appID string) (rsp nefSBRspData, trans PfdData, err error) {
 
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
 	transPfd, ok := af.pfdtrans[transID]
 
-	if !ok {
+	if !ok || transPfd.pending {
 		rsp.errorCode = 404
 		rsp.pd.Title = pfdNotFound
 		return rsp, trans, errors.New(pfdNotFound)
@@ -859,21 +896,25 @@ func (af *afData) afGetPfdApplication(nefCtx *nefContext,
 	}
 
 	//Return locally
-	return rsp, trans, err
+	return rsp, clonePfdData(trans), err
 }
 
 func (af *afData) afDeletePfdApplication(nefCtx *nefContext,
 	transID string, appID string) (rsp nefSBRspData, err error) {
 
-	transPfd, ok := af.pfdtrans[transID]
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-	if !ok {
+	transPfd := af.afAcquirePfdTrans(transID)
+
+	if transPfd == nil {
 		rsp.errorCode = 404
 		rsp.pd.Title = pfdNotFound
 		return rsp, errors.New(pfdNotFound)
 	}
+	defer transPfd.sbMu.Unlock()
 
-	_, ok = transPfd.pfdManagement.PfdDatas[appID]
+	_, ok := transPfd.pfdManagement.PfdDatas[appID]
 
 	if !ok {
 		rsp.errorCode = 404
@@ -886,6 +927,10 @@ func (af *afData) afDeletePfdApplication(nefCtx *nefContext,
 	// If all apps in trans are deleted, delete the trans
 	if len(transPfd.pfdManagement.PfdDatas) == 0 {
 		delete(af.pfdtrans, transID)
+		atomic.AddInt64(&af.pfdTransNum, -1)
+		nefCtx.nef.nefStoreDelPfdTrans(af, transID)
+	} else {
+		nefCtx.nef.nefStorePfdTrans(af, transPfd)
 	}
 
 	return rsp, err
@@ -894,22 +939,37 @@ func (af *afData) afDeletePfdApplication(nefCtx *nefContext,
 func (af *afData) afGetPfdTransaction(nefCtx *nefContext,
 	transID string) (rsp nefPFDSBRspData, trans PfdManagement, err error) {
 
-	transPfd, ok := af.pfdtrans[transID]
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-	if !ok {
+	return af.getPfdTransaction(nefCtx, transID)
+}
+
+// getPfdTransaction returns a copy of the PFD transaction, the caller must
+// hold the AF lock, which is released during the southbound request
+func (af *afData) getPfdTransaction(nefCtx *nefContext,
+	transID string) (rsp nefPFDSBRspData, trans PfdManagement, err error) {
+
+	transPfd := af.afAcquirePfdTrans(transID)
+
+	if transPfd == nil {
 		rsp.result.errorCode = 404
 		rsp.result.pd.Title = pfdNotFound
 		return rsp, trans, errors.New(pfdNotFound)
 	}
+	defer transPfd.sbMu.Unlock()
 
-	_, rsp, err = transPfd.NEFSBPfdGet(transPfd, nefCtx)
+	sbTrans := transPfd.afPfdTransSBCopy()
+	af.mu.Unlock()
+	_, rsp, err = sbTrans.NEFSBPfdGet(sbTrans, nefCtx)
+	af.mu.Lock()
 	if err != nil {
 		log.Infoln("Failed to Get PFD transaction")
-		return rsp, transPfd.pfdManagement, err
+		return rsp, sbTrans.pfdManagement, err
 	}
 
 	//Return locally
-	return rsp, transPfd.pfdManagement, err
+	return rsp, sbTrans.pfdManagement, err
 }
 
 func (af *afData) afGetPfdTransactionList(nefCtx *nefContext) (
@@ -917,17 +977,28 @@ func (af *afData) afGetPfdTransactionList(nefCtx *nefContext) (
 
 	var transPfd PfdManagement
 
-	if len(af.pfdtrans) > 0 {
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
+	//The AF lock is released during the southbound requests
+	transIDs := make([]string, 0, len(af.pfdtrans))
+	for key := range af.pfdtrans {
+		transIDs = append(transIDs, key)
+	}
 
-		for key := range af.pfdtrans {
+	for _, key := range transIDs {
 
-			rsp, transPfd, err = af.afGetPfdTransaction(nefCtx, key)
+		rsp, transPfd, err = af.getPfdTransaction(nefCtx, key)
 
-			if err != nil {
-				return rsp, transList, err
+		if err != nil {
+			//The transaction may have been deleted meanwhile
+			if _, ok := af.pfdtrans[key]; !ok {
+				rsp, err = nefPFDSBRspData{}, nil
+				continue
 			}
-			transList = append(transList, transPfd)
+			return rsp, transList, err
 		}
+		transList = append(transList, transPfd)
 	}
 	return rsp, transList, err
 }
@@ -938,28 +1009,48 @@ func (af *afData) afAddPFDTransaction(nefCtx *nefContext,
 	err error) {
 
 	rsp = make(map[string]nefPFDSBRspData)
+
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
+	if af.deleted {
+		return "", rsp, errAfDeleted
+	}
+
 	/*Check if max subscription reached */
-	if len(af.pfdtrans) >= nefCtx.cfg.MaxPfdTransSupport {
+	if len(af.pfdtrans) >= nefCtx.liveConfig().MaxPfdTransSupport {
 
 		return "", rsp, errors.New("MAX TRANS Created")
 	}
+	if af.afPfdAppQuotaExceeded(nefCtx, len(trans.PfdDatas)) {
+		return "", rsp, errPfdAppQuotaExceeded
+	}
 
 	//Generate a unique transaction ID string
 	transIDStr := strconv.Itoa(af.transIDnum)
 	af.transIDnum++
 
-	//Create PFD transaction data
-	aftrans := afPfdTransaction{transID: transIDStr, pfdManagement: trans}
+	//Create PFD transaction data. The transaction is reserved while its
+	//PFDs are installed without the AF lock, it is removed if the
+	//installation fails
+	aftrans := &afPfdTransaction{transID: transIDStr,
+		pfdManagement: clonePfdManagement(trans), sbMu: &sync.Mutex{},
+		pending: true}
 
-	aftrans.NEFSBPfdGet = nefSBUDRPFDGet
-	aftrans.NEFSBAppPfdPut = nefSBUDRAPPPFDPut
-	aftrans.NEFSBPfdPut = nefSBUDRPFDPut
-	aftrans.NEFSBPfdDelete = nefSBUDRPFDDelete
+	afPfdTransSetSBCallbacks(aftrans)
+
+	aftrans.sbMu.Lock()
+	defer aftrans.sbMu.Unlock()
+	af.pfdtrans[transIDStr] = aftrans
 
-	rsp, err = aftrans.NEFSBPfdPut(&aftrans, nefCtx, trans)
+	sbTrans := aftrans.afPfdTransSBCopy()
+	af.mu.Unlock()
+	rsp, err = sbTrans.NEFSBPfdPut(sbTrans, nefCtx, trans)
+	af.mu.Lock()
 
 	if err != nil {
 		//Return fatal  error
+		delete(af.pfdtrans, transIDStr)
 		return "", rsp, err
 	}
 
@@ -968,33 +1059,80 @@ func (af *afData) afAddPFDTransaction(nefCtx *nefContext,
 	err = updatePfdTransOnRsp(rsp, trans)
 
 	if err != nil {
+		delete(af.pfdtrans, transIDStr)
 		return "", rsp, errors.New(pfdAppsFailed)
 	}
 
-	//Link the PFD transaction with the AF
-	af.pfdtrans[transIDStr] = &aftrans
-
 	//Create Location URI
 	loc = nefCtx.nef.locationURLPrefixPfd + af.afID + "/transactions/" +
 		transIDStr
 
-	af.pfdtrans[transIDStr].pfdManagement.Self = Link(loc)
+	trans.Self = Link(loc)
 
 	//Also update the self link in each application
-	for k, v := range af.pfdtrans[transIDStr].pfdManagement.PfdDatas {
+	for k, v := range trans.PfdDatas {
 
 		/*Assign the application ID in the link */
 		v.Self = Link(loc) + "/applications/" + Link(k)
 		log.Infof("Application ID is %s", k)
-		af.pfdtrans[transIDStr].pfdManagement.PfdDatas[k] = v
+		trans.PfdDatas[k] = v
 
 	}
 
+	//Link a copy of the PFD transaction with the AF, the PFD reports are
+	//only sent once in the response
+	aftrans.pfdManagement = clonePfdManagement(trans)
+	aftrans.pfdManagement.PfdReports = nil
+	aftrans.pending = false
+	atomic.AddInt64(&af.pfdTransNum, 1)
+
+	nefCtx.nef.nefStoreAf(af)
+	nefCtx.nef.nefStorePfdTrans(af, aftrans)
+
 	log.Infoln(" NEW AF PFD transaction added " + transIDStr)
 
 	return loc, rsp, nil
 }
 
+// afAcquirePfdTrans waits for the southbound requests in progress on the PFD
+// transaction and locks it for the caller, nil if not found. The caller must
+// hold the AF lock, which is released while waiting
+func (af *afData) afAcquirePfdTrans(transID string) *afPfdTransaction {
+
+	for {
+		trans, ok := af.pfdtrans[transID]
+		if !ok {
+			return nil
+		}
+		af.mu.Unlock()
+		trans.sbMu.Lock()
+		af.mu.Lock()
+		//The transaction may have been deleted meanwhile
+		if af.pfdtrans[transID] == trans {
+			return trans
+		}
+		trans.sbMu.Unlock()
+	}
+}
+
+// afPfdTransSBCopy returns a copy of the PFD transaction for the southbound
+// requests, the caller must hold the AF lock
+func (trans *afPfdTransaction) afPfdTransSBCopy() *afPfdTransaction {
+
+	sbTrans := *trans
+	sbTrans.pfdManagement = clonePfdManagement(trans.pfdManagement)
+	return &sbTrans
+}
+
+// afPfdTransSetSBCallbacks sets the SB callbacks of the PFD transaction
+func afPfdTransSetSBCallbacks(aftrans *afPfdTransaction) {
+
+	aftrans.NEFSBPfdGet = nefSBUDRPFDGet
+	aftrans.NEFSBAppPfdPut = nefSBUDRAPPPFDPut
+	aftrans.NEFSBPfdPut = nefSBUDRPFDPut
+	aftrans.NEFSBPfdDelete = nefSBUDRPFDDelete
+}
+
 // Generate the notification uri for PFD
 func getNefLocationURLPrefixPfd(cfg *Config) string {
 
@@ -1103,14 +1241,23 @@ func nefSBUDRAPPPFDGet(transData *afPfdTransaction, nefCtx *nefContext,
 	log.Info("nefSBUDRAPPPFDGet Entered ")
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	r, e := nef.udrPfdClient.UdrPfdDataGet(cliCtx, UdrAppID(appID))
 	if e != nil {
-
+		if r.ResponseCode == 0 {
+			// UDR could not be reached or the response was invalid
+			rsp.result.errorCode = http.StatusServiceUnavailable
+			rsp.result.pd.Title = "UDR not available"
+		}
 		return appPfd, rsp, e
 	}
+	if r.AppPfd == nil {
+		rsp.result.errorCode = 404
+		rsp.result.pd.Title = appNotFound
+		return appPfd, rsp, errors.New(appNotFound)
+	}
 	appPfd.ExternalAppID = string(r.AppPfd.AppID)
 
 	appPfd.Pfds = make(map[string]Pfd)
@@ -1155,7 +1302,7 @@ func nefSBUDRAPPPFDPut(transData *afPfdTransaction, nefCtx *nefContext,
 	nef := &nefCtx.nef
 	var pfdApp PfdDataForApp
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	/*Timer for pfdApp.AllowedDelay can be started here, not supported
@@ -1166,7 +1313,8 @@ func nefSBUDRAPPPFDPut(transData *afPfdTransaction, nefCtx *nefContext,
 	if app.CachingTime != nil {
 
 		i := time.Duration(*app.CachingTime)
-		timeLater := DateTime(time.Now().Add(time.Second * i).String())
+		timeLater := DateTime(time.Now().Add(time.Second * i).UTC().Format(
+			time.RFC3339))
 		pfdApp.CachingTime = &timeLater
 	}
 
@@ -1180,13 +1328,44 @@ func nefSBUDRAPPPFDPut(transData *afPfdTransaction, nefCtx *nefContext,
 		pfdApp.Pfds = append(pfdApp.Pfds, c)
 	}
 
-	_, e := nef.udrPfdClient.UdrPfdDataCreate(cliCtx, pfdApp)
+	r, e := nef.udrPfdClient.UdrPfdDataCreate(cliCtx, pfdApp)
 
-	if e != nil {
+	if _, ok := e.(sbDecodeError); ok {
+		log.Errf("UDR PFD Put for %s: invalid response %d: %v",
+			app.ExternalAppID, r.ResponseCode, e)
+		if r.ResponseCode >= 300 {
+			// The failure of the application is reported to AF and the
+			// other ones are sent
+			rsp.result.errorCode = http.StatusBadRequest
+			var fc FailureCode = OtherReason
+			rsp.fc = &fc
+			return rsp, nil
+		}
+		// The UDR stored the PFDs of the application although its
+		// response cannot be decoded
+		e = nil
+	}
+
+	// The other errors of the UDR client abort the transaction
+	if e != nil && r.ResponseCode != 0 {
 		rsp.result.errorCode = 400
 		return rsp, e
 	}
 
+	if e != nil || r.ResponseCode >= 300 {
+		// Failure of the application is reported to AF in the PFD report
+		fc := udrPfdFailureCode(r.ResponseCode, r.Pd)
+		log.Errf("UDR PFD Put for %s failed: %d %v", app.ExternalAppID,
+			r.ResponseCode, e)
+		rsp.result.errorCode = int(r.ResponseCode)
+		if r.ResponseCode == 0 {
+			// No response was received from the UDR
+			rsp.result.errorCode = http.StatusInternalServerError
+		}
+		rsp.fc = &fc
+		return rsp, nil
+	}
+
 	if TestNEFSB {
 		rsp.result.errorCode = 400
 		var fc FailureCode = OtherReason
@@ -1222,15 +1401,24 @@ func nefSBUDRAPPPFDDelete(transData *afPfdTransaction, nefCtx *nefContext,
 	appID ApplicationID) (rsp nefPFDSBRspData, err error) {
 
 	nef := &nefCtx.nef
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
-	_, e := nef.udrPfdClient.UdrPfdDataDelete(cliCtx, UdrAppID(appID))
+	r, e := nef.udrPfdClient.UdrPfdDataDelete(cliCtx, UdrAppID(appID))
 
 	if e != nil {
 		return rsp, e
 	}
 
+	// Not found is ignored as the PFDs of the application are already removed
+	if r.ResponseCode >= 300 && r.ResponseCode != http.StatusNotFound {
+		rsp.result.errorCode = int(r.ResponseCode)
+		if r.Pd != nil {
+			rsp.result.pd = *r.Pd
+		}
+		return rsp, errors.New("UDR PFD Delete Failure")
+	}
+
 	return rsp, nil
 }
 
@@ -1283,49 +1471,26 @@ func updatePfdAppOnRsp(rsp nefPFDSBRspData, pfdData PfdData,
 func generatePfdReport(appID string,
 	failureReason string, pfdReportList map[string]PfdReport) {
 
-	switch failureReason {
-
-	case "APP_ID_DUPLICATED":
-		if _, ok := pfdReportList[failureReason]; !ok {
-			// Create the first PFD report
-			var appIds []string
-			appIds = append(appIds, appID)
-			pfdReport := PfdReport{ExternalAppIds: appIds,
-				FailureCode: AppIDDuplicated}
-			pfdReportList[failureReason] = pfdReport
-
-		} else {
-			pfdReport := pfdReportList[failureReason]
-			pfdReport.ExternalAppIds = append(pfdReport.ExternalAppIds,
-				appID)
-			pfdReportList[failureReason] = pfdReport
-		}
-	case "OTHER_REASON":
-		if _, ok := pfdReportList[failureReason]; !ok {
-			// Create the first PFD report
-			var appIds []string
-			appIds = append(appIds, appID)
-			pfdReport := PfdReport{ExternalAppIds: appIds,
-				FailureCode: OtherReason}
-			pfdReportList[failureReason] = pfdReport
-
-		} else {
-			pfdReport := pfdReportList[failureReason]
-			pfdReport.ExternalAppIds = append(pfdReport.ExternalAppIds,
-				appID)
-			pfdReportList[failureReason] = pfdReport
-		}
-
-	case "MALFUNCTION":
-		log.Infoln("MALFUNCTION failure Code is not handled")
+	pfdReport, ok := pfdReportList[failureReason]
+	if !ok {
+		// Create the first PFD report
+		pfdReport = PfdReport{FailureCode: FailureCode(failureReason)}
+	}
+	pfdReport.ExternalAppIds = append(pfdReport.ExternalAppIds, appID)
+	pfdReportList[failureReason] = pfdReport
+}
 
-	case "RESOURCE_LIMITATION":
-		log.Infoln("RESOURCE_LIMITATION failure code not handled")
+// udrPfdFailureCode maps the UDR failure into the PFD report failure code
+func udrPfdFailureCode(code uint16, pd *ProblemDetails) FailureCode {
 
-	case "SHORT_DELAY":
-		log.Infoln("SHORT_DELAY failure code is not handled")
+	if code == http.StatusInsufficientStorage ||
+		(pd != nil && pd.Cause == "INSUFFICIENT_RESOURCES") {
+		return ResourceLimitation
 	}
-
+	if code == 0 || code >= 500 {
+		return Malfunction
+	}
+	return OtherReason
 }
 
 func sendPFDErrorResponseToAF(w http.ResponseWriter,
@@ -1377,16 +1542,55 @@ func sendPFDErrorResponseToAF(w http.ResponseWriter,
 func nefCheckPfdAppIDExists(appID string, nefCtx *nefContext) bool {
 
 	nef := &nefCtx.nef
+
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
 	for _, v := range nef.afs {
+		v.mu.Lock()
 		for _, trans := range v.pfdtrans {
-			for key := range trans.pfdManagement.PfdDatas {
-				if key == appID {
-					return true
-				}
-
+			if _, ok := trans.pfdManagement.PfdDatas[appID]; ok {
+				v.mu.Unlock()
+				return true
 			}
 		}
+		v.mu.Unlock()
 	}
 	return false
 
 }
+
+// clonePfdData returns a deep copy of the PFD data so that the data stored
+// in the AF context is not shared with a request or response
+func clonePfdData(pfdData PfdData) PfdData {
+
+	if pfdData.Pfds != nil {
+		pfds := make(map[string]Pfd, len(pfdData.Pfds))
+		for k, v := range pfdData.Pfds {
+			pfds[k] = v
+		}
+		pfdData.Pfds = pfds
+	}
+	return pfdData
+}
+
+// clonePfdManagement returns a deep copy of the PFD transaction so that the
+// data stored in the AF context is not shared with a request or response
+func clonePfdManagement(trans PfdManagement) PfdManagement {
+
+	if trans.PfdDatas != nil {
+		pfdDatas := make(map[string]PfdData, len(trans.PfdDatas))
+		for k, v := range trans.PfdDatas {
+			pfdDatas[k] = clonePfdData(v)
+		}
+		trans.PfdDatas = pfdDatas
+	}
+	if trans.PfdReports != nil {
+		pfdReports := make(map[string]PfdReport, len(trans.PfdReports))
+		for k, v := range trans.PfdReports {
+			pfdReports[k] = v
+		}
+		trans.PfdReports = pfdReports
+	}
+	return trans
+}
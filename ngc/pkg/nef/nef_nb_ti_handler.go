@@ -12,6 +12,8 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	//"strconv"
 
@@ -24,19 +26,19 @@ func createNewSub(nefCtx *nefContext, afID string,
 	var af *afData
 	nef := &nefCtx.nef
 
-	af, err = nef.nefGetAf(afID)
-
-	if err != nil {
-		log.Err("NO AF PRESENT CREATE AF")
-		af, err = nef.nefAddAf(nefCtx, afID)
+	for {
+		af, err = nef.nefGetOrAddAf(nefCtx, afID)
 		if err != nil {
 			return loc, rsp, err
 		}
-	} else {
-		log.Infoln("AF PRESENT")
-	}
 
-	loc, rsp, err = af.afAddSubscription(nefCtx, ti)
+		loc, rsp, err = af.afAddSubscription(nefCtx, ti)
+
+		// Retry if the AF was deleted by a concurrent request
+		if err != errAfDeleted {
+			break
+		}
+	}
 
 	if err != nil {
 		return loc, rsp, err
@@ -125,6 +127,15 @@ func CreateTrafficInfluenceSubscription(w http.ResponseWriter,
 
 	//validate the mandatory parameters
 	resRsp, status := validateAFTrafficInfluenceData(trInBody)
+	if status {
+		resRsp, status = validateGeoZoneIDs(nefCtx, trInBody.ValidGeoZoneIDs)
+	}
+	if status {
+		resRsp, status = validateAfServiceID(nefCtx, vars["afId"], trInBody)
+	}
+	if status {
+		resRsp, status = validateExternalGroup(r.Context(), nefCtx, trInBody)
+	}
 	if !status {
 		log.Err(resRsp.pd.Title)
 		sendErrorResponseToAF(w, resRsp)
@@ -135,14 +146,21 @@ func CreateTrafficInfluenceSubscription(w http.ResponseWriter,
 
 	if err3 != nil {
 		log.Err(err3)
-		// we return bad request here since we have reached the max
-		rsp.errorCode = 400
+		// we return bad request here since we have reached the max, the
+		// unknown UE or group is returned as not found and the exceeded
+		// quota as too many requests
+		if err3 != errUdmUeNotFound && err3 != errGroupNotFound &&
+			err3 != errSubQuotaExceeded {
+			rsp.errorCode = 400
+		}
 		sendErrorResponseToAF(w, rsp)
 		return
 	}
 	log.Infoln(loc)
 
 	trInBody.Self = Link(loc)
+	trInBody.WebsockNotifConfig = afWebsockNotifConfig(
+		trInBody.WebsockNotifConfig, loc)
 
 	//Martshal data and send into the body
 	mdata, err2 := json.Marshal(trInBody)
@@ -248,6 +266,25 @@ func UpdatePutTrafficInfluenceSubscription(w http.ResponseWriter,
 			return
 		}
 
+		resRsp, status := validateTempValidities(trInBody.TempValidities)
+		if status {
+			resRsp, status = validateGeoZoneIDs(nefCtx,
+				trInBody.ValidGeoZoneIDs)
+		}
+		if status {
+			resRsp, status = validateAfServiceID(nefCtx, vars["afId"],
+				trInBody)
+		}
+		if status {
+			resRsp, status = validateExternalGroup(r.Context(), nefCtx,
+				trInBody)
+		}
+		if !status {
+			log.Err(resRsp.pd.Title)
+			sendErrorResponseToAF(w, resRsp)
+			return
+		}
+
 		rsp, newTI, err := af.afUpdateSubscription(nefCtx,
 			vars["subscriptionId"], trInBody)
 
@@ -316,6 +353,17 @@ func UpdatePatchTrafficInfluenceSubscription(w http.ResponseWriter,
 			return
 		}
 
+		resRsp, status := validateTempValidities(TrInSPBody.TempValidities)
+		if status {
+			resRsp, status = validateGeoZoneIDs(nefCtx,
+				TrInSPBody.ValidGeoZoneIDs)
+		}
+		if !status {
+			log.Err(resRsp.pd.Title)
+			sendErrorResponseToAF(w, resRsp)
+			return
+		}
+
 		rsp, ti, err := af.afPartialUpdateSubscription(nefCtx,
 			vars["subscriptionId"], TrInSPBody)
 
@@ -379,25 +427,20 @@ func DeleteTrafficInfluenceSubscription(w http.ResponseWriter,
 
 	log.Infof("HTTP Response sent: %d", http.StatusNoContent)
 
-	if af.afGetSubCount() == 0 {
-
-		_ = nef.nefDeleteAf(vars["afId"])
-	}
+	nef.nefCheckDeleteAf(vars["afId"])
 
 	logNef(nef)
 }
 
-// NotifySmfUPFEvent : Handles the SMF notification for UPF event
+// NotifySmfUPFEvent : Handles the SMF notification for UPF event. All the
+// supported events of the notification are relayed to the AF, a PDU session
+// release may also delete the single UE subscription
 func NotifySmfUPFEvent(w http.ResponseWriter,
 	r *http.Request) {
 
 	var (
-		smfEv    NsmfEventExposureNotification
-		ev       EventNotification
-		afURL    URI
-		nsmEvNo  NsmEventNotification
-		i        int
-		upfFound bool
+		smfEv NsmfEventExposureNotification
+		evs   []EventNotification
 	)
 
 	if r.Body == nil {
@@ -420,31 +463,31 @@ func NotifySmfUPFEvent(w http.ResponseWriter,
 		return
 	}
 
-	// Check if notification events with UP_PATH_CH is present
+	// Check if notification events are present
 	if len(smfEv.EventNotifs) == 0 {
 		log.Errf("NotifySmfUPFEvent missing event notifications")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	for i, nsmEvNo = range smfEv.EventNotifs {
-		if nsmEvNo.Event == "UP_PATH_CH" {
-			log.Infof("NotifySmfUPFEvent found an entry for UP_PATH_CH"+
-				"at index: %d", i)
-			upfFound = true
-			break
+	// Map the content of NsmfEventExposureNotification to EventNotificaiton
+	for i, nsmEvNo := range smfEv.EventNotifs {
+		ev, ok := smfEventToAfEvent(nsmEvNo)
+		if !ok {
+			log.Infof("NotifySmfUPFEvent ignoring unsupported event %s "+
+				"at index: %d", nsmEvNo.Event, i)
+			continue
 		}
-
+		evs = append(evs, ev)
 	}
 
-	if !upfFound {
-		log.Errf("NotifySmfUPFEvent missing event with UP_PATH_CH")
+	if len(evs) == 0 {
+		log.Errf("NotifySmfUPFEvent missing supported event")
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	// Map the content of NsmfEventExposureNotification to EventNotificaiton
 	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
-	afSubs, err1 := getSubFromCorrID(nefCtx, smfEv.NotifID)
+	afID, afSubs, err1 := getSubFromCorrID(nefCtx, smfEv.NotifID)
 	if err1 != nil {
 		log.Errf("NotifySmfUPFEvent getSubFromCorrId [%s]: %s",
 			smfEv.NotifID, err1.Error())
@@ -455,48 +498,143 @@ func NotifySmfUPFEvent(w http.ResponseWriter,
 		smfEv.NotifID, afSubs.ti.AfTransID,
 		afSubs.ti.NotificationDestination)
 
-	ev.AfTransID = afSubs.ti.AfTransID
-	afURL = URI(afSubs.ti.NotificationDestination)
+	w.WriteHeader(http.StatusOK)
+
+	released := false
+	for _, ev := range evs {
+		if ev.SubscribedEvent == SubscribedEventPduSessionRelease {
+			released = true
+		}
+		if !tiSubscribedTo(afSubs.ti, ev.SubscribedEvent) {
+			log.Infof("NotifySmfUPFEvent %s not subscribed by the AF",
+				ev.SubscribedEvent)
+			continue
+		}
+		ev.AfTransID = afSubs.ti.AfTransID
+
+		// The acknowledgement is expected before the AF can send it
+		if afAckRequired(afSubs.ti, ev) {
+			ev.AfAckInd = true
+			nefCtx.nef.acks.expect(afID, afSubs.subid, smfEv.NotifID,
+				smfEv.AckURI, ev)
+		}
+
+		// Queue the request towards AF, it is retried until delivered
+		nefCtx.nef.notifier.enqueue(afID, afSubs.subid,
+			URI(afSubs.ti.NotificationDestination), ev)
+	}
+
+	if released && nefCtx.cfg.SmfEventConfig.DeleteSubOnPduSesRel &&
+		isSingleUeSub(afSubs.ti) {
+		nefReleaseSub(nefCtx, afID, afSubs.subid)
+	}
+}
+
+// smfEventToAfEvent maps the SMF event notification to the notification of
+// the AF. ok is false if the event is not supported
+func smfEventToAfEvent(nsmEvNo NsmEventNotification) (ev EventNotification,
+	ok bool) {
+
 	ev.Gpsi = nsmEvNo.Gpsi
-	ev.DnaiChgType = nsmEvNo.DnaiChgType
-	ev.SrcUeIpv4Addr = nsmEvNo.SourceUeIpv4Addr
-	ev.SrcUeIpv6Prefix = nsmEvNo.SourceUeIpv6Prefix
-	ev.TgtUeIpv4Addr = nsmEvNo.TargetUeIpv4Addr
-	ev.TgtUeIpv6Prefix = nsmEvNo.TargetUeIpv6Prefix
-	ev.UeMac = nsmEvNo.UeMac
-	ev.SourceTrafficRoute = nsmEvNo.SourceTraRouting
-	ev.SubscribedEvent = SubscribedEvent("UP_PATH_CHANGE")
-	ev.TargetTrafficRoute = nsmEvNo.TargetTraRouting
+	switch nsmEvNo.Event {
+	case SmfEventUpPathCh:
+		ev.SubscribedEvent = SubscribedEventUpPathChange
+		ev.DnaiChgType = nsmEvNo.DnaiChgType
+		ev.SrcUeIpv4Addr = nsmEvNo.SourceUeIpv4Addr
+		ev.SrcUeIpv6Prefix = nsmEvNo.SourceUeIpv6Prefix
+		ev.TgtUeIpv4Addr = nsmEvNo.TargetUeIpv4Addr
+		ev.TgtUeIpv6Prefix = nsmEvNo.TargetUeIpv6Prefix
+		ev.UeMac = nsmEvNo.UeMac
+		ev.SourceTrafficRoute = nsmEvNo.SourceTraRouting
+		ev.TargetTrafficRoute = nsmEvNo.TargetTraRouting
+	case SmfEventUeIPCh:
+		// The removed addresses are the source ones and the added addresses
+		// the target ones
+		ev.SubscribedEvent = SubscribedEventUeIPChange
+		ev.SrcUeIpv4Addr = nsmEvNo.ReIpv4Addr
+		ev.SrcUeIpv6Prefix = nsmEvNo.ReIpv6Prefix
+		ev.TgtUeIpv4Addr = nsmEvNo.AdIpv4Addr
+		ev.TgtUeIpv6Prefix = nsmEvNo.AdIpv6Prefix
+	case SmfEventPlmnCh:
+		plmnID := nsmEvNo.PlmnID
+		ev.SubscribedEvent = SubscribedEventPlmnChange
+		ev.PlmnID = &plmnID
+	case SmfEventAcTyCh:
+		ev.SubscribedEvent = SubscribedEventAccessTypeChange
+		ev.AccType = nsmEvNo.AccType
+	case SmfEventPduSesRel:
+		ev.SubscribedEvent = SubscribedEventPduSessionRelease
+		ev.PduSeID = nsmEvNo.PduSeID
+	default:
+		return ev, false
+	}
+	return ev, true
+}
 
-	w.WriteHeader(http.StatusOK)
+// tiSubscribedTo returns true if the AF subscribed to the event. The UP path
+// changes are always notified, the other events only if they are in the
+// subscribedEvents or if no event is listed
+func tiSubscribedTo(ti TrafficInfluSub, event SubscribedEvent) bool {
+
+	if event == SubscribedEventUpPathChange || len(ti.SubscribedEvents) == 0 {
+		return true
+	}
+	for _, e := range ti.SubscribedEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
 
-	// Send the request towards AF
-	var afClient AfNotification = NewAfClient(&nefCtx.cfg)
-	err := afClient.AfNotificationUpfEvent(r.Context(), afURL, ev)
+// nefReleaseSub deletes the single UE subscription whose PDU session was
+// released
+func nefReleaseSub(nefCtx *nefContext, afID string, subID string) {
+
+	nef := &nefCtx.nef
+
+	af, err := nef.nefGetAf(afID)
 	if err != nil {
-		log.Errf("NotifySmfUPFEvent sending to AF failed : %s",
-			err.Error())
+		log.Errf("PDU session release of subscription %s: %v", subID, err)
+		return
+	}
+	if _, err = af.afDeleteSubscription(nefCtx, subID); err != nil {
+		log.Errf("Failed to delete subscription %s of AF %s on PDU session "+
+			"release: %v", subID, afID, err)
+		return
 	}
+	log.Infof("Subscription %s of AF %s deleted on PDU session release",
+		subID, afID)
+	nef.nefCheckDeleteAf(afID)
 }
 
-func getSubFromCorrID(nefCtx *nefContext, corrID string) (sub *afSubscription,
-	err error) {
+// getSubFromCorrID returns the AF ID and a copy of the subscription with the
+// notification correlation ID
+func getSubFromCorrID(nefCtx *nefContext, corrID string) (afID string,
+	sub *afSubscription, err error) {
 
 	nef := &nefCtx.nef
 
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
 	/*Search across all the AF registered */
 	for _, value := range nef.afs {
 
+		value.mu.Lock()
 		/*Search across all the Subscription*/
 		for _, vs := range value.subs {
 
 			if vs.NotifCorreID == corrID {
-				/*Match found return sub handle*/
-				return vs, nil
+				/*Match found return a copy of the sub */
+				subCopy := *vs
+				value.mu.Unlock()
+				return value.afID, &subCopy, nil
 			}
 		}
+		value.mu.Unlock()
 	}
-	return sub, errors.New("Subscription Not Found")
+	return "", sub, errors.New("Subscription Not Found")
 }
 
 //validateAFTrafficInfluenceData: Function to validate mandatory parameters of
@@ -525,65 +663,51 @@ func validateAFTrafficInfluenceData(ti TrafficInfluSub) (rsp nefSBRspData,
 			"ethTrafficFilters"
 		return rsp, false
 	}
-	return rsp, true
+	return validateTempValidities(ti.TempValidities)
 }
 
 //Creates a new subscription
 func (af *afData) afAddSubscription(nefCtx *nefContext,
 	ti TrafficInfluSub) (loc string, rsp nefSBRspData, err error) {
 
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
+	if af.deleted {
+		return "", rsp, errAfDeleted
+	}
+
 	/*Check if max subscription reached */
-	if len(af.subs) >= nefCtx.cfg.MaxSubSupport {
+	if len(af.subs) >= nefCtx.liveConfig().MaxSubSupport {
 
 		rsp.errorCode = 400
 		rsp.pd.Title = "MAX Subscription Reached"
 		return "", rsp, errors.New("MAX SUBS Created")
 	}
+	if af.afSubQuotaExceeded(nefCtx) {
+		rsp, _ = quotaExceededRsp(nefCtx, errSubQuotaExceeded)
+		return "", rsp, errSubQuotaExceeded
+	}
 
 	//Generate a unique subscription ID string
 	subIDStr := strconv.Itoa(af.subIDnum)
 	af.subIDnum++
 
-	//Create Subscription data
-	afsub := afSubscription{subid: subIDStr, ti: ti, appSessionID: "",
-		NotifCorreID: "", iid: ""}
+	//Create Subscription data, inactive until its policy is installed
+	afsub := &afSubscription{subid: subIDStr, ti: ti, appSessionID: "",
+		NotifCorreID: "", iid: "", inactive: true, sbMu: &sync.Mutex{},
+		pending: true}
 
-	if len(ti.Gpsi) > 0 || len(ti.Ipv4Addr) > 0 || len(ti.Ipv6Addr) > 0 {
+	if isSingleUeSub(ti) {
 
 		//Applicable to single UE, PCF case
 
-		rsp, err = nefSBPCFPost(&afsub, nefCtx, ti)
-
-		if err != nil {
-
-			//Return error failed to create subscription
-			return "", rsp, err
-		}
-		//Store Notification Destination URI
-		afsub.afNotificationDestination = ti.NotificationDestination
-		afsub.NEFSBGet = nefSBPCFGet
-		afsub.NEFSBPut = nefSBPCFPut
-		afsub.NEFSBPatch = nefSBPCFPatch
-		afsub.NEFSBDelete = nefSBPCFDelete
-
 	} else if len(ti.ExternalGroupID) > 0 || ti.AnyUeInd {
 
 		//Applicable to Any UE, UDR case
 
-		rsp, err = nefSBUDRPost(&afsub, nefCtx, ti)
-
-		if err != nil {
-
-			//Return error
-			return "", rsp, err
-		}
-		//Store Notification Destination URI
-		afsub.afNotificationDestination = ti.NotificationDestination
-
-		afsub.NEFSBGet = nefSBUDRGet
-		afsub.NEFSBPut = nefSBUDRPut
-		afsub.NEFSBPatch = nefSBUDRPatch
-		afsub.NEFSBDelete = nefSBUDRDelete
+		//Influence data is identified in UDR by the AF and subscription
+		afsub.iid = InfluenceID(af.afID + "-" + subIDStr)
 
 	} else {
 		//Invalid case. Return Error
@@ -592,42 +716,186 @@ func (af *afData) afAddSubscription(nefCtx *nefContext,
 		return "", rsp, errors.New("Invalid AF Request")
 	}
 
-	//Link the subscription with the AF
-	af.subs[subIDStr] = &afsub
+	//The subscription is reserved while its policy is installed without the
+	//AF lock, it is removed if the installation fails
+	afsub.sbMu.Lock()
+	defer afsub.sbMu.Unlock()
+	af.subs[subIDStr] = afsub
+
+	//The policy is installed later if the subscription starts in the future
+	if active, _ := tiActiveNow(ti); active {
+		sbSub := *afsub
+		af.mu.Unlock()
+		rsp, err = afSubSBPost(nefCtx, &sbSub)
+		af.mu.Lock()
+
+		if err != nil {
+
+			//Return error failed to create subscription
+			delete(af.subs, subIDStr)
+			return "", rsp, err
+		}
+		afsub.afSubSBCommit(&sbSub)
+		afsub.inactive = false
+	}
+	afsub.pending = false
+	af.afCountSub(afsub, 1)
+
+	//Store Notification Destination URI
+	afsub.afNotificationDestination = ti.NotificationDestination
+	afSubSetSBCallbacks(afsub)
 
 	//Create Location URI
 	loc = nefCtx.nef.locationURLPrefix + af.afID + "/subscriptions/" +
 		subIDStr
 
 	afsub.ti.Self = Link(loc)
+	afsub.ti.WebsockNotifConfig = afWebsockNotifConfig(ti.WebsockNotifConfig,
+		loc)
+
+	nefCtx.nef.nefStoreAf(af)
+	nefCtx.nef.nefStoreSub(af, afsub)
+	af.afScheduleSub(nefCtx, afsub)
+	af.afSendTestNotif(nefCtx, afsub)
 
 	log.Infoln(" NEW AF Subscription added " + subIDStr)
 
 	return loc, rsp, nil
 }
 
+// isSingleUeSub returns true if the traffic influence is applicable to a
+// single UE, which is handled through PCF. Otherwise it is handled through UDR
+func isSingleUeSub(ti TrafficInfluSub) bool {
+
+	return len(ti.Gpsi) > 0 || len(ti.Ipv4Addr) > 0 || len(ti.Ipv6Addr) > 0
+}
+
+// afSubSetSBCallbacks sets the SB callbacks of the subscription based on the
+// traffic influence data
+func afSubSetSBCallbacks(afsub *afSubscription) {
+
+	if isSingleUeSub(afsub.ti) {
+		afsub.NEFSBGet = nefSBPCFGet
+		afsub.NEFSBPut = nefSBPCFPut
+		afsub.NEFSBPatch = nefSBPCFPatch
+		afsub.NEFSBDelete = nefSBPCFDelete
+		return
+	}
+	afsub.NEFSBGet = nefSBUDRGet
+	afsub.NEFSBPut = nefSBUDRPut
+	afsub.NEFSBPatch = nefSBUDRPatch
+	afsub.NEFSBDelete = nefSBUDRDelete
+}
+
+// afAcquireSub waits for the southbound requests in progress on the
+// subscription and locks it for the caller, nil if not found. The caller
+// must hold the AF lock, which is released while waiting
+func (af *afData) afAcquireSub(subID string) *afSubscription {
+
+	for {
+		sub, ok := af.subs[subID]
+		if !ok {
+			return nil
+		}
+		af.mu.Unlock()
+		sub.sbMu.Lock()
+		af.mu.Lock()
+		//The subscription may have been deleted meanwhile
+		if af.subs[subID] == sub {
+			return sub
+		}
+		sub.sbMu.Unlock()
+	}
+}
+
+// afSubSBCommit updates the subscription with the southbound state of the
+// copy used for the southbound requests, the caller must hold the AF lock
+func (sub *afSubscription) afSubSBCommit(sbSub *afSubscription) {
+
+	sub.appSessionID = sbSub.appSessionID
+	sub.NotifCorreID = sbSub.NotifCorreID
+}
+
+// afSendTestNotif sends a test notification to the AF if requested in the
+// subscription, the caller must hold the AF lock. The test notification only
+// contains the afTransId. The delivery result is recorded on the
+// subscription
+func (af *afData) afSendTestNotif(nefCtx *nefContext, sub *afSubscription) {
+
+	if !sub.ti.RequestTestNotification {
+		return
+	}
+	nef := &nefCtx.nef
+
+	log.Infof("Sending test notification of subscription %s to %s",
+		sub.subid, sub.ti.NotificationDestination)
+	sent := time.Now()
+	nef.notifier.sendOnce(af.afID, sub.subid,
+		URI(sub.ti.NotificationDestination),
+		EventNotification{AfTransID: sub.ti.AfTransID}, func(err error) {
+
+			res := &NefTestNotifResult{Sent: sent, Delivered: err == nil}
+			if err != nil {
+				res.Reason = err.Error()
+				log.Errf("Test notification of subscription %s failed: %v",
+					sub.subid, err)
+			} else {
+				log.Infof("Test notification of subscription %s delivered",
+					sub.subid)
+			}
+
+			af.mu.Lock()
+			defer af.mu.Unlock()
+			// The subscription may have been deleted meanwhile
+			if af.subs[sub.subid] != sub {
+				return
+			}
+			sub.testNotif = res
+			nef.nefStoreSub(af, sub)
+		})
+}
+
 func (af *afData) afUpdateSubscription(nefCtx *nefContext, subID string,
 	ti TrafficInfluSub) (rsp nefSBRspData, updtTI TrafficInfluSub, err error) {
 
-	sub, ok := af.subs[subID]
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-	if !ok {
+	sub := af.afAcquireSub(subID)
+
+	if sub == nil {
 		rsp.errorCode = 400
 		rsp.pd.Title = subNotFound
 
 		return rsp, updtTI, errors.New(subNotFound)
 	}
+	defer sub.sbMu.Unlock()
 
-	rsp, err = sub.NEFSBPut(sub, nefCtx, ti)
+	//The policy of an inactive subscription is installed by its timer
+	if active, _ := tiActiveNow(ti); active && !sub.inactive {
+		sbSub := *sub
+		af.mu.Unlock()
+		rsp, err = sbSub.NEFSBPut(&sbSub, nefCtx, ti)
+		af.mu.Lock()
 
-	if err != nil {
-		log.Err("Failed to Update Subscription")
-		return rsp, updtTI, err
+		if err != nil {
+			log.Err("Failed to Update Subscription")
+			return rsp, updtTI, err
+		}
+		sub.afSubSBCommit(&sbSub)
 	}
 
 	updtTI = ti
 	updtTI.Self = sub.ti.Self
+	updtTI.WebsockNotifConfig = afWebsockNotifConfig(ti.WebsockNotifConfig,
+		string(sub.ti.Self))
+	if updtTI.WebsockNotifConfig.WebsocketURI == "" {
+		nefCtx.nef.notifier.websocks.close(af.afID, subID)
+	}
 	sub.ti = updtTI
+	nefCtx.nef.nefStoreSub(af, sub)
+	af.afScheduleSub(nefCtx, sub)
+	af.afSendTestNotif(nefCtx, sub)
 
 	log.Infoln("Update Subscription Successful")
 	return rsp, updtTI, err
@@ -669,21 +937,43 @@ func (af *afData) afPartialUpdateSubscription(nefCtx *nefContext, subID string,
 	tisp TrafficInfluSubPatch) (rsp nefSBRspData, ti TrafficInfluSub,
 	err error) {
 
-	sub, ok := af.subs[subID]
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-	if !ok {
+	sub := af.afAcquireSub(subID)
+
+	if sub == nil {
 		rsp.errorCode = 400
 		rsp.pd.Title = subNotFound
 		return rsp, ti, errors.New(subNotFound)
 	}
+	defer sub.sbMu.Unlock()
 
-	rsp, err = sub.NEFSBPatch(sub, nefCtx, tisp)
+	//The policy of an inactive subscription is installed by its timer
+	ti = sub.ti
+	updateTiFromTisp(&ti, tisp)
 
-	if err != nil {
-		log.Err("Failed to Patch Subscription")
-		return rsp, ti, err
+	// The patched traffic routes must be allowed by the AF service
+	if vRsp, ok := validateAfServiceID(nefCtx, af.afID, ti); !ok {
+		log.Err(vRsp.pd.Title)
+		return vRsp, ti, errors.New(vRsp.pd.Title)
 	}
-	updateTiFromTisp(&sub.ti, tisp)
+
+	if active, _ := tiActiveNow(ti); active && !sub.inactive {
+		sbSub := *sub
+		af.mu.Unlock()
+		rsp, err = sbSub.NEFSBPatch(&sbSub, nefCtx, tisp)
+		af.mu.Lock()
+
+		if err != nil {
+			log.Err("Failed to Patch Subscription")
+			return rsp, ti, err
+		}
+		sub.afSubSBCommit(&sbSub)
+	}
+	sub.ti = ti
+	nefCtx.nef.nefStoreSub(af, sub)
+	af.afScheduleSub(nefCtx, sub)
 
 	return rsp, sub.ti, err
 
@@ -692,9 +982,12 @@ func (af *afData) afPartialUpdateSubscription(nefCtx *nefContext, subID string,
 func (af *afData) afGetSubscription(nefCtx *nefContext,
 	subID string) (rsp nefSBRspData, ti TrafficInfluSub, err error) {
 
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
 	sub, ok := af.subs[subID]
 
-	if !ok {
+	if !ok || sub.pending {
 		rsp.errorCode = 404
 		rsp.pd.Title = subNotFound
 		return rsp, ti, errors.New(subNotFound)
@@ -718,18 +1011,13 @@ func (af *afData) afGetSubscription(nefCtx *nefContext,
 func (af *afData) afGetSubscriptionList(nefCtx *nefContext) (rsp nefSBRspData,
 	subsList []TrafficInfluSub, err error) {
 
-	var ti TrafficInfluSub
-
-	if len(af.subs) > 0 {
+	af.mu.Lock()
+	defer af.mu.Unlock()
 
-		for key := range af.subs {
-
-			rsp, ti, err = af.afGetSubscription(nefCtx, key)
-
-			if err != nil {
-				return rsp, subsList, err
-			}
-			subsList = append(subsList, ti)
+	//Return locally
+	for _, sub := range af.subs {
+		if !sub.pending {
+			subsList = append(subsList, sub.ti)
 		}
 	}
 	return rsp, subsList, err
@@ -738,29 +1026,45 @@ func (af *afData) afGetSubscriptionList(nefCtx *nefContext) (rsp nefSBRspData,
 func (af *afData) afDeleteSubscription(nefCtx *nefContext,
 	subID string) (rsp nefSBRspData, err error) {
 
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
 	//Check if AF is already present
-	sub, ok := af.subs[subID]
+	sub := af.afAcquireSub(subID)
 
-	if !ok {
+	if sub == nil {
 		rsp.errorCode = 404
 		rsp.pd.Title = subNotFound
 		return rsp, errors.New(subNotFound)
 	}
+	defer sub.sbMu.Unlock()
 
-	rsp, err = sub.NEFSBDelete(sub, nefCtx)
+	//No policy is installed for an inactive subscription
+	if !sub.inactive {
+		sbSub := *sub
+		af.mu.Unlock()
+		rsp, err = sbSub.NEFSBDelete(&sbSub, nefCtx)
+		af.mu.Lock()
 
-	if err != nil {
-		log.Err("Failed to Delete Subscription")
-		return rsp, err
+		if err != nil {
+			log.Err("Failed to Delete Subscription")
+			return rsp, err
+		}
 	}
 
 	//Delete local entry in map
 	delete(af.subs, subID)
+	af.afCountSub(sub, -1)
+	af.afStopSubTimer(sub)
+	nefCtx.nef.notifier.websocks.close(af.afID, subID)
 	//af.subIDnum--
+	nefCtx.nef.nefStoreDelSub(af, subID)
 
 	return rsp, err
 }
 
+// afGetSubCount returns the number of subscriptions, the caller must hold
+// the AF lock
 func (af *afData) afGetSubCount() (afCount int) {
 
 	return len(af.subs)
@@ -821,11 +1125,10 @@ func nefSBPCFPost(pcfSub *afSubscription, nefCtx *nefContext,
 	var appSessID AppSessionID
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
-	pcfSub.NotifCorreID = strconv.Itoa(int(nef.corrID))
-	nef.corrID++
+	pcfSub.NotifCorreID = nef.nefAllocCorrID()
 
 	appSessCtx := AppSessionContext{}
 	pcfPolicyResp := PcfPolicyResponse{}
@@ -863,54 +1166,75 @@ func nefSBPCFPost(pcfSub *afSubscription, nefCtx *nefContext,
 	_ = copy(appSessCtx.AscReqData.AfRoutReq.TempVals, ti.TempValidities)
 
 	//Populating Spatial Validity in App Session Context
-	_ = getSpatialValidityData(cliCtx, nefCtx,
-		&appSessCtx.AscReqData.AfRoutReq.SpVal)
+	appSessCtx.AscReqData.AfRoutReq.SpVal = getSpatialValidityData(nefCtx,
+		ti.ValidGeoZoneIDs)
 
 	//Populating IP and Mac Addresses in App Session Context
 	appSessCtx.AscReqData.UeIpv4 = ti.Ipv4Addr
 	appSessCtx.AscReqData.UeIpv6 = ti.Ipv6Addr
 	appSessCtx.AscReqData.UeMac = ti.MacAddr
 
-	//Populating DNN and NW Slice Info and SUPI in App Session Context
-	for _, afServIdcounter := range nefCtx.cfg.AfServiceIDs {
-		afServiceID := afServIdcounter.(map[string]interface{})
-		if 0 == strings.Compare(ti.AfServiceID, afServiceID["id"].(string)) {
-			appSessCtx.AscReqData.Dnn = Dnn(afServiceID["dnn"].(string))
-			appSessCtx.AscReqData.SliceInfo.Sd = afServiceID["snssai"].(string)
-			appSessCtx.AscReqData.SliceInfo.Sst =
-				uint8(len(appSessCtx.AscReqData.SliceInfo.Sd))
-		}
-	}
+	//Populating DNN and NW Slice Info in App Session Context
+	appSessCtx.AscReqData.Dnn, appSessCtx.AscReqData.SliceInfo =
+		getAfServiceData(nefCtx, ti)
 
 	//Populating SUPI in App Session Context
-	_ = getSupiData(cliCtx, nefCtx, &appSessCtx.AscReqData.Supi)
+	appSessCtx.AscReqData.Supi, rsp, err = getSupiData(cliCtx, nefCtx,
+		ti.Gpsi)
+	if err != nil {
+		return rsp, err
+	}
+	appSessCtx.AscReqData.Gpsi = ti.Gpsi
 
 	appSessID, pcfPolicyResp, err =
 		nef.pcfClient.PolicyAuthorizationCreate(cliCtx, appSessCtx)
 
+	rsp, err = nefSBPCFRsp("Create", pcfPolicyResp, err)
+	if err == nil {
+		pcfSub.appSessionID = appSessID
+	}
+	return rsp, err
+}
+
+// nefSBPCFRsp : This function converts the Policy Authorization response
+//               from PCF into the SB response data.
+// Input Args:
+//   - op: Policy Authorization operation, used for logging
+//   - pcfPolicyResp: Policy Authorization response received from PCF
+//   - err: error returned by the PCF client
+// Output Args:
+//    - rsp: This is Policy Authorization Response Data
+//    - error: retruns error in case there is failure happened in sending the
+//             request or any failure response is received.
+func nefSBPCFRsp(op string, pcfPolicyResp PcfPolicyResponse, err error) (
+	nefSBRspData, error) {
+
+	rsp := nefSBRspData{errorCode: int(pcfPolicyResp.ResponseCode)}
+	if pcfPolicyResp.Pd != nil {
+		rsp.pd = *pcfPolicyResp.Pd
+	}
+
 	if err != nil {
-		rsp.errorCode = int(pcfPolicyResp.ResponseCode)
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
+		if rsp.errorCode == 0 {
+			// PCF could not be reached or the response was invalid
+			rsp.errorCode = http.StatusServiceUnavailable
+			rsp.pd.Title = "PCF not available"
+			rsp.pd.Detail = err.Error()
 		}
-		log.Errf("PCF Policy Authorization Create Failure. Response Code: %d",
-			rsp.errorCode)
+		log.Errf("PCF Policy Authorization %s Failure. Response Code: %d",
+			op, rsp.errorCode)
 		return rsp, err
 	}
 
-	rsp.errorCode = int(pcfPolicyResp.ResponseCode)
 	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
-		}
-		log.Errf("PCF Policy Authorization Create Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
-		pcfSub.appSessionID = appSessID
-		log.Infof("PCF Policy Authorization Create Success. Response Code: %d",
-			rsp.errorCode)
+		log.Errf("PCF Policy Authorization %s Failure. Response Code: %d",
+			op, rsp.errorCode)
+		return rsp, errors.New("PCF Policy Authorization " + op + " Failure")
 	}
-	return rsp, err
+
+	log.Infof("PCF Policy Authorization %s Success. Response Code: %d",
+		op, rsp.errorCode)
+	return rsp, nil
 }
 
 // nefSBPCFGet : This function sends HTTP GET Request to PCF to fetch Policy
@@ -927,34 +1251,16 @@ func nefSBPCFGet(pcfSub *afSubscription, nefCtx *nefContext) (
 
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	pcfPolicyResp, err :=
 		nef.pcfClient.PolicyAuthorizationGet(cliCtx, pcfSub.appSessionID)
-	if err != nil {
-		rsp.errorCode = int(pcfPolicyResp.ResponseCode)
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
-		}
-		log.Errf("PCF Policy Authorization Get Failure. Response Code: %d",
-			rsp.errorCode)
-		return sub, rsp, err
-	}
 
-	rsp.errorCode = int(pcfPolicyResp.ResponseCode)
-	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
-		}
-		log.Errf("PCF Policy Authorization Get Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
+	rsp, err = nefSBPCFRsp("Get", pcfPolicyResp, err)
+	if err == nil {
 		sub = pcfSub.ti
-		log.Infof("PCF Policy Authorization Get Success. Response Code: %d",
-			rsp.errorCode)
 	}
-
 	return sub, rsp, err
 }
 
@@ -987,7 +1293,7 @@ func nefSBPCFPatch(pcfSub *afSubscription, nefCtx *nefContext,
 
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	appSessCtxUpdtData := AppSessionContextUpdateData{}
@@ -1014,34 +1320,15 @@ func nefSBPCFPatch(pcfSub *afSubscription, nefCtx *nefContext,
 	_ = copy(appSessCtxUpdtData.AfRoutReq.TempVals, tisp.TempValidities)
 
 	//Populating Spatial Validity in App Session Context
-	_ = getSpatialValidityData(cliCtx, nefCtx,
-		&appSessCtxUpdtData.AfRoutReq.SpVal)
+	if tisp.ValidGeoZoneIDs != nil {
+		appSessCtxUpdtData.AfRoutReq.SpVal = getSpatialValidityData(nefCtx,
+			tisp.ValidGeoZoneIDs)
+	}
 
 	pcfPolicyResp, err := nef.pcfClient.PolicyAuthorizationUpdate(cliCtx,
 		appSessCtxUpdtData, pcfSub.appSessionID)
-	if err != nil {
-		rsp.errorCode = int(pcfPolicyResp.ResponseCode)
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
-		}
-		log.Errf("PCF Policy Authorization Update Failure. Response Code: %d",
-			rsp.errorCode)
-		return rsp, err
-	}
 
-	rsp.errorCode = int(pcfPolicyResp.ResponseCode)
-	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
-		}
-		log.Errf("PCF Policy Authorization Update Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
-		log.Infof("PCF Policy Authorization Update Success. Response Code: %d",
-			rsp.errorCode)
-	}
-
-	return rsp, err
+	return nefSBPCFRsp("Update", pcfPolicyResp, err)
 }
 
 // nefSBPCFDelete : This function sends HTTP DELETE Request to PCF to delete
@@ -1057,34 +1344,19 @@ func nefSBPCFDelete(pcfSub *afSubscription, nefCtx *nefContext) (
 
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	pcfPolicyResp, err :=
 		nef.pcfClient.PolicyAuthorizationDelete(cliCtx, pcfSub.appSessionID)
-	if err != nil {
-		rsp.errorCode = int(pcfPolicyResp.ResponseCode)
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
-		}
-		log.Errf("PCF Policy Authorization Delete Failure. Response Code: %d",
-			rsp.errorCode)
-		return rsp, err
-	}
 
-	rsp.errorCode = int(pcfPolicyResp.ResponseCode)
-	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if pcfPolicyResp.Pd != nil {
-			rsp.pd = *pcfPolicyResp.Pd
-		}
-		log.Errf("PCF Policy Authorization Delete Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
-		log.Infof("PCF Policy Authorization Delete Success. Response Code: %d",
-			rsp.errorCode)
+	if err == nil && pcfPolicyResp.ResponseCode == http.StatusNotFound {
+		// App session is already removed in PCF, nothing left to delete
+		log.Infof("PCF App Session %s not found", pcfSub.appSessionID)
+		pcfPolicyResp = PcfPolicyResponse{ResponseCode: http.StatusNoContent}
 	}
 
-	return rsp, err
+	return nefSBPCFRsp("Delete", pcfPolicyResp, err)
 }
 
 // nefSBUDRPost :  HTTP POST Request to UDR is to trigger Put
@@ -1102,6 +1374,48 @@ func nefSBUDRPost(udrSub *afSubscription, nefCtx *nefContext,
 
 }
 
+// nefSBUDRRsp : This function converts the Traffic Influence Data response
+//               from UDR into the SB response data.
+// Input Args:
+//   - op: Traffic Influence Data operation, used for logging
+//   - udrInfluenceResp: Traffic Influence Data response received from UDR
+//   - err: error returned by the UDR client
+// Output Args:
+//    - rsp: This is Traffic Influence Data Response Data
+//    - error: retruns error in case there is failure happened in sending the
+//             request or any failure response is received.
+func nefSBUDRRsp(op string, udrInfluenceResp UdrInfluenceResponse,
+	err error) (nefSBRspData, error) {
+
+	rsp := nefSBRspData{errorCode: int(udrInfluenceResp.ResponseCode)}
+	if udrInfluenceResp.Pd != nil {
+		rsp.pd = *udrInfluenceResp.Pd
+	}
+
+	if err != nil {
+		if rsp.errorCode == 0 {
+			// UDR could not be reached or the response was invalid
+			rsp.errorCode = http.StatusServiceUnavailable
+			rsp.pd.Title = "UDR not available"
+			rsp.pd.Detail = err.Error()
+		}
+		log.Errf("UDR Traffic Influence Data %s Failure. Response Code: %d",
+			op, rsp.errorCode)
+		return rsp, err
+	}
+
+	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
+		log.Errf("UDR Traffic Influence Data %s Failure. Response Code: %d",
+			op, rsp.errorCode)
+		return rsp, errors.New("UDR Traffic Influence Data " + op +
+			" Failure")
+	}
+
+	log.Infof("UDR Traffic Influence Data %s Success. Response Code: %d",
+		op, rsp.errorCode)
+	return rsp, nil
+}
+
 // nefSBUDRGet : This function sends HTTP GET Request to UDR to fetch
 //               Traffic Influence Data.
 // Input Args:
@@ -1116,33 +1430,22 @@ func nefSBUDRGet(udrSub *afSubscription, nefCtx *nefContext) (
 
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
-	udrInfluenceResp, err := nef.udrClient.UdrInfluenceDataGet(cliCtx)
-	if err != nil {
-		rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Get Failure. Response Code: %d",
-			rsp.errorCode)
-		return sub, rsp, err
+	udrInfluenceResp, err := nef.udrClient.UdrInfluenceDataGet(cliCtx,
+		UdrInfluenceDataFilter{InfluenceIDs: []InfluenceID{udrSub.iid}})
+
+	if err == nil && udrInfluenceResp.ResponseCode == http.StatusOK &&
+		len(udrInfluenceResp.Tids) == 0 {
+		// Influence data of the subscription is not present in UDR
+		udrInfluenceResp.ResponseCode = http.StatusNotFound
 	}
 
-	rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Get Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
+	rsp, err = nefSBUDRRsp("Get", udrInfluenceResp, err)
+	if err == nil {
 		sub = udrSub.ti
-		log.Infof("UDR Traffic Influence Data Get Success. Response Code: %d",
-			rsp.errorCode)
 	}
-
 	return sub, rsp, err
 }
 
@@ -1160,7 +1463,7 @@ func nefSBUDRPut(udrSub *afSubscription, nefCtx *nefContext,
 
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	trafficInfluData := TrafficInfluData{}
@@ -1168,26 +1471,31 @@ func nefSBUDRPut(udrSub *afSubscription, nefCtx *nefContext,
 	//Populating Traffic Influence Data
 	trafficInfluData.AfAppID = ti.AfAppID
 
-	//Populating DNN and NW Slice Info in Traffic Influence Data
-	for _, afServIdcounter := range nefCtx.cfg.AfServiceIDs {
-		afServiceID := afServIdcounter.(map[string]interface{})
-		if 0 == strings.Compare(ti.AfServiceID, afServiceID["id"].(string)) {
-			trafficInfluData.Dnn = Dnn(afServiceID["dnn"].(string))
-			trafficInfluData.Snssai.Sd = afServiceID["snssai"].(string)
-			trafficInfluData.Snssai.Sst = uint8(len(trafficInfluData.Snssai.Sd))
-		}
+	//Populating SUPI in Traffic Influence Data
+	trafficInfluData.Supi, rsp, err = getSupiData(cliCtx, nefCtx, ti.Gpsi)
+	if err != nil {
+		return rsp, err
 	}
 
+	//Populating DNN and NW Slice Info in Traffic Influence Data
+	trafficInfluData.Dnn, trafficInfluData.Snssai = getAfServiceData(nefCtx,
+		ti)
+
 	trafficInfluData.AppReloInd = ti.AppReloInd
-	trafficInfluData.InterGroupID = string(ti.ExternalGroupID)
+
+	//Populating the internal group in Traffic Influence Data
+	trafficInfluData.InterGroupID, rsp, err = getInterGroupID(cliCtx, nefCtx,
+		ti.ExternalGroupID)
+	if err != nil {
+		return rsp, err
+	}
 
 	//Populating UP Path Chnage Subbscription Data in Traffic Influence Data
 	trafficInfluData.UpPathChgNotifURI = nefCtx.nef.upfNotificationURL
 
 	if len(ti.SubscribedEvents) > 0 &&
 		0 == strings.Compare(string(ti.SubscribedEvents[0]), "UP_PATH_CHANGE") {
-		udrSub.NotifCorreID = strconv.Itoa(int(nef.corrID))
-		nef.corrID++
+		udrSub.NotifCorreID = nef.nefAllocCorrID()
 		trafficInfluData.UpPathChgNotifCorreID = udrSub.NotifCorreID
 	}
 
@@ -1215,32 +1523,13 @@ func nefSBUDRPut(udrSub *afSubscription, nefCtx *nefContext,
 	}
 
 	//Populating Spatial Validity in Traffic Influence Data
-	_ = getNetworkAreaInfo(cliCtx, nefCtx, &trafficInfluData.NwAreaInfo)
+	trafficInfluData.NwAreaInfo = getNetworkAreaInfo(nefCtx,
+		ti.ValidGeoZoneIDs)
 
 	udrInfluenceResp, err := nef.udrClient.UdrInfluenceDataCreate(
 		cliCtx, trafficInfluData, udrSub.iid)
-	if err != nil {
-		rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Put Failure. Response Code: %d",
-			rsp.errorCode)
-		return rsp, err
-	}
 
-	rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Put Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
-		log.Infof("UDR Traffic Influence Data Put Success. Response Code: %d",
-			rsp.errorCode)
-	}
-	return rsp, err
+	return nefSBUDRRsp("Put", udrInfluenceResp, err)
 }
 
 // nefSBUDRPatch : This function sends HTTP PATCH Request to UDR to update
@@ -1257,7 +1546,7 @@ func nefSBUDRPatch(udrSub *afSubscription, nefCtx *nefContext,
 
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	trafficInfluDataPatch := TrafficInfluDataPatch{}
@@ -1287,30 +1576,16 @@ func nefSBUDRPatch(udrSub *afSubscription, nefCtx *nefContext,
 			DateTime(tisp.TempValidities[0].StopTime)
 	}
 
+	//Populating Spatial Validity in Traffic Influence Data
+	if tisp.ValidGeoZoneIDs != nil {
+		trafficInfluDataPatch.NwAreaInfo = getNetworkAreaInfo(nefCtx,
+			tisp.ValidGeoZoneIDs)
+	}
+
 	udrInfluenceResp, err := nef.udrClient.UdrInfluenceDataUpdate(
 		cliCtx, trafficInfluDataPatch, udrSub.iid)
-	if err != nil {
-		rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Update Failure. Response Code: %d",
-			rsp.errorCode)
-		return rsp, err
-	}
 
-	rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Update Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
-		log.Infof("UDR Traffic Influence Data Update Success.Response Code: %d",
-			rsp.errorCode)
-	}
-	return rsp, err
+	return nefSBUDRRsp("Update", udrInfluenceResp, err)
 }
 
 // nefSBUDRDelete : This function sends HTTP DELETE Request to UDR to delete
@@ -1326,94 +1601,43 @@ func nefSBUDRDelete(udrSub *afSubscription, nefCtx *nefContext) (
 
 	nef := &nefCtx.nef
 
-	cliCtx, cancel := context.WithCancel(nef.ctx)
+	cliCtx, cancel := context.WithCancel(nef.sbCtx)
 	defer cancel()
 
 	udrInfluenceResp, err := nef.udrClient.UdrInfluenceDataDelete(cliCtx,
 		udrSub.iid)
-	if err != nil {
-		rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Delete Failure. Response Code: %d",
-			rsp.errorCode)
-		return rsp, err
-	}
 
-	rsp.errorCode = int(udrInfluenceResp.ResponseCode)
-	if rsp.errorCode >= 300 && rsp.errorCode < 700 {
-		if udrInfluenceResp.Pd != nil {
-			rsp.pd = *udrInfluenceResp.Pd
-		}
-		log.Errf("UDR Traffic Influence Data Delete Failure. Response Code: %d",
-			rsp.errorCode)
-	} else {
-		log.Infof("UDR Traffic Influence Data Delete Success."+
-			"Response Code: %d", rsp.errorCode)
+	if err == nil && udrInfluenceResp.ResponseCode == http.StatusNotFound {
+		// Influence data is already removed in UDR, nothing left to delete
+		log.Infof("UDR Influence Data %s not found", udrSub.iid)
+		udrInfluenceResp = UdrInfluenceResponse{
+			ResponseCode: http.StatusNoContent}
 	}
 
-	return rsp, err
+	return nefSBUDRRsp("Delete", udrInfluenceResp, err)
 }
 
-func getSpatialValidityData(cliCtx context.Context, nefCtx *nefContext,
-	spVal *SpatialValidity) error {
-	_ = cliCtx
-	_ = nefCtx
-
-	spVal.PresenceInfoList.PraID = "PRA_01"
-	spVal.PresenceInfoList.PresenceState = "IN_AREA"
-	spVal.PresenceInfoList.EcgiList = make([]Ecgi, 1)
-	spVal.PresenceInfoList.EcgiList[0].EutraCellID = "EUTRACELL_01"
-	spVal.PresenceInfoList.EcgiList[0].PlmnID.Mcc = "634"
-	spVal.PresenceInfoList.EcgiList[0].PlmnID.Mnc = "635"
-	spVal.PresenceInfoList.NcgiList = make([]Ncgi, 1)
-	spVal.PresenceInfoList.NcgiList[0].NrCellID = "NRCELL_01"
-	spVal.PresenceInfoList.NcgiList[0].PlmnID.Mcc = "834"
-	spVal.PresenceInfoList.NcgiList[0].PlmnID.Mnc = "835"
-	spVal.PresenceInfoList.GlobalRanNodeIDList = make([]GlobalRanNodeID, 1)
-	spVal.PresenceInfoList.GlobalRanNodeIDList[0].PlmnID.Mcc = "934"
-	spVal.PresenceInfoList.GlobalRanNodeIDList[0].PlmnID.Mnc = "935"
-	spVal.PresenceInfoList.GlobalRanNodeIDList[0].N3IwfID = "IWF_01"
-	spVal.PresenceInfoList.GlobalRanNodeIDList[0].GNbID.BitLength = 48
-	spVal.PresenceInfoList.GlobalRanNodeIDList[0].GNbID.GNBValue = "GNB_01"
-	spVal.PresenceInfoList.GlobalRanNodeIDList[0].NgeNbID = "NB_01"
-
-	return nil
-}
+// getSupiData translates the GPSI sent by the AF into the SUPI of the UE. The
+// SUPI is empty if there is no GPSI
+func getSupiData(cliCtx context.Context, nefCtx *nefContext, gpsi Gpsi) (
+	supi Supi, rsp nefSBRspData, err error) {
 
-func getSupiData(cliCtx context.Context, nefCtx *nefContext,
-	supi *Supi) error {
-	_ = cliCtx
-	_ = nefCtx
-	*supi = "imsi-8"
-	return nil
-}
+	if gpsi == "" {
+		return "", rsp, nil
+	}
 
-func getNetworkAreaInfo(cliCtx context.Context, nefCtx *nefContext,
-	nwAreaInfo *NetworkAreaInfo) error {
-	_ = cliCtx
-	_ = nefCtx
-
-	nwAreaInfo.Ecgis = make([]Ecgi, 1)
-	nwAreaInfo.Ecgis[0].EutraCellID = "EUTRACELL_01"
-	nwAreaInfo.Ecgis[0].PlmnID.Mcc = "634"
-	nwAreaInfo.Ecgis[0].PlmnID.Mnc = "635"
-	nwAreaInfo.Ncgis = make([]Ncgi, 1)
-	nwAreaInfo.Ncgis[0].NrCellID = "NRCELL_01"
-	nwAreaInfo.Ncgis[0].PlmnID.Mcc = "834"
-	nwAreaInfo.Ncgis[0].PlmnID.Mnc = "835"
-	nwAreaInfo.GRanNodeIds = make([]GlobalRanNodeID, 1)
-	nwAreaInfo.GRanNodeIds[0].PlmnID.Mcc = "934"
-	nwAreaInfo.GRanNodeIds[0].PlmnID.Mnc = "935"
-	nwAreaInfo.GRanNodeIds[0].N3IwfID = "IWF_01"
-	nwAreaInfo.GRanNodeIds[0].GNbID.BitLength = 48
-	nwAreaInfo.GRanNodeIds[0].GNbID.GNBValue = "GNB_01"
-	nwAreaInfo.GRanNodeIds[0].NgeNbID = "NB_01"
-	nwAreaInfo.Tais = make([]Tai, 1)
-	nwAreaInfo.Tais[0].PlmnID.Mcc = "734"
-	nwAreaInfo.Tais[0].PlmnID.Mnc = "735"
-	nwAreaInfo.Tais[0].Tac = "TAC_01"
-
-	return nil
+	supi, err = nefCtx.nef.udmClient.UdmGetSupi(cliCtx, gpsi)
+	switch {
+	case err == errUdmUeNotFound:
+		log.Errf("UE of GPSI %s not found", gpsi)
+		rsp.errorCode = 404
+		rsp.pd.Title = "UE not found"
+		rsp.pd.Detail = "No SUPI found for GPSI " + string(gpsi)
+		rsp.pd.Status = 404
+	case err != nil:
+		log.Errf("Failed to translate GPSI %s: %v", gpsi, err)
+		rsp.errorCode = 503
+		rsp.pd.Title = "UDM identifier translation failed"
+	}
+	return supi, rsp, err
 }
@@ -0,0 +1,493 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Client of the Nnrf_NFManagement and Nnrf_NFDiscovery services (29.510) */
+
+package ngcnef
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NRF resource URIs
+const (
+	nrfNfInstancesURI = "/nnrf-nfm/v1/nf-instances"
+	nrfDiscoveryURI   = "/nnrf-disc/v1/nf-instances"
+)
+
+// Names of the NEF services registered in the NRF
+const (
+	nefServiceTrafficInfluence = "nnef-trafficinfluence"
+	nefServicePfdManagement    = "nnef-pfdmanagement"
+)
+
+// Default heartbeat timer in seconds
+const nrfDefaultHeartbeatTimer = 60
+
+// Default validity in seconds of the discovery results
+const nrfDefaultDiscoveryValidity = 300
+
+// Timeout of the deregistration when the NEF is stopped
+const nrfDeregisterTimeout = 5 * time.Second
+
+// NRFConfig contains the settings of the NRF client
+type NRFConfig struct {
+	// API Root of the NRF e.g https://nrf:29510, NRF is not used if empty
+	APIRoot string `json:"apiRoot"`
+	// NF instance ID (UUID) of the NEF, generated at start if empty
+	NfInstanceID string `json:"nfInstanceId"`
+	// Heartbeat timer in seconds proposed to the NRF, 60 if not set
+	HeartbeatTimer int `json:"heartbeatTimer"`
+	// Validity in seconds of the discovery results if not set by the NRF,
+	// 300 if not set
+	DiscoveryValidity int `json:"discoveryValidity"`
+}
+
+// nrfDiscovery is a cached discovery result
+type nrfDiscovery struct {
+	apiRoot string
+	expiry  time.Time
+}
+
+// nrfClient registers the NEF in the NRF and discovers the PCF and UDR
+type nrfClient struct {
+	sb       *sbHTTPClient
+	profile  NfProfile
+	validity time.Duration
+	// done is closed once the registration/heartbeat routine is stopped
+	done chan struct{}
+
+	mu         sync.Mutex
+	registered bool
+	heartbeat  time.Duration
+	discovered map[string]nrfDiscovery
+}
+
+// newNRFClient creates the NRF client, nil is returned if the NRF is not
+// configured
+func newNRFClient(cfg *Config) (*nrfClient, error) {
+
+	if cfg.NRFConfig.APIRoot == "" {
+		return nil, nil
+	}
+
+	sb, err := newSBHTTPClient(cfg, cfg.NRFConfig.APIRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := nefProfile(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	validity := cfg.NRFConfig.DiscoveryValidity
+	if validity <= 0 {
+		validity = nrfDefaultDiscoveryValidity
+	}
+	log.Infof("NRF Client created for %s, NF instance ID %s",
+		cfg.NRFConfig.APIRoot, profile.NfInstanceID)
+	return &nrfClient{sb: sb, profile: profile,
+		validity:   time.Duration(validity) * time.Second,
+		heartbeat:  time.Duration(profile.HeartBeatTimer) * time.Second,
+		discovered: make(map[string]nrfDiscovery)}, nil
+}
+
+// newNfInstanceID generates a random (version 4) UUID
+func newNfInstanceID() (string, error) {
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10],
+		b[10:]), nil
+}
+
+// nefAPIRootURL parses NefAPIRoot, a host or an URL with a scheme, port or
+// path, e.g. localhost or https://nef.example.org:8443
+func nefAPIRootURL(apiRoot string) (*url.URL, error) {
+
+	if net.ParseIP(apiRoot) != nil && strings.Contains(apiRoot, ":") {
+		apiRoot = "[" + apiRoot + "]"
+	}
+	if !strings.Contains(apiRoot, "://") {
+		apiRoot = "//" + apiRoot
+	}
+	u, err := url.Parse(apiRoot)
+	if err != nil {
+		return nil, fmt.Errorf("NefAPIRoot: %v", err)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("NefAPIRoot: no host")
+	}
+	return u, nil
+}
+
+// nefProfile returns the NF profile of the NEF registered in the NRF
+func nefProfile(cfg *Config) (profile NfProfile, err error) {
+
+	profile.NfInstanceID = cfg.NRFConfig.NfInstanceID
+	if profile.NfInstanceID == "" {
+		if profile.NfInstanceID, err = newNfInstanceID(); err != nil {
+			return profile, err
+		}
+	}
+	profile.NfType = NfTypeNEF
+	profile.NfStatus = NfStatusRegistered
+	profile.HeartBeatTimer = cfg.NRFConfig.HeartbeatTimer
+	if profile.HeartBeatTimer <= 0 {
+		profile.HeartBeatTimer = nrfDefaultHeartbeatTimer
+	}
+	apiRoot, err := nefAPIRootURL(cfg.NefAPIRoot)
+	if err != nil {
+		return profile, err
+	}
+	host := apiRoot.Hostname()
+	if ip := net.ParseIP(host); ip == nil {
+		profile.Fqdn = host
+	} else if ip.To4() != nil {
+		profile.Ipv4Addresses = []Ipv4Addr{Ipv4Addr(host)}
+	} else {
+		profile.Ipv6Addresses = []Ipv6Addr{Ipv6Addr(host)}
+	}
+
+	// The HTTP2 endpoint is preferred when both are configured, the scheme
+	// and port of NefAPIRoot override the ones of the endpoint
+	scheme, endpoint := "https", cfg.HTTP2Config.Endpoint
+	if endpoint == "" {
+		scheme, endpoint = "http", cfg.HTTPConfig.Endpoint
+	}
+	port := 0
+	if _, p, err1 := net.SplitHostPort(endpoint); err1 == nil {
+		port, _ = strconv.Atoi(p)
+	}
+	if apiRoot.Scheme != "" {
+		scheme = apiRoot.Scheme
+	}
+	if p := apiRoot.Port(); p != "" {
+		port, _ = strconv.Atoi(p)
+	}
+
+	for _, svc := range []struct{ name, prefix string }{
+		{nefServiceTrafficInfluence, cfg.LocationPrefix},
+		{nefServicePfdManagement, cfg.LocationPrefixPfd}} {
+		// The prefix is /<API name>/<version>/
+		segs := strings.Split(strings.Trim(svc.prefix, "/"), "/")
+		if segs[0] == "" {
+			continue
+		}
+		version := "v1"
+		if len(segs) > 1 {
+			version = segs[1]
+		}
+		srv := NfService{ServiceInstanceID: svc.name, ServiceName: svc.name,
+			Versions: []NfServiceVersion{{APIVersionInURI: version,
+				APIFullVersion: strings.TrimPrefix(version, "v") + ".0.0"}},
+			Scheme: scheme, NfServiceStatus: NfStatusRegistered,
+			APIPrefix: strings.Trim(apiRoot.Path, "/")}
+		if port != 0 {
+			srv.IPEndPoints = []IPEndPoint{{Transport: "TCP", Port: port}}
+		}
+		profile.NfServices = append(profile.NfServices, srv)
+	}
+	return profile, nil
+}
+
+// nrfError returns the error of a failed NRF request
+func nrfError(op string, rsp sbHTTPRsp) error {
+
+	if rsp.pd != nil && rsp.pd.Cause != "" {
+		return fmt.Errorf("NRF %s failed: %d %s", op, rsp.code, rsp.pd.Cause)
+	}
+	return fmt.Errorf("NRF %s failed: %d", op, rsp.code)
+}
+
+func (n *nrfClient) instanceURI() string {
+	return nrfNfInstancesURI + "/" + url.PathEscape(n.profile.NfInstanceID)
+}
+
+func (n *nrfClient) isRegistered() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.registered
+}
+
+func (n *nrfClient) setRegistered(registered bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.registered = registered
+}
+
+func (n *nrfClient) heartbeatInterval() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.heartbeat
+}
+
+// register sends PUT request to register the NEF profile
+// Successful response : 201 or 200 and body contains the NfProfile
+func (n *nrfClient) register(ctx context.Context) error {
+
+	profile := NfProfile{}
+	rsp, err := n.sb.do(ctx, http.MethodPut, n.instanceURI(),
+		"application/json", n.profile, &profile)
+	if err != nil {
+		return err
+	}
+	if rsp.code != http.StatusOK && rsp.code != http.StatusCreated {
+		return nrfError("registration", rsp)
+	}
+
+	n.mu.Lock()
+	n.registered = true
+	// The NRF may change the heartbeat timer proposed by the NEF
+	if profile.HeartBeatTimer > 0 {
+		n.heartbeat = time.Duration(profile.HeartBeatTimer) * time.Second
+	}
+	n.mu.Unlock()
+	log.Infof("NEF registered in NRF, heartbeat timer %v",
+		n.heartbeatInterval())
+	return nil
+}
+
+// sendHeartbeat sends PATCH request to update the status of the NEF profile
+// Successful response : 204 or 200 and body contains the NfProfile
+func (n *nrfClient) sendHeartbeat(ctx context.Context) error {
+
+	patch := []PatchItem{{Op: "replace", Path: "/nfStatus",
+		Value: NfStatusRegistered}}
+	rsp, err := n.sb.do(ctx, http.MethodPatch, n.instanceURI(),
+		"application/json-patch+json", patch, nil)
+	if err != nil {
+		return err
+	}
+	if rsp.code == http.StatusNotFound {
+		// The NRF lost the profile e.g. it got restarted
+		n.setRegistered(false)
+	}
+	if rsp.code != http.StatusOK && rsp.code != http.StatusNoContent {
+		return nrfError("heartbeat", rsp)
+	}
+	return nil
+}
+
+// deregister sends DELETE request to remove the NEF profile
+// Successful response : 204
+func (n *nrfClient) deregister(ctx context.Context) error {
+
+	rsp, err := n.sb.do(ctx, http.MethodDelete, n.instanceURI(), "", nil, nil)
+	if err != nil {
+		return err
+	}
+	n.setRegistered(false)
+	if rsp.code != http.StatusNoContent && rsp.code != http.StatusNotFound {
+		return nrfError("deregistration", rsp)
+	}
+	log.Infoln("NEF deregistered from NRF")
+	return nil
+}
+
+// run registers the NEF and sends the heartbeats until ctx is done. The
+// registration is retried at each heartbeat interval until it succeeds
+func (n *nrfClient) run(ctx context.Context) {
+
+	defer close(n.done)
+	for {
+		var err error
+		if n.isRegistered() {
+			err = n.sendHeartbeat(ctx)
+		}
+		if !n.isRegistered() {
+			err = n.register(ctx)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Errf("NRF: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(n.heartbeatInterval()):
+		}
+	}
+}
+
+// start starts the registration and heartbeats in the background
+func (n *nrfClient) start(ctx context.Context) {
+
+	if n == nil {
+		return
+	}
+	n.done = make(chan struct{})
+	go n.run(ctx)
+}
+
+// stop waits for the end of the heartbeats, the context given to start must
+// be done, and deregisters the NEF
+func (n *nrfClient) stop() {
+
+	if n == nil || n.done == nil {
+		return
+	}
+	<-n.done
+	if !n.isRegistered() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		nrfDeregisterTimeout)
+	defer cancel()
+	if err := n.deregister(ctx); err != nil {
+		log.Errf("NRF: %v", err)
+	}
+}
+
+// discoverer returns the function discovering the API root of the service
+// of the target NF type. nil is returned if the NRF is not configured
+func (n *nrfClient) discoverer(target NfType, service string) sbDiscoverFn {
+
+	if n == nil {
+		return nil
+	}
+	return func(ctx context.Context) (string, error) {
+		return n.discover(ctx, target, service)
+	}
+}
+
+// discover returns the API root of the service of the target NF type. The
+// result is cached for the validity period of the discovery
+func (n *nrfClient) discover(ctx context.Context, target NfType,
+	service string) (string, error) {
+
+	key := string(target) + "/" + service
+	n.mu.Lock()
+	d, ok := n.discovered[key]
+	n.mu.Unlock()
+	if ok && time.Now().Before(d.expiry) {
+		return d.apiRoot, nil
+	}
+
+	q := url.Values{}
+	q.Set("target-nf-type", string(target))
+	q.Set("requester-nf-type", string(NfTypeNEF))
+	q.Set("requester-nf-instance-id", n.profile.NfInstanceID)
+	q.Set("service-names", service)
+
+	result := SearchResult{}
+	rsp, err := n.sb.do(ctx, http.MethodGet, nrfDiscoveryURI+"?"+q.Encode(),
+		"", nil, &result)
+	if err != nil {
+		return "", err
+	}
+	if rsp.code != http.StatusOK {
+		return "", nrfError("discovery", rsp)
+	}
+
+	apiRoot := nrfSelectAPIRoot(result.NfInstances, service)
+	if apiRoot == "" {
+		return "", errors.New("No " + string(target) + " " + service +
+			" instance found in NRF")
+	}
+
+	validity := n.validity
+	if result.ValidityPeriod > 0 {
+		validity = time.Duration(result.ValidityPeriod) * time.Second
+	}
+	n.mu.Lock()
+	n.discovered[key] = nrfDiscovery{apiRoot: apiRoot,
+		expiry: time.Now().Add(validity)}
+	n.mu.Unlock()
+	log.Infof("NRF discovered %s %s: %s, valid for %v", target, service,
+		apiRoot, validity)
+	return apiRoot, nil
+}
+
+// isNfAvailable returns true if the NF or service status allows sending
+// requests to it. A missing status is considered as registered
+func isNfAvailable(status NfStatus) bool {
+	return status == "" || status == NfStatusRegistered
+}
+
+// nrfSelectAPIRoot returns the API root of the first registered instance of
+// the service
+func nrfSelectAPIRoot(profiles []NfProfile, service string) string {
+
+	for _, p := range profiles {
+		if !isNfAvailable(p.NfStatus) {
+			continue
+		}
+		for _, s := range p.NfServices {
+			if s.ServiceName != service || !isNfAvailable(s.NfServiceStatus) {
+				continue
+			}
+			if apiRoot := nfServiceAPIRoot(p, s); apiRoot != "" {
+				return apiRoot
+			}
+		}
+	}
+	return ""
+}
+
+// nfServiceHost returns the address of the service or of the NF instance
+// if the service has no address
+func nfServiceHost(p NfProfile, s NfService) (host string, port int) {
+
+	host = s.Fqdn
+	if len(s.IPEndPoints) > 0 {
+		ep := s.IPEndPoints[0]
+		port = ep.Port
+		if host == "" {
+			host = string(ep.Ipv4Address)
+		}
+		if host == "" {
+			host = string(ep.Ipv6Address)
+		}
+	}
+	if host == "" {
+		host = p.Fqdn
+	}
+	if host == "" && len(p.Ipv4Addresses) > 0 {
+		host = string(p.Ipv4Addresses[0])
+	}
+	if host == "" && len(p.Ipv6Addresses) > 0 {
+		host = string(p.Ipv6Addresses[0])
+	}
+	return host, port
+}
+
+// nfServiceAPIRoot builds the API root of the service
+// {scheme}://{fqdn or ip}[:{port}][/{apiPrefix}]
+func nfServiceAPIRoot(p NfProfile, s NfService) string {
+
+	host, port := nfServiceHost(p, s)
+	if host == "" {
+		return ""
+	}
+
+	if port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	apiRoot := scheme + "://" + host
+	if prefix := strings.Trim(s.APIPrefix, "/"); prefix != "" {
+		apiRoot += "/" + prefix
+	}
+	return apiRoot
+}
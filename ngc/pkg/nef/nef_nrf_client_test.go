@@ -0,0 +1,182 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+const nefTestNfInstanceID = "9a5d3e2c-3a1f-4c8e-9b9e-6f1d2a3b4c5d"
+const pcfTestNfInstanceID = "5b2f2e6a-1c0d-4e8f-8a7b-3c4d5e6f7a8b"
+
+// pcfTestProfile returns the profile of the PCF listening at apiRoot
+func pcfTestProfile(apiRoot string) ngcnef.NfProfile {
+
+	u, err := url.Parse(apiRoot)
+	Expect(err).Should(BeNil())
+	host, p, err := net.SplitHostPort(u.Host)
+	Expect(err).Should(BeNil())
+	port, err := strconv.Atoi(p)
+	Expect(err).Should(BeNil())
+
+	return ngcnef.NfProfile{NfInstanceID: pcfTestNfInstanceID,
+		NfType: ngcnef.NfTypePCF, NfStatus: ngcnef.NfStatusRegistered,
+		NfServices: []ngcnef.NfService{{ServiceInstanceID: "1",
+			ServiceName: "npcf-policyauthorization", Scheme: u.Scheme,
+			NfServiceStatus: ngcnef.NfStatusRegistered,
+			IPEndPoints: []ngcnef.IPEndPoint{{
+				Ipv4Address: ngcnef.Ipv4Addr(host), Port: port}}}}}
+}
+
+var _ = Describe("Test NEF NRF Client", func() {
+
+	var (
+		nrf    *ngcnef.NrfStub
+		nrfSrv *httptest.Server
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+		// NefAPIRoot of the NEF configuration
+		apiRoot string
+	)
+
+	BeforeEach(func() {
+		apiRoot = "localhost"
+		nrf = ngcnef.NewNrfStub()
+		nrf.ValidityPeriod = 1
+		nrfSrv = httptest.NewServer(nrf)
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+		nrf.Register(pcfTestProfile(pcfSrv.URL))
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-nrf")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["NRFConfig"] = map[string]interface{}{
+					"apiRoot":        nrfSrv.URL,
+					"nfInstanceId":   nefTestNfInstanceID,
+					"heartbeatTimer": 1}
+				cfg["PCFConfig"] = map[string]interface{}{"type": "http"}
+				cfg["NefAPIRoot"] = apiRoot
+			})
+
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		_ = os.RemoveAll(tmpDir)
+		nrfSrv.Close()
+		pcfSrv.Close()
+	})
+
+	It("Will register, send heartbeats and deregister", func() {
+		profile, ok := nrf.Profile(nefTestNfInstanceID)
+		Expect(ok).Should(BeTrue())
+		Expect(profile.NfType).Should(Equal(ngcnef.NfTypeNEF))
+		Expect(profile.HeartBeatTimer).Should(Equal(1))
+		Expect(profile.Fqdn).Should(Equal("localhost"))
+		Expect(profile.NfServices).Should(HaveLen(2))
+		Expect(profile.NfServices[0].ServiceName).Should(
+			Equal("nnef-trafficinfluence"))
+		Expect(profile.NfServices[1].ServiceName).Should(
+			Equal("nnef-pfdmanagement"))
+		Expect(profile.NfServices[0].Scheme).Should(Equal("https"))
+		Expect(profile.NfServices[0].IPEndPoints[0].Port).Should(Equal(8090))
+		Eventually(func() int {
+			return nrf.Heartbeats(nefTestNfInstanceID)
+		}, 3*time.Second).Should(BeNumerically(">=", 1))
+
+		nef.stop()
+		Eventually(func() bool {
+			_, ok = nrf.Profile(nefTestNfInstanceID)
+			return ok
+		}, 3*time.Second).Should(BeFalse())
+	})
+
+	Context("With an URL as NefAPIRoot", func() {
+
+		BeforeEach(func() {
+			apiRoot = "http://10.0.0.1:9443/nef"
+		})
+
+		It("Will register its address, scheme, port and prefix", func() {
+			profile, ok := nrf.Profile(nefTestNfInstanceID)
+			Expect(ok).Should(BeTrue())
+			Expect(profile.Fqdn).Should(BeEmpty())
+			Expect(profile.Ipv4Addresses).Should(ConsistOf(
+				ngcnef.Ipv4Addr("10.0.0.1")))
+			Expect(profile.NfServices).Should(HaveLen(2))
+			for _, s := range profile.NfServices {
+				Expect(s.Scheme).Should(Equal("http"))
+				Expect(s.IPEndPoints[0].Port).Should(Equal(9443))
+				Expect(s.APIPrefix).Should(Equal("nef"))
+			}
+		})
+	})
+
+	It("Will register again if the NRF lost the profile", func() {
+		nrf.Deregister(nefTestNfInstanceID)
+		Eventually(func() bool {
+			_, ok := nrf.Profile(nefTestNfInstanceID)
+			return ok
+		}, 3*time.Second).Should(BeTrue())
+	})
+
+	It("Will discover the PCF and cache it for the validity period", func() {
+		postbody, _ := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		patchbody, _ := ioutil.ReadFile(testJSONPath + "AF_NEF_PATCH_01.json")
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", postbody)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+		Expect(pcf.count()).Should(Equal(1))
+		Expect(nrf.Discoveries()).Should(Equal(1))
+
+		rr, req = CreateReqForNEF(ctx, "PATCH", "11111", patchbody)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		Expect(nrf.Discoveries()).Should(Equal(1))
+
+		// The discovery result is valid for 1 second
+		time.Sleep(1500 * time.Millisecond)
+		rr, req = CreateReqForNEF(ctx, "DELETE", "11111", nil)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusNoContent))
+		Expect(pcf.count()).Should(Equal(0))
+		Expect(nrf.Discoveries()).Should(Equal(2))
+	})
+
+	It("Will not create the subscription if no PCF is registered", func() {
+		postbody, _ := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		nrf.Deregister(pcfTestNfInstanceID)
+		// The PCF discovered by the readiness probe is valid for 1 second
+		time.Sleep(1500 * time.Millisecond)
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", postbody)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusBadRequest))
+		Expect(pcf.count()).Should(Equal(0))
+	})
+})
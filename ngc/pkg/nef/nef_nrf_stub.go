@@ -0,0 +1,238 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Local NRF server implementing the subset of Nnrf_NFManagement and
+ * Nnrf_NFDiscovery used by the NEF. To be used for tests only */
+
+package ngcnef
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// NrfStub is a local NRF server keeping the NF profiles in memory
+type NrfStub struct {
+	// ValidityPeriod in seconds returned in the discovery results, not
+	// returned if 0
+	ValidityPeriod int
+	// HeartBeatTimer in seconds returned in the registration response, the
+	// timer proposed by the NF is kept if 0
+	HeartBeatTimer int
+
+	mu          sync.Mutex
+	profiles    map[string]NfProfile
+	heartbeats  map[string]int
+	discoveries int
+}
+
+// NewNrfStub creates an NRF stub without any NF registered
+func NewNrfStub() *NrfStub {
+	return &NrfStub{profiles: make(map[string]NfProfile),
+		heartbeats: make(map[string]int)}
+}
+
+// Register adds or replaces the profile of a NF instance
+func (n *NrfStub) Register(profile NfProfile) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.profiles[profile.NfInstanceID] = profile
+}
+
+// Deregister removes the profile of a NF instance
+func (n *NrfStub) Deregister(nfInstanceID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.profiles, nfInstanceID)
+}
+
+// Profile returns the profile of a registered NF instance
+func (n *NrfStub) Profile(nfInstanceID string) (NfProfile, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	p, ok := n.profiles[nfInstanceID]
+	return p, ok
+}
+
+// Heartbeats returns the number of heartbeats received from a NF instance
+func (n *NrfStub) Heartbeats(nfInstanceID string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.heartbeats[nfInstanceID]
+}
+
+// Discoveries returns the number of discovery requests received
+func (n *NrfStub) Discoveries() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.discoveries
+}
+
+func nrfStubRsp(w http.ResponseWriter, code int, body interface{}) {
+
+	if body == nil {
+		w.WriteHeader(code)
+		return
+	}
+	contentType := "application/json"
+	if _, ok := body.(ProblemDetails); ok {
+		contentType = "application/problem+json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Errf("NRF stub: %v", err)
+	}
+}
+
+func nrfStubProblem(w http.ResponseWriter, code int, cause string) {
+	nrfStubRsp(w, code, ProblemDetails{Title: http.StatusText(code),
+		Status: int32(code), Cause: cause})
+}
+
+// ServeHTTP handles the NF management and discovery requests
+func (n *NrfStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if r.URL.Path == nrfDiscoveryURI {
+		if r.Method != http.MethodGet {
+			nrfStubRsp(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		n.discover(w, r)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, nrfNfInstancesURI+"/") {
+		nrfStubProblem(w, http.StatusNotFound,
+			"RESOURCE_URI_STRUCTURE_NOT_FOUND")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, nrfNfInstancesURI+"/")
+
+	switch r.Method {
+	case http.MethodPut:
+		n.register(w, r, id)
+	case http.MethodPatch:
+		n.update(w, r, id)
+	case http.MethodDelete:
+		if _, ok := n.profiles[id]; !ok {
+			nrfStubProblem(w, http.StatusNotFound, "")
+			return
+		}
+		delete(n.profiles, id)
+		nrfStubRsp(w, http.StatusNoContent, nil)
+	case http.MethodGet:
+		p, ok := n.profiles[id]
+		if !ok {
+			nrfStubProblem(w, http.StatusNotFound, "")
+			return
+		}
+		nrfStubRsp(w, http.StatusOK, p)
+	default:
+		nrfStubRsp(w, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// register handles the NFRegister (PUT) request
+func (n *NrfStub) register(w http.ResponseWriter, r *http.Request,
+	id string) {
+
+	p := NfProfile{}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		nrfStubProblem(w, http.StatusBadRequest, "INVALID_MSG_FORMAT")
+		return
+	}
+	if p.NfInstanceID != id || p.NfType == "" {
+		nrfStubProblem(w, http.StatusBadRequest, "MANDATORY_IE_INCORRECT")
+		return
+	}
+	if p.NfStatus == "" {
+		p.NfStatus = NfStatusRegistered
+	}
+	if n.HeartBeatTimer > 0 {
+		p.HeartBeatTimer = n.HeartBeatTimer
+	}
+
+	code := http.StatusOK
+	if _, ok := n.profiles[id]; !ok {
+		code = http.StatusCreated
+		w.Header().Set("Location", "http://"+r.Host+r.URL.Path)
+	}
+	n.profiles[id] = p
+	nrfStubRsp(w, code, p)
+}
+
+// update handles the NFUpdate (PATCH) request, only the replacement of the
+// nfStatus is supported
+func (n *NrfStub) update(w http.ResponseWriter, r *http.Request, id string) {
+
+	p, ok := n.profiles[id]
+	if !ok {
+		nrfStubProblem(w, http.StatusNotFound, "")
+		return
+	}
+	var patch []PatchItem
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		nrfStubProblem(w, http.StatusBadRequest, "INVALID_MSG_FORMAT")
+		return
+	}
+	for _, item := range patch {
+		if status, isStr := item.Value.(string); item.Op == "replace" &&
+			item.Path == "/nfStatus" && isStr {
+			p.NfStatus = NfStatus(status)
+		}
+	}
+	n.profiles[id] = p
+	n.heartbeats[id]++
+	nrfStubRsp(w, http.StatusNoContent, nil)
+}
+
+// discover handles the NFDiscover (GET) request
+func (n *NrfStub) discover(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+	target := NfType(q.Get("target-nf-type"))
+	if target == "" || q.Get("requester-nf-type") == "" {
+		nrfStubProblem(w, http.StatusBadRequest, "MANDATORY_IE_MISSING")
+		return
+	}
+	n.discoveries++
+
+	var services []string
+	if s := q.Get("service-names"); s != "" {
+		services = strings.Split(s, ",")
+	}
+
+	result := SearchResult{ValidityPeriod: n.ValidityPeriod,
+		NfInstances: []NfProfile{}}
+	for _, p := range n.profiles {
+		if p.NfType == target && p.NfStatus == NfStatusRegistered &&
+			hasNfService(p, services) {
+			result.NfInstances = append(result.NfInstances, p)
+		}
+	}
+	nrfStubRsp(w, http.StatusOK, result)
+}
+
+// hasNfService returns true if the profile has one of the services, or if
+// no service is requested
+func hasNfService(p NfProfile, services []string) bool {
+
+	if len(services) == 0 {
+		return true
+	}
+	for _, s := range p.NfServices {
+		for _, name := range services {
+			if s.ServiceName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"math/rand"
 	"strconv"
+	"sync"
 )
 
 // PcfClientStub is an implementation of the Pcf Authorization
@@ -18,6 +19,8 @@ type PcfClientStub struct {
 	initialID int
 	// database to store the contents of the app session contexts created
 	paDb map[int]AppSessionContext
+	// mu protects the database from concurrent requests
+	mu sync.Mutex
 }
 
 // NewPCFClient creates a new PCF Client
@@ -58,6 +61,9 @@ func (pcf *PcfClientStub) PolicyAuthorizationCreate(ctx context.Context,
 	log.Infof("PCFs PolicyAuthorizationCreate Entered")
 	_ = ctx
 
+	pcf.mu.Lock()
+	defer pcf.mu.Unlock()
+
 	var err error
 	pcfPr := PcfPolicyResponse{}
 	// generated a session id return the same body as provided in the request
@@ -87,6 +93,9 @@ func (pcf *PcfClientStub) PolicyAuthorizationUpdate(ctx context.Context,
 		string(appSessionID))
 	_ = ctx
 
+	pcf.mu.Lock()
+	defer pcf.mu.Unlock()
+
 	var err error
 	pcfPr := PcfPolicyResponse{}
 	// convert the appsession id to integer
@@ -123,6 +132,9 @@ func (pcf *PcfClientStub) PolicyAuthorizationDelete(ctx context.Context,
 		string(appSessionID))
 	_ = ctx
 
+	pcf.mu.Lock()
+	defer pcf.mu.Unlock()
+
 	var err error
 	pcfPr := PcfPolicyResponse{}
 	// convert the appsession id to integer
@@ -155,6 +167,9 @@ func (pcf *PcfClientStub) PolicyAuthorizationGet(ctx context.Context,
 		string(appSessionID))
 	_ = ctx
 
+	pcf.mu.Lock()
+	defer pcf.mu.Unlock()
+
 	var err error
 	pcfPr := PcfPolicyResponse{}
 	// convert the appsession id to integer
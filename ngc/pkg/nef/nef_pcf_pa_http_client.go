@@ -0,0 +1,152 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Client implementation of the Npcf_PolicyAuthorization service (29.514) */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// PCF Policy Authorization resource URI
+const pcfAppSessionsURI = "/npcf-policyauthorization/v1/app-sessions"
+
+// PCF Policy Authorization service name used for the NRF discovery
+const pcfPolicyAuthorizationService = "npcf-policyauthorization"
+
+// PcfClient is an HTTP implementation of the Pcf Authorization
+type PcfClient struct {
+	sb *sbHTTPClient
+}
+
+// NewPCFHTTPClient creates a new PCF Client sending the requests to the PCF
+// API root in the configuration
+func NewPCFHTTPClient(cfg *Config) (*PcfClient, error) {
+
+	return newPCFHTTPClient(cfg, nil)
+}
+
+// newPCFHTTPClient creates a new PCF Client, the PCF is discovered through
+// the NRF if the API root is not configured
+func newPCFHTTPClient(cfg *Config, nrf *nrfClient) (*PcfClient, error) {
+
+	sb, err := newSBHTTPClient(cfg, cfg.PCFConfig.APIRoot,
+		nrf.discoverer(NfTypePCF, pcfPolicyAuthorizationService))
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("PCF Client created for %s",
+		sbAPIRootName(cfg.PCFConfig.APIRoot))
+	return &PcfClient{sb: sb}, nil
+}
+
+// newPCFClient creates the PCF client selected in the configuration
+func newPCFClient(cfg *Config, nrf *nrfClient) (PcfPolicyAuthorization,
+	error) {
+
+	isHTTP, err := cfg.PCFConfig.isHTTP()
+	if err != nil {
+		return nil, err
+	}
+	if isHTTP {
+		return newPCFHTTPClient(cfg, nrf)
+	}
+	return NewPCFClient(cfg), nil
+}
+
+// pcfPolicyResponse fills the PCF response from the HTTP response
+func pcfPolicyResponse(rsp sbHTTPRsp, asc *AppSessionContext) (
+	pcfPr PcfPolicyResponse) {
+
+	pcfPr.ResponseCode = rsp.code
+	pcfPr.Pd = rsp.pd
+	if rsp.code < 300 && asc != nil {
+		pcfPr.Asc = asc
+	}
+	return pcfPr
+}
+
+// PolicyAuthorizationCreate sends POST request to create an app session
+// Successful response : 201 and body contains AppSessionContext
+func (pcf *PcfClient) PolicyAuthorizationCreate(ctx context.Context,
+	body AppSessionContext) (AppSessionID, PcfPolicyResponse, error) {
+
+	asc := AppSessionContext{}
+	rsp, err := pcf.sb.do(ctx, http.MethodPost, pcfAppSessionsURI,
+		"application/json", body, &asc)
+	if err != nil {
+		return "", pcfPolicyResponse(rsp, nil), err
+	}
+
+	pcfPr := pcfPolicyResponse(rsp, &asc)
+	if rsp.code != http.StatusCreated {
+		log.Errf("PCF PolicyAuthorizationCreate failed: %d", rsp.code)
+		return "", pcfPr, nil
+	}
+
+	appSessionID, err := sbLocationID(rsp.header)
+	if err != nil {
+		log.Errf("PCF PolicyAuthorizationCreate: %v", err)
+		return "", pcfPr, err
+	}
+	log.Infof("PCF PolicyAuthorizationCreate AppSessionID: %s", appSessionID)
+	return AppSessionID(appSessionID), pcfPr, nil
+}
+
+// PolicyAuthorizationUpdate sends PATCH request to modify the app session
+// Successful response : 200 and body contains AppSessionContext or 204
+func (pcf *PcfClient) PolicyAuthorizationUpdate(ctx context.Context,
+	body AppSessionContextUpdateData,
+	appSessionID AppSessionID) (PcfPolicyResponse, error) {
+
+	if appSessionID == "" {
+		return PcfPolicyResponse{}, errors.New("AppSessionID is empty")
+	}
+
+	asc := AppSessionContext{}
+	rsp, err := pcf.sb.do(ctx, http.MethodPatch,
+		pcfAppSessionsURI+"/"+string(appSessionID),
+		"application/merge-patch+json", body, &asc)
+	if err != nil || rsp.code == http.StatusNoContent {
+		return pcfPolicyResponse(rsp, nil), err
+	}
+	return pcfPolicyResponse(rsp, &asc), nil
+}
+
+// PolicyAuthorizationDelete sends POST request to the delete custom operation
+// of the app session
+// Successful response : 204 or 200 with the events notification
+func (pcf *PcfClient) PolicyAuthorizationDelete(ctx context.Context,
+	appSessionID AppSessionID) (PcfPolicyResponse, error) {
+
+	if appSessionID == "" {
+		return PcfPolicyResponse{}, errors.New("AppSessionID is empty")
+	}
+
+	rsp, err := pcf.sb.do(ctx, http.MethodPost,
+		pcfAppSessionsURI+"/"+string(appSessionID)+"/delete",
+		"application/json", nil, nil)
+	return pcfPolicyResponse(rsp, nil), err
+}
+
+// PolicyAuthorizationGet sends GET request to read the app session
+// Successful response : 200 and body contains AppSessionContext
+func (pcf *PcfClient) PolicyAuthorizationGet(ctx context.Context,
+	appSessionID AppSessionID) (PcfPolicyResponse, error) {
+
+	if appSessionID == "" {
+		return PcfPolicyResponse{}, errors.New("AppSessionID is empty")
+	}
+
+	asc := AppSessionContext{}
+	rsp, err := pcf.sb.do(ctx, http.MethodGet,
+		pcfAppSessionsURI+"/"+string(appSessionID), "", nil, &asc)
+	if err != nil {
+		return pcfPolicyResponse(rsp, nil), err
+	}
+	return pcfPolicyResponse(rsp, &asc), nil
+}
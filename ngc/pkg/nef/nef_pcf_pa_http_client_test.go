@@ -0,0 +1,256 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// fakePCF is a minimal Npcf_PolicyAuthorization server
+type fakePCF struct {
+	mu       sync.Mutex
+	nextID   int
+	sessions map[string]ngcnef.AppSessionContext
+	// failCode if set is returned for all the requests with ProblemDetails
+	failCode int
+}
+
+func newFakePCF() *fakePCF {
+	return &fakePCF{nextID: 100,
+		sessions: make(map[string]ngcnef.AppSessionContext)}
+}
+
+func (p *fakePCF) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sessions)
+}
+
+func (p *fakePCF) problem(w http.ResponseWriter, code int, cause string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(ngcnef.ProblemDetails{
+		Title: http.StatusText(code), Cause: cause, Status: int32(code)})
+}
+
+func (p *fakePCF) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	const base = "/npcf-policyauthorization/v1/app-sessions"
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failCode != 0 {
+		p.problem(w, p.failCode, "REQUESTED_SERVICE_NOT_AUTHORIZED")
+		return
+	}
+
+	if r.URL.Path == base && r.Method == http.MethodPost {
+		asc := ngcnef.AppSessionContext{}
+		if json.NewDecoder(r.Body).Decode(&asc) != nil {
+			p.problem(w, http.StatusBadRequest, "INVALID_MSG_FORMAT")
+			return
+		}
+		id := strconv.Itoa(p.nextID)
+		p.nextID++
+		p.sessions[id] = asc
+		w.Header().Set("Location", "http://"+r.Host+base+"/"+id)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(asc)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, base+"/")
+	id := strings.TrimSuffix(rest, "/delete")
+	asc, ok := p.sessions[id]
+	if !ok {
+		p.problem(w, http.StatusNotFound, "")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(asc)
+	case r.Method == http.MethodPatch:
+		upd := ngcnef.AppSessionContextUpdateData{}
+		if json.NewDecoder(r.Body).Decode(&upd) != nil {
+			p.problem(w, http.StatusBadRequest, "INVALID_MSG_FORMAT")
+			return
+		}
+		asc.AscReqData.AfRoutReq = upd.AfRoutReq
+		p.sessions[id] = asc
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(asc)
+	case r.Method == http.MethodPost && strings.HasSuffix(rest, "/delete"):
+		delete(p.sessions, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+var _ = Describe("Test NEF PCF HTTP Client", func() {
+
+	var (
+		pcf *fakePCF
+		srv *httptest.Server
+	)
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		srv = httptest.NewServer(pcf)
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	Describe("Policy Authorization requests", func() {
+
+		var client *ngcnef.PcfClient
+
+		BeforeEach(func() {
+			var err error
+			client, err = ngcnef.NewPCFHTTPClient(&ngcnef.Config{
+				PCFConfig: ngcnef.SBClientConfig{Type: "http",
+					APIRoot: srv.URL}})
+			Expect(err).Should(BeNil())
+		})
+
+		It("Will create, update, get and delete an app session", func() {
+			ctx := context.Background()
+			asc := ngcnef.AppSessionContext{}
+			asc.AscReqData.AfAppID = "app1"
+
+			id, rsp, err := client.PolicyAuthorizationCreate(ctx, asc)
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(201)))
+			Expect(id).Should(Equal(ngcnef.AppSessionID("100")))
+			Expect(rsp.Asc.AscReqData.AfAppID).Should(
+				Equal(ngcnef.AfAppID("app1")))
+
+			upd := ngcnef.AppSessionContextUpdateData{}
+			upd.AfRoutReq.AppReloc = true
+			rsp, err = client.PolicyAuthorizationUpdate(ctx, upd, id)
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(200)))
+			Expect(rsp.Asc.AscReqData.AfRoutReq.AppReloc).Should(BeTrue())
+
+			rsp, err = client.PolicyAuthorizationGet(ctx, id)
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(200)))
+			Expect(rsp.Asc.AscReqData.AfRoutReq.AppReloc).Should(BeTrue())
+
+			rsp, err = client.PolicyAuthorizationDelete(ctx, id)
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(204)))
+			Expect(pcf.count()).Should(Equal(0))
+
+			rsp, err = client.PolicyAuthorizationGet(ctx, id)
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(404)))
+			Expect(rsp.Asc).Should(BeNil())
+			Expect(rsp.Pd).ShouldNot(BeNil())
+		})
+
+		It("Will return the ProblemDetails of a failure response", func() {
+			pcf.failCode = http.StatusForbidden
+			id, rsp, err := client.PolicyAuthorizationCreate(
+				context.Background(), ngcnef.AppSessionContext{})
+			Expect(err).Should(BeNil())
+			Expect(id).Should(BeEmpty())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(403)))
+			Expect(rsp.Pd.Cause).Should(
+				Equal("REQUESTED_SERVICE_NOT_AUTHORIZED"))
+		})
+
+		It("Will return an error if PCF is not reachable", func() {
+			srv.Close()
+			_, rsp, err := client.PolicyAuthorizationCreate(
+				context.Background(), ngcnef.AppSessionContext{})
+			Expect(err).ShouldNot(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(0)))
+		})
+	})
+
+	Describe("NEF with PCF HTTP Client", func() {
+
+		var (
+			ctx    context.Context
+			nef    *testNef
+			tmpDir string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "nef-pcf")
+			Expect(err).Should(BeNil())
+			cfgPath := writeNefTestConfig(tmpDir,
+				func(cfg map[string]interface{}) {
+					cfg["PCFConfig"] = map[string]interface{}{
+						"type": "http", "apiRoot": srv.URL}
+				})
+
+			nef = newTestNef(cfgPath)
+			ctx = nef.ctx
+		})
+
+		AfterEach(func() {
+			nef.stop()
+			_ = os.RemoveAll(tmpDir)
+		})
+
+		It("Will create and delete the app session in PCF", func() {
+			postbody, _ := ioutil.ReadFile(testJSONPath +
+				"AF_NEF_POST_01.json")
+			patchbody, _ := ioutil.ReadFile(testJSONPath +
+				"AF_NEF_PATCH_01.json")
+
+			rr, req := CreateReqForNEF(ctx, "POST", "", postbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+			Expect(pcf.count()).Should(Equal(1))
+
+			rr, req = CreateReqForNEF(ctx, "PATCH", "11111", patchbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+
+			rr, req = CreateReqForNEF(ctx, "DELETE", "11111", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusNoContent))
+			Expect(pcf.count()).Should(Equal(0))
+		})
+
+		It("Will not create the subscription if PCF rejects it", func() {
+			postbody, _ := ioutil.ReadFile(testJSONPath +
+				"AF_NEF_POST_01.json")
+			pcf.mu.Lock()
+			pcf.failCode = http.StatusForbidden
+			pcf.mu.Unlock()
+
+			rr, req := CreateReqForNEF(ctx, "POST", "", postbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusBadRequest))
+
+			rr, req = CreateReqForNEF(ctx, "GET", "11111", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+})
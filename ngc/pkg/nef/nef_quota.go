@@ -0,0 +1,322 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Quotas of the AFs. Each AF is limited in number of traffic influence
+ * subscriptions, number of PFD applications and rate of requests. The rate is
+ * enforced by the NEF router with a token bucket per AF, the numbers when the
+ * subscriptions and PFD transactions are created. The requests exceeding a
+ * quota are rejected with 429, Retry-After and the cause of the limit. */
+
+package ngcnef
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// quotaDefaultRetryAfter is the default Retry-After in seconds sent when a
+// subscription or PFD application quota is exceeded
+const quotaDefaultRetryAfter = 60
+
+// quotaMaxIdleBuckets is the number of rate buckets above which the full
+// buckets, i.e. of the AFs idle for a while, are dropped
+const quotaMaxIdleBuckets = 1024
+
+// Causes of the 429 responses
+const (
+	quotaCauseRate     = "TOO_MANY_REQUESTS"
+	quotaCauseSubs     = "SUBSCRIPTION_QUOTA_EXCEEDED"
+	quotaCausePfdApps  = "PFD_APPLICATION_QUOTA_EXCEEDED"
+	quotaTitleExceeded = "Quota exceeded"
+)
+
+// Errors returned when a quota of the AF is exceeded
+var (
+	errSubQuotaExceeded    = errors.New("Subscription quota exceeded")
+	errPfdAppQuotaExceeded = errors.New("PFD application quota exceeded")
+)
+
+// AfQuota contains the quotas of an AF, 0 means no limit
+type AfQuota struct {
+	// Maximum number of traffic influence subscriptions
+	MaxSubscriptions int `json:"maxSubscriptions"`
+	// Maximum number of applications in the PFD transactions
+	MaxPfdApps int `json:"maxPfdApps"`
+	// Sustained rate of requests per second
+	Rate float64 `json:"rate"`
+	// Maximum burst of requests, the rate rounded up if not set
+	Burst int `json:"burst"`
+}
+
+// QuotaConfig contains the quotas of the AFs
+type QuotaConfig struct {
+	// Quotas of the AFs not listed in Afs
+	Default AfQuota `json:"default"`
+	// Quotas per AF ID, replacing the default ones
+	Afs map[string]AfQuota `json:"afs"`
+	// Retry-After in seconds sent when the subscription or PFD application
+	// quota is exceeded, 60 if not set
+	RetryAfter int `json:"retryAfter"`
+}
+
+// NefAdminQuota is the usage of the quotas of an AF
+type NefAdminQuota struct {
+	AfID  string  `json:"afId"`
+	Quota AfQuota `json:"quota"`
+	// Number of traffic influence subscriptions
+	Subscriptions int `json:"subscriptions"`
+	// Number of applications in the PFD transactions
+	PfdApps int `json:"pfdApps"`
+	// Number of requests rejected because of the rate
+	Throttled uint64 `json:"throttled"`
+}
+
+// quotaBucket is the token bucket of the requests of an AF
+type quotaBucket struct {
+	tokens    float64
+	last      time.Time
+	throttled uint64
+}
+
+// afQuotas contains the quotas and the rate buckets of the AFs
+type afQuotas struct {
+	cfg QuotaConfig
+
+	mu      sync.Mutex
+	buckets map[string]*quotaBucket
+}
+
+// validateAfQuota checks that the quota has no negative limit
+func validateAfQuota(afID string, q AfQuota) error {
+
+	if q.MaxSubscriptions < 0 || q.MaxPfdApps < 0 || q.Rate < 0 ||
+		q.Burst < 0 {
+		return errors.New("Negative quota of AF " + afID)
+	}
+	return nil
+}
+
+// newAfQuotas creates the quotas of the configuration
+func newAfQuotas(cfg QuotaConfig) (*afQuotas, error) {
+
+	if err := validateAfQuota("default", cfg.Default); err != nil {
+		return nil, err
+	}
+	for afID, q := range cfg.Afs {
+		if err := validateAfQuota(afID, q); err != nil {
+			return nil, err
+		}
+	}
+	return &afQuotas{cfg: cfg, buckets: make(map[string]*quotaBucket)}, nil
+}
+
+// quota returns the quotas of the AF
+func (q *afQuotas) quota(afID string) AfQuota {
+
+	if aq, ok := q.cfg.Afs[afID]; ok {
+		return aq
+	}
+	return q.cfg.Default
+}
+
+// burst returns the size of the token bucket of the quota
+func (aq AfQuota) burst() float64 {
+
+	if aq.Burst > 0 {
+		return float64(aq.Burst)
+	}
+	return math.Ceil(aq.Rate)
+}
+
+// allow takes a token from the bucket of the AF. If there is none, false is
+// returned with the time until the next token
+func (q *afQuotas) allow(afID string, now time.Time) (bool, time.Duration) {
+
+	aq := q.quota(afID)
+	if aq.Rate == 0 {
+		return true, 0
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	b, ok := q.buckets[afID]
+	if !ok {
+		q.dropIdleBuckets(now)
+		b = &quotaBucket{tokens: aq.burst(), last: now}
+		q.buckets[afID] = b
+	}
+	b.tokens = math.Min(aq.burst(),
+		b.tokens+now.Sub(b.last).Seconds()*aq.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	b.throttled++
+	return false, time.Duration((1 - b.tokens) / aq.Rate * float64(time.Second))
+}
+
+// dropIdleBuckets drops the buckets which are full again when there are too
+// many, the caller must hold the lock
+func (q *afQuotas) dropIdleBuckets(now time.Time) {
+
+	if len(q.buckets) < quotaMaxIdleBuckets {
+		return
+	}
+	for afID, b := range q.buckets {
+		aq := q.quota(afID)
+		if b.tokens+now.Sub(b.last).Seconds()*aq.Rate >= aq.burst() {
+			delete(q.buckets, afID)
+		}
+	}
+}
+
+// throttled returns the number of requests of the AF rejected because of
+// the rate
+func (q *afQuotas) throttled(afID string) uint64 {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if b, ok := q.buckets[afID]; ok {
+		return b.throttled
+	}
+	return 0
+}
+
+// retryAfter returns the Retry-After of the subscription and PFD
+// application quotas
+func (q *afQuotas) retryAfter() int {
+
+	return notifConfigValue(q.cfg.RetryAfter, quotaDefaultRetryAfter)
+}
+
+// afSubQuotaExceeded returns true if the AF cannot add a subscription, the
+// caller must hold the AF lock
+func (af *afData) afSubQuotaExceeded(nefCtx *nefContext) bool {
+
+	max := nefCtx.nef.quotas.quota(af.afID).MaxSubscriptions
+	return max > 0 && len(af.subs) >= max
+}
+
+// afPfdAppCount returns the number of applications in the PFD transactions
+// of the AF, the caller must hold the AF lock
+func (af *afData) afPfdAppCount() int {
+
+	count := 0
+	for _, trans := range af.pfdtrans {
+		count += len(trans.pfdManagement.PfdDatas)
+	}
+	return count
+}
+
+// afPfdAppQuotaExceeded returns true if the AF cannot add the applications,
+// the caller must hold the AF lock
+func (af *afData) afPfdAppQuotaExceeded(nefCtx *nefContext, apps int) bool {
+
+	max := nefCtx.nef.quotas.quota(af.afID).MaxPfdApps
+	return max > 0 && af.afPfdAppCount()+apps > max
+}
+
+// quotaExceededRsp returns the 429 response of the quota error, false if
+// the error is not a quota error
+func quotaExceededRsp(nefCtx *nefContext, err error) (rsp nefSBRspData,
+	ok bool) {
+
+	switch err {
+	case errSubQuotaExceeded:
+		rsp.pd.Cause = quotaCauseSubs
+	case errPfdAppQuotaExceeded:
+		rsp.pd.Cause = quotaCausePfdApps
+	default:
+		return rsp, false
+	}
+	rsp.errorCode = http.StatusTooManyRequests
+	rsp.pd.Title = quotaTitleExceeded
+	rsp.pd.Status = http.StatusTooManyRequests
+	rsp.pd.Detail = err.Error()
+	rsp.retryAfter = nefCtx.nef.quotas.retryAfter()
+	return rsp, true
+}
+
+// nefRateLimit checks the rate of the requests of the AF of the route, 429
+// is sent if exceeded
+func nefRateLimit(w http.ResponseWriter, r *http.Request,
+	nefCtx *nefContext) bool {
+
+	_, afID := nefRouteAf(r)
+	if afID == "" {
+		return true
+	}
+	ok, wait := nefCtx.nef.quotas.allow(afID, time.Now())
+	if ok {
+		return true
+	}
+
+	log.Infof("Request rate of AF %s exceeded", afID)
+	rsp := nefSBRspData{errorCode: http.StatusTooManyRequests,
+		retryAfter: int(math.Ceil(wait.Seconds())),
+		pd: ProblemDetails{Title: quotaTitleExceeded,
+			Status: http.StatusTooManyRequests, Cause: quotaCauseRate,
+			Detail: "Request rate of AF " + afID + " exceeded"}}
+	sendErrorResponseToAF(w, rsp)
+	return false
+}
+
+// ListQuotas : Returns the quotas and their usage per AF. The afId query
+// parameter selects an AF
+func ListQuotas(w http.ResponseWriter, r *http.Request) {
+
+	nefCtx := r.Context().Value(nefCtxKey("nefCtx")).(*nefContext)
+	nef := &nefCtx.nef
+	quotas := nef.quotas
+	afID := r.URL.Query().Get("afId")
+
+	usage := map[string]*NefAdminQuota{}
+	nef.mu.RLock()
+	for _, af := range nef.afs {
+		if afID != "" && af.afID != afID {
+			continue
+		}
+		af.mu.Lock()
+		usage[af.afID] = &NefAdminQuota{AfID: af.afID,
+			Subscriptions: len(af.subs), PfdApps: af.afPfdAppCount()}
+		af.mu.Unlock()
+	}
+	nef.mu.RUnlock()
+
+	// The AFs throttled before creating any data are listed too
+	quotas.mu.Lock()
+	for id := range quotas.buckets {
+		if _, ok := usage[id]; !ok && (afID == "" || id == afID) {
+			usage[id] = &NefAdminQuota{AfID: id}
+		}
+	}
+	quotas.mu.Unlock()
+
+	list := []NefAdminQuota{}
+	for id, u := range usage {
+		u.Quota = quotas.quota(id)
+		u.Throttled = quotas.throttled(id)
+		list = append(list, *u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].AfID < list[j].AfID
+	})
+	sendAdminJSONRsp(w, list)
+}
+
+// setRetryAfter sets the Retry-After header of the response
+func setRetryAfter(w http.ResponseWriter, seconds int) {
+
+	if seconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
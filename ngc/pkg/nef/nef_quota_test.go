@@ -0,0 +1,142 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// getAdminQuotas returns the quotas of the AF listed by the admin API
+func getAdminQuotas(ctx context.Context, afID string) []ngcnef.NefAdminQuota {
+
+	req := httptest.NewRequest("GET",
+		"http://127.0.0.1:18071/nef-admin/v1/quotas?afId="+afID, nil)
+	rr := httptest.NewRecorder()
+	ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+	Expect(rr.Code).Should(Equal(http.StatusOK))
+
+	var quotas []ngcnef.NefAdminQuota
+	Expect(json.Unmarshal(rr.Body.Bytes(), &quotas)).Should(Succeed())
+	return quotas
+}
+
+// expectQuotaExceeded checks the 429 response and its cause
+func expectQuotaExceeded(rr *httptest.ResponseRecorder, retryAfter string,
+	cause string) {
+
+	Expect(rr.Code).Should(Equal(http.StatusTooManyRequests))
+	Expect(rr.Header().Get("Retry-After")).Should(Equal(retryAfter))
+	pd := ngcnef.ProblemDetails{}
+	Expect(json.Unmarshal(rr.Body.Bytes(), &pd)).Should(Succeed())
+	Expect(pd.Cause).Should(Equal(cause))
+}
+
+var _ = Describe("Test NEF AF quotas", func() {
+
+	var (
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-quota")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["QuotaConfig"] = map[string]interface{}{
+					"default": map[string]interface{}{"maxSubscriptions": 1},
+					"afs": map[string]interface{}{
+						"AF_01": map[string]interface{}{
+							"maxSubscriptions": 1, "maxPfdApps": 1},
+						"AF_03": map[string]interface{}{
+							"rate": 1, "burst": 2}},
+					"retryAfter": 5}
+			})
+
+		nef = newTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will reject the subscription above the quota with 429", func() {
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+		rr, req = CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		expectQuotaExceeded(rr, "5", "SUBSCRIPTION_QUOTA_EXCEEDED")
+
+		quotas := getAdminQuotas(ctx, "AF_01")
+		Expect(quotas).Should(HaveLen(1))
+		Expect(quotas[0].Subscriptions).Should(Equal(1))
+		Expect(quotas[0].Quota.MaxSubscriptions).Should(Equal(1))
+	})
+
+	It("Will reject the PFD applications above the quota with 429", func() {
+		data, err := ioutil.ReadFile(testJSONPFDPath +
+			"AF_NEF_PFD_POST_001.json")
+		Expect(err).Should(BeNil())
+
+		req := httptest.NewRequest("POST", "http://localhost:8091/"+
+			"3gpp-pfd-management/v1/AF_01/transactions",
+			bytes.NewBuffer(data))
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		expectQuotaExceeded(rr, "5", "PFD_APPLICATION_QUOTA_EXCEEDED")
+		Expect(getAdminQuotas(ctx, "AF_01")[0].PfdApps).Should(Equal(0))
+	})
+
+	It("Will throttle the requests of an AF above its rate", func() {
+		get := func(afID string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "http://localhost:8091/"+
+				"3gpp-traffic-influence/v1/"+afID+"/subscriptions", nil)
+			rr := httptest.NewRecorder()
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			return rr
+		}
+
+		Expect(get("AF_03").Code).ShouldNot(Equal(
+			http.StatusTooManyRequests))
+		Expect(get("AF_03").Code).ShouldNot(Equal(
+			http.StatusTooManyRequests))
+		expectQuotaExceeded(get("AF_03"), "1", "TOO_MANY_REQUESTS")
+
+		// The AFs without rate are not throttled
+		for i := 0; i < 5; i++ {
+			Expect(get("AF_02").Code).ShouldNot(Equal(
+				http.StatusTooManyRequests))
+		}
+
+		quotas := getAdminQuotas(ctx, "AF_03")
+		Expect(quotas).Should(HaveLen(1))
+		Expect(quotas[0].Throttled).Should(Equal(uint64(1)))
+
+		// A token is available again after a second
+		time.Sleep(time.Second)
+		Expect(get("AF_03").Code).ShouldNot(Equal(
+			http.StatusTooManyRequests))
+	})
+})
@@ -0,0 +1,143 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Reload of the NEF configuration without restart. The configuration file is
+ * read again on SIGHUP or when it changes. The limits, the OAuth2 settings,
+ * the AF services and the log level are applied to the live configuration,
+ * which is swapped at once, and the OAuth2 configuration file is read
+ * again. The changes of the other fields, e.g. the listen end points, are
+ * reported and applied after a restart only. An invalid configuration is
+ * rejected and the current one is kept. */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	logtool "github.com/open-ness/common/log"
+	config "github.com/open-ness/epcforedge/ngc/pkg/config"
+	oauth2 "github.com/open-ness/epcforedge/ngc/pkg/oauth2"
+)
+
+// nefDefaultWatchInterval is the default interval in seconds between two
+// checks of the configuration file
+const nefDefaultWatchInterval = 10
+
+// nefReloadable are the fields of the configuration applied by a reload
+var nefReloadable = []string{
+	"maxSubSupport",
+	"maxPfdTransSupport",
+	"maxAFSupport",
+	"OAuth2Support",
+	"OAuth2Clients",
+	"afServiceIDs",
+	"logLevel",
+	"HTTP2Config.ClientCertAfs",
+}
+
+// liveConfig returns the configuration of the NEF with the reloaded fields
+func (nefCtx *nefContext) liveConfig() *Config {
+
+	if cfg, ok := nefCtx.live.Load().(*Config); ok {
+		return cfg
+	}
+	return &nefCtx.cfg
+}
+
+// setLogLevel sets the log level of the configuration, if any
+func setLogLevel(level string) error {
+
+	if level == "" {
+		return nil
+	}
+	lvl, err := logtool.ParseLevel(level)
+	if err != nil {
+		return errors.New("logLevel: " + err.Error())
+	}
+	logtool.SetLevel(lvl)
+	return nil
+}
+
+// configWatchInterval returns the interval between two checks of the
+// configuration file, 0 if the file is not watched
+func configWatchInterval(cfg *Config) time.Duration {
+
+	if cfg.ConfigWatchInterval < 0 {
+		return 0
+	}
+	return time.Duration(notifConfigValue(cfg.ConfigWatchInterval,
+		nefDefaultWatchInterval)) * time.Second
+}
+
+// nefReloadConfig reads the configuration file again and applies its
+// reloadable fields
+func nefReloadConfig(nefCtx *nefContext) (config.ReloadResult, error) {
+
+	cur := nefCtx.liveConfig()
+	var next Config
+	res, err := config.Reload(nefCtx.cfgPath, nefEnvPrefix, cur, &next,
+		nefReloadable...)
+	if err != nil {
+		return res, err
+	}
+
+	// The AF services are validated by the catalogue, nothing is applied
+	// if they are invalid
+	added, removed, changed, err := nefCtx.nef.afServices.replace(
+		next.AfServiceIDs)
+	if err != nil {
+		return res, err
+	}
+	if added+removed+changed != 0 {
+		log.Infof("AF services reloaded: %d added, %d removed, %d changed",
+			added, removed, changed)
+	}
+
+	live := *cur
+	live.MaxSubSupport = next.MaxSubSupport
+	live.MaxPfdTransSupport = next.MaxPfdTransSupport
+	live.MaxAFSupport = next.MaxAFSupport
+	live.OAuth2Support = next.OAuth2Support
+	live.OAuth2Clients = next.OAuth2Clients
+	live.AfServiceIDs = next.AfServiceIDs
+	live.LogLevel = next.LogLevel
+	live.HTTP2Config.ClientCertAfs = next.HTTP2Config.ClientCertAfs
+	nefCtx.live.Store(&live)
+
+	// The level is validated with the configuration
+	_ = setLogLevel(live.LogLevel)
+
+	// The signing key of the access tokens is read again, the current one
+	// is kept if the OAuth2 configuration is invalid
+	if err = oauth2.Reload(); err != nil {
+		log.Errf("OAuth2 configuration not reloaded: %v", err)
+	}
+	return res, nil
+}
+
+// nefWatchConfig reloads the configuration on SIGHUP or when the
+// configuration file changes, until ctx is done
+func nefWatchConfig(ctx context.Context, nefCtx *nefContext) {
+
+	config.Watch(ctx, nefCtx.cfgPath, configWatchInterval(&nefCtx.cfg),
+		func(reason string) {
+			log.Infof("%s, reloading the NEF configuration", reason)
+			res, err := nefReloadConfig(nefCtx)
+			if err != nil {
+				log.Errf("NEF configuration not reloaded: %v", err)
+				return
+			}
+			for _, c := range res.Applied {
+				log.Infof("Configuration reloaded, %v", c)
+			}
+			for _, c := range res.Ignored {
+				log.Infof("Configuration not reloaded, restart required: %v",
+					c)
+			}
+			log.Infof("NEF configuration reloaded: %d changes applied, %d "+
+				"requiring a restart", len(res.Applied), len(res.Ignored))
+		})
+}
@@ -0,0 +1,82 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+var _ = Describe("Test NEF configuration reload", func() {
+
+	var (
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// writeConfig writes the configuration with the subscription limit
+	writeConfig := func(maxSubs int, modify func(map[string]interface{})) {
+		_ = writeNefTestConfig(tmpDir, func(cfg map[string]interface{}) {
+			cfg["configWatchInterval"] = 1
+			cfg["maxSubSupport"] = maxSubs
+			modify(cfg)
+		})
+	}
+
+	// createSub returns the status of the creation of a subscription
+	createSub := func() int {
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr.Code
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-reload")
+		Expect(err).Should(BeNil())
+		writeConfig(1, func(map[string]interface{}) {})
+
+		nef = startTestNef(tmpDir + "/nef.json")
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will apply the reloadable fields only", func() {
+		Expect(createSub()).Should(Equal(http.StatusCreated))
+		Expect(createSub()).Should(Equal(http.StatusBadRequest))
+
+		// The end point change is reported and not applied
+		writeConfig(2, func(cfg map[string]interface{}) {
+			cfg["HTTPConfig"] = map[string]interface{}{"endpoint": ":8191"}
+		})
+		Eventually(createSub, 3*time.Second, 200*time.Millisecond).Should(
+			Equal(http.StatusCreated))
+		Expect(createSub()).Should(Equal(http.StatusBadRequest))
+	})
+
+	It("Will keep the configuration if the new one is invalid", func() {
+		Expect(createSub()).Should(Equal(http.StatusCreated))
+
+		writeConfig(2, func(cfg map[string]interface{}) {
+			cfg["logLevel"] = "loud"
+		})
+		Consistently(createSub, 2*time.Second, 500*time.Millisecond).Should(
+			Equal(http.StatusBadRequest))
+	})
+})
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
 	oauth2 "github.com/open-ness/epcforedge/ngc/pkg/oauth2"
 )
 
@@ -63,6 +64,20 @@ var NEFRoutes = []Route{
 		"/3gpp-traffic-influence/v1/{afId}/subscriptions/{subscriptionId}",
 		DeleteTrafficInfluenceSubscription,
 	},
+	{
+		"ConnectTrafficInfluenceWebsocket",
+		strings.ToUpper("Get"),
+		"/3gpp-traffic-influence/v1/{afId}/subscriptions/{subscriptionId}" +
+			"/websocket",
+		ConnectTrafficInfluenceWebsocket,
+	},
+	{
+		"AckTrafficInfluenceNotification",
+		strings.ToUpper("Post"),
+		"/3gpp-traffic-influence/v1/{afId}/subscriptions/{subscriptionId}" +
+			"/ack",
+		AckTrafficInfluenceNotification,
+	},
 	// PFD Management Routes
 	{
 		"ReadAllPFDManagementTransaction",
@@ -132,11 +147,143 @@ var NEFRoutes = []Route{
 	},
 }
 
+// NEFAdminRoutes : NEF administration Routes only served by the admin
+//                  listener, they are not served if AdminConfig.endpoint
+//                  is not set except the metrics served by the HTTP listener
+var NEFAdminRoutes = []Route{
+	{
+		"ReadHealth",
+		strings.ToUpper("Get"),
+		"/healthz",
+		ReadHealth,
+	},
+
+	{
+		"ReadReadiness",
+		strings.ToUpper("Get"),
+		"/readyz",
+		ReadReadiness,
+	},
+
+	{
+		"ListAfs",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/afs",
+		ListAfs,
+	},
+
+	{
+		"ListSubscriptions",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/subscriptions",
+		ListSubscriptions,
+	},
+
+	{
+		"ListPfdTransactions",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/pfd-transactions",
+		ListPfdTransactions,
+	},
+
+	{
+		"ListDeadLetterNotifications",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/notifications/dead-letters",
+		ListDeadLetterNotifications,
+	},
+
+	{
+		"ReplayAllDeadLetterNotifications",
+		strings.ToUpper("Post"),
+		"/nef-admin/v1/notifications/dead-letters/replay",
+		ReplayAllDeadLetterNotifications,
+	},
+
+	{
+		"ReplayDeadLetterNotification",
+		strings.ToUpper("Post"),
+		"/nef-admin/v1/notifications/dead-letters/{deadLetterId}/replay",
+		ReplayDeadLetterNotification,
+	},
+
+	{
+		"DeleteDeadLetterNotification",
+		strings.ToUpper("Delete"),
+		"/nef-admin/v1/notifications/dead-letters/{deadLetterId}",
+		DeleteDeadLetterNotification,
+	},
+
+	{
+		"ListGeoZones",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/geo-zones",
+		ListGeoZones,
+	},
+
+	{
+		"ReadGeoZone",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/geo-zones/{zoneId}",
+		ReadGeoZone,
+	},
+
+	{
+		"UpdateGeoZone",
+		strings.ToUpper("Put"),
+		"/nef-admin/v1/geo-zones/{zoneId}",
+		UpdateGeoZone,
+	},
+
+	{
+		"DeleteGeoZone",
+		strings.ToUpper("Delete"),
+		"/nef-admin/v1/geo-zones/{zoneId}",
+		DeleteGeoZone,
+	},
+
+	{
+		"ReadGroup",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/groups/{externalGroupId}",
+		ReadGroup,
+	},
+
+	{
+		"ListAfServices",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/af-services",
+		ListAfServices,
+	},
+
+	{
+		"ReadAfService",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/af-services/{afServiceId}",
+		ReadAfService,
+	},
+
+	{
+		"ListQuotas",
+		strings.ToUpper("Get"),
+		"/nef-admin/v1/quotas",
+		ListQuotas,
+	},
+
+	{
+		"ReadMetrics",
+		strings.ToUpper("Get"),
+		metrics.Path,
+		ReadMetrics,
+	},
+}
+
 type nefCtxKey string
 
 // NewNEFRouter : This function creates and initializes a NEF Router with all
 //                the available routes for NEF Module. This router object is
-//                defined in "github.com/gorilla/mux" package.
+//                defined in "github.com/gorilla/mux" package. The NEF
+//                administration Routes are only served by the admin router.
 //  Input Args:
 //     - nefCtx: This is NEF Module Context. This contains the NEF Module Data.
 //  Output Args:
@@ -151,20 +298,14 @@ func NewNEFRouter(nefCtx *nefContext) *mux.Router {
 	smfNotif.Method = strings.ToUpper("Post")
 	smfNotif.Handler = NotifySmfUPFEvent
 	smfNotif.Pattern = nefCtx.cfg.UpfNotificationResURIPath
-	NEFRoutes = append(NEFRoutes, smfNotif)
-
-	for _, route := range NEFRoutes {
-
-		var handler http.Handler = route.Handler
-		handler = nefRouteLogger(handler, route.Name)
-
-		router.
-			Methods(route.Method).
-			Path(route.Pattern).
-			Name(route.Name).
-			Handler(handler)
-	}
-
+	// The global route list is copied so that creating a router again (e.g.
+	// on restart) does not keep appending the notification route to it
+	routes := append(NEFRoutes[:len(NEFRoutes):len(NEFRoutes)], smfNotif)
+	addNefRoutes(router, routes)
+
+	// The metrics middleware is added first to record the requests rejected
+	// by the authorization and rate limit too
+	router.Use(nefCtx.nef.metrics.http.Middleware)
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := context.WithValue(
@@ -172,12 +313,17 @@ func NewNEFRouter(nefCtx *nefContext) *mux.Router {
 				nefCtxKey("nefCtx"),
 				nefCtx)
 
-			if nefCtx.cfg.OAuth2Support {
-				if nefValidateAccessToken(w, r) {
-					next.ServeHTTP(w, r.WithContext(ctx))
+			if !nefAuthorizeClientCert(w, r, nefCtx) {
+				return
+			}
+			if nefCtx.liveConfig().OAuth2Support {
+				claims := nefValidateAccessToken(w, r)
+				if claims == nil || !nefAuthorize(w, r, nefCtx, claims) {
+					return
 				}
-			} else {
-				//OAuth2 disabled
+			}
+			//The rate of the AF is checked once the request is authorized
+			if nefRateLimit(w, r, nefCtx) {
 				next.ServeHTTP(w, r.WithContext(ctx))
 			}
 		})
@@ -185,7 +331,66 @@ func NewNEFRouter(nefCtx *nefContext) *mux.Router {
 	return router
 }
 
-func nefValidateAccessToken(w http.ResponseWriter, r *http.Request) bool {
+// NewNEFAdminRouter : This function creates the router of the NEF admin
+//                     listener with the NEF administration Routes. The
+//                     requests are neither authorized nor rate limited, the
+//                     listener must only be reachable by the operators
+//  Input Args:
+//     - nefCtx: This is NEF Module Context. This contains the NEF Module Data.
+//  Output Args:
+//     - error: retruns pointer to created mux.Router object
+func NewNEFAdminRouter(nefCtx *nefContext) *mux.Router {
+
+	router := mux.NewRouter().StrictSlash(true)
+	addNefRoutes(router, NEFAdminRoutes)
+
+	router.Use(nefCtx.nef.metrics.http.Middleware)
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(
+				r.Context(),
+				nefCtxKey("nefCtx"),
+				nefCtx)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	return router
+}
+
+// newNEFMetricsHandler returns the handler of the HTTP listener without
+// admin listener: the metrics are served by the admin router, without
+// authorization nor rate limit, and the other requests by the NEF router
+func newNEFMetricsHandler(nefRouter, adminRouter http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metrics.Path {
+			adminRouter.ServeHTTP(w, r)
+			return
+		}
+		nefRouter.ServeHTTP(w, r)
+	})
+}
+
+// addNefRoutes adds the routes with their logger to the router
+func addNefRoutes(router *mux.Router, routes []Route) {
+
+	for _, route := range routes {
+
+		var handler http.Handler = route.Handler
+		handler = nefRouteLogger(handler, route.Name)
+
+		router.
+			Methods(route.Method).
+			Path(route.Pattern).
+			Name(route.Name).
+			Handler(handler)
+	}
+}
+
+// nefValidateAccessToken returns the claims of the access token of the
+// request, nil if the token is missing or invalid and the error is sent
+func nefValidateAccessToken(w http.ResponseWriter,
+	r *http.Request) *oauth2.AccessTokenClaims {
 
 	reqToken := r.Header.Get("Authorization")
 
@@ -195,29 +400,35 @@ func nefValidateAccessToken(w http.ResponseWriter, r *http.Request) bool {
 		w.Header().Set("WWW-Authenticate", "Bearer realm="+r.RequestURI)
 
 		w.WriteHeader(http.StatusUnauthorized)
-		return false
+		return nil
 	}
 
 	//Get the token
 	splitToken := strings.Split(reqToken, "Bearer ")
+	if len(splitToken) != 2 {
+		log.Info("Authorization header is not a bearer token")
+		w.Header().Set("WWW-Authenticate", "Bearer realm="+r.RequestURI)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
 	reqToken = splitToken[1]
 
-	status, err := oauth2.ValidateAccessToken(reqToken)
+	claims, status, err := oauth2.ParseAccessToken(reqToken)
 
 	if err != nil {
 		log.Infoln("Token Validation failed")
 		if status == oauth2.StatusInvalidToken {
 			w.Header().Set("WWW-Authenticate", "Bearer realm="+r.RequestURI)
 			w.WriteHeader(http.StatusUnauthorized)
-			return false
+			return nil
 		} else if status == oauth2.StatusBadRequest {
 			w.WriteHeader(http.StatusBadRequest)
-			return false
+			return nil
 		}
 		w.WriteHeader(http.StatusInternalServerError)
-		return false
+		return nil
 	}
-	return true
+	return claims
 }
 
 // nefRouteLogger : This function logs data received in HTTP request.
@@ -0,0 +1,374 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Common HTTP client used by the southbound (PCF/UDR) clients */
+
+package ngcnef
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/open-ness/epcforedge/ngc/pkg/oauth2"
+	"golang.org/x/net/http2"
+)
+
+// Southbound client types
+const (
+	sbClientTypeStub = "stub"
+	sbClientTypeHTTP = "http"
+)
+
+// Default timeout in seconds for the southbound requests
+const sbDefaultTimeout = 15
+
+// SBConfig contains the settings shared by all the southbound HTTP clients
+type SBConfig struct {
+	// CA certificate used to verify the PCF/UDR servers for https
+	CACert string `json:"caCert"`
+	// Send an OAuth2 access token in the requests
+	OAuth2Support bool `json:"oauth2Support"`
+	// Request timeout in seconds, 15 if not set
+	Timeout int `json:"timeout"`
+}
+
+// SBClientConfig selects the implementation of a southbound client
+type SBClientConfig struct {
+	// "stub" (default) or "http"
+	Type string `json:"type"`
+	// API Root of the NF e.g https://pcf.example.com:29507
+	APIRoot string `json:"apiRoot"`
+}
+
+// isHTTP returns true if the real HTTP client is selected. An error is
+// returned for unknown client types
+func (c SBClientConfig) isHTTP() (bool, error) {
+
+	switch c.Type {
+	case "", sbClientTypeStub:
+		return false, nil
+	case sbClientTypeHTTP:
+		return true, nil
+	}
+	return false, errors.New("Invalid SB client type: " + c.Type)
+}
+
+// sbDiscoverFn returns the API root of the NF to which the requests are sent
+type sbDiscoverFn func(ctx context.Context) (string, error)
+
+// sbClientOption is an option of the southbound HTTP client
+type sbClientOption int
+
+// Options of the southbound HTTP client
+const (
+	// The client has neither API root nor discovery, the URIs of the
+	// requests are absolute, e.g. the callback URIs given by the NF
+	sbAbsoluteURIs sbClientOption = iota + 1
+)
+
+// sbHTTPClient sends the southbound requests and decodes the responses
+type sbHTTPClient struct {
+	client  *http.Client
+	apiRoot string
+	// discover is used to get the API root when apiRoot is not configured
+	discover sbDiscoverFn
+	// absolute is set if the URIs of the requests are absolute
+	absolute  bool
+	userAgent string
+	oAuth2    bool
+}
+
+// sbTransport sends the https requests over HTTP/2 and the http requests
+// over HTTP/1.1
+type sbTransport struct {
+	h1 http.RoundTripper
+	h2 http.RoundTripper
+}
+
+// RoundTrip sends the request with the transport of the URL scheme
+func (t *sbTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	if req.URL.Scheme == "https" {
+		if t.h2 == nil {
+			return nil, errors.New("https is not configured")
+		}
+		return t.h2.RoundTrip(req)
+	}
+	return t.h1.RoundTrip(req)
+}
+
+// sbHTTPRsp contains the decoded response of a southbound request
+type sbHTTPRsp struct {
+	code   uint16
+	header http.Header
+	// pd is set for 3xx, 4xx and 5xx responses containing ProblemDetails
+	pd *ProblemDetails
+}
+
+// sbDecodeError is returned when the body of a successful response cannot be
+// decoded
+type sbDecodeError struct {
+	err error
+}
+
+func (e sbDecodeError) Error() string {
+	return "invalid response body: " + e.err.Error()
+}
+
+// newSBHTTPClient creates the HTTP client for the NF at apiRoot using the
+// common southbound settings. If apiRoot is empty the API root is got from
+// discover before each request, or the URIs of the requests are absolute
+// with sbAbsoluteURIs. HTTP/2 is used for https API roots
+func newSBHTTPClient(cfg *Config, apiRoot string, discover sbDiscoverFn,
+	opts ...sbClientOption) (*sbHTTPClient, error) {
+
+	timeout := cfg.SBConfig.Timeout
+	if timeout <= 0 {
+		timeout = sbDefaultTimeout
+	}
+
+	c := &sbHTTPClient{apiRoot: strings.TrimSuffix(apiRoot, "/"),
+		discover: discover, userAgent: cfg.UserAgent,
+		oAuth2: cfg.SBConfig.OAuth2Support}
+	for _, opt := range opts {
+		if opt == sbAbsoluteURIs {
+			c.absolute = true
+		}
+	}
+	transport := &sbTransport{h1: http.DefaultTransport}
+	c.client = &http.Client{Timeout: time.Duration(timeout) * time.Second,
+		Transport: transport}
+
+	// The scheme of a discovered API root is only known when sending the
+	// request, https has to be available
+	scheme := "https"
+	if c.absolute {
+		if apiRoot != "" || discover != nil {
+			return nil, errors.New("apiRoot or discovery set with " +
+				"absolute URIs")
+		}
+	} else if apiRoot == "" {
+		if discover == nil {
+			return nil, errors.New("apiRoot is empty")
+		}
+	} else {
+		u, err := url.Parse(apiRoot)
+		if err != nil {
+			return nil, err
+		}
+		scheme = u.Scheme
+	}
+
+	switch scheme {
+	case "http":
+	case "https":
+		tlsCfg := &tls.Config{}
+		if cfg.SBConfig.CACert != "" {
+			caCert, err := ioutil.ReadFile(filepath.Clean(
+				cfg.SBConfig.CACert))
+			if err != nil {
+				return nil, err
+			}
+			tlsCfg.RootCAs = x509.NewCertPool()
+			if !tlsCfg.RootCAs.AppendCertsFromPEM(caCert) {
+				return nil, errors.New("Invalid SB CA certificate")
+			}
+		}
+		transport.h2 = &http2.Transport{TLSClientConfig: tlsCfg}
+	default:
+		return nil, errors.New("Unsupported url scheme: " + scheme)
+	}
+	return c, nil
+}
+
+// sbAPIRootName returns the name of the API root to be logged
+func sbAPIRootName(apiRoot string) string {
+
+	if apiRoot == "" {
+		return "the NF discovered through NRF"
+	}
+	return apiRoot
+}
+
+// root returns the API root to which the requests are sent
+func (c *sbHTTPClient) root(ctx context.Context) (string, error) {
+
+	if c.absolute {
+		return "", errors.New("no API root, the URIs are absolute")
+	}
+	if c.apiRoot != "" {
+		return c.apiRoot, nil
+	}
+	apiRoot, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	if apiRoot == "" {
+		return "", errors.New("no API root discovered")
+	}
+	return strings.TrimSuffix(apiRoot, "/"), nil
+}
+
+// requestURL returns the URL of the request of the uri, relative to the API
+// root or absolute
+func (c *sbHTTPClient) requestURL(ctx context.Context, uri string) (string,
+	error) {
+
+	if !c.absolute {
+		apiRoot, err := c.root(ctx)
+		if err != nil {
+			return "", err
+		}
+		return apiRoot + uri, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("not an absolute http or https URI: " + uri)
+	}
+	return uri, nil
+}
+
+// probe checks that a connection can be opened to the NF, the NF is
+// discovered first if its API root is not configured
+func (c *sbHTTPClient) probe(ctx context.Context) error {
+
+	apiRoot, err := c.root(ctx)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(apiRoot)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{
+			"http": "80", "https": "443"}[u.Scheme])
+	}
+
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// do sends a request with the JSON encoded body to the uri relative to the
+// API root and decodes a successful response into rspBody. The error is only
+// returned if the request could not be sent or the response not decoded
+func (c *sbHTTPClient) do(ctx context.Context, method string, uri string,
+	contentType string, body interface{}, rspBody interface{}) (
+	rsp sbHTTPRsp, err error) {
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err1 := json.Marshal(body)
+		if err1 != nil {
+			return rsp, err1
+		}
+		reqBody = bytes.NewBuffer(b)
+	}
+
+	reqURL, err := c.requestURL(ctx, uri)
+	if err != nil {
+		return rsp, err
+	}
+	req, err := http.NewRequest(method, reqURL, reqBody)
+	if err != nil {
+		return rsp, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.oAuth2 {
+		token, err1 := oauth2.GetAccessToken()
+		if err1 != nil {
+			return rsp, err1
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	log.Infof("SB Request %s %s", method, req.URL)
+	res, err := c.client.Do(req)
+	if err != nil {
+		log.Errf("SB Request %s %s failed: %v", method, req.URL, err)
+		return rsp, err
+	}
+	defer func() {
+		if err1 := res.Body.Close(); err1 != nil {
+			log.Errf("response body was not closed properly")
+		}
+	}()
+
+	rsp.code = uint16(res.StatusCode)
+	rsp.header = res.Header
+	log.Infof("SB Response %s %s: %d", method, req.URL, res.StatusCode)
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return rsp, err
+	}
+	if len(data) == 0 {
+		return rsp, nil
+	}
+
+	if res.StatusCode >= 300 {
+		// The error details are optional, ignore a body that is not
+		// ProblemDetails
+		pd := ProblemDetails{}
+		if json.Unmarshal(data, &pd) == nil {
+			rsp.pd = &pd
+		}
+		return rsp, nil
+	}
+
+	if rspBody != nil {
+		if err = json.Unmarshal(data, rspBody); err != nil {
+			return rsp, sbDecodeError{err: err}
+		}
+	}
+	return rsp, nil
+}
+
+// sbLocationID returns the last segment of the location header i.e the ID
+// of the created resource
+func sbLocationID(header http.Header) (string, error) {
+
+	loc := header.Get("Location")
+	if loc == "" {
+		return "", errors.New("Location header is missing")
+	}
+	u, err := url.Parse(loc)
+	if err != nil {
+		return "", err
+	}
+	id := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if id == "." || id == "/" {
+		return "", errors.New("Invalid Location header: " + loc)
+	}
+	return id, nil
+}
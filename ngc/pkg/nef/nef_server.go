@@ -8,23 +8,31 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	logtool "github.com/open-ness/common/log"
+	config "github.com/open-ness/epcforedge/ngc/pkg/config"
 	"golang.org/x/net/http2"
 )
 
 // NefApp structure to store the variables/contexts for access in UT
 type NefApp struct {
-	NefRouter *mux.Router
-	NefCtx    *nefContext
+	NefRouter      *mux.Router
+	NefAdminRouter *mux.Router
+	NefCtx         *nefContext
 }
 
 // NefAppG is the NEF App variable which can be used for accessing the
 // global contexts
 var NefAppG NefApp
 
+// nefAppMu guards the updates of NefAppG by Run, which may run in another go
+// routine than its readers
+var nefAppMu sync.Mutex
+
 // Log handler initialized. This is to be used throughout the nef module for
 // logging
 var log = logtool.DefaultLogger.WithField("NEF", nil)
@@ -40,14 +48,34 @@ type HTTP2Config struct {
 	NefServerCert string `json:"NefServerCert"`
 	NefServerKey  string `json:"NefServerKey"`
 	AfClientCert  string `json:"AfClientCert"`
+	// CA bundle verifying the AF client certificates, mutual TLS is enabled
+	// if set
+	ClientCACert string `json:"ClientCACert"`
+	// Reject the AF requests without client certificate
+	ClientCertRequired bool `json:"ClientCertRequired"`
+	// AF IDs allowed for each identity of the client certificates
+	ClientCertAfs map[string][]string `json:"ClientCertAfs"`
+}
+
+// AdminConfig contains the configuration of the admin listener
+type AdminConfig struct {
+	// Endpoint of the admin listener, the administration routes are not
+	// served if not set except the metrics served by the HTTP listener
+	Endpoint string `json:"endpoint"`
+}
+
+// SmfEventConfig contains the settings of the SMF event handling
+type SmfEventConfig struct {
+	// Delete the single UE subscription when its PDU session is released
+	DeleteSubOnPduSesRel bool `json:"deleteSubOnPduSesRel"`
 }
 
 // Config contains NEF Module Configuration Data Structure
 type Config struct {
 	// API Root for the NEF
-	NefAPIRoot                string `json:"nefAPIRoot"`
-	LocationPrefix            string `json:"locationPrefix"`
-	LocationPrefixPfd         string `json:"locationPrefixPfd"`
+	NefAPIRoot                string `json:"nefAPIRoot" config:"required"`
+	LocationPrefix            string `json:"locationPrefix" config:"required"`
+	LocationPrefixPfd         string `json:"locationPrefixPfd" config:"required"`
 	MaxSubSupport             int    `json:"maxSubSupport"`
 	MaxPfdTransSupport        int    `json:"maxPfdTransSupport"`
 	MaxAFSupport              int    `json:"maxAFSupport"`
@@ -55,16 +83,66 @@ type Config struct {
 	PfdTransStartID           int    `json:"pfdTransStartID"`
 	UpfNotificationResURIPath string `json:"UpfNotificationResUriPath"`
 	UserAgent                 string `json:"UserAgent"`
+	LogLevel                  string `json:"logLevel"`
 	HTTPConfig                HTTPConfig
 	HTTP2Config               HTTP2Config
-	AfServiceIDs              []interface{} `json:"afServiceIDs"`
-	OAuth2Support             bool          `json:"OAuth2Support"`
+	AdminConfig               AdminConfig
+	AfServiceIDs              []AfService         `json:"afServiceIDs"`
+	ConfigWatchInterval       int                 `json:"configWatchInterval"`
+	OAuth2Support             bool                `json:"OAuth2Support"`
+	OAuth2Clients             map[string][]string `json:"OAuth2Clients"`
+	StoreConfig               StoreConfig
+	ShutdownConfig            ShutdownConfig
+	SBConfig                  SBConfig
+	PCFConfig                 SBClientConfig
+	UDRConfig                 SBClientConfig
+	UDMConfig                 UDMConfig
+	NRFConfig                 NRFConfig
+	NotifConfig               NotifConfig
+	SmfEventConfig            SmfEventConfig
+	AckConfig                 AckConfig
+	GeoZones                  []GeoZone
+	GroupConfig               GroupConfig
+	QuotaConfig               QuotaConfig
+}
+
+// nefEnvPrefix is the prefix of the environment variables overriding the
+// NEF configuration, e.g. NEF_HTTPCONFIG_ENDPOINT
+const nefEnvPrefix = "NEF"
+
+// Validate checks the NEF configuration which is loaded
+func (cfg Config) Validate() error {
+
+	if cfg.HTTPConfig.Endpoint == "" && cfg.HTTP2Config.Endpoint == "" {
+		return errors.New("HTTPConfig.endpoint or HTTP2Config.endpoint " +
+			"is required")
+	}
+	if cfg.HTTP2Config.Endpoint != "" && (cfg.HTTP2Config.NefServerCert == "" ||
+		cfg.HTTP2Config.NefServerKey == "") {
+		return errors.New("HTTP2Config.NefServerCert and " +
+			"HTTP2Config.NefServerKey are required with HTTP2Config.endpoint")
+	}
+	if cfg.HTTP2Config.ClientCACert == "" &&
+		(cfg.HTTP2Config.ClientCertRequired ||
+			len(cfg.HTTP2Config.ClientCertAfs) != 0) {
+		return errors.New("HTTP2Config.ClientCACert is required with " +
+			"HTTP2Config.ClientCertRequired and ClientCertAfs")
+	}
+	if cfg.LogLevel != "" {
+		if _, err := logtool.ParseLevel(cfg.LogLevel); err != nil {
+			return errors.New("logLevel: " + err.Error())
+		}
+	}
+	return nil
 }
 
 // NEF Module Context Data Structure
 type nefContext struct {
-	cfg Config
-	nef nefData
+	cfg     Config
+	cfgPath string
+	// Configuration with the reloaded fields, see liveConfig
+	live atomic.Value
+	nef  nefData
 }
 
 /* Go Routine is spawned here for starting HTTP Server */
@@ -79,14 +157,25 @@ func startHTTPServer(server *http.Server,
 	stopServerCh <- true
 }
 
+/* Go Routine is spawned here for starting the admin Server */
+func startAdminServer(server *http.Server,
+	stopServerCh chan bool) {
+	if server != nil {
+		log.Infof("Admin HTTP 1.1 listening on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Errf("Admin server error: " + err.Error())
+		}
+	}
+	stopServerCh <- true
+}
+
 /* Go Routine is spawned here for starting HTTP-2 Server */
 func startHTTP2Server(serverHTTP2 *http.Server, nefCtx *nefContext,
 	stopServerCh chan bool) {
 	if serverHTTP2 != nil {
 		log.Infof("HTTP 2.0 listening on %s", serverHTTP2.Addr)
-		if err := serverHTTP2.ListenAndServeTLS(
-			nefCtx.cfg.HTTP2Config.NefServerCert,
-			nefCtx.cfg.HTTP2Config.NefServerKey); err != nil {
+		// The certificate is provided by the TLS configuration
+		if err := serverHTTP2.ListenAndServeTLS("", ""); err != nil {
 			log.Errf("HTTP2server error: " + err.Error())
 		}
 	}
@@ -106,28 +195,30 @@ func startHTTP2Server(serverHTTP2 *http.Server, nefCtx *nefContext,
 func runServer(ctx context.Context, nefCtx *nefContext) error {
 
 	var err error
-	var server, serverHTTP2 *http.Server
+	var server, serverHTTP2, serverAdmin *http.Server
 
 	/* NEFRouter obeject is created. After creation this object contains all
 	 * the HTTP Service Handlers. These hanlders will be called when HTTP
 	 * server receives any HTTP Request */
 	nefRouter := NewNEFRouter(nefCtx)
+	adminRouter := NewNEFAdminRouter(nefCtx)
+	nefAppMu.Lock()
 	NefAppG.NefRouter = nefRouter
-
-	// 1 for http2, 1 for http and 1 for the os signal
-	numchannels := 3
-
-	// Check if http and http 2 are both configured to determine number
-	// of channels
+	NefAppG.NefAdminRouter = adminRouter
+	nefAppMu.Unlock()
 
 	if nefCtx.cfg.HTTPConfig.Endpoint == "" {
 		log.Info("HTTP Server not configured")
-		numchannels--
 	} else {
+		var handler http.Handler = nefRouter
+		if nefCtx.cfg.AdminConfig.Endpoint == "" {
+			// The metrics are served by the HTTP Server without admin server
+			handler = newNEFMetricsHandler(nefRouter, adminRouter)
+		}
 		// HTTP Server object is created
 		server = &http.Server{
 			Addr:           nefCtx.cfg.HTTPConfig.Endpoint,
-			Handler:        nefRouter,
+			Handler:        handler,
 			ReadTimeout:    10 * time.Second,
 			WriteTimeout:   10 * time.Second,
 			MaxHeaderBytes: 1 << 20,
@@ -136,7 +227,6 @@ func runServer(ctx context.Context, nefCtx *nefContext) error {
 
 	if nefCtx.cfg.HTTP2Config.Endpoint == "" {
 		log.Info("HTTP 2 Server not configured")
-		numchannels--
 	} else {
 		serverHTTP2 = &http.Server{
 			Addr:           nefCtx.cfg.HTTP2Config.Endpoint,
@@ -145,8 +235,14 @@ func runServer(ctx context.Context, nefCtx *nefContext) error {
 			WriteTimeout:   10 * time.Second,
 			MaxHeaderBytes: 1 << 20,
 		}
-
-		if err = http2.ConfigureServer(serverHTTP2,
+		if serverHTTP2.TLSConfig, err = nefServerTLSConfig(
+			nefCtx); err != nil {
+			/* The HTTP 2 server cannot listen without its certificates,
+			 * the other servers are started */
+			log.Errf("HTTP2server error: failed to load the certificates: %v",
+				err)
+			serverHTTP2 = nil
+		} else if err = http2.ConfigureServer(serverHTTP2,
 			&http2.Server{}); err != nil {
 			log.Errf("failed at configuring HTTP2 server")
 			return err
@@ -157,45 +253,51 @@ func runServer(ctx context.Context, nefCtx *nefContext) error {
 		return errors.New("HTTP Endpoints config missing")
 	}
 
-	stopServerCh := make(chan bool, numchannels)
-
-	/* Go Routine is spawned here for listening for cancellation event on
-	 * context */
-	go func(stopServerCh chan bool) {
-		<-ctx.Done()
-		if server != nil {
-			log.Info("Executing graceful stop for HTTP Server")
-			if err = server.Close(); err != nil {
-				log.Errf("Could not close HTTP server: %#v", err)
-			}
-			log.Info("HTTP server stopped")
+	if nefCtx.cfg.AdminConfig.Endpoint == "" {
+		/* The admin routes are not served by the NEF end points, which
+		 * are reachable by the AFs */
+		log.Info("Admin Server not configured, the admin routes are " +
+			"not served except the metrics on the HTTP Server")
+	} else {
+		serverAdmin = &http.Server{
+			Addr:           nefCtx.cfg.AdminConfig.Endpoint,
+			Handler:        adminRouter,
+			ReadTimeout:    10 * time.Second,
+			WriteTimeout:   10 * time.Second,
+			MaxHeaderBytes: 1 << 20,
 		}
+	}
 
-		if serverHTTP2 != nil {
+	// 1 for each server
+	stopServerCh := make(chan bool, 3)
+	stoppedCh := make(chan bool)
 
-			if err = serverHTTP2.Close(); err != nil {
-				log.Errf("Could not close HTTP2 server: %#v", err)
-			}
-			log.Info("HTTP2 server stopped")
-		}
+	/* Go Routine is spawned here for listening for cancellation event on
+	 * context. The servers stop accepting connections and the requests in
+	 * progress are drained before the NEF data is de-initialized */
+	go func() {
+		<-ctx.Done()
+		log.Info("Executing graceful stop for NEF Servers")
+		shutdownServers(&nefCtx.cfg, server, serverHTTP2, serverAdmin)
 
 		/* De-initializes NEF Data */
-		nefCtx.nef.nefDestroy()
+		nefCtx.nef.nefDestroy(nefCtx)
 
-		stopServerCh <- true
-	}(stopServerCh)
+		close(stoppedCh)
+	}()
 
 	/* Go Routine is spawned here for starting HTTP Server */
 	go startHTTPServer(server, stopServerCh)
 	/* Go Routine is spawned here for starting HTTP-2 Server */
 	go startHTTP2Server(serverHTTP2, nefCtx, stopServerCh)
+	/* Go Routine is spawned here for starting the admin Server */
+	go startAdminServer(serverAdmin, stopServerCh)
 	/* This self go routine is waiting for the receive events from the spawned
 	 * go routines */
 	<-stopServerCh
 	<-stopServerCh
-	if numchannels == 3 {
-		<-stopServerCh
-	}
+	<-stopServerCh
+	<-stoppedCh
 	log.Info("Exiting NEF server")
 	return nil
 
@@ -215,16 +317,22 @@ func Run(ctx context.Context, cfgPath string) error {
 
 	var nefCtx nefContext
 
-	/* Reads NEF Configuration file which is json format. Also it converts
-	 * configuration data from json format to structure data */
-	err := loadJSONConfig(cfgPath, &nefCtx.cfg)
+	/* Reads NEF Configuration file which is json or yaml format, overrides
+	 * it with the NEF_ environment variables and validates it */
+	err := config.Load(cfgPath, nefEnvPrefix, &nefCtx.cfg)
 	if err != nil {
 		log.Errf("Failed to load NEF configuration: %v", err)
 		return err
 
 	}
 
+	nefCtx.cfgPath = cfgPath
 	printConfig(nefCtx.cfg)
+	live := nefCtx.cfg
+	nefCtx.live.Store(&live)
+	if err = setLogLevel(nefCtx.cfg.LogLevel); err != nil {
+		return err
+	}
 
 	/* Creates/Initializes NEF Data */
 	err = nefCtx.nef.nefCreate(ctx, nefCtx.cfg)
@@ -232,7 +340,17 @@ func Run(ctx context.Context, cfgPath string) error {
 		log.Errf("NEF Create Failed: %v", err)
 		return err
 	}
+	nefAppMu.Lock()
 	NefAppG.NefCtx = &nefCtx
+	nefAppMu.Unlock()
+
+	/* Reloads the configuration on SIGHUP or when the configuration file
+	 * changes */
+	go nefWatchConfig(ctx, &nefCtx)
+
+	/* Starts the activation of the subscriptions according to their
+	 * tempValidities */
+	nefCtx.nef.nefScheduleSubs(&nefCtx)
 	return runServer(ctx, &nefCtx)
 }
 
@@ -245,13 +363,47 @@ func printConfig(cfg Config) {
 	log.Infoln("UpfNotificationResUriPath:", cfg.UpfNotificationResURIPath)
 	log.Infoln("Trans Start ID", cfg.PfdTransStartID)
 	log.Infoln("UserAgent:", cfg.UserAgent)
+	log.Infoln("LogLevel:", cfg.LogLevel)
 	log.Infoln("OAuth2Support:", cfg.OAuth2Support)
+	log.Infoln("OAuth2Clients:", len(cfg.OAuth2Clients))
+	log.Infoln("Store(Type/Path):", cfg.StoreConfig.Type, cfg.StoreConfig.Path)
+	log.Infoln("Shutdown(DrainTimeout/Policy):",
+		cfg.ShutdownConfig.DrainTimeout, cfg.ShutdownConfig.Policy)
 	log.Infoln("-------------------------- NEF SERVER ----------------------")
 	log.Infoln("EndPoint(HTTP): ", cfg.HTTPConfig.Endpoint)
 	log.Infoln("EndPoint(HTTP2): ", cfg.HTTP2Config.Endpoint)
 	log.Infoln("ServerCert(HTTP2): ", cfg.HTTP2Config.NefServerCert)
 	log.Infoln("ServerKey(HTTP2): ", cfg.HTTP2Config.NefServerKey)
 	log.Infoln("AFClientCert(HTTP2): ", cfg.HTTP2Config.AfClientCert)
+	log.Infoln("ClientCACert/Required(HTTP2): ", cfg.HTTP2Config.ClientCACert,
+		cfg.HTTP2Config.ClientCertRequired)
+	log.Infoln("EndPoint(Admin): ", cfg.AdminConfig.Endpoint)
+	log.Infoln("-------------------------- NEF CLIENTS ---------------------")
+	log.Infoln("SB(CACert/OAuth2/Timeout): ", cfg.SBConfig.CACert,
+		cfg.SBConfig.OAuth2Support, cfg.SBConfig.Timeout)
+	log.Infoln("PCF(Type/APIRoot): ", cfg.PCFConfig.Type,
+		cfg.PCFConfig.APIRoot)
+	log.Infoln("UDR(Type/APIRoot): ", cfg.UDRConfig.Type,
+		cfg.UDRConfig.APIRoot)
+	log.Infoln("UDM(Type/APIRoot): ", cfg.UDMConfig.Type,
+		cfg.UDMConfig.APIRoot)
+	log.Infoln("NRF(APIRoot/NfInstanceID/Heartbeat/Validity): ",
+		cfg.NRFConfig.APIRoot, cfg.NRFConfig.NfInstanceID,
+		cfg.NRFConfig.HeartbeatTimer, cfg.NRFConfig.DiscoveryValidity)
+	log.Infoln("Notif(QueueSize/Backoff/MaxBackoff/MaxAge/DeadLetters): ",
+		cfg.NotifConfig.QueueSize, cfg.NotifConfig.InitialBackoff,
+		cfg.NotifConfig.MaxBackoff, cfg.NotifConfig.MaxAge,
+		cfg.NotifConfig.DeadLetterSize)
+	log.Infoln("SmfEvent(DeleteSubOnPduSesRel): ",
+		cfg.SmfEventConfig.DeleteSubOnPduSesRel)
+	log.Infoln("Ack(Timeout): ", cfg.AckConfig.Timeout)
+	log.Infoln("GeoZones: ", len(cfg.GeoZones))
+	log.Infoln("AfServices(Count): ", len(cfg.AfServiceIDs))
+	log.Infoln("ConfigWatchInterval: ", cfg.ConfigWatchInterval)
+	log.Infoln("Groups(Backend/Groups): ", cfg.GroupConfig.Backend,
+		len(cfg.GroupConfig.Groups))
+	log.Infof("Quotas(Default/Afs): %+v %d", cfg.QuotaConfig.Default,
+		len(cfg.QuotaConfig.Afs))
 	log.Infoln("*************************************************************")
 
 }
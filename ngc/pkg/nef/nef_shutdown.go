@@ -0,0 +1,182 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Graceful shutdown of the NEF. When the NEF is stopped, the listeners stop
+ * accepting connections and the requests in progress are given a drain
+ * timeout to complete. The southbound state of the subscriptions, i.e. the
+ * PCF app sessions and the UDR influence data, is then either kept, to be
+ * used again after a restart with a persistent store, or torn down. The
+ * subscriptions torn down are stored as inactive so that their policy is
+ * installed again if the NEF is restarted with the same store. */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// shutdownDefaultDrainTimeout is the default time in seconds given to the
+// requests in progress to complete
+const shutdownDefaultDrainTimeout = 10
+
+// Shutdown policies of the southbound state
+const (
+	shutdownPolicyKeep     = "keep"
+	shutdownPolicyTeardown = "teardown"
+)
+
+// ShutdownConfig contains the settings of the NEF shutdown
+type ShutdownConfig struct {
+	// Time in seconds given to the requests in progress to complete, 10 if
+	// not set
+	DrainTimeout int `json:"drainTimeout"`
+	// Southbound state of the subscriptions at shutdown, "keep" or
+	// "teardown". Kept if not set and the store is persistent, torn down
+	// otherwise
+	Policy string `json:"policy"`
+}
+
+// NefShutdownSummary is the southbound state released at shutdown
+type NefShutdownSummary struct {
+	// Number of PCF app sessions deleted
+	AppSessions int
+	// Number of UDR influence data deleted
+	InfluenceData int
+	// Number of subscriptions whose state could not be deleted
+	Failed int
+	// Number of inactive subscriptions without southbound state
+	Inactive int
+}
+
+// shutdownPolicy returns the shutdown policy of the configuration. Keeping
+// the southbound state requires a persistent store, the state could not be
+// released after a restart otherwise
+func shutdownPolicy(cfg *Config) (string, error) {
+
+	persistent := cfg.StoreConfig.Type == storeTypeFile
+	switch cfg.ShutdownConfig.Policy {
+	case "":
+		if persistent {
+			return shutdownPolicyKeep, nil
+		}
+		return shutdownPolicyTeardown, nil
+	case shutdownPolicyKeep:
+		if !persistent {
+			return "", errors.New("Shutdown policy keep requires a " +
+				"persistent store")
+		}
+		return shutdownPolicyKeep, nil
+	case shutdownPolicyTeardown:
+		return shutdownPolicyTeardown, nil
+	}
+	return "", errors.New("Invalid shutdown policy: " +
+		cfg.ShutdownConfig.Policy)
+}
+
+// shutdownServers stops the servers, the requests in progress are given the
+// drain timeout of the configuration to complete. The connections still
+// open are then closed
+func shutdownServers(cfg *Config, servers ...*http.Server) {
+
+	drain := time.Duration(notifConfigValue(cfg.ShutdownConfig.DrainTimeout,
+		shutdownDefaultDrainTimeout)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		if server == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Errf("Requests on %s not drained within %v: %v",
+					server.Addr, drain, err)
+				if err = server.Close(); err != nil {
+					log.Errf("Could not close server %s: %v", server.Addr,
+						err)
+				}
+			}
+			log.Infof("Server %s stopped", server.Addr)
+		}(server)
+	}
+	wg.Wait()
+}
+
+// nefTeardownSubs deletes the southbound state of all the subscriptions
+// through their NEFSBDelete callback
+func (nef *nefData) nefTeardownSubs(nefCtx *nefContext) NefShutdownSummary {
+
+	var sum NefShutdownSummary
+
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
+	for _, af := range nef.afs {
+		af.mu.Lock()
+		// The AF lock is released during the southbound requests
+		subIDs := make([]string, 0, len(af.subs))
+		for subID := range af.subs {
+			subIDs = append(subIDs, subID)
+		}
+		for _, subID := range subIDs {
+			if sub := af.afAcquireSub(subID); sub != nil {
+				nef.nefTeardownSub(nefCtx, af, sub, &sum)
+				sub.sbMu.Unlock()
+			}
+		}
+		af.mu.Unlock()
+	}
+	return sum
+}
+
+// nefTeardownSub deletes the southbound state of the subscription and counts
+// it in the summary, the caller must hold the AF lock and the subscription
+func (nef *nefData) nefTeardownSub(nefCtx *nefContext, af *afData,
+	sub *afSubscription, sum *NefShutdownSummary) {
+
+	if sub.inactive {
+		sum.Inactive++
+		return
+	}
+	if err := af.afDeactivateSub(nefCtx, sub); err != nil {
+		log.Errf("Failed to release subscription %s of AF %s: %v",
+			sub.subid, af.afID, err)
+		sum.Failed++
+		return
+	}
+	if isSingleUeSub(sub.ti) {
+		sum.AppSessions++
+	} else {
+		sum.InfluenceData++
+	}
+	nef.nefStoreSub(af, sub)
+}
+
+// nefShutdown applies the shutdown policy to the southbound state of the
+// subscriptions, the activation timers must be stopped
+func (nef *nefData) nefShutdown(nefCtx *nefContext) {
+
+	policy, err := shutdownPolicy(&nefCtx.cfg)
+	if err != nil {
+		// The policy is validated at start
+		log.Errf("Shutdown policy error: %v", err)
+		return
+	}
+	if policy == shutdownPolicyKeep {
+		log.Infoln("Shutdown: southbound state of the subscriptions kept")
+		return
+	}
+
+	sum := nef.nefTeardownSubs(nefCtx)
+	log.Infof("Shutdown: %d PCF app sessions and %d UDR influence data "+
+		"released, %d failed, %d inactive subscriptions", sum.AppSessions,
+		sum.InfluenceData, sum.Failed, sum.Inactive)
+}
@@ -0,0 +1,101 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+var _ = Describe("Test NEF shutdown", func() {
+
+	var (
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		tmpDir string
+	)
+
+	// runNef starts the NEF with the configuration, creates a single UE
+	// subscription and stops the NEF
+	runNef := func(modify func(cfg map[string]interface{})) {
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+				modify(cfg)
+			})
+
+		nef := startTestNef(cfgPath)
+		defer nef.stop()
+		ctx := nef.ctx
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+		Expect(pcf.count()).Should(Equal(1))
+	}
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-shutdown")
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		pcfSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will release the PCF app sessions without persistent store",
+		func() {
+			runNef(func(cfg map[string]interface{}) {
+				cfg["ShutdownConfig"] = map[string]interface{}{
+					"drainTimeout": 1}
+			})
+			Expect(pcf.count()).Should(Equal(0))
+		})
+
+	It("Will keep the PCF app sessions with a persistent store", func() {
+		runNef(func(cfg map[string]interface{}) {
+			cfg["StoreConfig"] = map[string]interface{}{
+				"type": "file", "path": filepath.Join(tmpDir, "nef.db")}
+		})
+		Expect(pcf.count()).Should(Equal(1))
+	})
+
+	It("Will release the PCF app sessions as per the policy", func() {
+		runNef(func(cfg map[string]interface{}) {
+			cfg["StoreConfig"] = map[string]interface{}{
+				"type": "file", "path": filepath.Join(tmpDir, "nef.db")}
+			cfg["ShutdownConfig"] = map[string]interface{}{
+				"policy": "teardown"}
+		})
+		Expect(pcf.count()).Should(Equal(0))
+	})
+
+	It("Will not start with an invalid shutdown policy", func() {
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["ShutdownConfig"] = map[string]interface{}{
+					"policy": "keep"}
+			})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		Expect(ngcnef.Run(ctx, cfgPath)).ShouldNot(BeNil())
+	})
+})
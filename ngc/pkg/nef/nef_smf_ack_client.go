@@ -0,0 +1,47 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Client relaying the AF acknowledgements to the ackUri of the SMF */
+
+package ngcnef
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// SmfAckClient is an HTTP implementation of the SMF acknowledgement
+type SmfAckClient struct {
+	sb *sbHTTPClient
+}
+
+// NewSmfAckClient creates a new SMF acknowledgement client using the common
+// southbound settings
+func NewSmfAckClient(cfg *Config) (*SmfAckClient, error) {
+
+	// The ackUri are absolute URIs, there is no API root
+	sb, err := newSBHTTPClient(cfg, "", nil, sbAbsoluteURIs)
+	if err != nil {
+		return nil, err
+	}
+	return &SmfAckClient{sb: sb}, nil
+}
+
+// SmfNotifAck sends POST request with the acknowledgement to the ackUri
+// Successful response : 204
+func (smf *SmfAckClient) SmfNotifAck(ctx context.Context, ackURI URI,
+	body AckOfNotify) error {
+
+	rsp, err := smf.sb.do(ctx, http.MethodPost, string(ackURI),
+		"application/json", body, nil)
+	if err != nil {
+		return err
+	}
+	if rsp.code >= 300 {
+		return fmt.Errorf("SMF acknowledgement failed with status %d",
+			rsp.code)
+	}
+	return nil
+}
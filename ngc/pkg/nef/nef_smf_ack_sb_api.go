@@ -0,0 +1,19 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef
+
+import "context"
+
+/* The SB interface towards the SMF for relaying the acknowledgements of the
+   AF */
+
+// SmfNotifAck defines the interface relaying the acknowledgements of the
+// early UP path change notifications to the SMF
+type SmfNotifAck interface {
+
+	// SmfNotifAck sends the acknowledgement through POST method to the
+	// ackUri given by the SMF in the notification
+	SmfNotifAck(ctx context.Context, ackURI URI, body AckOfNotify) error
+}
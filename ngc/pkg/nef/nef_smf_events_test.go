@@ -0,0 +1,136 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+var _ = Describe("Test NEF SMF event notifications", func() {
+
+	var (
+		af     *fakeAFNotif
+		afSrv  *httptest.Server
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// createSub creates the subscription 11111 for the events
+	createSub := func(events []ngcnef.SubscribedEvent) {
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.NotificationDestination = ngcnef.Link(afSrv.URL)
+		ti.SubscribedEvents = events
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+	}
+
+	// sendSmfEvents sends the events for the subscription 11111
+	sendSmfEvents := func(evs ...ngcnef.NsmEventNotification) int {
+
+		smfEv := ngcnef.NsmfEventExposureNotification{NotifID: "11131",
+			EventNotifs: evs}
+		data, err := json.Marshal(smfEv)
+		Expect(err).Should(BeNil())
+
+		req := httptest.NewRequest("POST", NefTIFApiPrefix+
+			"notification/upf", bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr.Code
+	}
+
+	BeforeEach(func() {
+		af = &fakeAFNotif{}
+		afSrv = httptest.NewServer(af)
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-smf-events")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+				cfg["SmfEventConfig"] = map[string]interface{}{
+					"deleteSubOnPduSesRel": true}
+			})
+
+		nef = newTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		afSrv.Close()
+		pcfSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will relay the subscribed events in order", func() {
+		createSub([]ngcnef.SubscribedEvent{"UP_PATH_CHANGE", "PLMN_CHANGE"})
+
+		Expect(sendSmfEvents(
+			ngcnef.NsmEventNotification{Event: "PLMN_CH",
+				PlmnID: ngcnef.PlmnID{Mcc: "310", Mnc: "410"}},
+			ngcnef.NsmEventNotification{Event: "UE_IP_CH",
+				AdIpv4Addr: "10.0.0.2", ReIpv4Addr: "10.0.0.1"},
+			ngcnef.NsmEventNotification{Event: "QFI_ALLOC"},
+			ngcnef.NsmEventNotification{Event: "UP_PATH_CH",
+				DnaiChgType: "EARLY"})).Should(Equal(http.StatusOK))
+
+		Eventually(af.receivedEvents, 2*time.Second).Should(Equal(
+			[]ngcnef.SubscribedEvent{"PLMN_CHANGE", "UP_PATH_CHANGE"}))
+		Expect(getAdminSubs(ctx)).Should(HaveLen(1))
+	})
+
+	It("Will reject a notification without supported event", func() {
+		createSub(nil)
+
+		Expect(sendSmfEvents(ngcnef.NsmEventNotification{
+			Event: "QFI_ALLOC"})).Should(Equal(http.StatusBadRequest))
+		Consistently(af.receivedEvents, time.Second).Should(BeEmpty())
+	})
+
+	It("Will delete the subscription on PDU session release", func() {
+		createSub(nil)
+		Expect(pcf.count()).Should(Equal(1))
+
+		Expect(sendSmfEvents(
+			ngcnef.NsmEventNotification{Event: "AC_TY_CH",
+				AccType: "3GPP_ACCESS"},
+			ngcnef.NsmEventNotification{Event: "PDU_SES_REL",
+				PduSeID: 5})).Should(Equal(http.StatusOK))
+
+		Eventually(af.receivedEvents, 2*time.Second).Should(Equal(
+			[]ngcnef.SubscribedEvent{"ACCESS_TYPE_CHANGE",
+				"PDU_SESSION_RELEASE"}))
+		Expect(pcf.count()).Should(Equal(0))
+		Expect(getAdminSubs(ctx)).Should(BeEmpty())
+	})
+})
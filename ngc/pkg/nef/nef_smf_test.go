@@ -14,25 +14,19 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"time"
 )
 
 var _ = Describe("NefSmf", func() {
 	var (
-		ctx    context.Context
-		cancel func()
+		ctx context.Context
+		nef *testNef
 	)
 
 	Describe("NefServer SMF Functionality", func() {
 		It("Starting the NEF server", func() {
-			ctx, cancel = context.WithCancel(context.Background())
-			defer cancel()
-			go func() {
-				fmt.Println("** Starting the NEF server ***")
-				err := ngcnef.Run(ctx, NefTestCfgBasepath+"valid.json")
-				Expect(err).To(BeNil())
-			}()
-			time.Sleep(2 * time.Second)
+			fmt.Println("** Starting the NEF server ***")
+			nef = newTestNef(NefTestCfgBasepath + "valid.json")
+			ctx = nef.ctx
 		})
 
 		It("POST an UPF notification for missing body", func() {
@@ -108,7 +102,7 @@ var _ = Describe("NefSmf", func() {
 				var afTestClient ngcnef.AfNotification = ngcnef.NewAfClient(nil)
 				testURI := ngcnef.URI("invalid")
 				var ev ngcnef.EventNotification
-				err := afTestClient.AfNotificationUpfEvent(ctx,
+				_, err := afTestClient.AfNotificationUpfEvent(ctx,
 					testURI, ev)
 				Expect(err).ToNot(BeNil())
 
@@ -202,8 +196,7 @@ var _ = Describe("NefSmf", func() {
 			})
 
 		It("Stopping the NEF server", func() {
-			cancel()
-			time.Sleep(2 * time.Second)
+			nef.stop()
 			fmt.Print("** Stopping the NEF server ** ")
 		})
 
@@ -0,0 +1,500 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store types supported by the NEF
+const (
+	storeTypeMemory = "memory"
+	storeTypeFile   = "file"
+)
+
+// Journal operations of the NEF store
+const (
+	storeOpPutAf     = "putAf"
+	storeOpDelAf     = "delAf"
+	storeOpPutSub    = "putSub"
+	storeOpDelSub    = "delSub"
+	storeOpPutTrans  = "putTrans"
+	storeOpDelTrans  = "delTrans"
+	storeOpPutCorrID = "putCorrID"
+	storeOpPutDead   = "putDead"
+	storeOpDelDead   = "delDead"
+	storeOpPutZone   = "putZone"
+	storeOpDelZone   = "delZone"
+)
+
+// StoreConfig contains the configuration of the NEF state store
+type StoreConfig struct {
+	// Type of the store, "memory" (default) or "file"
+	Type string `json:"type"`
+	// Path of the journal file, applicable to the file store only
+	Path string `json:"path"`
+}
+
+// NefStoreAf is the persisted data of an AF
+type NefStoreAf struct {
+	AfID       string `json:"afId"`
+	SubIDNum   int    `json:"subIdNum"`
+	TransIDNum int    `json:"transIdNum"`
+}
+
+// NefStoreSub is the persisted data of a traffic influence subscription
+type NefStoreSub struct {
+	AfID                      string          `json:"afId"`
+	SubID                     string          `json:"subId"`
+	Ti                        TrafficInfluSub `json:"ti"`
+	AppSessionID              AppSessionID    `json:"appSessionId,omitempty"`
+	Iid                       InfluenceID     `json:"iid,omitempty"`
+	NotifCorreID              string          `json:"notifCorreId,omitempty"`
+	AfNotificationDestination Link            `json:"afNotifDest,omitempty"`
+	// Result of the last test notification requested by the AF
+	TestNotif *NefTestNotifResult `json:"testNotif,omitempty"`
+	// Set if the policy is not installed as the subscription is outside of
+	// its tempValidities
+	Inactive bool `json:"inactive,omitempty"`
+}
+
+// NefTestNotifResult is the delivery result of a test notification
+type NefTestNotifResult struct {
+	Sent      time.Time `json:"sent"`
+	Delivered bool      `json:"delivered"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// NefStorePfdTrans is the persisted data of a PFD transaction
+type NefStorePfdTrans struct {
+	AfID          string        `json:"afId"`
+	TransID       string        `json:"transId"`
+	PfdManagement PfdManagement `json:"pfdManagement"`
+}
+
+// NefStoreDeadLetter is an AF notification that could not be delivered
+type NefStoreDeadLetter struct {
+	ID           string            `json:"id"`
+	AfID         string            `json:"afId"`
+	SubID        string            `json:"subId"`
+	Destination  URI               `json:"destination"`
+	Notification EventNotification `json:"notification"`
+	// Time at which the notification was received from the SMF
+	Created time.Time `json:"created"`
+	// Time at which the notification was moved to the dead letters
+	Failed   time.Time `json:"failed"`
+	Attempts int       `json:"attempts"`
+	Reason   string    `json:"reason"`
+}
+
+// NefStoreState is the complete NEF state as loaded from a store
+type NefStoreState struct {
+	CorrID      uint                                   `json:"corrId"`
+	Afs         map[string]NefStoreAf                  `json:"afs"`
+	Subs        map[string]map[string]NefStoreSub      `json:"subs"`
+	PfdTrans    map[string]map[string]NefStorePfdTrans `json:"pfdTrans"`
+	DeadLetters map[string]NefStoreDeadLetter          `json:"deadLetters"`
+	// Geo zones changed through the admin API, nil for a deleted zone
+	GeoZones map[string]*GeoZone `json:"geoZones"`
+}
+
+// NefStore is the interface for persisting the NEF state. The NEF writes
+// every change of its AFs, subscriptions, PFD transactions, ID counters,
+// undelivered AF notifications and geo zones to the store and reloads the
+// state from it on startup.
+type NefStore interface {
+	Load() (NefStoreState, error)
+	PutAf(af NefStoreAf) error
+	DeleteAf(afID string) error
+	PutSub(sub NefStoreSub) error
+	DeleteSub(afID string, subID string) error
+	PutPfdTrans(trans NefStorePfdTrans) error
+	DeletePfdTrans(afID string, transID string) error
+	PutCorrID(corrID uint) error
+	PutDeadLetter(dl NefStoreDeadLetter) error
+	DeleteDeadLetter(id string) error
+	PutGeoZone(zone GeoZone) error
+	DeleteGeoZone(id string) error
+	Close() error
+}
+
+// nefStoreEntry is a single change applied to the store. In the file store
+// each entry is written as one line of the journal.
+type nefStoreEntry struct {
+	Op     string              `json:"op"`
+	AfID   string              `json:"afId,omitempty"`
+	ID     string              `json:"id,omitempty"`
+	CorrID uint                `json:"corrId,omitempty"`
+	Af     *NefStoreAf         `json:"af,omitempty"`
+	Sub    *NefStoreSub        `json:"sub,omitempty"`
+	Trans  *NefStorePfdTrans   `json:"trans,omitempty"`
+	Dead   *NefStoreDeadLetter `json:"dead,omitempty"`
+	Zone   *GeoZone            `json:"zone,omitempty"`
+}
+
+func newNefStoreState() NefStoreState {
+	return NefStoreState{
+		Afs:         make(map[string]NefStoreAf),
+		Subs:        make(map[string]map[string]NefStoreSub),
+		PfdTrans:    make(map[string]map[string]NefStorePfdTrans),
+		DeadLetters: make(map[string]NefStoreDeadLetter),
+		GeoZones:    make(map[string]*GeoZone),
+	}
+}
+
+// apply updates the state with the store entry
+func (s *NefStoreState) apply(e *nefStoreEntry) {
+
+	switch e.Op {
+	case storeOpPutAf:
+		s.Afs[e.Af.AfID] = *e.Af
+	case storeOpDelAf:
+		delete(s.Afs, e.AfID)
+		delete(s.Subs, e.AfID)
+		delete(s.PfdTrans, e.AfID)
+	case storeOpPutSub:
+		if s.Subs[e.Sub.AfID] == nil {
+			s.Subs[e.Sub.AfID] = make(map[string]NefStoreSub)
+		}
+		s.Subs[e.Sub.AfID][e.Sub.SubID] = *e.Sub
+	case storeOpDelSub:
+		delete(s.Subs[e.AfID], e.ID)
+	case storeOpPutTrans:
+		if s.PfdTrans[e.Trans.AfID] == nil {
+			s.PfdTrans[e.Trans.AfID] =
+				make(map[string]NefStorePfdTrans)
+		}
+		s.PfdTrans[e.Trans.AfID][e.Trans.TransID] = *e.Trans
+	case storeOpDelTrans:
+		delete(s.PfdTrans[e.AfID], e.ID)
+	case storeOpPutCorrID:
+		s.CorrID = e.CorrID
+	case storeOpPutDead:
+		s.DeadLetters[e.Dead.ID] = *e.Dead
+	case storeOpDelDead:
+		delete(s.DeadLetters, e.ID)
+	case storeOpPutZone:
+		s.GeoZones[e.Zone.ID] = e.Zone
+	case storeOpDelZone:
+		s.GeoZones[e.ID] = nil
+	default:
+		log.Errf("Unknown NEF store operation %s", e.Op)
+	}
+}
+
+// entries returns the store entries needed to rebuild the state
+func (s *NefStoreState) entries() (el []nefStoreEntry) {
+
+	el = append(el, nefStoreEntry{Op: storeOpPutCorrID, CorrID: s.CorrID})
+	for _, af := range s.Afs {
+		af := af
+		el = append(el, nefStoreEntry{Op: storeOpPutAf, Af: &af})
+	}
+	for _, subs := range s.Subs {
+		for _, sub := range subs {
+			sub := sub
+			el = append(el, nefStoreEntry{Op: storeOpPutSub, Sub: &sub})
+		}
+	}
+	for _, transl := range s.PfdTrans {
+		for _, trans := range transl {
+			trans := trans
+			el = append(el, nefStoreEntry{Op: storeOpPutTrans,
+				Trans: &trans})
+		}
+	}
+	for _, dl := range s.DeadLetters {
+		dl := dl
+		el = append(el, nefStoreEntry{Op: storeOpPutDead, Dead: &dl})
+	}
+	for id, z := range s.GeoZones {
+		if z == nil {
+			el = append(el, nefStoreEntry{Op: storeOpDelZone, ID: id})
+			continue
+		}
+		el = append(el, nefStoreEntry{Op: storeOpPutZone, Zone: z})
+	}
+	return el
+}
+
+// nefMemStore keeps the NEF state in memory. The state does not survive a
+// restart of the NEF.
+type nefMemStore struct {
+	mu    sync.Mutex
+	state NefStoreState
+	// write is invoked with the encoded entry before it is applied
+	write func(data []byte) error
+}
+
+// NewNefMemStore creates a new in-memory NEF store
+func NewNefMemStore() NefStore {
+
+	return &nefMemStore{state: newNefStoreState()}
+}
+
+// update encodes the entry and applies a decoded copy of it to the state so
+// that the store never shares any maps or slices with the NEF context
+func (m *nefMemStore) update(e nefStoreEntry) error {
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.write != nil {
+		if err = m.write(data); err != nil {
+			return err
+		}
+	}
+	var ec nefStoreEntry
+	if err = json.Unmarshal(data, &ec); err != nil {
+		return err
+	}
+	m.state.apply(&ec)
+	return nil
+}
+
+// Load returns a copy of the state kept in the store
+func (m *nefMemStore) Load() (state NefStoreState, err error) {
+
+	m.mu.Lock()
+	data, err := json.Marshal(m.state)
+	m.mu.Unlock()
+	if err != nil {
+		return state, err
+	}
+	state = newNefStoreState()
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// PutAf stores the AF data
+func (m *nefMemStore) PutAf(af NefStoreAf) error {
+	return m.update(nefStoreEntry{Op: storeOpPutAf, Af: &af})
+}
+
+// DeleteAf deletes the AF along with its subscriptions and transactions
+func (m *nefMemStore) DeleteAf(afID string) error {
+	return m.update(nefStoreEntry{Op: storeOpDelAf, AfID: afID})
+}
+
+// PutSub stores the subscription data
+func (m *nefMemStore) PutSub(sub NefStoreSub) error {
+	return m.update(nefStoreEntry{Op: storeOpPutSub, Sub: &sub})
+}
+
+// DeleteSub deletes the subscription data
+func (m *nefMemStore) DeleteSub(afID string, subID string) error {
+	return m.update(nefStoreEntry{Op: storeOpDelSub, AfID: afID, ID: subID})
+}
+
+// PutPfdTrans stores the PFD transaction data
+func (m *nefMemStore) PutPfdTrans(trans NefStorePfdTrans) error {
+	return m.update(nefStoreEntry{Op: storeOpPutTrans, Trans: &trans})
+}
+
+// DeletePfdTrans deletes the PFD transaction data
+func (m *nefMemStore) DeletePfdTrans(afID string, transID string) error {
+	return m.update(nefStoreEntry{Op: storeOpDelTrans, AfID: afID,
+		ID: transID})
+}
+
+// PutCorrID stores the next notification correlation ID
+func (m *nefMemStore) PutCorrID(corrID uint) error {
+	return m.update(nefStoreEntry{Op: storeOpPutCorrID, CorrID: corrID})
+}
+
+// PutDeadLetter stores an undelivered AF notification
+func (m *nefMemStore) PutDeadLetter(dl NefStoreDeadLetter) error {
+	return m.update(nefStoreEntry{Op: storeOpPutDead, Dead: &dl})
+}
+
+// DeleteDeadLetter deletes an undelivered AF notification
+func (m *nefMemStore) DeleteDeadLetter(id string) error {
+	return m.update(nefStoreEntry{Op: storeOpDelDead, ID: id})
+}
+
+// PutGeoZone adds or replaces a geo zone changed through the admin API
+func (m *nefMemStore) PutGeoZone(zone GeoZone) error {
+	return m.update(nefStoreEntry{Op: storeOpPutZone, Zone: &zone})
+}
+
+// DeleteGeoZone records the deletion of a geo zone through the admin API
+func (m *nefMemStore) DeleteGeoZone(id string) error {
+	return m.update(nefStoreEntry{Op: storeOpDelZone, ID: id})
+}
+
+// Close closes the store
+func (m *nefMemStore) Close() error {
+	return nil
+}
+
+// nefFileStore persists the NEF state in an append only journal file. On
+// open the journal is replayed and compacted to the current state.
+type nefFileStore struct {
+	nefMemStore
+	path string
+	file *os.File
+}
+
+// NewNefFileStore opens (or creates) the journal file at path and returns a
+// NEF store backed by it
+func NewNefFileStore(path string) (NefStore, error) {
+
+	if path == "" {
+		return nil, errors.New("NEF store journal path is empty")
+	}
+
+	fs := &nefFileStore{path: filepath.Clean(path)}
+	fs.state = newNefStoreState()
+
+	if err := os.MkdirAll(filepath.Dir(fs.path), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := fs.replay(); err != nil {
+		return nil, err
+	}
+	if err := fs.compact(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fs.file = f
+	fs.write = fs.append
+	log.Infof("NEF file store opened: %s", fs.path)
+	return fs, nil
+}
+
+// replay reads the journal and applies all the entries to the state
+func (fs *nefFileStore) replay() error {
+
+	f, err := os.Open(fs.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err = f.Close(); err != nil {
+			log.Errf("Failed to close NEF journal: %v", err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	var invalid error
+	for scanner.Scan() {
+		line++
+		if invalid != nil {
+			// Only the last entry can be partially written, the journal
+			// is not compacted without a corrupt entry
+			return invalid
+		}
+		var e nefStoreEntry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			invalid = fmt.Errorf("NEF journal %s: invalid entry at line %d: %v",
+				fs.path, line, err)
+			continue
+		}
+		fs.state.apply(&e)
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if invalid != nil {
+		// A partially written last entry is expected after a crash
+		log.Errf("%v, skipping it", invalid)
+	}
+	return nil
+}
+
+// compact rewrites the journal so that it only contains the current state
+func (fs *nefFileStore) compact() (err error) {
+
+	tmpPath := fs.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, e := range fs.state.entries() {
+		var data []byte
+		if data, err = json.Marshal(e); err != nil {
+			break
+		}
+		if _, err = w.Write(append(data, '\n')); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, fs.path)
+}
+
+// append writes an entry at the end of the journal
+func (fs *nefFileStore) append(data []byte) error {
+
+	if fs.file == nil {
+		return errors.New("NEF file store is closed")
+	}
+	if _, err := fs.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return fs.file.Sync()
+}
+
+// Close closes the journal file
+func (fs *nefFileStore) Close() error {
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.file == nil {
+		return nil
+	}
+	err := fs.file.Close()
+	fs.file = nil
+	return err
+}
+
+// newNefStore creates the NEF store as per the configuration
+func newNefStore(cfg StoreConfig) (NefStore, error) {
+
+	switch cfg.Type {
+	case "", storeTypeMemory:
+		return NewNefMemStore(), nil
+	case storeTypeFile:
+		return NewNefFileStore(cfg.Path)
+	}
+	return nil, errors.New("Invalid NEF store type: " + cfg.Type)
+}
@@ -0,0 +1,263 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+var _ = Describe("Test NEF Store", func() {
+
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-store")
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	Describe("File store journal", func() {
+
+		It("Will reload the stored state after reopen", func() {
+			path := filepath.Join(tmpDir, "nef.journal")
+			store, err := ngcnef.NewNefFileStore(path)
+			Expect(err).Should(BeNil())
+
+			Expect(store.PutAf(ngcnef.NefStoreAf{AfID: "AF_01",
+				SubIDNum: 11113, TransIDNum: 10001})).Should(Succeed())
+			Expect(store.PutSub(ngcnef.NefStoreSub{AfID: "AF_01",
+				SubID: "11111", AppSessionID: "1234",
+				NotifCorreID: "11131",
+				Ti: ngcnef.TrafficInfluSub{AfTransID: "tr1",
+					Gpsi: "5551234"}})).Should(Succeed())
+			Expect(store.PutSub(ngcnef.NefStoreSub{AfID: "AF_01",
+				SubID: "11112"})).Should(Succeed())
+			Expect(store.DeleteSub("AF_01", "11112")).Should(Succeed())
+			Expect(store.PutPfdTrans(ngcnef.NefStorePfdTrans{AfID: "AF_01",
+				TransID: "10000"})).Should(Succeed())
+			Expect(store.PutCorrID(11132)).Should(Succeed())
+			Expect(store.Close()).Should(Succeed())
+
+			// Writes after close are rejected
+			Expect(store.PutCorrID(11133)).ShouldNot(Succeed())
+
+			store, err = ngcnef.NewNefFileStore(path)
+			Expect(err).Should(BeNil())
+			state, err := store.Load()
+			Expect(err).Should(BeNil())
+
+			Expect(state.CorrID).Should(Equal(uint(11132)))
+			Expect(state.Afs["AF_01"].SubIDNum).Should(Equal(11113))
+			Expect(state.Afs["AF_01"].TransIDNum).Should(Equal(10001))
+			Expect(state.Subs["AF_01"]).Should(HaveLen(1))
+			sub := state.Subs["AF_01"]["11111"]
+			Expect(sub.AppSessionID).Should(Equal(ngcnef.AppSessionID("1234")))
+			Expect(sub.NotifCorreID).Should(Equal("11131"))
+			Expect(sub.Ti.Gpsi).Should(Equal(ngcnef.Gpsi("5551234")))
+			Expect(state.PfdTrans["AF_01"]).Should(HaveKey("10000"))
+
+			Expect(store.DeleteAf("AF_01")).Should(Succeed())
+			Expect(store.Close()).Should(Succeed())
+
+			store, err = ngcnef.NewNefFileStore(path)
+			Expect(err).Should(BeNil())
+			state, err = store.Load()
+			Expect(err).Should(BeNil())
+			Expect(state.Afs).Should(BeEmpty())
+			Expect(state.Subs).Should(BeEmpty())
+			Expect(state.PfdTrans).Should(BeEmpty())
+			Expect(store.Close()).Should(Succeed())
+		})
+
+		It("Will skip a partially written journal entry", func() {
+			path := filepath.Join(tmpDir, "nef.journal")
+			store, err := ngcnef.NewNefFileStore(path)
+			Expect(err).Should(BeNil())
+			Expect(store.PutCorrID(200)).Should(Succeed())
+			Expect(store.Close()).Should(Succeed())
+
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+			Expect(err).Should(BeNil())
+			_, err = f.WriteString(`{"op":"putCorrID","corr`)
+			Expect(err).Should(BeNil())
+			Expect(f.Close()).Should(Succeed())
+
+			store, err = ngcnef.NewNefFileStore(path)
+			Expect(err).Should(BeNil())
+			state, err := store.Load()
+			Expect(err).Should(BeNil())
+			Expect(state.CorrID).Should(Equal(uint(200)))
+			Expect(store.Close()).Should(Succeed())
+		})
+
+		It("Will not open a journal with a corrupt entry", func() {
+			// The corrupt entry is followed by another one
+			path := filepath.Join(tmpDir, "nef.journal")
+			journal := []byte(`{"op":"putCorrID","corrId":200}` + "\n" +
+				`{"op":"putCorrID","corr` + "\n" +
+				`{"op":"putCorrID","corrId":201}` + "\n")
+			Expect(ioutil.WriteFile(path, journal, 0600)).Should(Succeed())
+
+			_, err := ngcnef.NewNefFileStore(path)
+			Expect(err).ShouldNot(BeNil())
+			// The journal is not compacted
+			data, err := ioutil.ReadFile(path)
+			Expect(err).Should(BeNil())
+			Expect(data).Should(Equal(journal))
+		})
+	})
+
+	Describe("File store geo zones", func() {
+
+		It("Will reload the changed and deleted geo zones", func() {
+			path := filepath.Join(tmpDir, "nef.journal")
+			store, err := ngcnef.NewNefFileStore(path)
+			Expect(err).Should(BeNil())
+
+			zone := ngcnef.GeoZone{ID: "ZONE_02", Tais: []ngcnef.Tai{{
+				Tac: "000002"}}}
+			Expect(store.PutGeoZone(zone)).Should(Succeed())
+			Expect(store.DeleteGeoZone("ZONE_01")).Should(Succeed())
+			Expect(store.Close()).Should(Succeed())
+
+			store, err = ngcnef.NewNefFileStore(path)
+			Expect(err).Should(BeNil())
+			state, err := store.Load()
+			Expect(err).Should(BeNil())
+			Expect(state.GeoZones).Should(Equal(map[string]*ngcnef.GeoZone{
+				"ZONE_01": nil, "ZONE_02": &zone}))
+			Expect(store.Close()).Should(Succeed())
+		})
+	})
+
+	Describe("NEF restart with file store", func() {
+
+		It("Will not open the store with an invalid configuration", func() {
+			path := filepath.Join(tmpDir, "store", "nef.journal")
+			cfgPath := writeNefTestConfig(tmpDir,
+				func(cfg map[string]interface{}) {
+					cfg["StoreConfig"] = map[string]interface{}{
+						"type": "file", "path": path}
+					cfg["UpfNotificationResUriPath"] = ""
+				})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			Expect(ngcnef.Run(ctx, cfgPath)).ShouldNot(BeNil())
+			_, err := os.Stat(filepath.Dir(path))
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		It("Will restore the geo zones changed through the admin API",
+			func() {
+				cfgPath := writeNefTestConfig(tmpDir,
+					func(cfg map[string]interface{}) {
+						cfg["StoreConfig"] = map[string]interface{}{
+							"type": "file",
+							"path": filepath.Join(tmpDir, "nef.journal"),
+						}
+					})
+
+				// sendZoneReq sends a request of the admin API of the zone
+				sendZoneReq := func(ctx context.Context, method string,
+					zoneID string, body string) int {
+
+					uri := "http://127.0.0.1:18071/nef-admin/v1/geo-zones/" +
+						zoneID
+					req := httptest.NewRequest(method, uri,
+						strings.NewReader(body))
+					rr := httptest.NewRecorder()
+					ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr,
+						req.WithContext(ctx))
+					return rr.Code
+				}
+
+				nef := startTestNef(cfgPath)
+				ctx := nef.ctx
+
+				Expect(sendZoneReq(ctx, "PUT", "ZONE_02",
+					`{"tais": [{"tac": "000002"}]}`)).Should(Equal(
+					http.StatusCreated))
+				Expect(sendZoneReq(ctx, "DELETE", "ZONE_01", "")).Should(
+					Equal(http.StatusNoContent))
+
+				nef.stop()
+
+				// The stored changes win over the configured ZONE_01
+				nef = startTestNef(cfgPath)
+				defer nef.stop()
+				ctx = nef.ctx
+
+				Expect(sendZoneReq(ctx, "GET", "ZONE_01", "")).Should(Equal(
+					http.StatusNotFound))
+				Expect(sendZoneReq(ctx, "GET", "ZONE_02", "")).Should(Equal(
+					http.StatusOK))
+
+				nef.stop()
+			})
+
+		It("Will restore subscriptions and PFD transactions", func() {
+			cfgPath := writeNefTestConfig(tmpDir,
+				func(cfg map[string]interface{}) {
+					cfg["StoreConfig"] = map[string]interface{}{
+						"type": "file",
+						"path": filepath.Join(tmpDir, "nef.journal"),
+					}
+				})
+			postbody, _ := ioutil.ReadFile(testJSONPath +
+				"AF_NEF_POST_01.json")
+			pfdbody, _ := ioutil.ReadFile(testJSONPFDPath +
+				"AF_NEF_PFD_POST_001.json")
+
+			nef := startTestNef(cfgPath)
+			ctx := nef.ctx
+
+			rr, req := CreateReqForNEF(ctx, "POST", "", postbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+			rr, req = CreatePFDReqForNEF(ctx, "POST", "", "", pfdbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+			nef.stop()
+
+			nef = startTestNef(cfgPath)
+			defer nef.stop()
+			ctx = nef.ctx
+
+			rr, req = CreateReqForNEF(ctx, "GET", "11111", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+
+			rr, req = CreatePFDReqForNEF(ctx, "GET", "10000", "app1", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+
+			// The ID counters continue from the persisted values
+			rr, req = CreateReqForNEF(ctx, "POST", "", postbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+			Expect(rr.Header().Get("Location")).Should(
+				HaveSuffix("/subscriptions/11112"))
+
+			nef.stop()
+		})
+	})
+})
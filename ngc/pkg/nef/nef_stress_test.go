@@ -0,0 +1,324 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+const (
+	stressAfCount    = 8
+	stressWorkers    = 3
+	stressIterations = 10
+	stressTIFPrefix  = "http://localhost:8091/3gpp-traffic-influence/v1/"
+	stressPFDPrefix  = "http://localhost:8091/3gpp-pfd-management/v1/"
+)
+
+// stressRsp is the part of a NEF response checked by the stress test
+type stressRsp struct {
+	Code     int
+	Location string
+	Body     string
+}
+
+// stressReq sends a request to the NEF HTTP endpoint. The requests go
+// through the network rather than NefAppG.NefRouter so that the race
+// detector only sees the accesses made by the NEF itself.
+func stressReq(ctx context.Context, method string, url string,
+	body []byte) stressRsp {
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return stressRsp{Body: err.Error()}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rsp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return stressRsp{Body: err.Error()}
+	}
+	defer rsp.Body.Close()
+	b, _ := ioutil.ReadAll(rsp.Body)
+	return stressRsp{Code: rsp.StatusCode,
+		Location: rsp.Header.Get("Location"), Body: string(b)}
+}
+
+// stressPfdBody creates a PFD transaction with a single application
+func stressPfdBody(appID string) []byte {
+
+	trans := ngcnef.PfdManagement{PfdDatas: map[string]ngcnef.PfdData{
+		appID: {ExternalAppID: appID, Pfds: map[string]ngcnef.Pfd{
+			"pfd1": {PfdID: "pfd1", DomainNames: []string{"www.edge.com"}},
+		}},
+	}}
+	b, _ := json.Marshal(trans)
+	return b
+}
+
+// stressTIWorker creates, updates, reads and deletes subscriptions of an AF
+func stressTIWorker(ctx context.Context, afID string, body []byte,
+	patchBody []byte) error {
+
+	for i := 0; i < stressIterations; i++ {
+		rr := stressReq(ctx, "POST", stressTIFPrefix+afID+"/subscriptions",
+			body)
+		if rr.Code != http.StatusCreated {
+			return fmt.Errorf("%s POST: %d", afID, rr.Code)
+		}
+		loc := rr.Location
+		subID := loc[strings.LastIndex(loc, "/")+1:]
+
+		subURL := stressTIFPrefix + afID + "/subscriptions/" + subID
+		if rr = stressReq(ctx, "PATCH", subURL, patchBody); rr.Code !=
+			http.StatusOK {
+			return fmt.Errorf("%s PATCH %s: %d", afID, subID, rr.Code)
+		}
+		if rr = stressReq(ctx, "GET", subURL, nil); rr.Code !=
+			http.StatusOK {
+			return fmt.Errorf("%s GET %s: %d", afID, subID, rr.Code)
+		}
+		if rr = stressReq(ctx, "GET", stressTIFPrefix+afID+
+			"/subscriptions", nil); rr.Code != http.StatusOK {
+			return fmt.Errorf("%s GET all: %d", afID, rr.Code)
+		}
+		if rr = stressReq(ctx, "DELETE", subURL, nil); rr.Code !=
+			http.StatusNoContent {
+			return fmt.Errorf("%s DELETE %s: %d", afID, subID, rr.Code)
+		}
+	}
+	return nil
+}
+
+// stressPFDWorker creates, reads and deletes PFD transactions of an AF
+func stressPFDWorker(ctx context.Context, afID string, worker int) error {
+
+	for i := 0; i < stressIterations; i++ {
+		appID := fmt.Sprintf("%s-app-%d-%d", afID, worker, i)
+		rr := stressReq(ctx, "POST", stressPFDPrefix+afID+"/transactions",
+			stressPfdBody(appID))
+		if rr.Code != http.StatusCreated {
+			return fmt.Errorf("%s PFD POST: %d", afID, rr.Code)
+		}
+		loc := rr.Location
+		transURL := stressPFDPrefix + afID + "/transactions/" +
+			loc[strings.LastIndex(loc, "/")+1:]
+
+		if rr = stressReq(ctx, "GET", transURL+"/applications/"+appID,
+			nil); rr.Code != http.StatusOK {
+			return fmt.Errorf("%s PFD GET app %s: %d", afID, appID, rr.Code)
+		}
+		if rr = stressReq(ctx, "GET", stressPFDPrefix+afID+"/transactions",
+			nil); rr.Code != http.StatusOK {
+			return fmt.Errorf("%s PFD GET all: %d", afID, rr.Code)
+		}
+		if rr = stressReq(ctx, "DELETE", transURL, nil); rr.Code !=
+			http.StatusNoContent {
+			return fmt.Errorf("%s PFD DELETE: %d", afID, rr.Code)
+		}
+	}
+	return nil
+}
+
+var _ = Describe("Test NEF concurrent requests", func() {
+
+	var (
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-stress")
+		Expect(err).Should(BeNil())
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["MaxAFSupport"] = stressAfCount
+				cfg["MaxSubSupport"] = stressWorkers * 2
+				cfg["MaxPfdTransSupport"] = stressWorkers
+			})
+
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will handle parallel create/patch/delete across many AFs", func() {
+		postPCF, _ := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		patchPCF, _ := ioutil.ReadFile(testJSONPath + "AF_NEF_PATCH_01.json")
+		postUDR, _ := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_UDR_01.json")
+		patchUDR, _ := ioutil.ReadFile(testJSONPath +
+			"AF_NEF_PATCH_UDR_01.json")
+
+		var wg sync.WaitGroup
+		total := stressAfCount * stressWorkers * 2
+		errs := make(chan error, total)
+
+		for a := 0; a < stressAfCount; a++ {
+			afID := fmt.Sprintf("AF_STRESS_%02d", a)
+			for w := 0; w < stressWorkers; w++ {
+				body, patch := postPCF, patchPCF
+				if w%2 == 1 {
+					body, patch = postUDR, patchUDR
+				}
+				wg.Add(2)
+				go func(afID string, body, patch []byte) {
+					defer wg.Done()
+					errs <- stressTIWorker(ctx, afID, body, patch)
+				}(afID, body, patch)
+				go func(afID string, w int) {
+					defer wg.Done()
+					errs <- stressPFDWorker(ctx, afID, w)
+				}(afID, w)
+			}
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			Expect(err).Should(BeNil())
+		}
+
+		// All the AFs are deleted with their last subscription/transaction
+		for a := 0; a < stressAfCount; a++ {
+			afID := fmt.Sprintf("AF_STRESS_%02d", a)
+			rr := stressReq(ctx, "GET", stressTIFPrefix+afID+"/subscriptions",
+				nil)
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+			Expect(strings.TrimSpace(rr.Body)).Should(Equal("null"))
+			rr = stressReq(ctx, "GET", stressPFDPrefix+afID+"/transactions",
+				nil)
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+			Expect(strings.TrimSpace(rr.Body)).Should(Equal("null"))
+		}
+	})
+
+	It("Will allocate unique IDs for parallel creates on one AF", func() {
+		postPCF, _ := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		afID := "AF_STRESS_IDS"
+		count := stressWorkers * 2
+
+		var wg sync.WaitGroup
+		locs := make(chan string, count)
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rr := stressReq(ctx, "POST", stressTIFPrefix+afID+
+					"/subscriptions", postPCF)
+				if rr.Code == http.StatusCreated {
+					locs <- rr.Location
+				}
+			}()
+		}
+		wg.Wait()
+		close(locs)
+
+		unique := make(map[string]bool)
+		for loc := range locs {
+			unique[loc] = true
+		}
+		Expect(unique).Should(HaveLen(count))
+
+		for loc := range unique {
+			subID := loc[strings.LastIndex(loc, "/")+1:]
+			rr := stressReq(ctx, "DELETE", stressTIFPrefix+afID+
+				"/subscriptions/"+subID, nil)
+			Expect(rr.Code).Should(Equal(http.StatusNoContent))
+		}
+	})
+})
+
+var _ = Describe("Test NEF southbound requests in progress", func() {
+
+	It("Will serve the AF while a PCF request is pending", func() {
+		pcf := newFakePCF()
+		held := make(chan struct{})
+		release := make(chan struct{})
+		var posts int32
+		// The second app session creation is held until released
+		pcfSrv := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost &&
+					atomic.AddInt32(&posts, 1) == 2 {
+					close(held)
+					<-release
+				}
+				pcf.ServeHTTP(w, r)
+			}))
+		defer pcfSrv.Close()
+		var unhold sync.Once
+		defer unhold.Do(func() { close(release) })
+
+		tmpDir, err := ioutil.TempDir("", "nef-sb-pending")
+		Expect(err).Should(BeNil())
+		defer func() { _ = os.RemoveAll(tmpDir) }()
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+			})
+
+		nef := startTestNef(cfgPath)
+		defer nef.stop()
+		ctx := nef.ctx
+
+		afID := "AF_SB_PENDING"
+		post, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		rr := stressReq(ctx, "POST", stressTIFPrefix+afID+"/subscriptions",
+			post)
+		Expect(rr.Code).Should(Equal(http.StatusCreated))
+		subURL := stressTIFPrefix + afID + "/subscriptions/" +
+			rr.Location[strings.LastIndex(rr.Location, "/")+1:]
+
+		pending := make(chan stressRsp, 1)
+		go func() {
+			pending <- stressReq(ctx, "POST", stressTIFPrefix+afID+
+				"/subscriptions", post)
+		}()
+		Eventually(held, 5*time.Second).Should(BeClosed())
+
+		// The subscription being created is not visible and the other
+		// requests of the AF are not blocked by the pending request
+		reqCtx, reqCancel := context.WithTimeout(ctx, 2*time.Second)
+		defer reqCancel()
+		rr = stressReq(reqCtx, "GET", stressTIFPrefix+afID+"/subscriptions",
+			nil)
+		Expect(rr.Code).Should(Equal(http.StatusOK))
+		var subs []ngcnef.TrafficInfluSub
+		Expect(json.Unmarshal([]byte(rr.Body), &subs)).Should(Succeed())
+		Expect(subs).Should(HaveLen(1))
+		Expect(stressReq(reqCtx, "DELETE", subURL, nil).Code).Should(
+			Equal(http.StatusNoContent))
+		Expect(pcf.count()).Should(Equal(0))
+
+		unhold.Do(func() { close(release) })
+		Eventually(pending, 5*time.Second).Should(Receive(
+			WithTransform(func(r stressRsp) int { return r.Code },
+				Equal(http.StatusCreated))))
+		Expect(pcf.count()).Should(Equal(1))
+	})
+})
@@ -5,10 +5,20 @@
 package ngcnef_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
 )
 
 const NefTestCfgBasepath = "../../test/nef/configs/"
@@ -20,3 +30,130 @@ func TestNef(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Nef Suite")
 }
+
+// writeNefTestConfig writes a copy of the valid NEF test configuration with
+// the updates applied by modify into dir and returns the path of the copy
+func writeNefTestConfig(dir string,
+	modify func(cfg map[string]interface{})) string {
+
+	data, err := ioutil.ReadFile(NefTestCfgBasepath + "valid.json")
+	Expect(err).Should(BeNil())
+
+	cfg := make(map[string]interface{})
+	Expect(json.Unmarshal(data, &cfg)).Should(Succeed())
+	modify(cfg)
+
+	data, err = json.Marshal(cfg)
+	Expect(err).Should(BeNil())
+	cfgPath := filepath.Join(dir, "nef.json")
+	Expect(ioutil.WriteFile(cfgPath, data, 0600)).Should(Succeed())
+	return cfgPath
+}
+
+// testNef is a NEF started by a test, its routers are set in NefAppG
+type testNef struct {
+	ctx    context.Context
+	cancel func()
+	// done receives the result of Run, nil if the servers are not started
+	done chan error
+	// destroy destroys the NEF data if the servers are not started
+	destroy func()
+	stopped bool
+}
+
+// testNefEndpoints are the endpoints of a NEF test configuration
+type testNefEndpoints struct {
+	HTTPConfig struct {
+		Endpoint string
+	}
+	AdminConfig struct {
+		Endpoint string
+	}
+}
+
+// startTestNef runs the NEF with the configuration and waits until its
+// listeners accept the connections and it is ready
+func startTestNef(cfgPath string) *testNef {
+
+	data, err := ioutil.ReadFile(cfgPath)
+	Expect(err).Should(BeNil())
+	eps := testNefEndpoints{}
+	Expect(json.Unmarshal(data, &eps)).Should(Succeed())
+
+	ngcnef.ResetNefApp()
+	n := &testNef{done: make(chan error, 1)}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	go func() {
+		n.done <- ngcnef.Run(n.ctx, cfgPath)
+	}()
+
+	// NefAppG is read by the tests once Run created the routers
+	Eventually(func() bool {
+		select {
+		case err = <-n.done:
+			Fail(fmt.Sprintf("NEF stopped at start: %v", err))
+		default:
+		}
+		return ngcnef.NefStarted()
+	}, 5*time.Second, 10*time.Millisecond).Should(BeTrue())
+
+	if eps.HTTPConfig.Endpoint != "" {
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", eps.HTTPConfig.Endpoint)
+			if err == nil {
+				_ = conn.Close()
+			}
+			return err
+		}, 5*time.Second, 10*time.Millisecond).Should(Succeed())
+	}
+	Eventually(func() int {
+		return getTestNefReadiness(eps.AdminConfig.Endpoint)
+	}, 5*time.Second, 10*time.Millisecond).Should(Equal(http.StatusOK))
+	return n
+}
+
+// getTestNefReadiness returns the status of /readyz, served by the admin
+// listener if its endpoint is set and by the admin router otherwise
+func getTestNefReadiness(adminEndpoint string) int {
+
+	if adminEndpoint == "" {
+		rr := httptest.NewRecorder()
+		ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr,
+			httptest.NewRequest("GET", "http://127.0.0.1/readyz", nil))
+		return rr.Code
+	}
+	rsp, err := http.Get("http://" + adminEndpoint + "/readyz")
+	if err != nil {
+		return 0
+	}
+	_ = rsp.Body.Close()
+	return rsp.StatusCode
+}
+
+// newTestNef creates the NEF data and routers of the configuration without
+// starting the NEF servers, the handlers are served with httptest
+func newTestNef(cfgPath string) *testNef {
+
+	n := &testNef{}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	var err error
+	n.destroy, err = ngcnef.NewNefRouters(n.ctx, cfgPath)
+	Expect(err).Should(BeNil())
+	return n
+}
+
+// stop stops the NEF if not yet stopped and waits until its data is
+// destroyed
+func (n *testNef) stop() {
+
+	if n.stopped {
+		return
+	}
+	n.stopped = true
+	n.cancel()
+	if n.done == nil {
+		n.destroy()
+		return
+	}
+	Eventually(n.done, 15*time.Second).Should(Receive(BeNil()))
+}
@@ -0,0 +1,258 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Activation of the traffic influence subscriptions according to their
+ * tempValidities. A subscription is active, i.e. its policy is installed in
+ * the PCF or UDR, only during its validity windows. A timer per subscription
+ * installs the policy at each startTime and removes it at each stopTime. The
+ * windows whose times are not RFC 3339 date-times are not scheduled, a
+ * subscription without any scheduled window is always active. */
+
+package ngcnef
+
+import (
+	"errors"
+	"time"
+)
+
+// tiActivationRetry is the delay before retrying a failed installation or
+// removal of the policy of a subscription
+const tiActivationRetry = 10 * time.Second
+
+// tempValidityWindow is a validity window, a zero start or stop time means
+// that the window is not bounded
+type tempValidityWindow struct {
+	start time.Time
+	stop  time.Time
+}
+
+// parseTempValidityTime parses a time of a validity window, an empty time is
+// returned as the zero time
+func parseTempValidityTime(t string) (time.Time, error) {
+
+	if t == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, t)
+}
+
+// parseTempValidities returns the validity windows to be scheduled and the
+// number of windows ignored because their times are not RFC 3339 date-times.
+// An error is returned if a window stops before it starts
+func parseTempValidities(tvs []TemporalValidity) (ws []tempValidityWindow,
+	ignored int, err error) {
+
+	for _, tv := range tvs {
+		if tv.StartTime == "" && tv.StopTime == "" {
+			continue
+		}
+		start, err1 := parseTempValidityTime(tv.StartTime)
+		stop, err2 := parseTempValidityTime(tv.StopTime)
+		if err1 != nil || err2 != nil {
+			ignored++
+			continue
+		}
+		if !start.IsZero() && !stop.IsZero() && !stop.After(start) {
+			return nil, ignored, errors.New("tempValidities stopTime " +
+				tv.StopTime + " is not after startTime " + tv.StartTime)
+		}
+		ws = append(ws, tempValidityWindow{start: start, stop: stop})
+	}
+	return ws, ignored, nil
+}
+
+// tempValidityState returns whether a subscription with the validity windows
+// is active at now and the time of the next change of state, zero if there is
+// none. A subscription without window is always active
+func tempValidityState(ws []tempValidityWindow, now time.Time) (active bool,
+	next time.Time) {
+
+	if len(ws) == 0 {
+		return true, next
+	}
+	for _, w := range ws {
+		if (w.start.IsZero() || !now.Before(w.start)) &&
+			(w.stop.IsZero() || now.Before(w.stop)) {
+			active = true
+		}
+		for _, t := range []time.Time{w.start, w.stop} {
+			if t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	return active, next
+}
+
+// validateTempValidities checks the validity windows sent by the AF
+func validateTempValidities(tvs []TemporalValidity) (rsp nefSBRspData,
+	status bool) {
+
+	_, ignored, err := parseTempValidities(tvs)
+	if err != nil {
+		rsp.errorCode = 400
+		rsp.pd.Title = err.Error()
+		return rsp, false
+	}
+	if ignored > 0 {
+		log.Infof("%d tempValidities without RFC 3339 date-time are not "+
+			"scheduled", ignored)
+	}
+	return rsp, true
+}
+
+// tiActiveNow returns whether a subscription with the traffic influence data
+// must be active now and the time of the next change of state
+func tiActiveNow(ti TrafficInfluSub) (active bool, next time.Time) {
+
+	// The windows are validated when received from the AF
+	ws, _, err := parseTempValidities(ti.TempValidities)
+	if err != nil {
+		return true, next
+	}
+	return tempValidityState(ws, time.Now())
+}
+
+// afSubSBPost installs the policy of the subscription in the PCF or UDR
+func afSubSBPost(nefCtx *nefContext, sub *afSubscription) (
+	rsp nefSBRspData, err error) {
+
+	if isSingleUeSub(sub.ti) {
+		return nefSBPCFPost(sub, nefCtx, sub.ti)
+	}
+	return nefSBUDRPost(sub, nefCtx, sub.ti)
+}
+
+// afScheduleSub arms the activation timer of the subscription for its next
+// change of state, the caller must hold the AF lock. The timer fires
+// immediately if the policy is not in the expected state
+func (af *afData) afScheduleSub(nefCtx *nefContext, sub *afSubscription) {
+
+	af.afStopSubTimer(sub)
+
+	active, next := tiActiveNow(sub.ti)
+	sub.nextActivationChange = next
+
+	var delay time.Duration
+	if active == !sub.inactive {
+		if next.IsZero() {
+			return
+		}
+		delay = time.Until(next)
+	}
+	sub.activationTimer = time.AfterFunc(delay, func() {
+		af.afActivateSub(nefCtx, sub)
+	})
+}
+
+// afStopSubTimer stops the activation timer of the subscription, the caller
+// must hold the AF lock
+func (af *afData) afStopSubTimer(sub *afSubscription) {
+
+	if sub.activationTimer != nil {
+		sub.activationTimer.Stop()
+		sub.activationTimer = nil
+	}
+}
+
+// afActivateSub installs or removes the policy of the subscription according
+// to its validity windows and schedules the next change
+func (af *afData) afActivateSub(nefCtx *nefContext, sub *afSubscription) {
+
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
+	// The subscription may have been deleted or the NEF stopped meanwhile
+	cur := af.afAcquireSub(sub.subid)
+	if cur == nil {
+		return
+	}
+	defer cur.sbMu.Unlock()
+	if nefCtx.nef.ctx.Err() != nil || cur != sub {
+		return
+	}
+
+	var err error
+	active, _ := tiActiveNow(sub.ti)
+	if active && sub.inactive {
+		sbSub := *sub
+		af.mu.Unlock()
+		_, err = afSubSBPost(nefCtx, &sbSub)
+		af.mu.Lock()
+		if err == nil {
+			sub.afSubSBCommit(&sbSub)
+			af.afSetSubInactive(sub, false)
+			log.Infof("Subscription %s of AF %s activated", sub.subid,
+				af.afID)
+		}
+	} else if !active && !sub.inactive {
+		if err = af.afDeactivateSub(nefCtx, sub); err == nil {
+			log.Infof("Subscription %s of AF %s deactivated", sub.subid,
+				af.afID)
+		}
+	}
+
+	if err != nil {
+		log.Errf("Failed to change the state of subscription %s of AF %s, "+
+			"retrying in %v: %v", sub.subid, af.afID, tiActivationRetry, err)
+		sub.activationTimer = time.AfterFunc(tiActivationRetry, func() {
+			af.afActivateSub(nefCtx, sub)
+		})
+		return
+	}
+	nefCtx.nef.nefStoreSub(af, sub)
+	af.afScheduleSub(nefCtx, sub)
+}
+
+// afDeactivateSub removes the policy of the subscription from the PCF or
+// UDR. The caller must hold the AF lock and the subscription from
+// afAcquireSub, the AF lock is released during the request
+func (af *afData) afDeactivateSub(nefCtx *nefContext,
+	sub *afSubscription) error {
+
+	sbSub := *sub
+	af.mu.Unlock()
+	_, err := sbSub.NEFSBDelete(&sbSub, nefCtx)
+	af.mu.Lock()
+	if err != nil {
+		return err
+	}
+	af.afSetSubInactive(sub, true)
+	if isSingleUeSub(sub.ti) {
+		sub.appSessionID = ""
+		sub.NotifCorreID = ""
+	}
+	return nil
+}
+
+// nefScheduleSubs arms the activation timers of all the subscriptions, e.g.
+// after they are restored from the store
+func (nef *nefData) nefScheduleSubs(nefCtx *nefContext) {
+
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
+	for _, af := range nef.afs {
+		af.mu.Lock()
+		for _, sub := range af.subs {
+			af.afScheduleSub(nefCtx, sub)
+		}
+		af.mu.Unlock()
+	}
+}
+
+// nefStopSubTimers stops the activation timers of all the subscriptions
+func (nef *nefData) nefStopSubTimers() {
+
+	nef.mu.RLock()
+	defer nef.mu.RUnlock()
+
+	for _, af := range nef.afs {
+		af.mu.Lock()
+		for _, sub := range af.subs {
+			af.afStopSubTimer(sub)
+		}
+		af.mu.Unlock()
+	}
+}
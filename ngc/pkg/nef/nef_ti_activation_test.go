@@ -0,0 +1,179 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// tempValidityFromNow returns a validity window relative to the current time
+func tempValidityFromNow(start time.Duration,
+	stop time.Duration) []ngcnef.TemporalValidity {
+
+	now := time.Now().UTC()
+	return []ngcnef.TemporalValidity{{
+		StartTime: now.Add(start).Format(time.RFC3339),
+		StopTime:  now.Add(stop).Format(time.RFC3339)}}
+}
+
+// getAdminSubs returns the subscriptions listed by the admin API
+func getAdminSubs(ctx context.Context) []ngcnef.NefAdminSub {
+
+	req := httptest.NewRequest("GET",
+		"http://127.0.0.1:18071/nef-admin/v1/subscriptions", nil)
+	rr := httptest.NewRecorder()
+	ngcnef.NefAppG.NefAdminRouter.ServeHTTP(rr, req.WithContext(ctx))
+	Expect(rr.Code).Should(Equal(http.StatusOK))
+
+	var subs []ngcnef.NefAdminSub
+	Expect(json.Unmarshal(rr.Body.Bytes(), &subs)).Should(Succeed())
+	return subs
+}
+
+var _ = Describe("Test NEF subscription activation", func() {
+
+	var (
+		pcf     *fakePCF
+		pcfSrv  *httptest.Server
+		ctx     context.Context
+		nef     *testNef
+		tmpDir  string
+		cfgPath string
+	)
+
+	startNef := func() {
+		nef = startTestNef(cfgPath)
+		ctx = nef.ctx
+	}
+
+	// sendTi sends the traffic influence request of the file with the
+	// validity windows
+	sendTi := func(method string, subID string, file string,
+		tvs []ngcnef.TemporalValidity) int {
+
+		data, err := ioutil.ReadFile(testJSONPath + file)
+		Expect(err).Should(BeNil())
+		ti := map[string]interface{}{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti["tempValidities"] = tvs
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, method, subID, data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr.Code
+	}
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-activation")
+		Expect(err).Should(BeNil())
+		cfgPath = writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["StoreConfig"] = map[string]interface{}{"type": "file",
+					"path": filepath.Join(tmpDir, "nef.journal")}
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+			})
+		startNef()
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		pcfSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will install the policy at startTime and remove it at stopTime",
+		func() {
+			Expect(sendTi("POST", "", "AF_NEF_POST_01.json",
+				tempValidityFromNow(2*time.Second, 4*time.Second))).Should(
+				Equal(http.StatusCreated))
+			Expect(pcf.count()).Should(Equal(0))
+
+			subs := getAdminSubs(ctx)
+			Expect(subs).Should(HaveLen(1))
+			Expect(subs[0].SubID).Should(Equal("11111"))
+			Expect(subs[0].Active).Should(BeFalse())
+			Expect(subs[0].NextActivationChange).ShouldNot(BeNil())
+
+			Eventually(pcf.count, 3*time.Second).Should(Equal(1))
+			Expect(getAdminSubs(ctx)[0].Active).Should(BeTrue())
+			Eventually(pcf.count, 3*time.Second).Should(Equal(0))
+			subs = getAdminSubs(ctx)
+			Expect(subs[0].Active).Should(BeFalse())
+			Expect(subs[0].NextActivationChange).Should(BeNil())
+
+			// An inactive subscription is deleted without PCF request
+			rr, req := CreateReqForNEF(ctx, "DELETE", "11111", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusNoContent))
+			Expect(getAdminSubs(ctx)).Should(BeEmpty())
+		})
+
+	It("Will reject a window stopping before it starts", func() {
+		Expect(sendTi("POST", "", "AF_NEF_POST_01.json",
+			tempValidityFromNow(time.Hour, time.Minute))).Should(
+			Equal(http.StatusBadRequest))
+		Expect(pcf.count()).Should(Equal(0))
+	})
+
+	It("Will remove the policy of a window ended during a restart", func() {
+		Expect(sendTi("POST", "", "AF_NEF_POST_01.json",
+			tempValidityFromNow(-time.Hour, 2*time.Second))).Should(
+			Equal(http.StatusCreated))
+		Expect(pcf.count()).Should(Equal(1))
+
+		nef.stop()
+		Expect(pcf.count()).Should(Equal(1))
+		// The window ends while the NEF is stopped
+		time.Sleep(2 * time.Second)
+		startNef()
+
+		Eventually(pcf.count, 2*time.Second).Should(Equal(0))
+		subs := getAdminSubs(ctx)
+		Expect(subs).Should(HaveLen(1))
+		Expect(subs[0].Active).Should(BeFalse())
+	})
+
+	It("Will follow the windows changed by PATCH", func() {
+		// The windows without RFC 3339 date-time are not scheduled
+		Expect(sendTi("POST", "", "AF_NEF_POST_01.json",
+			[]ngcnef.TemporalValidity{{StartTime: "10:30:00",
+				StopTime: "11:30:00"}})).Should(Equal(http.StatusCreated))
+		Expect(pcf.count()).Should(Equal(1))
+		Expect(getAdminSubs(ctx)[0].Active).Should(BeTrue())
+
+		Expect(sendTi("PATCH", "11111", "AF_NEF_PATCH_01.json",
+			tempValidityFromNow(time.Hour, 2*time.Hour))).Should(
+			Equal(http.StatusOK))
+		Eventually(pcf.count, 2*time.Second).Should(Equal(0))
+		subs := getAdminSubs(ctx)
+		Expect(subs[0].Active).Should(BeFalse())
+		Expect(subs[0].NextActivationChange).ShouldNot(BeNil())
+		Expect(time.Until(*subs[0].NextActivationChange)).Should(
+			BeNumerically(">", 59*time.Minute))
+
+		Expect(sendTi("PATCH", "11111", "AF_NEF_PATCH_01.json",
+			tempValidityFromNow(-time.Minute, time.Hour))).Should(
+			Equal(http.StatusOK))
+		Eventually(pcf.count, 2*time.Second).Should(Equal(1))
+		Expect(getAdminSubs(ctx)[0].Active).Should(BeTrue())
+	})
+})
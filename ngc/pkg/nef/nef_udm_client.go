@@ -0,0 +1,81 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Client implementation of the UDM Stub translating the GPSI and the external
+ * group IDs with the local tables of the configuration */
+
+package ngcnef
+
+import (
+	"context"
+)
+
+// UDMConfig selects the implementation of the identifier translation
+type UDMConfig struct {
+	SBClientConfig
+	// GPSI to SUPI table used by the "stub" client
+	GpsiToSupi map[Gpsi]Supi `json:"gpsiToSupi"`
+	// Groups returned by the "stub" client
+	Groups []GroupIdentifiers `json:"groups"`
+}
+
+// UdmClientStub is an implementation of the UDM identifier translation
+type UdmClientStub struct {
+	supis  map[Gpsi]Supi
+	groups map[ExternalGroupID]GroupIdentifiers
+}
+
+// NewUDMClient creates a new UDM Client translating the GPSI and the groups
+// with the tables of the configuration
+func NewUDMClient(cfg *Config) *UdmClientStub {
+
+	c := &UdmClientStub{supis: make(map[Gpsi]Supi),
+		groups: make(map[ExternalGroupID]GroupIdentifiers)}
+	for gpsi, supi := range cfg.UDMConfig.GpsiToSupi {
+		c.supis[gpsi] = supi
+	}
+	for _, g := range cfg.UDMConfig.Groups {
+		c.groups[g.ExtGroupID] = g
+	}
+	log.Infof("UDM Stub Client created with %d GPSI and %d groups",
+		len(c.supis), len(c.groups))
+	return c
+}
+
+// newUDMClient creates the UDM client selected in the configuration
+func newUDMClient(cfg *Config, nrf *nrfClient) (UdmIDTranslation, error) {
+
+	isHTTP, err := cfg.UDMConfig.isHTTP()
+	if err != nil {
+		return nil, err
+	}
+	if isHTTP {
+		return newUDMHTTPClient(cfg, nrf)
+	}
+	return NewUDMClient(cfg), nil
+}
+
+// UdmGetSupi is a stub implementation
+func (udm *UdmClientStub) UdmGetSupi(ctx context.Context,
+	gpsi Gpsi) (Supi, error) {
+
+	_ = ctx
+	supi, ok := udm.supis[gpsi]
+	if !ok {
+		return "", errUdmUeNotFound
+	}
+	return supi, nil
+}
+
+// UdmGetGroupIdentifiers is a stub implementation
+func (udm *UdmClientStub) UdmGetGroupIdentifiers(ctx context.Context,
+	extGroupID ExternalGroupID) (GroupIdentifiers, error) {
+
+	_ = ctx
+	g, ok := udm.groups[extGroupID]
+	if !ok {
+		return g, errUdmGroupNotFound
+	}
+	return g, nil
+}
@@ -0,0 +1,107 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Client implementation of the Nudm_SDM identifier translation and group
+ * identifiers retrieval (29.503) */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// UDM SDM resource URI
+const udmSdmURI = "/nudm-sdm/v2/"
+
+// UDM SDM service name used for the NRF discovery
+const udmSdmService = "nudm-sdm"
+
+// UdmClient is an HTTP implementation of the UDM identifier translation
+type UdmClient struct {
+	sb *sbHTTPClient
+}
+
+// NewUDMHTTPClient creates a new UDM Client sending the requests to the UDM
+// API root in the configuration
+func NewUDMHTTPClient(cfg *Config) (*UdmClient, error) {
+
+	return newUDMHTTPClient(cfg, nil)
+}
+
+// newUDMHTTPClient creates a new UDM Client, the UDM is discovered through
+// the NRF if the API root is not configured
+func newUDMHTTPClient(cfg *Config, nrf *nrfClient) (*UdmClient, error) {
+
+	sb, err := newSBHTTPClient(cfg, cfg.UDMConfig.APIRoot,
+		nrf.discoverer(NfTypeUDM, udmSdmService))
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("UDM Client created for %s",
+		sbAPIRootName(cfg.UDMConfig.APIRoot))
+	return &UdmClient{sb: sb}, nil
+}
+
+// UdmGetSupi sends GET request to read the identifier translation result of
+// the GPSI
+// Successful response : 200 and body contains IdTranslationResult
+func (udm *UdmClient) UdmGetSupi(ctx context.Context,
+	gpsi Gpsi) (Supi, error) {
+
+	if gpsi == "" {
+		return "", errors.New("GPSI is empty")
+	}
+
+	res := IDTranslationResult{}
+	rsp, err := udm.sb.do(ctx, http.MethodGet, udmSdmURI+
+		url.PathEscape(string(gpsi))+"/id-translation-result", "", nil, &res)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case rsp.code == http.StatusNotFound:
+		return "", errUdmUeNotFound
+	case rsp.code != http.StatusOK:
+		return "", fmt.Errorf("UDM identifier translation failed: %d",
+			rsp.code)
+	case res.Supi == "":
+		return "", errors.New("UDM identifier translation without SUPI")
+	}
+	return res.Supi, nil
+}
+
+// UdmGetGroupIdentifiers sends GET request to read the group identifiers of
+// the external group
+// Successful response : 200 and body contains GroupIdentifiers
+func (udm *UdmClient) UdmGetGroupIdentifiers(ctx context.Context,
+	extGroupID ExternalGroupID) (GroupIdentifiers, error) {
+
+	res := GroupIdentifiers{}
+	if extGroupID == "" {
+		return res, errors.New("External group ID is empty")
+	}
+
+	rsp, err := udm.sb.do(ctx, http.MethodGet, udmSdmURI+
+		"group-data/group-identifiers?ext-group-id="+
+		url.QueryEscape(string(extGroupID)), "", nil, &res)
+	if err != nil {
+		return res, err
+	}
+
+	switch {
+	case rsp.code == http.StatusNotFound:
+		return res, errUdmGroupNotFound
+	case rsp.code != http.StatusOK:
+		return res, fmt.Errorf("UDM group identifiers retrieval failed: %d",
+			rsp.code)
+	case res.IntGroupID == "":
+		return res, errors.New("UDM group identifiers without intGroupId")
+	}
+	return res, nil
+}
@@ -0,0 +1,171 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// fakeUDM is a minimal Nudm_SDM server translating the GPSI and the
+// external groups
+type fakeUDM struct {
+	supis  map[string]ngcnef.Supi
+	groups map[string]ngcnef.GroupIdentifiers
+}
+
+func (u *fakeUDM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	const base = "/nudm-sdm/v2/"
+	const suffix = "/id-translation-result"
+
+	if r.URL.Path == base+"group-data/group-identifiers" {
+		g, ok := u.groups[r.URL.Query().Get("ext-group-id")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(g)
+		return
+	}
+
+	gpsi := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, base), suffix)
+	supi, ok := u.supis[gpsi]
+	if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, suffix) ||
+		!ok {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(ngcnef.ProblemDetails{
+			Cause: "USER_NOT_FOUND", Status: http.StatusNotFound})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ngcnef.IDTranslationResult{Supi: supi,
+		Gpsi: ngcnef.Gpsi(gpsi)})
+}
+
+// pcfSupi returns the SUPI of the only app session of the PCF
+func pcfSupi(p *fakePCF) ngcnef.Supi {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	Expect(p.sessions).Should(HaveLen(1))
+	for _, asc := range p.sessions {
+		return asc.AscReqData.Supi
+	}
+	return ""
+}
+
+var _ = Describe("Test NEF UDM identifier translation", func() {
+
+	var (
+		pcf    *fakePCF
+		pcfSrv *httptest.Server
+		udmSrv *httptest.Server
+		ctx    context.Context
+		nef    *testNef
+		tmpDir string
+	)
+
+	// startNef starts the NEF with the UDM configuration, the stub one if
+	// nil
+	startNef := func(udmCfg map[string]interface{}) {
+
+		cfgPath := writeNefTestConfig(tmpDir,
+			func(cfg map[string]interface{}) {
+				cfg["PCFConfig"] = map[string]interface{}{
+					"type": "http", "apiRoot": pcfSrv.URL}
+				if udmCfg != nil {
+					cfg["UDMConfig"] = udmCfg
+				}
+			})
+
+		nef = newTestNef(cfgPath)
+		ctx = nef.ctx
+	}
+
+	// createSub creates a subscription for the UE of the GPSI
+	createSub := func(gpsi string) *httptest.ResponseRecorder {
+
+		data, err := ioutil.ReadFile(testJSONPath + "AF_NEF_POST_01.json")
+		Expect(err).Should(BeNil())
+		ti := ngcnef.TrafficInfluSub{}
+		Expect(json.Unmarshal(data, &ti)).Should(Succeed())
+		ti.Gpsi = ngcnef.Gpsi(gpsi)
+		data, err = json.Marshal(ti)
+		Expect(err).Should(BeNil())
+
+		rr, req := CreateReqForNEF(ctx, "POST", "", data)
+		ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	// expectUeNotFound checks the response of a subscription for an unknown
+	// UE
+	expectUeNotFound := func(rr *httptest.ResponseRecorder) {
+
+		Expect(rr.Code).Should(Equal(http.StatusNotFound))
+		pd := ngcnef.ProblemDetails{}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &pd)).Should(Succeed())
+		Expect(pd.Title).Should(Equal("UE not found"))
+		Expect(pd.Status).Should(Equal(int32(http.StatusNotFound)))
+		Expect(pcf.count()).Should(Equal(0))
+	}
+
+	BeforeEach(func() {
+		pcf = newFakePCF()
+		pcfSrv = httptest.NewServer(pcf)
+		udmSrv = httptest.NewServer(&fakeUDM{supis: map[string]ngcnef.Supi{
+			"extid-ue1@example.com": "imsi-001010000000001"}})
+
+		var err error
+		tmpDir, err = ioutil.TempDir("", "nef-udm")
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		nef.stop()
+		pcfSrv.Close()
+		udmSrv.Close()
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will translate the GPSI with the local table", func() {
+		startNef(nil)
+
+		Expect(createSub("msisdn-1234567890").Code).Should(Equal(
+			http.StatusCreated))
+		Expect(pcfSupi(pcf)).Should(Equal(
+			ngcnef.Supi("imsi-123456789012345")))
+	})
+
+	It("Will reject the GPSI missing in the local table", func() {
+		startNef(nil)
+
+		expectUeNotFound(createSub("msisdn-1"))
+	})
+
+	It("Will translate the GPSI through the UDM", func() {
+		startNef(map[string]interface{}{"type": "http",
+			"apiRoot": udmSrv.URL})
+
+		expectUeNotFound(createSub("msisdn-1234567890"))
+		Expect(createSub("extid-ue1@example.com").Code).Should(Equal(
+			http.StatusCreated))
+		Expect(pcfSupi(pcf)).Should(Equal(
+			ngcnef.Supi("imsi-001010000000001")))
+	})
+})
@@ -0,0 +1,34 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+)
+
+/* The SB interface towards the UDM for translating the identifiers of the
+   UE sent by the AF */
+
+// errUdmUeNotFound is returned if the UE of the GPSI is unknown
+var errUdmUeNotFound = errors.New("UE not found")
+
+// errUdmGroupNotFound is returned if the external group is unknown
+var errUdmGroupNotFound = errors.New("Group not found")
+
+// UdmIDTranslation defines the interface translating the GPSI of the UE
+// into its SUPI and the external group ID into the group identifiers
+type UdmIDTranslation interface {
+
+	// UdmGetSupi returns the SUPI of the UE of the GPSI (msisdn- or extid-).
+	// errUdmUeNotFound is returned if the UE is unknown
+	UdmGetSupi(ctx context.Context, gpsi Gpsi) (Supi, error)
+
+	// UdmGetGroupIdentifiers returns the internal group ID and the members of
+	// the external group. errUdmGroupNotFound is returned if the group is
+	// unknown
+	UdmGetGroupIdentifiers(ctx context.Context,
+		extGroupID ExternalGroupID) (GroupIdentifiers, error)
+}
@@ -8,6 +8,7 @@ package ngcnef
 
 import (
 	"context"
+	"sync"
 )
 
 // UdrClientStub is an implementation of the Udr Influence data
@@ -15,6 +16,8 @@ type UdrClientStub struct {
 	udr string
 	// database to store the contents of the udr influence data
 	tidDb map[string]TrafficInfluData
+	// mu protects the database from concurrent requests
+	mu sync.Mutex
 }
 
 // NewUDRClient creates a new Udr Client
@@ -34,6 +37,9 @@ func (udr *UdrClientStub) UdrInfluenceDataCreate(ctx context.Context,
 	log.Infof("UDRs InfluenceDataCreate Entered for %s", string(iid))
 	_ = ctx
 
+	udr.mu.Lock()
+	defer udr.mu.Unlock()
+
 	var err error
 	udrPr := UdrInfluenceResponse{}
 	// generated a session id return the same body as provided in the request
@@ -104,6 +110,9 @@ func (udr *UdrClientStub) UdrInfluenceDataUpdate(ctx context.Context,
 	log.Infof("UDRs InfluenceDataUpdate Entered for %s", string(iid))
 	_ = ctx
 
+	udr.mu.Lock()
+	defer udr.mu.Unlock()
+
 	var err error
 	udrPr := UdrInfluenceResponse{}
 	// check for the presence of the sessid in the database
@@ -133,6 +142,9 @@ func (udr *UdrClientStub) UdrInfluenceDataDelete(ctx context.Context,
 	log.Infof("UDRs InfluenceDataDelete for %s", string(iid))
 	_ = ctx
 
+	udr.mu.Lock()
+	defer udr.mu.Unlock()
+
 	var err error
 	udrPr := UdrInfluenceResponse{}
 	// check for the presence of the sessid in the database
@@ -155,12 +167,53 @@ func (udr *UdrClientStub) UdrInfluenceDataDelete(ctx context.Context,
 }
 
 // UdrInfluenceDataGet is a stub implementation
-func (udr *UdrClientStub) UdrInfluenceDataGet(ctx context.Context) (
-	UdrInfluenceResponse, error) {
+func (udr *UdrClientStub) UdrInfluenceDataGet(ctx context.Context,
+	filter UdrInfluenceDataFilter) (UdrInfluenceResponse, error) {
 	log.Infof("UdrInfluenceDataGet Stub Entered")
 	_ = ctx
+
+	udr.mu.Lock()
+	defer udr.mu.Unlock()
 	udrPr := UdrInfluenceResponse{}
 	var err error
+	for iid, tid := range udr.tidDb {
+		if filter.match(InfluenceID(iid), &tid) {
+			udrPr.Tids = append(udrPr.Tids, tid)
+		}
+	}
+	udrPr.ResponseCode = 200
 	log.Infof("UdrInfluenceDataGet Stub Exited")
 	return udrPr, err
 }
+
+// match returns true if the influence data is selected by the filter
+func (f *UdrInfluenceDataFilter) match(iid InfluenceID,
+	tid *TrafficInfluData) bool {
+
+	return filterMatch(len(f.InfluenceIDs), func(i int) bool {
+		return f.InfluenceIDs[i] == iid
+	}) && filterMatch(len(f.Dnns), func(i int) bool {
+		return f.Dnns[i] == tid.Dnn
+	}) && filterMatch(len(f.Snssais), func(i int) bool {
+		return f.Snssais[i] == tid.Snssai
+	}) && filterMatch(len(f.InternalGroupIDs), func(i int) bool {
+		return f.InternalGroupIDs[i] == tid.InterGroupID
+	}) && filterMatch(len(f.Supis), func(i int) bool {
+		return f.Supis[i] == tid.Supi
+	})
+}
+
+// filterMatch returns true if the filter with n values is empty or any of
+// its values is equal
+func filterMatch(n int, equal func(i int) bool) bool {
+
+	if n == 0 {
+		return true
+	}
+	for i := 0; i < n; i++ {
+		if equal(i) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Client implementation of the Nudr_DataRepository influence data (29.519) */
+
+package ngcnef
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// UDR influence data resource URI
+const udrInfluenceDataURI = "/nudr-dr/v1/application-data/influenceData"
+
+// UDR data repository service name used for the NRF discovery
+const udrDataRepositoryService = "nudr-dr"
+
+// UdrClient is an HTTP implementation of the Udr Influence data
+type UdrClient struct {
+	sb *sbHTTPClient
+}
+
+// NewUDRHTTPClient creates a new UDR Client sending the requests to the UDR
+// API root in the configuration
+func NewUDRHTTPClient(cfg *Config) (*UdrClient, error) {
+
+	return newUDRHTTPClient(cfg, nil)
+}
+
+// newUDRHTTPClient creates a new UDR Client, the UDR is discovered through
+// the NRF if the API root is not configured
+func newUDRHTTPClient(cfg *Config, nrf *nrfClient) (*UdrClient, error) {
+
+	sb, err := newSBHTTPClient(cfg, cfg.UDRConfig.APIRoot,
+		nrf.discoverer(NfTypeUDR, udrDataRepositoryService))
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("UDR Client created for %s",
+		sbAPIRootName(cfg.UDRConfig.APIRoot))
+	return &UdrClient{sb: sb}, nil
+}
+
+// newUDRClient creates the UDR influence data client selected in the
+// configuration
+func newUDRClient(cfg *Config, nrf *nrfClient) (UdrInfluenceData, error) {
+
+	isHTTP, err := cfg.UDRConfig.isHTTP()
+	if err != nil {
+		return nil, err
+	}
+	if isHTTP {
+		return newUDRHTTPClient(cfg, nrf)
+	}
+	return NewUDRClient(cfg), nil
+}
+
+// udrInfluenceResponse fills the UDR response from the HTTP response
+func udrInfluenceResponse(rsp sbHTTPRsp, tid *TrafficInfluData) (
+	udrPr UdrInfluenceResponse) {
+
+	udrPr.ResponseCode = rsp.code
+	udrPr.Pd = rsp.pd
+	if rsp.code < 300 && rsp.code != http.StatusNoContent {
+		udrPr.Tid = tid
+	}
+	return udrPr
+}
+
+// udrInfluenceDataIDURI returns the URI of the individual influence data
+func udrInfluenceDataIDURI(iid InfluenceID) (string, error) {
+
+	if iid == "" {
+		return "", errors.New("InfluenceID is empty")
+	}
+	return udrInfluenceDataURI + "/" + url.PathEscape(string(iid)), nil
+}
+
+// UdrInfluenceDataCreate sends PUT request to create or replace the
+// influence data
+// Successful response : 201 or 200 and body contains TrafficInfluData, 204
+func (udr *UdrClient) UdrInfluenceDataCreate(ctx context.Context,
+	body TrafficInfluData, iid InfluenceID) (UdrInfluenceResponse, error) {
+
+	uri, err := udrInfluenceDataIDURI(iid)
+	if err != nil {
+		return UdrInfluenceResponse{}, err
+	}
+
+	tid := TrafficInfluData{}
+	rsp, err := udr.sb.do(ctx, http.MethodPut, uri, "application/json",
+		body, &tid)
+	if err != nil {
+		return udrInfluenceResponse(rsp, nil), err
+	}
+	return udrInfluenceResponse(rsp, &tid), nil
+}
+
+// UdrInfluenceDataUpdate sends PATCH request to modify the influence data
+// Successful response : 200 and body contains TrafficInfluData or 204
+func (udr *UdrClient) UdrInfluenceDataUpdate(ctx context.Context,
+	body TrafficInfluDataPatch, iid InfluenceID) (UdrInfluenceResponse,
+	error) {
+
+	uri, err := udrInfluenceDataIDURI(iid)
+	if err != nil {
+		return UdrInfluenceResponse{}, err
+	}
+
+	tid := TrafficInfluData{}
+	rsp, err := udr.sb.do(ctx, http.MethodPatch, uri,
+		"application/merge-patch+json", body, &tid)
+	if err != nil {
+		return udrInfluenceResponse(rsp, nil), err
+	}
+	return udrInfluenceResponse(rsp, &tid), nil
+}
+
+// UdrInfluenceDataDelete sends DELETE request to remove the influence data
+// Successful response : 204
+func (udr *UdrClient) UdrInfluenceDataDelete(ctx context.Context,
+	iid InfluenceID) (UdrInfluenceResponse, error) {
+
+	uri, err := udrInfluenceDataIDURI(iid)
+	if err != nil {
+		return UdrInfluenceResponse{}, err
+	}
+
+	rsp, err := udr.sb.do(ctx, http.MethodDelete, uri, "", nil, nil)
+	return udrInfluenceResponse(rsp, nil), err
+}
+
+// UdrInfluenceDataGet sends GET request to read the influence data matching
+// the filter
+// Successful response : 200 and body contains the TrafficInfluData list
+func (udr *UdrClient) UdrInfluenceDataGet(ctx context.Context,
+	filter UdrInfluenceDataFilter) (UdrInfluenceResponse, error) {
+
+	query, err := filter.query()
+	if err != nil {
+		return UdrInfluenceResponse{}, err
+	}
+	uri := udrInfluenceDataURI
+	if query != "" {
+		uri += "?" + query
+	}
+
+	var tids []TrafficInfluData
+	rsp, err := udr.sb.do(ctx, http.MethodGet, uri, "", nil, &tids)
+	udrPr := udrInfluenceResponse(rsp, nil)
+	if err == nil && rsp.code == http.StatusOK {
+		udrPr.Tids = tids
+	}
+	return udrPr, err
+}
+
+// query encodes the filter as the query parameters of the GET request
+func (f *UdrInfluenceDataFilter) query() (string, error) {
+
+	q := url.Values{}
+	if len(f.InfluenceIDs) > 0 {
+		ids := make([]string, len(f.InfluenceIDs))
+		for i, iid := range f.InfluenceIDs {
+			ids[i] = string(iid)
+		}
+		q.Set("influence-Ids", strings.Join(ids, ","))
+	}
+	if len(f.Dnns) > 0 {
+		dnns := make([]string, len(f.Dnns))
+		for i, dnn := range f.Dnns {
+			dnns[i] = string(dnn)
+		}
+		q.Set("dnns", strings.Join(dnns, ","))
+	}
+	if len(f.Snssais) > 0 {
+		// snssais is a JSON encoded array of Snssai
+		b, err := json.Marshal(f.Snssais)
+		if err != nil {
+			return "", err
+		}
+		q.Set("snssais", string(b))
+	}
+	if len(f.InternalGroupIDs) > 0 {
+		q.Set("internal-Group-Ids", strings.Join(f.InternalGroupIDs, ","))
+	}
+	if len(f.Supis) > 0 {
+		supis := make([]string, len(f.Supis))
+		for i, supi := range f.Supis {
+			supis[i] = string(supi)
+		}
+		q.Set("supis", strings.Join(supis, ","))
+	}
+	return q.Encode(), nil
+}
@@ -0,0 +1,238 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// fakeUDR is a minimal Nudr_DataRepository influence data server
+type fakeUDR struct {
+	mu  sync.Mutex
+	tid map[string]ngcnef.TrafficInfluData
+	// lastQuery is the query of the last GET request
+	lastQuery url.Values
+}
+
+func newFakeUDR() *fakeUDR {
+	return &fakeUDR{tid: make(map[string]ngcnef.TrafficInfluData)}
+}
+
+func (u *fakeUDR) ids() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var ids []string
+	for id := range u.tid {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (u *fakeUDR) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	const base = "/nudr-dr/v1/application-data/influenceData"
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if r.URL.Path == base && r.Method == http.MethodGet {
+		u.lastQuery = r.URL.Query()
+		tids := []ngcnef.TrafficInfluData{}
+		for _, id := range strings.Split(u.lastQuery.Get("influence-Ids"),
+			",") {
+			if tid, ok := u.tid[id]; ok {
+				tids = append(tids, tid)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tids)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, base+"/")
+	tid, ok := u.tid[id]
+	switch r.Method {
+	case http.MethodPut:
+		if json.NewDecoder(r.Body).Decode(&tid) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		u.tid[id] = tid
+		code := http.StatusOK
+		if !ok {
+			code = http.StatusCreated
+			w.Header().Set("Location", "http://"+r.Host+r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(tid)
+	case http.MethodPatch, http.MethodDelete:
+		if !ok {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(ngcnef.ProblemDetails{
+				Title: "Not Found", Cause: "DATA_NOT_FOUND"})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(u.tid, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		patch := ngcnef.TrafficInfluDataPatch{}
+		if json.NewDecoder(r.Body).Decode(&patch) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tid.AppReloInd = patch.AppReloInd
+		u.tid[id] = tid
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+var _ = Describe("Test NEF UDR Influence Data HTTP Client", func() {
+
+	var (
+		udr *fakeUDR
+		srv *httptest.Server
+	)
+
+	BeforeEach(func() {
+		udr = newFakeUDR()
+		srv = httptest.NewServer(udr)
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	Describe("Influence Data requests", func() {
+
+		var client *ngcnef.UdrClient
+
+		BeforeEach(func() {
+			var err error
+			client, err = ngcnef.NewUDRHTTPClient(&ngcnef.Config{
+				UDRConfig: ngcnef.SBClientConfig{Type: "http",
+					APIRoot: srv.URL + "/"}})
+			Expect(err).Should(BeNil())
+		})
+
+		It("Will put, patch, get and delete the influence data", func() {
+			ctx := context.Background()
+			tid := ngcnef.TrafficInfluData{AfAppID: "app1", Dnn: "edge"}
+
+			rsp, err := client.UdrInfluenceDataCreate(ctx, tid, "iid1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(201)))
+			Expect(rsp.Tid.AfAppID).Should(Equal("app1"))
+
+			rsp, err = client.UdrInfluenceDataCreate(ctx, tid, "iid1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(200)))
+
+			rsp, err = client.UdrInfluenceDataUpdate(ctx,
+				ngcnef.TrafficInfluDataPatch{AppReloInd: true}, "iid1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(204)))
+			Expect(rsp.Tid).Should(BeNil())
+
+			rsp, err = client.UdrInfluenceDataGet(ctx,
+				ngcnef.UdrInfluenceDataFilter{
+					InfluenceIDs: []ngcnef.InfluenceID{"iid1", "iid2"},
+					Dnns:         []ngcnef.Dnn{"edge"},
+					Snssais:      []ngcnef.Snssai{{Sst: 1, Sd: "010203"}},
+					Supis:        []ngcnef.Supi{"imsi-1"}})
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(200)))
+			Expect(rsp.Tids).Should(HaveLen(1))
+			Expect(rsp.Tids[0].AppReloInd).Should(BeTrue())
+			Expect(udr.lastQuery.Get("influence-Ids")).Should(
+				Equal("iid1,iid2"))
+			Expect(udr.lastQuery.Get("dnns")).Should(Equal("edge"))
+			Expect(udr.lastQuery.Get("snssais")).Should(
+				Equal(`[{"sst":1,"sd":"010203"}]`))
+			Expect(udr.lastQuery.Get("supis")).Should(Equal("imsi-1"))
+			Expect(udr.lastQuery).ShouldNot(HaveKey("internal-Group-Ids"))
+
+			rsp, err = client.UdrInfluenceDataDelete(ctx, "iid1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(204)))
+
+			rsp, err = client.UdrInfluenceDataDelete(ctx, "iid1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(404)))
+			Expect(rsp.Pd.Cause).Should(Equal("DATA_NOT_FOUND"))
+		})
+
+		It("Will reject an empty influence id", func() {
+			_, err := client.UdrInfluenceDataDelete(context.Background(), "")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Describe("NEF with UDR HTTP Client", func() {
+
+		var (
+			ctx    context.Context
+			nef    *testNef
+			tmpDir string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "nef-udr")
+			Expect(err).Should(BeNil())
+			cfgPath := writeNefTestConfig(tmpDir,
+				func(cfg map[string]interface{}) {
+					cfg["UDRConfig"] = map[string]interface{}{
+						"type": "http", "apiRoot": srv.URL}
+				})
+
+			nef = newTestNef(cfgPath)
+			ctx = nef.ctx
+		})
+
+		AfterEach(func() {
+			nef.stop()
+			_ = os.RemoveAll(tmpDir)
+		})
+
+		It("Will store the AnyUE influence data in UDR", func() {
+			postbody, _ := ioutil.ReadFile(testJSONPath +
+				"AF_NEF_POST_UDR_01.json")
+			patchbody, _ := ioutil.ReadFile(testJSONPath +
+				"AF_NEF_PATCH_UDR_01.json")
+
+			rr, req := CreateReqForNEF(ctx, "POST", "", postbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+			Expect(udr.ids()).Should(ConsistOf("AF_01-11111"))
+
+			rr, req = CreateReqForNEF(ctx, "PATCH", "11111", patchbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+
+			rr, req = CreateReqForNEF(ctx, "DELETE", "11111", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusNoContent))
+			Expect(udr.ids()).Should(BeEmpty())
+		})
+	})
+})
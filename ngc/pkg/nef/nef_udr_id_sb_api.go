@@ -15,6 +15,8 @@ type UdrInfluenceResponse struct {
 	ResponseCode uint16
 	// tid if not nil contains the TrafficInfluData data provided by UDR
 	Tid *TrafficInfluData
+	// tids contains the TrafficInfluData list matching the GET filter
+	Tids []TrafficInfluData
 	// pd if not not contains the problem infomration from UDR.
 	// Valid for 3xx, 4xx, 5xx or 6xx responses
 	Pd *ProblemDetails
@@ -25,6 +27,16 @@ type UdrInfluenceResponse struct {
 // "{apiRoot}/nudr-dr/v1/aapplication-data/influenceData/{influenceId}"
 type InfluenceID string
 
+// UdrInfluenceDataFilter contains the query parameters to select the
+// influence data read from the UDR. Empty filters are not sent
+type UdrInfluenceDataFilter struct {
+	InfluenceIDs     []InfluenceID
+	Dnns             []Dnn
+	Snssais          []Snssai
+	InternalGroupIDs []string
+	Supis            []Supi
+}
+
 // UdrInfluenceData defines the interfaces that are exposed for
 // TrafficInfluence
 type UdrInfluenceData interface {
@@ -59,12 +71,12 @@ type UdrInfluenceData interface {
 	UdrInfluenceDataDelete(ctx context.Context, iid InfluenceID) (
 		UdrInfluenceResponse, error)
 
-	// UdrInfluenceDataGet sends GET request to the UDR
+	// UdrInfluenceDataGet sends GET request to the UDR with the query filter
 	// using the configuration mentioned in the context. Context would have all
 	// the infomration related to the UDR like the URI, authentication, logging,
 	// cancellation. It returns the response received from the UDR and any error
 	// encountered when sending therequest. The contents of the actual response
 	// received are part of the UdrPolicyResponse
-	UdrInfluenceDataGet(ctx context.Context) (
+	UdrInfluenceDataGet(ctx context.Context, filter UdrInfluenceDataFilter) (
 		UdrInfluenceResponse, error)
 }
@@ -9,6 +9,7 @@ package ngcnef
 import (
 	"context"
 	"errors"
+	"sync"
 )
 
 // TestClient variable is only for UnitTesting purpose to inject errors in stub
@@ -19,6 +20,8 @@ type UdrPfdClientStub struct {
 	udr string
 	//database to store content of udr PFD data
 	appPfd map[string]*PfdDataForApp
+	// mu protects the database from concurrent requests
+	mu sync.Mutex
 }
 
 // NewUDRPfdClient creates a new Udr Client
@@ -38,6 +41,9 @@ func (udr *UdrPfdClientStub) UdrPfdDataCreate(ctx context.Context,
 	log.Infof("UdrPfdDataCreate Stub Entered")
 	_ = ctx
 
+	udr.mu.Lock()
+	defer udr.mu.Unlock()
+
 	log.Info("UdrPfdDataCreate: Invoke UDR SB PUT -> ")
 	udr.appPfd[string(body.AppID)] = &body
 	log.Infof("UdrPfdDataCreate Stub Exited")
@@ -55,6 +61,9 @@ func (udr *UdrPfdClientStub) UdrPfdDataGet(ctx context.Context,
 	log.Infof("UdrPfdDataGet Stub Entered")
 	_ = ctx
 
+	udr.mu.Lock()
+	defer udr.mu.Unlock()
+
 	rsp.AppPfd = udr.appPfd[string(appID)]
 	log.Info("Get PFD for AppId : ", appID)
 	log.Info("UdrPfdDataGet: Invoke UDR SB GET -> ")
@@ -73,6 +82,9 @@ func (udr *UdrPfdClientStub) UdrPfdDataDelete(ctx context.Context,
 	appID UdrAppID) (rsp UdrPfdResponse, err error) {
 	log.Infof("UdrPfdDataDelete Stub Entered")
 	_ = ctx
+
+	udr.mu.Lock()
+	defer udr.mu.Unlock()
 	log.Info("Deleted PFD AppId : ", appID)
 	log.Info("UdrPfdDataDelete: Invoke UDR SB DELETE -> ")
 
@@ -0,0 +1,127 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+/* Client implementation of the Nudr_DataRepository PFD data (29.519) */
+
+package ngcnef
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// UDR PFD data resource URI
+const udrPfdDataURI = "/nudr-dr/v1/application-data/pfds"
+
+// UdrPfdClient is an HTTP implementation of the Udr PFD data
+type UdrPfdClient struct {
+	sb *sbHTTPClient
+}
+
+// NewUDRPfdHTTPClient creates a new UDR PFD Client sending the requests to
+// the UDR API root in the configuration
+func NewUDRPfdHTTPClient(cfg *Config) (*UdrPfdClient, error) {
+
+	return newUDRPfdHTTPClient(cfg, nil)
+}
+
+// newUDRPfdHTTPClient creates a new UDR PFD Client, the UDR is discovered
+// through the NRF if the API root is not configured
+func newUDRPfdHTTPClient(cfg *Config, nrf *nrfClient) (*UdrPfdClient,
+	error) {
+
+	sb, err := newSBHTTPClient(cfg, cfg.UDRConfig.APIRoot,
+		nrf.discoverer(NfTypeUDR, udrDataRepositoryService))
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("UDR PFD Client created for %s",
+		sbAPIRootName(cfg.UDRConfig.APIRoot))
+	return &UdrPfdClient{sb: sb}, nil
+}
+
+// newUDRPfdClient creates the UDR PFD data client selected in the
+// configuration
+func newUDRPfdClient(cfg *Config, nrf *nrfClient) (UdrPfdData, error) {
+
+	isHTTP, err := cfg.UDRConfig.isHTTP()
+	if err != nil {
+		return nil, err
+	}
+	if isHTTP {
+		return newUDRPfdHTTPClient(cfg, nrf)
+	}
+	return NewUDRPfdClient(cfg), nil
+}
+
+// udrPfdDataIDURI returns the URI of the PFD data of the application
+func udrPfdDataIDURI(appID UdrAppID) (string, error) {
+
+	if appID == "" {
+		return "", errors.New("AppID is empty")
+	}
+	return udrPfdDataURI + "/" + url.PathEscape(string(appID)), nil
+}
+
+// UdrPfdDataCreate sends PUT request to create or replace the PFD data of
+// the application
+// Successful response : 201 or 200 and body contains PfdDataForApp, 204
+func (udr *UdrPfdClient) UdrPfdDataCreate(ctx context.Context,
+	body PfdDataForApp) (rsp UdrPfdResponse, err error) {
+
+	uri, err := udrPfdDataIDURI(UdrAppID(body.AppID))
+	if err != nil {
+		return rsp, err
+	}
+
+	appPfd := PfdDataForApp{}
+	r, err := udr.sb.do(ctx, http.MethodPut, uri, "application/json", body,
+		&appPfd)
+	rsp.ResponseCode = r.code
+	rsp.Pd = r.pd
+	if err == nil && (r.code == http.StatusOK ||
+		r.code == http.StatusCreated) {
+		rsp.AppPfd = &appPfd
+	}
+	return rsp, err
+}
+
+// UdrPfdDataGet sends GET request to read the PFD data of the application
+// Successful response : 200 and body contains PfdDataForApp
+func (udr *UdrPfdClient) UdrPfdDataGet(ctx context.Context,
+	appID UdrAppID) (rsp UdrPfdResponse, err error) {
+
+	uri, err := udrPfdDataIDURI(appID)
+	if err != nil {
+		return rsp, err
+	}
+
+	appPfd := PfdDataForApp{}
+	r, err := udr.sb.do(ctx, http.MethodGet, uri, "", nil, &appPfd)
+	rsp.ResponseCode = r.code
+	rsp.Pd = r.pd
+	if err == nil && r.code == http.StatusOK {
+		rsp.AppPfd = &appPfd
+	}
+	return rsp, err
+}
+
+// UdrPfdDataDelete sends DELETE request to remove the PFD data of the
+// application
+// Successful response : 204
+func (udr *UdrPfdClient) UdrPfdDataDelete(ctx context.Context,
+	appID UdrAppID) (rsp UdrPfdResponse, err error) {
+
+	uri, err := udrPfdDataIDURI(appID)
+	if err != nil {
+		return rsp, err
+	}
+
+	r, err := udr.sb.do(ctx, http.MethodDelete, uri, "", nil, nil)
+	rsp.ResponseCode = r.code
+	rsp.Pd = r.pd
+	return rsp, err
+}
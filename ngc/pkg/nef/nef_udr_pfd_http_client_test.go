@@ -0,0 +1,256 @@
+/* SPDX-License-Identifier: Apache-2.0
+* Copyright (c) 2020 Intel Corporation
+ */
+
+package ngcnef_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ngcnef "github.com/open-ness/epcforedge/ngc/pkg/nef"
+)
+
+// fakeUDRPfd is a minimal Nudr_DataRepository PFD data server
+type fakeUDRPfd struct {
+	mu   sync.Mutex
+	pfds map[string]ngcnef.PfdDataForApp
+	// fail contains the response code to be returned for an application
+	fail map[string]int
+	// invalid contains the applications whose stored PFDs are returned in
+	// a body which cannot be decoded
+	invalid map[string]bool
+}
+
+func newFakeUDRPfd() *fakeUDRPfd {
+	return &fakeUDRPfd{pfds: make(map[string]ngcnef.PfdDataForApp),
+		fail: make(map[string]int), invalid: make(map[string]bool)}
+}
+
+func (u *fakeUDRPfd) get(appID string) (ngcnef.PfdDataForApp, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	app, ok := u.pfds[appID]
+	return app, ok
+}
+
+func (u *fakeUDRPfd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	appID := strings.TrimPrefix(r.URL.Path,
+		"/nudr-dr/v1/application-data/pfds/")
+	if code, ok := u.fail[appID]; ok {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(ngcnef.ProblemDetails{
+			Title: http.StatusText(code)})
+		return
+	}
+
+	app, ok := u.pfds[appID]
+	switch r.Method {
+	case http.MethodPut:
+		if json.NewDecoder(r.Body).Decode(&app) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		u.pfds[appID] = app
+		code := http.StatusOK
+		if !ok {
+			code = http.StatusCreated
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if u.invalid[appID] {
+			_, _ = w.Write([]byte("{"))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(app)
+	case http.MethodGet, http.MethodDelete:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(u.pfds, appID)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(app)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+var _ = Describe("Test NEF UDR PFD HTTP Client", func() {
+
+	var (
+		udr *fakeUDRPfd
+		srv *httptest.Server
+	)
+
+	BeforeEach(func() {
+		udr = newFakeUDRPfd()
+		srv = httptest.NewServer(udr)
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	Describe("PFD data requests", func() {
+
+		It("Will put, get and delete the PFDs of an application", func() {
+			client, err := ngcnef.NewUDRPfdHTTPClient(&ngcnef.Config{
+				UDRConfig: ngcnef.SBClientConfig{Type: "http",
+					APIRoot: srv.URL}})
+			Expect(err).Should(BeNil())
+			ctx := context.Background()
+
+			app := ngcnef.PfdDataForApp{AppID: "app1",
+				Pfds: []ngcnef.PfdContent{{PfdID: "pfd1",
+					DomainNames: []string{"www.edge.com"}}}}
+			rsp, err := client.UdrPfdDataCreate(ctx, app)
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(201)))
+			Expect(rsp.AppPfd.Pfds).Should(HaveLen(1))
+
+			rsp, err = client.UdrPfdDataGet(ctx, "app1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(200)))
+			Expect(rsp.AppPfd.Pfds[0].DomainNames).Should(
+				ConsistOf("www.edge.com"))
+
+			rsp, err = client.UdrPfdDataDelete(ctx, "app1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(204)))
+
+			rsp, err = client.UdrPfdDataGet(ctx, "app1")
+			Expect(err).Should(BeNil())
+			Expect(rsp.ResponseCode).Should(Equal(uint16(404)))
+			Expect(rsp.AppPfd).Should(BeNil())
+		})
+	})
+
+	Describe("NEF with UDR PFD HTTP Client", func() {
+
+		var (
+			ctx    context.Context
+			nef    *testNef
+			tmpDir string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "nef-udr-pfd")
+			Expect(err).Should(BeNil())
+			cfgPath := writeNefTestConfig(tmpDir,
+				func(cfg map[string]interface{}) {
+					cfg["UDRConfig"] = map[string]interface{}{
+						"type": "http", "apiRoot": srv.URL}
+				})
+
+			nef = newTestNef(cfgPath)
+			ctx = nef.ctx
+		})
+
+		AfterEach(func() {
+			nef.stop()
+			_ = os.RemoveAll(tmpDir)
+		})
+
+		It("Will store the PFDs with the caching time in UDR", func() {
+			pfdbody, _ := ioutil.ReadFile(testJSONPFDPath +
+				"AF_NEF_PFD_POST_001.json")
+
+			rr, req := CreatePFDReqForNEF(ctx, "POST", "", "", pfdbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+			app, ok := udr.get("app1")
+			Expect(ok).Should(BeTrue())
+			Expect(app.Pfds).Should(HaveLen(2))
+			Expect(app.CachingTime).ShouldNot(BeNil())
+			cachingTime, err := time.Parse(time.RFC3339,
+				string(*app.CachingTime))
+			Expect(err).Should(BeNil())
+			Expect(cachingTime).Should(BeTemporally("~",
+				time.Now().Add(1000*time.Second), 10*time.Second))
+
+			rr, req = CreatePFDReqForNEF(ctx, "GET", "10000", "app2", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusOK))
+
+			rr, req = CreatePFDReqForNEF(ctx, "DELETE", "10000", "", nil)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusNoContent))
+			_, ok = udr.get("app1")
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("Will report the UDR failures per application", func() {
+			pfdbody, _ := ioutil.ReadFile(testJSONPFDPath +
+				"AF_NEF_PFD_POST_001.json")
+			udr.mu.Lock()
+			udr.fail["app2"] = http.StatusInsufficientStorage
+			udr.mu.Unlock()
+
+			rr, req := CreatePFDReqForNEF(ctx, "POST", "", "", pfdbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+			trans := ngcnef.PfdManagement{}
+			Expect(json.Unmarshal(rr.Body.Bytes(), &trans)).Should(Succeed())
+			Expect(trans.PfdDatas).Should(HaveKey("app1"))
+			Expect(trans.PfdDatas).ShouldNot(HaveKey("app2"))
+			Expect(trans.PfdReports).Should(HaveKey("RESOURCE_LIMITATION"))
+			Expect(trans.PfdReports["RESOURCE_LIMITATION"].ExternalAppIds).
+				Should(ConsistOf("app2"))
+
+			// UDR is not reachable, all the applications fail
+			srv.Close()
+			delete(trans.PfdDatas["app1"].Pfds, "pfd2")
+			trans.PfdReports = nil
+			putbody, _ := json.Marshal(trans)
+			rr, req = CreatePFDReqForNEF(ctx, "PUT", "10000", "", putbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusInternalServerError))
+
+			var reports []ngcnef.PfdReport
+			Expect(json.Unmarshal(rr.Body.Bytes(), &reports)).Should(Succeed())
+			Expect(reports).Should(HaveLen(1))
+			Expect(reports[0].FailureCode).Should(Equal(ngcnef.Malfunction))
+			Expect(reports[0].ExternalAppIds).Should(ConsistOf("app1"))
+		})
+
+		It("Will store an application with an invalid UDR response", func() {
+			pfdbody, _ := ioutil.ReadFile(testJSONPFDPath +
+				"AF_NEF_PFD_POST_001.json")
+			udr.mu.Lock()
+			udr.invalid["app2"] = true
+			udr.mu.Unlock()
+
+			rr, req := CreatePFDReqForNEF(ctx, "POST", "", "", pfdbody)
+			ngcnef.NefAppG.NefRouter.ServeHTTP(rr, req.WithContext(ctx))
+			Expect(rr.Code).Should(Equal(http.StatusCreated))
+
+			trans := ngcnef.PfdManagement{}
+			Expect(json.Unmarshal(rr.Body.Bytes(), &trans)).Should(Succeed())
+			Expect(trans.PfdDatas).Should(HaveKey("app1"))
+			Expect(trans.PfdDatas).Should(HaveKey("app2"))
+			Expect(trans.PfdReports).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package oam
+
+import (
+	"net/http"
+
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
+	tlsmgr "github.com/open-ness/epcforedge/ngc/pkg/tlsmgr"
+)
+
+var (
+	// oamMetrics contains the metrics of the OAM exposed on /metrics
+	oamMetrics = metrics.NewRegistry()
+	// oamHTTPMetrics records the requests of the OAM routes
+	oamHTTPMetrics = metrics.NewHTTPMetrics(oamMetrics, "oam")
+	// oamTLSMetrics reports the expiry of the server certificate
+	oamTLSMetrics = tlsmgr.NewMetrics(oamMetrics, "oam")
+)
+
+// getMetrics : function returning the metrics in the Prometheus text format
+func getMetrics(w http.ResponseWriter, r *http.Request) {
+	oamMetrics.Handler().ServeHTTP(w, r)
+}
+
+// SetServerTLS sets the manager of the server certificate whose expiry is
+// exposed
+func SetServerTLS(m *tlsmgr.Manager) {
+	oamTLSMetrics.Set("server", m)
+}
@@ -272,4 +272,22 @@ var _ = Describe("NGC_APIStub", func() {
 
 			})
 	})
+
+	Describe("Metrics", func() {
+		It("Will count the requests by route and status code",
+			func() {
+				router := NewRouter()
+				req, err := http.NewRequest("GET", "/", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				router.ServeHTTP(httptest.NewRecorder(), req)
+
+				req, err = http.NewRequest("GET", "/metrics", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				rsp := httptest.NewRecorder()
+				router.ServeHTTP(rsp, req)
+				Expect(rsp.Code).To(Equal(http.StatusOK))
+				Expect(rsp.Body.String()).To(ContainSubstring(
+					`oam_http_requests_total{code="200",route="Index"}`))
+			})
+	})
 })
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
 )
 
 // Route : route handler structure
@@ -32,6 +33,7 @@ func NewRouter() *mux.Router {
 			Name(route.Name).
 			Handler(route.HandlerFunc)
 	}
+	router.Use(oamHTTPMetrics.Middleware)
 
 	return router
 }
@@ -83,4 +85,11 @@ var routes = Routes{
 		"/ngcoam/v1/af/services/{afServiceId}",
 		update,
 	},
+
+	Route{
+		"GetMetrics",
+		strings.ToUpper("Get"),
+		metrics.Path,
+		getMetrics,
+	},
 }
@@ -4,21 +4,34 @@
 package oauth2
 
 import (
-	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 
 	logger "github.com/open-ness/common/log"
+	config "github.com/open-ness/epcforedge/ngc/pkg/config"
 )
 
 var log = logger.DefaultLogger.WithField("oauth2", nil)
 
 // Path for OAuth2 Configuration file
-const cfgPath string = "configs/oauth2.json"
+var cfgPath = "configs/oauth2.json"
+
+// cfgMu protects cfgPath and cfg
+var cfgMu sync.RWMutex
+
+// cfg is the OAuth2 configuration loaded from cfgPath, nil if it was not
+// loaded yet or could not be loaded
+var cfg *Config
+
+// Scopes of the NEF northbound APIs
+const (
+	ScopeTrafficInfluence = "nnef-trafficinfluence"
+	ScopePfdManagement    = "nnef-pfdmanagement"
+)
 
 //TokenVerificationResult Result of the token verification
 type TokenVerificationResult string
@@ -33,7 +46,7 @@ const (
 
 //Config OAuth2 config struct
 type Config struct {
-	SigningKey string `json:"signingkey"`
+	SigningKey string `json:"signingkey" config:"required"`
 	Expiration int64  `json:"expiration"`
 }
 
@@ -56,6 +69,8 @@ type AccessTokenReq struct {
 	TargetNfInstanceID string  `json:"targetNfInstanceId,omitempty"`
 	RequesterPlmn      *PlmnID `json:"requesterPlmn,omitempty"`
 	TargetPlmn         *PlmnID `json:"targetPlmn,omitempty"`
+	// AF the token is requested for, OpenNESS extension
+	AfID string `json:"afId,omitempty"`
 }
 
 //AccessTokenClaims struct
@@ -65,18 +80,76 @@ type AccessTokenClaims struct {
 	Audience   interface{} `json:"audience"`
 	Scope      string      `json:"scope"`
 	Expiration int64       `json:"expiration"`
+	// AF the token is issued for
+	AfID string `json:"afId,omitempty"`
 	jwt.StandardClaims
 }
 
-// LoadJSONConfig reads a file located at configPath and unmarshals it to
-// config structure
-func loadJSONConfig(configPath string, config interface{}) error {
-	cfgData, err := ioutil.ReadFile(filepath.Clean(configPath))
+// HasScope returns true if the scope is one of the space separated scopes
+// of the token
+func (c *AccessTokenClaims) HasScope(scope string) bool {
+
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SetConfigPath sets the path of the OAuth2 configuration file and loads
+// it. If it cannot be loaded, it is loaded again when it is used
+func SetConfigPath(path string) {
+
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	cfgPath = path
+	cfg = nil
+	_ = loadConfig()
+}
+
+// Reload reads the OAuth2 configuration file again. The current
+// configuration is kept if the file is invalid
+func Reload() error {
+
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	return loadConfig()
+}
+
+// loadConfig reads the OAuth2 configuration file, JSON or YAML, overridden
+// by the OAUTH2_ environment variables, e.g. OAUTH2_SIGNINGKEY. It must be
+// called with cfgMu locked
+func loadConfig() error {
+
+	next := Config{}
+	err := config.Load(cfgPath, "OAUTH2", &next)
 	if err != nil {
 		log.Infoln(err)
 		return err
 	}
-	return json.Unmarshal(cfgData, config)
+	cfg = &next
+	return nil
+}
+
+// getConfig returns the OAuth2 configuration, loaded on first use
+func getConfig() (Config, error) {
+
+	cfgMu.RLock()
+	cur := cfg
+	cfgMu.RUnlock()
+	if cur != nil {
+		return *cur, nil
+	}
+
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	if cfg == nil {
+		if err := loadConfig(); err != nil {
+			return Config{}, err
+		}
+	}
+	return *cfg, nil
 }
 
 // GetNEFAccessTokenFromNRF Generates the token. This is the functionality of
@@ -84,10 +157,8 @@ func loadJSONConfig(configPath string, config interface{}) error {
 func GetNEFAccessTokenFromNRF(accessTokenReq AccessTokenReq) (
 	NefAccessToken string, err error) {
 
-	var oAuth2Cfg = Config{}
-
-	//Read Json config
-	err = loadJSONConfig(cfgPath, &oAuth2Cfg)
+	//Read the config
+	oAuth2Cfg, err := getConfig()
 	if err != nil {
 		log.Errln("Failed to load OAuth2 configuration")
 		return NefAccessToken, err
@@ -103,12 +174,13 @@ func GetNEFAccessTokenFromNRF(accessTokenReq AccessTokenReq) (
 	//log.Infoln("Expiration Set to ", expiration)
 	// Create AccessToken
 	var accessTokenClaims = AccessTokenClaims{
-		"OpenNESS",             //Issuer:
-		"NEF Validation token", //Subject:
-		"AF-NEF",               //Audience:
-		accessTokenReq.Scope,   //Scope:
-		oAuth2Cfg.Expiration,   //Expiration:
-		jwt.StandardClaims{ExpiresAt: expiration},
+		Issuer:         "OpenNESS",
+		Subject:        "NEF Validation token",
+		Audience:       "AF-NEF",
+		Scope:          accessTokenReq.Scope,
+		Expiration:     oAuth2Cfg.Expiration,
+		AfID:           accessTokenReq.AfID,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: expiration},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
@@ -121,7 +193,8 @@ func GetNEFAccessTokenFromNRF(accessTokenReq AccessTokenReq) (
 	return accessToken, nil
 }
 
-func fetchNEFAccessTokenFromNRF() (token string, err error) {
+func fetchNEFAccessTokenFromNRF(scope string, afID string) (token string,
+	err error) {
 
 	var accessTokenReq AccessTokenReq
 
@@ -132,7 +205,8 @@ func fetchNEFAccessTokenFromNRF() (token string, err error) {
 	accessTokenReq.NfInstanceID = "0"
 	accessTokenReq.NfType = "AF"
 	accessTokenReq.TargetNfType = "NEF"
-	accessTokenReq.Scope = "nnrf-nfm"
+	accessTokenReq.Scope = scope
+	accessTokenReq.AfID = afID
 	accessTokenReq.TargetNfInstanceID = "0" //Instance of NEF
 
 	//POST AccessTokenRequest to NRF /oauth2/token
@@ -146,7 +220,23 @@ func fetchNEFAccessTokenFromNRF() (token string, err error) {
 //		err		: error code in case of failure or nil in success
 func GetAccessToken() (token string, err error) {
 
-	token, err = fetchNEFAccessTokenFromNRF()
+	token, err = fetchNEFAccessTokenFromNRF("nnrf-nfm", "")
+
+	if err != nil {
+		log.Info("Failed to get NEF access token ")
+	}
+	return token, err
+}
+
+//GetAFAccessToken Get the access token of the AF to access the traffic
+//                 influence and PFD management APIs of NEF for its AF ID
+// i/p afID     : AF ID the token is requested for
+// o/p  token	: The access token
+//		err		: error code in case of failure or nil in success
+func GetAFAccessToken(afID string) (token string, err error) {
+
+	token, err = fetchNEFAccessTokenFromNRF(
+		ScopeTrafficInfluence+" "+ScopePfdManagement, afID)
 
 	if err != nil {
 		log.Info("Failed to get NEF access token ")
@@ -161,16 +251,26 @@ func GetAccessToken() (token string, err error) {
 func ValidateAccessToken(reqToken string) (status TokenVerificationResult,
 	err error) {
 
-	var oAuth2Cfg = Config{}
+	_, status, err = ParseAccessToken(reqToken)
+	return status, err
+}
+
+//ParseAccessToken Validate the access token and return its claims
+// i/p reqToken : token to be validated
+// o/p claims : claims of the valid token
+//     status : Success/Failure result of the operation
+//     err    : error info of the token validation process.
+func ParseAccessToken(reqToken string) (claims *AccessTokenClaims,
+	status TokenVerificationResult, err error) {
 
-	//Read Json config
-	err = loadJSONConfig(cfgPath, &oAuth2Cfg)
+	//Read the config
+	oAuth2Cfg, err := getConfig()
 	if err != nil {
 		log.Errln("Failed to load OAuth2 configuration")
-		return StatusConfigErr, err
+		return nil, StatusConfigErr, err
 	}
 	var mySigningKey = []byte(oAuth2Cfg.SigningKey)
-	claims := &AccessTokenClaims{}
+	claims = &AccessTokenClaims{}
 
 	tkn, err := jwt.ParseWithClaims(reqToken, claims, func(token *jwt.Token) (
 		interface{}, error) {
@@ -182,21 +282,21 @@ func ValidateAccessToken(reqToken string) (status TokenVerificationResult,
 
 		if err == jwt.ErrSignatureInvalid {
 			log.Info("Token is invalid, ErrSignatureInvalid")
-			return StatusInvalidToken, err
+			return nil, StatusInvalidToken, err
 		}
 		//Check for Validation error
 		validationErr, ok := err.(*jwt.ValidationError)
 
 		if !ok || validationErr.Errors == jwt.ValidationErrorExpired {
-			return StatusInvalidToken, err
+			return nil, StatusInvalidToken, err
 		}
 
-		return StatusBadRequest, err
+		return nil, StatusBadRequest, err
 	}
 	if !tkn.Valid {
 		log.Info("Token is invalid")
-		return StatusInvalidToken, errors.New("Token is Invalid")
+		return nil, StatusInvalidToken, errors.New("Token is Invalid")
 	}
 	log.Info("OAuth2 Token Validation successful")
-	return StatusSuccess, nil
+	return claims, StatusSuccess, nil
 }
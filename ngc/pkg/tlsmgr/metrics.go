@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package tlsmgr
+
+import (
+	"sync"
+
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
+)
+
+// Metrics reports the expiry of the certificates and CA bundles of the
+// managers of a daemon
+type Metrics struct {
+	mu       sync.Mutex
+	managers map[string]*Manager
+}
+
+// NewMetrics registers the gauge <namespace>_tls_cert_expiry_timestamp_seconds
+// with the expiry of the files of the managers by role and file
+func NewMetrics(r *metrics.Registry, namespace string) *Metrics {
+
+	mt := &Metrics{managers: make(map[string]*Manager)}
+	r.NewGaugeFunc(namespace+"_tls_cert_expiry_timestamp_seconds",
+		"Expiry time of the TLS certificates and CA bundles in seconds "+
+			"since the epoch by role and file",
+		[]string{"role", "file"}, mt.collect)
+	return mt
+}
+
+// Set sets the manager of the role, e.g. server, nil to remove it
+func (mt *Metrics) Set(role string, m *Manager) {
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if m == nil {
+		delete(mt.managers, role)
+		return
+	}
+	mt.managers[role] = m
+}
+
+// collect reports the expiry of the files of each manager
+func (mt *Metrics) collect(set func(v float64, values ...string)) {
+
+	mt.mu.Lock()
+	managers := make(map[string]*Manager, len(mt.managers))
+	for role, m := range mt.managers {
+		managers[role] = m
+	}
+	mt.mu.Unlock()
+
+	for role, m := range managers {
+		for file, t := range m.Expiry() {
+			set(float64(t.Unix()), role, file)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+// Package tlsmgr loads the TLS certificates, keys and CA bundles of the NGC
+// daemons and reloads them when the files change, so that a rotated
+// certificate is used without restart. The servers get their certificate
+// through tls.Config.GetCertificate and the clients dial with the current
+// root CAs. The files are checked on use, at most once per interval.
+package tlsmgr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	logger "github.com/open-ness/common/log"
+	"golang.org/x/net/http2"
+)
+
+// DefaultInterval is the default interval between two checks of the files
+const DefaultInterval = 10 * time.Second
+
+var log = logger.DefaultLogger.WithField("tlsmgr", nil)
+
+// Config is the configuration of a manager
+type Config struct {
+	// Certificate and key in PEM format, none if empty
+	CertPath string
+	KeyPath  string
+	// CA bundles in PEM format, the root CAs of a client or the client CAs
+	// of a server
+	CAPaths []string
+	// Interval between two checks of the files for a change,
+	// DefaultInterval if 0. The files are not reloaded if negative
+	Interval time.Duration
+}
+
+// stamp is the modification time and size of a file
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Manager provides the certificate and the CA pool loaded from the files of
+// its configuration
+type Manager struct {
+	cfg Config
+
+	mu      sync.Mutex
+	checked time.Time
+	stamps  []stamp
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	// Expiry of the certificate and of the CA bundles by file
+	expiry map[string]time.Time
+}
+
+// New creates a manager of the files, which are loaded by Reload
+func New(cfg Config) *Manager {
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+	return &Manager{cfg: cfg}
+}
+
+// files returns the files of the manager
+func (m *Manager) files() []string {
+	var files []string
+	if m.cfg.CertPath != "" {
+		files = append(files, m.cfg.CertPath, m.cfg.KeyPath)
+	}
+	return append(files, m.cfg.CAPaths...)
+}
+
+// fileStamps returns the stamps of the files, zero for a missing file
+func (m *Manager) fileStamps() []stamp {
+	files := m.files()
+	stamps := make([]stamp, len(files))
+	for i, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			stamps[i] = stamp{modTime: fi.ModTime(), size: fi.Size()}
+		}
+	}
+	return stamps
+}
+
+// loadCAs reads the certificates of the CA bundle into the pool and returns
+// the earliest expiry of the bundle
+func loadCAs(path string, pool *x509.CertPool) (time.Time, error) {
+	var expiry time.Time
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return expiry, err
+	}
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return expiry, fmt.Errorf("%s: %v", path, err)
+		}
+		pool.AddCert(cert)
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	if expiry.IsZero() {
+		return expiry, errors.New(path + ": no CA certificate found")
+	}
+	return expiry, nil
+}
+
+// Reload loads the files. The current certificate and CA pool are kept if
+// one of the files is invalid
+func (m *Manager) Reload() error {
+
+	stamps := m.fileStamps()
+	expiry := make(map[string]time.Time)
+	var cert *tls.Certificate
+	var pool *x509.CertPool
+	err := func() error {
+		if m.cfg.CertPath != "" {
+			c, err := tls.LoadX509KeyPair(m.cfg.CertPath, m.cfg.KeyPath)
+			if err != nil {
+				return err
+			}
+			c.Leaf, err = x509.ParseCertificate(c.Certificate[0])
+			if err != nil {
+				return err
+			}
+			cert = &c
+			expiry[m.cfg.CertPath] = c.Leaf.NotAfter
+		}
+		if len(m.cfg.CAPaths) != 0 {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range m.cfg.CAPaths {
+			t, err := loadCAs(path, pool)
+			if err != nil {
+				return err
+			}
+			expiry[path] = t
+		}
+		return nil
+	}()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// The files are not loaded again before they change
+	m.stamps = stamps
+	m.checked = time.Now()
+	if err != nil {
+		return err
+	}
+	m.cert, m.pool, m.expiry = cert, pool, expiry
+	return nil
+}
+
+// refresh reloads the files if they changed since the last check, at most
+// once per interval
+func (m *Manager) refresh() {
+
+	if m.cfg.Interval < 0 {
+		return
+	}
+	m.mu.Lock()
+	if time.Since(m.checked) < m.cfg.Interval {
+		m.mu.Unlock()
+		return
+	}
+	m.checked = time.Now()
+	cur := m.stamps
+	m.mu.Unlock()
+
+	changed := false
+	for i, s := range m.fileStamps() {
+		if i >= len(cur) || !s.modTime.Equal(cur[i].modTime) ||
+			s.size != cur[i].size {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := m.Reload(); err != nil {
+		log.Errf("TLS files not reloaded, keeping the current ones: %v", err)
+		return
+	}
+	log.Infof("TLS files reloaded: %v", m.files())
+}
+
+// Certificate returns the current certificate
+func (m *Manager) Certificate() (*tls.Certificate, error) {
+
+	m.refresh()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cert == nil {
+		return nil, errors.New("No TLS certificate loaded")
+	}
+	return m.cert, nil
+}
+
+// GetCertificate returns the current certificate of a server, see
+// tls.Config.GetCertificate
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate,
+	error) {
+	return m.Certificate()
+}
+
+// GetClientCertificate returns the current certificate of a client, see
+// tls.Config.GetClientCertificate
+func (m *Manager) GetClientCertificate(*tls.CertificateRequestInfo) (
+	*tls.Certificate, error) {
+	return m.Certificate()
+}
+
+// CertPool returns the current pool of the CA bundles, nil if none is
+// loaded
+func (m *Manager) CertPool() *x509.CertPool {
+
+	m.refresh()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pool
+}
+
+// Expiry returns the expiry of the certificate and of each CA bundle by
+// file, the earliest one for a bundle
+func (m *Manager) Expiry() map[string]time.Time {
+
+	m.refresh()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiry := make(map[string]time.Time, len(m.expiry))
+	for f, t := range m.expiry {
+		expiry[f] = t
+	}
+	return expiry
+}
+
+// ServerConfig returns the TLS configuration of a server with the current
+// certificate. The client certificates are verified with the current CA
+// pool according to clientAuth
+func (m *Manager) ServerConfig(clientAuth tls.ClientAuthType) *tls.Config {
+
+	cfg := &tls.Config{GetCertificate: m.GetCertificate,
+		ClientAuth: clientAuth}
+	if clientAuth == tls.NoClientCert || len(m.cfg.CAPaths) == 0 {
+		return cfg
+	}
+	// The configuration is cloned on each handshake to get the settings
+	// added afterwards, e.g. by http2.ConfigureServer
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config,
+		error) {
+		pool := m.CertPool()
+		if pool == nil {
+			return nil, errors.New("No client CA certificate loaded")
+		}
+		c := cfg.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = pool
+		return c, nil
+	}
+	return cfg
+}
+
+// DialTLS dials a TLS connection with the current CA pool as root CAs and
+// the current certificate as client certificate, if any. It is the dial
+// function of a http2.Transport, the connection must negotiate HTTP/2
+func (m *Manager) DialTLS(network string, addr string,
+	cfg *tls.Config) (net.Conn, error) {
+
+	if cfg = cfg.Clone(); cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if len(m.cfg.CAPaths) != 0 {
+		if cfg.RootCAs = m.CertPool(); cfg.RootCAs == nil {
+			return nil, errors.New("No CA certificate loaded")
+		}
+	}
+	if m.cfg.CertPath != "" {
+		cfg.GetClientCertificate = m.GetClientCertificate
+	}
+
+	conn, err := tls.Dial(network, addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if p := conn.ConnectionState().NegotiatedProtocol; p !=
+		http2.NextProtoTLS {
+		_ = conn.Close()
+		return nil, fmt.Errorf("Unexpected ALPN protocol %q, want %q", p,
+			http2.NextProtoTLS)
+	}
+	return conn, nil
+}
+
+// HTTP2Transport returns a HTTP/2 transport dialing with DialTLS
+func (m *Manager) HTTP2Transport() *http2.Transport {
+	return &http2.Transport{DialTLS: m.DialTLS}
+}
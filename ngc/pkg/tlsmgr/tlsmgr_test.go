@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (c) 2020 Intel Corporation
+
+package tlsmgr
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/open-ness/epcforedge/ngc/pkg/metrics"
+	"golang.org/x/net/http2"
+)
+
+func TestTLSMgr(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TLS manager suite")
+}
+
+// testCert is a certificate with its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert creates a certificate valid for ttl, signed by the CA or
+// self-signed if ca is nil. The CA certificates are named after cn
+func newTestCert(cn string, ca *testCert, ttl time.Duration) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).Should(BeNil())
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	Expect(err).Should(BeNil())
+	tmpl := &x509.Certificate{SerialNumber: serial,
+		Subject:     pkix.Name{CommonName: cn},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(ttl).Truncate(time.Second),
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth}}
+
+	parent, signer := tmpl, key
+	if ca != nil {
+		parent, signer = ca.cert, ca.key
+	} else {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent,
+		&key.PublicKey, signer)
+	Expect(err).Should(BeNil())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).Should(BeNil())
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and its key in the PEM files
+func (c *testCert) write(certPath string, keyPath string) {
+	Expect(ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: c.der}), 0600)).Should(Succeed())
+	if keyPath == "" {
+		return
+	}
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	Expect(err).Should(BeNil())
+	Expect(ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{
+		Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).Should(Succeed())
+}
+
+var _ = Describe("Manager", func() {
+
+	var (
+		tmpDir string
+		ca     *testCert
+	)
+
+	// path returns the path of the file in the temporary directory
+	path := func(name string) string {
+		return filepath.Join(tmpDir, name)
+	}
+
+	// leaf returns the current certificate of the manager
+	leaf := func(m *Manager) func() *x509.Certificate {
+		return func() *x509.Certificate {
+			cert, err := m.Certificate()
+			Expect(err).Should(BeNil())
+			return cert.Leaf
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "tlsmgr")
+		Expect(err).Should(BeNil())
+		ca = newTestCert("Test CA 1", nil, time.Hour)
+		ca.write(path("ca.pem"), "")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	It("Will reload the rotated files and keep them if invalid", func() {
+		first := newTestCert("first", ca, time.Hour)
+		first.write(path("cert.pem"), path("key.pem"))
+		m := New(Config{CertPath: path("cert.pem"), KeyPath: path("key.pem"),
+			CAPaths: []string{path("ca.pem")}, Interval: 10 * time.Millisecond})
+		Expect(m.Reload()).Should(Succeed())
+		Expect(leaf(m)()).Should(Equal(first.cert))
+		Expect(m.CertPool().Subjects()).Should(HaveLen(1))
+
+		second := newTestCert("second", ca, 2*time.Hour)
+		second.write(path("cert.pem"), path("key.pem"))
+		Eventually(leaf(m), time.Second, 10*time.Millisecond).Should(
+			Equal(second.cert))
+
+		// A key not matching the certificate is not loaded
+		newTestCert("third", ca, time.Hour).write(path("cert.pem"), "")
+		Consistently(leaf(m), 100*time.Millisecond,
+			10*time.Millisecond).Should(Equal(second.cert))
+
+		Expect(ioutil.WriteFile(path("ca.pem"), []byte("none"),
+			0600)).Should(Succeed())
+		Expect(m.Reload()).ShouldNot(Succeed())
+		Expect(m.CertPool()).ShouldNot(BeNil())
+	})
+
+	It("Will not load the files if the interval is negative", func() {
+		first := newTestCert("first", ca, time.Hour)
+		first.write(path("cert.pem"), path("key.pem"))
+		m := New(Config{CertPath: path("cert.pem"), KeyPath: path("key.pem"),
+			Interval: -1})
+		Expect(m.Reload()).Should(Succeed())
+
+		newTestCert("second", ca, time.Hour).write(path("cert.pem"),
+			path("key.pem"))
+		Consistently(leaf(m), 100*time.Millisecond,
+			10*time.Millisecond).Should(Equal(first.cert))
+	})
+
+	It("Will serve and dial with the rotated certificates and CAs", func() {
+		newTestCert("server", ca, time.Hour).write(path("server.pem"),
+			path("server-key.pem"))
+		newTestCert("client", ca, time.Hour).write(path("client.pem"),
+			path("client-key.pem"))
+
+		server := New(Config{CertPath: path("server.pem"),
+			KeyPath: path("server-key.pem"), CAPaths: []string{path("ca.pem")},
+			Interval: 10 * time.Millisecond})
+		Expect(server.Reload()).Should(Succeed())
+		srv := &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter,
+				r *http.Request) {
+				_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.
+					CommonName))
+			}),
+			TLSConfig: server.ServerConfig(tls.RequireAndVerifyClientCert),
+		}
+		Expect(http2.ConfigureServer(srv, nil)).Should(Succeed())
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).Should(BeNil())
+		go func() { _ = srv.ServeTLS(ln, "", "") }()
+		defer func() { _ = srv.Close() }()
+
+		client := New(Config{CertPath: path("client.pem"),
+			KeyPath: path("client-key.pem"), CAPaths: []string{path("ca.pem")},
+			Interval: 10 * time.Millisecond})
+		Expect(client.Reload()).Should(Succeed())
+
+		// get returns the common names of the client and server
+		// certificates on a new connection
+		get := func() (string, error) {
+			transport := client.HTTP2Transport()
+			defer transport.CloseIdleConnections()
+			rsp, err := (&http.Client{Transport: transport,
+				Timeout: 5 * time.Second}).Get("https://" +
+				ln.Addr().String())
+			if err != nil {
+				return "", err
+			}
+			defer func() { _ = rsp.Body.Close() }()
+			body, err := ioutil.ReadAll(rsp.Body)
+			Expect(err).Should(BeNil())
+			return string(body) + "/" +
+				rsp.TLS.PeerCertificates[0].Subject.CommonName, nil
+		}
+		Expect(get()).Should(Equal("client/server"))
+
+		// The CA is rotated, the certificates signed by the old CA are
+		// rejected and the new ones are accepted
+		newCA := newTestCert("Test CA 2", nil, time.Hour)
+		newTestCert("new server", newCA, time.Hour).write(
+			path("server.pem"), path("server-key.pem"))
+		time.Sleep(20 * time.Millisecond)
+		_, err = get()
+		Expect(err).Should(HaveOccurred())
+
+		newCA.write(path("ca.pem"), "")
+		newTestCert("new client", newCA, time.Hour).write(
+			path("client.pem"), path("client-key.pem"))
+		Eventually(get, time.Second, 20*time.Millisecond).Should(
+			Equal("new client/new server"))
+	})
+
+	It("Will expose the expiry of the files", func() {
+		cert := newTestCert("server", ca, 2*time.Hour)
+		cert.write(path("cert.pem"), path("key.pem"))
+		m := New(Config{CertPath: path("cert.pem"), KeyPath: path("key.pem"),
+			CAPaths: []string{path("ca.pem")}})
+		Expect(m.Reload()).Should(Succeed())
+		Expect(m.Expiry()).Should(Equal(map[string]time.Time{
+			path("cert.pem"): cert.cert.NotAfter,
+			path("ca.pem"):   ca.cert.NotAfter}))
+
+		r := metrics.NewRegistry()
+		mt := NewMetrics(r, "test")
+		mt.Set("server", m)
+		var b bytes.Buffer
+		Expect(r.Write(&b)).Should(Succeed())
+		Expect(b.String()).Should(ContainSubstring(
+			`test_tls_cert_expiry_timestamp_seconds{file="` +
+				path("cert.pem") + `",role="server"} ` + strconv.FormatFloat(
+				float64(cert.cert.NotAfter.Unix()), 'g', -1, 64) + "\n"))
+
+		mt.Set("server", nil)
+		b.Reset()
+		Expect(r.Write(&b)).Should(Succeed())
+		Expect(b.String()).ShouldNot(ContainSubstring(`role="server"`))
+	})
+})